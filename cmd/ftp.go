@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/apex/log"
+	"github.com/spf13/cobra"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/ftp"
+	"github.com/pterodactyl/wings/loggers/cli"
+)
+
+// newFtpCommand returns the "wings ftp" command group used to inspect and
+// administer the FTP server. Commands that report on the running server do so
+// through the API of the Wings instance on this node.
+func newFtpCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "ftp",
+		Short: "Inspect and administer the FTP server of this Wings instance.",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			initConfig()
+			log.SetHandler(cli.Default)
+		},
+	}
+
+	users := &cobra.Command{
+		Use:   "users",
+		Short: "Manage the FTP accounts stored on this node.",
+	}
+	users.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the FTP accounts stored on this node.",
+		Args:  cobra.NoArgs,
+		RunE:  ftpUsersListCmdRun,
+	}, &cobra.Command{
+		Use:   "delete <username>",
+		Short: "Delete an FTP account from this node.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ftpUsersDeleteCmdRun,
+	})
+
+	command.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show the state of the FTP listeners of the running Wings instance.",
+		Args:  cobra.NoArgs,
+		RunE:  ftpStatusCmdRun,
+	}, &cobra.Command{
+		Use:   "sessions <server>",
+		Short: "List the FTP sessions logged in to a server.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ftpSessionsCmdRun,
+	}, &cobra.Command{
+		Use:   "test-login <username>",
+		Short: "Run through the checks made when an FTP client logs in, without connecting.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ftpTestLoginCmdRun,
+	}, users)
+
+	return command
+}
+
+func ftpStatusCmdRun(*cobra.Command, []string) error {
+	var h ftp.Health
+	if err := ftpAPIRequest("/api/system/ftp", &h); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Listening:\t%t\n", h.Listening)
+	if h.StartedAt != nil {
+		fmt.Fprintf(w, "Uptime:\t%s\n", (time.Duration(h.Uptime) * time.Second).String())
+	}
+	fmt.Fprintf(w, "Sessions:\t%d\n", h.Sessions)
+	for _, l := range h.Listeners {
+		tls := l.TLS
+		if tls == "" {
+			tls = "none"
+		}
+		fmt.Fprintf(w, "Listener:\t%s (tls: %s, listening: %t)\n", l.Address, tls, l.Listening)
+	}
+	for kind, n := range h.Errors {
+		fmt.Fprintf(w, "Errors (%s, last hour):\t%d\n", kind, n)
+	}
+	if h.LastError != nil {
+		fmt.Fprintf(w, "Last error:\t%s %s: %s\n", h.LastError.Time.Format(time.RFC3339), h.LastError.Kind, h.LastError.Message)
+	}
+	return w.Flush()
+}
+
+func ftpSessionsCmdRun(_ *cobra.Command, args []string) error {
+	var sessions []ftp.SessionInfo
+	if err := ftpAPIRequest("/api/servers/"+args[0]+"/ftp/sessions", &sessions); err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No FTP sessions are logged in to this server.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tIP\tCONNECTED\tCLIENT\tTLS\tCOMMAND\tUPLOADED\tDOWNLOADED\tTRANSFER")
+	for _, s := range sessions {
+		transfer := "-"
+		if t := s.Transfer; t != nil {
+			transfer = fmt.Sprintf("%s %s (%d bytes)", t.Direction, t.Path, t.Bytes)
+		}
+		client, tls := s.Client, "none"
+		if client == "" {
+			client = "-"
+		}
+		if s.TLS != nil {
+			tls = s.TLS.Version
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			s.ID, s.Username, s.IP, s.ConnectedAt.Format(time.RFC3339), client, tls, s.Command,
+			s.BytesUploaded, s.BytesDownloaded, transfer)
+	}
+	return w.Flush()
+}
+
+func ftpUsersListCmdRun(*cobra.Command, []string) error {
+	accounts, err := ftp.Accounts()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USERNAME\tSERVER\tROOT")
+	for _, a := range accounts {
+		root := "/" + a.Root
+		fmt.Fprintf(w, "%s\t%s\t%s\n", a.Username, a.Server, root)
+	}
+	return w.Flush()
+}
+
+func ftpUsersDeleteCmdRun(_ *cobra.Command, args []string) error {
+	if err := ftp.DeleteAccount(args[0]); err != nil {
+		return err
+	}
+	fmt.Println("Deleted FTP account " + args[0] + ". Sessions already logged in with it remain connected.")
+	return nil
+}
+
+func ftpTestLoginCmdRun(_ *cobra.Command, args []string) error {
+	var password string
+	if err := survey.AskOne(&survey.Password{Message: "Password for " + args[0] + ":"}, &password); err != nil {
+		return err
+	}
+	failed := false
+	for _, s := range ftp.TestLogin(args[0], password) {
+		result := "ok"
+		if !s.OK {
+			result = "FAILED"
+			failed = true
+		}
+		fmt.Printf("%-16s %-6s %s\n", s.Name, result, s.Detail)
+	}
+	if failed {
+		return fmt.Errorf("login for %s would be rejected", args[0])
+	}
+	fmt.Println("Login would succeed.")
+	return nil
+}
+
+// ftpAPIRequest makes an authenticated GET request to the API of the Wings
+// instance running on this node and decodes the JSON response.
+func ftpAPIRequest(path string, v interface{}) error {
+	cfg := config.Get()
+	scheme := "http"
+	if cfg.Api.Ssl.Enabled {
+		scheme = "https"
+	}
+	host := cfg.Api.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+host+":"+strconv.Itoa(cfg.Api.Port)+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token.Token)
+	req.Header.Set("Accept", "application/json")
+
+	// The certificate is issued for the public hostname of the node rather
+	// than the loopback address the request is sent to.
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the Wings API, is Wings running? %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("wings API returned %s for %s", res.Status, path)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"runtime"
@@ -88,6 +89,7 @@ func init() {
 	rootCommand.AddCommand(versionCommand)
 	rootCommand.AddCommand(configureCmd)
 	rootCommand.AddCommand(newDiagnosticsCommand())
+	rootCommand.AddCommand(newFtpCommand())
 }
 
 func rootCmdRun(cmd *cobra.Command, _ []string) {
@@ -285,14 +287,26 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		s.StartAsync()
 	}
 
+	ftpServer := ftp.New(manager, pclient)
 	go func() {
 		// Run the FTP server.
-		if err := ftp.New(manager, pclient).Run(); err != nil {
+		if err := ftpServer.Run(); err != nil {
 			log.WithError(err).Fatal("failed to initialize the ftp server")
 			return
 		}
 	}()
 
+	// Reload the FTP configuration when Wings receives a SIGHUP.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := ftpServer.Reload(); err != nil {
+				log.WithField("error", err).Error("failed to reload the ftp configuration")
+			}
+		}
+	}()
+
 	go func() {
 		log.Info("updating server states on Panel: marking installing/restoring servers as normal")
 		// Update all the servers on the Panel to be in a valid state if they're
@@ -331,7 +345,7 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 	// and external clients.
 	s := &http.Server{
 		Addr:      api.Host + ":" + strconv.Itoa(api.Port),
-		Handler:   router.Configure(manager, pclient),
+		Handler:   router.Configure(manager, pclient, ftpServer),
 		TLSConfig: config.DefaultTLSConfig,
 	}
 
@@ -75,10 +75,676 @@ type SftpConfiguration struct {
 type FtpConfiguration struct {
 	// The bind address of the FTP server.
 	Address string `default:"0.0.0.0" json:"bind_address" yaml:"bind_address"`
-	// The bind port of the FTP server.
+	// The bind port of the FTP server. If set to 0 any free port is used.
 	Port int `default:"21" json:"bind_port" yaml:"bind_port"`
 	// If set to true, no write actions will be allowed on the FTP server.
 	ReadOnly bool `default:"false" yaml:"read_only"`
+
+	// If set to true, the files of servers are opened, created, and removed
+	// for FTP sessions with the filesystem UID and GID of the container user
+	// set in system.user, rather than as the user Wings runs as, so that a
+	// bug in the FTP server cannot reach files that user cannot. Only
+	// supported on Linux.
+	DropPrivileges bool `default:"false" json:"drop_privileges" yaml:"drop_privileges"`
+
+	// Listeners, if set, replaces the bind address and port above with one or
+	// more listeners, such as one on an internal management address and one on
+	// a public address, each with their own passive and TLS settings.
+	Listeners []FtpListenerConfiguration `json:"listeners" yaml:"listeners"`
+
+	// The passive port ranges are checked against the ports allocated to game
+	// servers and published by Docker containers whenever the listeners are
+	// bound. Overlaps are logged as a warning, or if this is set to true, stop
+	// the FTP server from starting.
+	RefusePassivePortConflicts bool `default:"false" json:"refuse_passive_port_conflicts" yaml:"refuse_passive_port_conflicts"`
+
+	// DedicatedPorts gives each server a listener on its own port, on which
+	// usernames do not need the server suffix.
+	DedicatedPorts FtpDedicatedPortsConfiguration `json:"dedicated_ports" yaml:"dedicated_ports"`
+
+	// WebDAV serves the same files and accounts over WebDAV, for users on
+	// networks that block FTP.
+	WebDAV FtpWebDAVConfiguration `json:"webdav" yaml:"webdav"`
+
+	// The greeting sent to clients when they connect, and the message sent
+	// once they have logged in. Both are templates, see the FTP README for
+	// the variables available. The welcome message is not sent if empty.
+	Banner         string `default:"Welcome to Pterodactyl FTP Server" json:"banner" yaml:"banner"`
+	WelcomeMessage string `json:"welcome_message" yaml:"welcome_message"`
+
+	// How logins are validated: "local" against the FTP accounts stored on
+	// the node, "panel" by the Panel in the same way as SFTP, or "both" to
+	// check the accounts on the node first and the Panel for anything else.
+	Authentication string `default:"local" json:"authentication" yaml:"authentication"`
+
+	// Messages replaces the text of the replies sent when a login fails, the
+	// server is read-only or out of disk space, or the node is busy.
+	Messages FtpMessagesConfiguration `json:"messages" yaml:"messages"`
+
+	// The maximum number of control connections the FTP server accepts at
+	// once, and from a single IP address. Connections beyond these receive a
+	// 421 reply. Set to 0 to disable either limit.
+	MaxConnections      int `default:"1000" json:"max_connections" yaml:"max_connections"`
+	MaxConnectionsPerIP int `default:"50" json:"max_connections_per_ip" yaml:"max_connections_per_ip"`
+
+	// The maximum number of FTP sessions that can be logged in to a single
+	// server at once, so that one server cannot use up the resources of the
+	// node. ServerMaxSessions overrides this for the server with the given
+	// UUID. Set to 0 to disable.
+	MaxSessionsPerServer int            `default:"10" json:"max_sessions_per_server" yaml:"max_sessions_per_server"`
+	ServerMaxSessions    map[string]int `json:"server_max_sessions" yaml:"server_max_sessions"`
+
+	// The maximum number of FTP data transfers that read from or write to the
+	// disk at once on the node, so that the disk is left to the running game
+	// servers. Transfers beyond this are not queued, the client receives a
+	// 450 reply and can try again. Set to 0 to disable.
+	MaxConcurrentTransfers int `default:"0" json:"max_concurrent_transfers" yaml:"max_concurrent_transfers"`
+
+	// The number of seconds a session may be idle before it is disconnected,
+	// and the number of seconds a client has to log in after connecting. Set
+	// to 0 to disable either timeout.
+	IdleTimeout  int `default:"900" json:"idle_timeout" yaml:"idle_timeout"`
+	LoginTimeout int `default:"60" json:"login_timeout" yaml:"login_timeout"`
+
+	// The number of seconds a data connection may go without moving any data
+	// before the transfer is aborted with a 426. Set to 0 to disable.
+	StalledTransferTimeout int `default:"120" json:"stalled_transfer_timeout" yaml:"stalled_transfer_timeout"`
+
+	// The maximum rate, in KiB/s, data is transferred at by a single FTP
+	// connection, so that one client cannot saturate the uplink of the node.
+	// Set to 0 to disable.
+	PerConnectionBandwidth int `default:"0" json:"per_connection_bandwidth" yaml:"per_connection_bandwidth"`
+
+	// The maximum rate, in KiB/s, data is transferred at by all the FTP
+	// sessions of a server combined. ServerBandwidthOverrides replaces this
+	// for the server with the given UUID. Set to 0 to disable.
+	ServerBandwidth          int            `default:"0" json:"server_bandwidth" yaml:"server_bandwidth"`
+	ServerBandwidthOverrides map[string]int `json:"server_bandwidth_overrides" yaml:"server_bandwidth_overrides"`
+
+	// The maximum rate, in KiB/s, of all FTP transfers on the node combined,
+	// which is split evenly between the transfers in progress. This stops FTP
+	// from starving game traffic on nodes without other QoS. Set to 0 to
+	// disable.
+	NodeBandwidth int `default:"0" json:"node_bandwidth" yaml:"node_bandwidth"`
+
+	// If set to true the components of paths given by FTP clients are matched
+	// against existing files and directories without regard to case. This
+	// helps clients on Windows whose tooling changes the case of file names.
+	CaseInsensitive bool `default:"false" json:"case_insensitive" yaml:"case_insensitive"`
+
+	// The maximum length in bytes of a path, relative to the server root, and
+	// the maximum number of directories it may be nested in, that can be
+	// created over FTP. This stops runaway recursive uploads from creating
+	// paths that cannot be handled by the kernel or the Panel. Set to 0 to
+	// disable either limit.
+	MaxPathLength int `default:"1024" json:"max_path_length" yaml:"max_path_length"`
+	MaxPathDepth  int `default:"32" json:"max_path_depth" yaml:"max_path_depth"`
+
+	// The maximum number of entries returned when listing a directory. Larger
+	// directories are rejected with a 450 reply. Set to 0 to disable.
+	MaxListEntries int `default:"100000" json:"max_list_entries" yaml:"max_list_entries"`
+
+	// The number of seconds the entries of a listed directory are cached for,
+	// shared by every session on the node. Changes made over FTP drop the
+	// cached listings they affect straight away. Set to 0 to disable.
+	ListCacheTTL int `default:"5" json:"list_cache_ttl" yaml:"list_cache_ttl"`
+
+	// If set to true the local backups of each server are listed in a
+	// read-only ".backups" directory in the FTP root, so that they can be
+	// downloaded over FTP. Accounts jailed to a directory do not see them.
+	ExposeBackups bool `default:"false" json:"expose_backups" yaml:"expose_backups"`
+
+	// Directories containing more than this many entries are removed in the
+	// background when deleted with RMD, so that the client is not left waiting
+	// for the delete to finish. Set to 0 to always delete in the foreground.
+	BackgroundDeleteThreshold int `default:"10000" json:"background_delete_threshold" yaml:"background_delete_threshold"`
+
+	// Trash controls the recycle-bin behavior for files deleted over FTP.
+	Trash FtpTrashConfiguration `json:"trash" yaml:"trash"`
+
+	// ProtectedPaths is a list of glob patterns, relative to the root of each
+	// server, that FTP users are not able to delete, rename, or upload over.
+	// For example: "server.jar" or "world/level.dat". This is enforced in
+	// addition to the Egg file denylist.
+	ProtectedPaths []string `json:"protected_paths" yaml:"protected_paths"`
+
+	// WritablePaths, if set, limits FTP users to modifying files and
+	// directories at or beneath these paths, relative to the root of each
+	// server. Everything else is read-only. For example: "plugins" and "world".
+	// Each component of a path may be a glob.
+	WritablePaths []string `json:"writable_paths" yaml:"writable_paths"`
+
+	// EggWritablePaths replaces WritablePaths for servers using the Egg with
+	// the given UUID. An empty list makes the whole server writable.
+	EggWritablePaths map[string][]string `json:"egg_writable_paths" yaml:"egg_writable_paths"`
+
+	// SnapshotPaths is a list of glob patterns, relative to the root of each
+	// server, for files that are copied before they are downloaded so that
+	// the client does not receive a file that is being written to by the
+	// game, such as "world/region/*.mca". Copies are reflinks on filesystems
+	// that support them.
+	SnapshotPaths []string `json:"snapshot_paths" yaml:"snapshot_paths"`
+
+	// Uploads restricts the types of files that can be uploaded over FTP.
+	Uploads FtpUploadConfiguration `json:"uploads" yaml:"uploads"`
+
+	// Resume controls whether uploads that fail part way through can be
+	// continued by the client after it reconnects.
+	Resume FtpResumeConfiguration `json:"resume" yaml:"resume"`
+
+	// IOPriority lowers the disk I/O priority of transfers so that heavy FTP
+	// usage does not starve running servers of disk bandwidth.
+	IOPriority FtpIOPriorityConfiguration `json:"io_priority" yaml:"io_priority"`
+
+	// The size in MiB of the chunks that disk space is preallocated in once an
+	// upload grows beyond that size. This reduces fragmentation and stops an
+	// upload that cannot fit on the volume early. Set to 0 to disable, space
+	// is still preallocated when the client announces the size with ALLO.
+	PreallocateSize int `default:"64" json:"preallocate_size" yaml:"preallocate_size"`
+
+	// The size in KiB of the buffers data is copied through during transfers
+	// that cannot be handed off to sendfile or splice, such as those over TLS
+	// or with a limited rate. Larger buffers mean fewer system calls on fast
+	// links, at the cost of memory for each transfer in progress.
+	CopyBufferSize int `default:"256" json:"copy_buffer_size" yaml:"copy_buffer_size"`
+
+	// The most memory, in MiB, the copy buffers of all transfers on the node
+	// may use at once. Transfers wait for a buffer once it is used up, which
+	// slows down their clients rather than letting memory grow while the disk
+	// is slow. Set to 0 to disable.
+	TransferMemory int `default:"256" json:"transfer_memory" yaml:"transfer_memory"`
+
+	// The number of MiB of a file that is read into the page cache ahead of
+	// a download, so that downloads from volumes on spinning disks do not
+	// stall between reads. Set to 0 to leave read-ahead to the kernel.
+	ReadAhead int `default:"8" json:"read_ahead" yaml:"read_ahead"`
+
+	// If set to true the file reads of downloads and writes of uploads that
+	// are not handed off to sendfile or splice are queued on an io_uring, which
+	// saves system calls on nodes with hundreds of transfers in progress.
+	// This is experimental and requires Linux 5.6 or newer, older kernels fall
+	// back to regular file I/O.
+	IOUring bool `default:"false" json:"io_uring" yaml:"io_uring"`
+
+	// Files larger than this many MiB have their pages dropped from the page
+	// cache as they are downloaded or uploaded over FTP, so that transferring
+	// a large world archive does not evict the pages running servers depend
+	// on. Set to 0 to disable.
+	DropCacheSize int `default:"0" json:"drop_cache_size" yaml:"drop_cache_size"`
+
+	// If set to true a message is written to the server console whenever a
+	// file is uploaded, deleted, or renamed over FTP.
+	ConsoleNotifications bool `default:"false" json:"console_notifications" yaml:"console_notifications"`
+
+	// If set to true a SHA-256 checksum is computed for each upload as it is
+	// received and stored in an extended attribute on the file, so that it
+	// can be verified later with "SITE CHECK" or through the API.
+	Checksums bool `default:"false" json:"checksums" yaml:"checksums"`
+
+	// The path of a file that every FTP login and completed transfer is
+	// written to as a line of JSON. Disabled if empty.
+	AccessLog string `json:"access_log" yaml:"access_log"`
+
+	// If set to true every command received from FTP clients and every reply
+	// sent is logged at the debug level, along with the paths they map to.
+	// Passwords are never logged and long parameters are truncated.
+	LogCommands bool `default:"false" json:"log_commands" yaml:"log_commands"`
+
+	// The path of a file that completed FTP transfers are written to in the
+	// xferlog format of wu-ftpd, for tooling that parses it. Disabled if
+	// empty.
+	XferLog string `json:"xferlog" yaml:"xferlog"`
+
+	// Tracing exports spans of FTP logins, path resolution, and transfers
+	// over OTLP.
+	Tracing FtpTracingConfiguration `json:"tracing" yaml:"tracing"`
+
+	// Webhooks receive a JSON payload for FTP events such as uploads,
+	// deletes, logins from a new IP address, and uploads exceeding the disk
+	// space of the server.
+	Webhooks []FtpWebhookConfiguration `json:"webhooks" yaml:"webhooks"`
+
+	// ClamAV configures scanning of completed uploads with clamd.
+	ClamAV FtpClamAVConfiguration `json:"clamav" yaml:"clamav"`
+
+	// Maintenance defines windows of time during which FTP is read-only, or
+	// unavailable, so that nothing is written while backups are being taken.
+	Maintenance FtpMaintenanceConfiguration `json:"maintenance" yaml:"maintenance"`
+
+	// BruteForce defines what is done with addresses that fail to log in too
+	// many times.
+	BruteForce FtpBruteForceConfiguration `json:"brute_force" yaml:"brute_force"`
+
+	// Socket tunes the TCP sockets of control and passive data connections.
+	Socket FtpSocketConfiguration `json:"socket" yaml:"socket"`
+
+	// Clients defines which FTP client software may log in.
+	Clients FtpClientPolicyConfiguration `json:"clients" yaml:"clients"`
+
+	// GeoIP restricts the countries FTP connections are accepted from.
+	GeoIP FtpGeoIPConfiguration `json:"geoip" yaml:"geoip"`
+
+	// PasswordChangeLimit limits how often the password change endpoint can be
+	// called without the node's authorization.
+	PasswordChangeLimit FtpRateLimitConfiguration `json:"password_change_limit" yaml:"password_change_limit"`
+
+	// PasswordPolicy defines the passwords FTP accounts can be given.
+	PasswordPolicy FtpPasswordPolicyConfiguration `json:"password_policy" yaml:"password_policy"`
+
+	// CredentialsPath is the directory the passwords and settings of the FTP
+	// accounts stored on the node are kept in. If empty it is
+	// /var/lib/pterodactyl/passwords, or C:\ProgramData\Pterodactyl\passwords
+	// on Windows.
+	CredentialsPath string `json:"credentials_path" yaml:"credentials_path"`
+
+	// CredentialEncryption encrypts the files in the credentials directory.
+	CredentialEncryption FtpCredentialEncryptionConfiguration `json:"credential_encryption" yaml:"credential_encryption"`
+
+	// Features turns the optional extensions of the FTP server on or off.
+	// Only the extensions that are enabled are advertised in reply to FEAT.
+	Features FtpFeaturesConfiguration `json:"features" yaml:"features"`
+
+	// If set to true CPU and heap profiles of Wings can be captured through
+	// the API, to find out what FTP is spending its time on.
+	Profiling bool `default:"false" json:"profiling" yaml:"profiling"`
+}
+
+// FtpListenerConfiguration defines an address the FTP server accepts control
+// connections on.
+type FtpListenerConfiguration struct {
+	Address string `json:"bind_address" yaml:"bind_address"`
+	Port    int    `json:"bind_port" yaml:"bind_port"`
+
+	// The IP address sent to clients in reply to PASV. If empty the address
+	// the client connected to is used.
+	PublicHost string `json:"public_host" yaml:"public_host"`
+
+	// The range of ports used for passive data connections. Defaults to
+	// 40000-50000 if not set.
+	PassivePortStart int `json:"passive_port_start" yaml:"passive_port_start"`
+	PassivePortEnd   int `json:"passive_port_end" yaml:"passive_port_end"`
+
+	// TLS configures FTPS for connections to this listener.
+	TLS FtpTLSConfiguration `json:"tls" yaml:"tls"`
+}
+
+// FtpTLSConfiguration defines the certificate used for FTPS and whether it is
+// required.
+type FtpTLSConfiguration struct {
+	// Either "explicit" to allow clients to upgrade with AUTH TLS, "required"
+	// to reject clients that do not, or "implicit" for connections that are
+	// encrypted from the start. TLS is disabled if empty.
+	Mode string `json:"mode" yaml:"mode"`
+
+	CertificateFile string `json:"cert" yaml:"cert"`
+	KeyFile         string `json:"key" yaml:"key"`
+}
+
+// FtpWebDAVConfiguration defines the WebDAV listener, which accepts the same
+// credentials as FTP and serves the same files.
+type FtpWebDAVConfiguration struct {
+	Enabled bool   `default:"false" json:"enabled" yaml:"enabled"`
+	Address string `default:"0.0.0.0" json:"bind_address" yaml:"bind_address"`
+	Port    int    `default:"2121" json:"bind_port" yaml:"bind_port"`
+
+	// The certificate WebDAV is served over HTTPS with. Credentials are sent
+	// with every request, so these should only be left empty behind a proxy
+	// that terminates TLS.
+	CertificateFile string `json:"cert" yaml:"cert"`
+	KeyFile         string `json:"key" yaml:"key"`
+
+	// The number of seconds a WebDAV login is remembered for after its last
+	// request, so that the credentials are not checked for every request.
+	SessionTimeout int `default:"300" json:"session_timeout" yaml:"session_timeout"`
+}
+
+// FtpDedicatedPortsConfiguration defines the range of ports servers are
+// assigned their own FTP listener from.
+type FtpDedicatedPortsConfiguration struct {
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// The range each server is assigned a port from. A server keeps its port
+	// until it is deleted from the node.
+	PortStart int `default:"2100" json:"port_start" yaml:"port_start"`
+	PortEnd   int `default:"2999" json:"port_end" yaml:"port_end"`
+
+	// The address, public host, passive port range, and TLS settings used for
+	// the dedicated listeners. The port is ignored.
+	Listener FtpListenerConfiguration `json:"listener" yaml:"listener"`
+}
+
+// FtpMessagesConfiguration defines the text of replies sent to clients in
+// place of the built-in English ones, such as for white-label hosting.
+type FtpMessagesConfiguration struct {
+	// The language whose messages are used. Messages it does not define fall
+	// back to the ones given here, and then to the built-in ones.
+	Language string `json:"language" yaml:"language"`
+
+	FtpMessages `yaml:",inline"`
+
+	// The messages of each language, keyed by its code, such as "de".
+	Languages map[string]FtpMessages `json:"languages" yaml:"languages"`
+}
+
+// FtpMessages is the text of the replies that can be replaced. Empty messages
+// are not replaced.
+type FtpMessages struct {
+	// Sent when a login fails because of the credentials given.
+	LoginFailed string `json:"login_failed" yaml:"login_failed"`
+	// Sent when a command would write to a read-only server.
+	ReadOnly string `json:"read_only" yaml:"read_only"`
+	// Sent when an upload does not fit in the disk space of the server.
+	QuotaExceeded string `json:"quota_exceeded" yaml:"quota_exceeded"`
+	// Sent when a connection, login, or transfer is rejected because the
+	// node or the server has too many of them already.
+	ServerBusy string `json:"server_busy" yaml:"server_busy"`
+}
+
+// FtpTracingConfiguration defines where spans of FTP operations are exported
+// to with OpenTelemetry.
+type FtpTracingConfiguration struct {
+	// The URL of the OTLP/HTTP endpoint spans are exported to, such as
+	// "http://localhost:4318". If empty, the standard OTEL_EXPORTER_OTLP_*
+	// environment variables are used, and tracing is disabled if they are
+	// not set either.
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Headers sent with every export, such as for authentication.
+	Headers map[string]string `json:"headers" yaml:"headers"`
+
+	// The fraction of traces that are sampled, from 0 to 1.
+	SampleRatio float64 `default:"1" json:"sample_ratio" yaml:"sample_ratio"`
+}
+
+// FtpWebhookConfiguration defines a URL that FTP events are sent to.
+type FtpWebhookConfiguration struct {
+	URL string `json:"url" yaml:"url"`
+
+	// If set, the payload is signed with an HMAC-SHA256 of this secret, sent
+	// in the X-Wings-Signature header.
+	Secret string `json:"secret" yaml:"secret"`
+
+	// The events sent to the webhook, all of them if empty.
+	Events []string `json:"events" yaml:"events"`
+
+	// The number of times a delivery is retried, with an exponential backoff,
+	// before it is dropped. Defaults to 3.
+	Retries int `json:"retries" yaml:"retries"`
+}
+
+// FtpBruteForceConfiguration defines the threshold of failed logins after
+// which an address is penalized, and how.
+type FtpBruteForceConfiguration struct {
+	// The number of failed logins from an address within Window seconds
+	// after which it is penalized for Penalty seconds. Set MaxFailures to 0
+	// to disable.
+	MaxFailures int `default:"10" json:"max_failures" yaml:"max_failures"`
+	Window      int `default:"600" json:"window" yaml:"window"`
+	Penalty     int `default:"3600" json:"penalty" yaml:"penalty"`
+
+	// Either "ban" to reject connections from penalized addresses, or
+	// "tarpit" to accept them but delay every reply by TarpitDelay seconds,
+	// greet them with TarpitBanner, and fail every login.
+	Mode         string `default:"ban" json:"mode" yaml:"mode"`
+	TarpitDelay  int    `default:"10" json:"tarpit_delay" yaml:"tarpit_delay"`
+	TarpitBanner string `default:"ProFTPD 1.3.5 Server ready." json:"tarpit_banner" yaml:"tarpit_banner"`
+}
+
+// FtpRateLimitConfiguration defines how many requests can be made within a
+// window of time.
+type FtpRateLimitConfiguration struct {
+	// The number of requests from a single address, and for a single
+	// username, allowed within Window seconds. Set to 0 to not limit them.
+	PerIP       int `default:"10" json:"per_ip" yaml:"per_ip"`
+	PerUsername int `default:"5" json:"per_username" yaml:"per_username"`
+	Window      int `default:"900" json:"window" yaml:"window"`
+}
+
+// FtpCredentialEncryptionConfiguration defines how the files of the FTP
+// accounts stored on the node are encrypted at rest.
+type FtpCredentialEncryptionConfiguration struct {
+	// If set to true the files of the FTP accounts are encrypted when they are
+	// written, and the files that are not encrypted yet are encrypted when the
+	// FTP server starts. If it is turned off again they are decrypted when the
+	// FTP server next starts.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+	// KeyFile is the file holding the secret the key is derived from. If
+	// empty the key is derived from the token of the node, and the files can
+	// no longer be read once the token is changed.
+	KeyFile string `json:"key_file" yaml:"key_file"`
+}
+
+// FtpFeaturesConfiguration defines the optional extensions of the FTP server.
+type FtpFeaturesConfiguration struct {
+	// If set to true files can be hashed with the HASH command, along with
+	// XCRC, MD5, XMD5, and the XSHA commands. Each reads the whole file.
+	Hash bool `default:"false" json:"hash" yaml:"hash"`
+	// If set to true the SITE command is refused, including the SITE
+	// commands handled by Wings.
+	DisableSite bool `default:"false" json:"disable_site" yaml:"disable_site"`
+	// If set to true file names are not advertised as UTF-8 and OPTS UTF8 is
+	// refused, so that clients fall back to their local character set for
+	// servers whose files were named by legacy software.
+	DisableUTF8 bool `default:"false" json:"disable_utf8" yaml:"disable_utf8"`
+}
+
+// FtpPasswordPolicyConfiguration defines the passwords accepted when the
+// password of an FTP account is changed.
+type FtpPasswordPolicyConfiguration struct {
+	MinLength int `default:"6" json:"min_length" yaml:"min_length"`
+
+	// The classes of characters every password must contain at least one of.
+	RequireUppercase bool `default:"false" json:"require_uppercase" yaml:"require_uppercase"`
+	RequireLowercase bool `default:"false" json:"require_lowercase" yaml:"require_lowercase"`
+	RequireDigit     bool `default:"false" json:"require_digit" yaml:"require_digit"`
+	RequireSymbol    bool `default:"false" json:"require_symbol" yaml:"require_symbol"`
+
+	// The minimum strength of the password as scored by zxcvbn, from 0 to 4.
+	// Set to 0 to not score passwords.
+	MinScore int `default:"0" json:"min_score" yaml:"min_score"`
+
+	// If set, passwords from a built-in list of the most common ones are
+	// rejected.
+	DenyCommon bool `default:"true" json:"deny_common" yaml:"deny_common"`
+
+	// The path to a file of further passwords to reject, one per line.
+	Denylist string `json:"denylist" yaml:"denylist"`
+}
+
+// FtpSocketConfiguration defines the options set on the TCP sockets of FTP
+// connections. Options left at 0 keep the defaults of Go and the kernel.
+type FtpSocketConfiguration struct {
+	// The interval in seconds between TCP keepalive probes. Set to -1 to
+	// disable keepalives.
+	KeepAlive int `default:"0" json:"keepalive" yaml:"keepalive"`
+
+	// The size in KiB of the send and receive buffers. Setting these turns
+	// off the kernel's automatic tuning of the buffer for the socket.
+	SendBuffer    int `default:"0" json:"send_buffer" yaml:"send_buffer"`
+	ReceiveBuffer int `default:"0" json:"receive_buffer" yaml:"receive_buffer"`
+
+	// If set to false, Nagle's algorithm is enabled so that small writes are
+	// combined into fewer packets.
+	NoDelay bool `default:"true" json:"no_delay" yaml:"no_delay"`
+
+	// The DSCP value, from 0 to 63, packets of control and data connections
+	// are marked with so that routers can prioritize them.
+	ControlDSCP int `default:"0" json:"control_dscp" yaml:"control_dscp"`
+	DataDSCP    int `default:"0" json:"data_dscp" yaml:"data_dscp"`
+
+	// The interval in seconds a Telnet NOP is sent on the control connection
+	// while a passive data transfer is in progress. Routers that drop
+	// connections without traffic, even with TCP keepalives, would otherwise
+	// close the control connection during long transfers before the final
+	// reply is sent. Clients that do not strip Telnet commands from replies
+	// may fail to read them, so this is disabled by default. Set to 0 to
+	// disable.
+	ControlKeepAlive int `default:"0" json:"control_keepalive" yaml:"control_keepalive"`
+}
+
+// FtpClientPolicyConfiguration defines what is done with clients that are
+// outdated or do not use TLS.
+type FtpClientPolicyConfiguration struct {
+	// The minimum version of each client, keyed by the name it identifies
+	// itself with using CLNT, such as "FileZilla": "3.60.0".
+	MinVersions map[string]string `json:"min_versions" yaml:"min_versions"`
+
+	// What is done with logins from clients older than their minimum
+	// version, and from clients that have not enabled TLS: "allow", "warn"
+	// to log them, or "block" to reject them.
+	OutdatedAction string `default:"warn" json:"outdated_action" yaml:"outdated_action"`
+	PlainAction    string `default:"allow" json:"plain_action" yaml:"plain_action"`
+}
+
+// FtpGeoIPConfiguration defines the countries FTP connections are accepted
+// from, using a MaxMind database to find the country of each address.
+type FtpGeoIPConfiguration struct {
+	// The path of a MaxMind GeoIP2 or GeoLite2 Country or City database. The
+	// checks are disabled if empty.
+	Database string `json:"database" yaml:"database"`
+
+	// If set, connections are only accepted from these countries, given as
+	// ISO 3166-1 alpha-2 codes such as "DE".
+	AllowedCountries []string `json:"allowed_countries" yaml:"allowed_countries"`
+
+	// Connections from these countries are rejected.
+	BlockedCountries []string `json:"blocked_countries" yaml:"blocked_countries"`
+
+	// Logins from these countries are accepted, but every attempt is logged
+	// and failed attempts are only answered after FailedLoginDelay seconds
+	// to slow down password guessing.
+	ScrutinizedCountries []string `json:"scrutinized_countries" yaml:"scrutinized_countries"`
+	FailedLoginDelay     int      `default:"5" json:"failed_login_delay" yaml:"failed_login_delay"`
+}
+
+// FtpMaintenanceConfiguration defines the maintenance windows for FTP.
+type FtpMaintenanceConfiguration struct {
+	// Windows that apply to every server on the node.
+	Windows []FtpMaintenanceWindow `json:"windows" yaml:"windows"`
+
+	// Windows that apply to the server with the given UUID, in addition to
+	// the node-wide ones.
+	Servers map[string][]FtpMaintenanceWindow `json:"servers" yaml:"servers"`
+}
+
+// FtpMaintenanceWindow is a recurring period of time during which FTP access
+// is restricted. Times are in the timezone configured for the node.
+type FtpMaintenanceWindow struct {
+	// The days of the week the window starts on, such as "mon" or "sunday".
+	// If empty the window applies every day.
+	Days []string `json:"days" yaml:"days"`
+
+	// The time the window starts and ends at in "15:04" format. If the end is
+	// before the start the window runs past midnight.
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+
+	// Either "read-only" to reject anything that would write to the server,
+	// or "blocked" to reject all access to it.
+	Mode string `default:"read-only" json:"mode" yaml:"mode"`
+
+	// The message sent to clients whose request is rejected. A default message
+	// is used if this is empty.
+	Message string `json:"message" yaml:"message"`
+}
+
+// FtpClamAVConfiguration defines how files uploaded over FTP are handed off to
+// a clamd daemon for scanning.
+type FtpClamAVConfiguration struct {
+	// If set to true every completed upload is streamed to clamd before the
+	// transfer is acknowledged.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// The address of the clamd daemon. This is either the path to a unix
+	// socket, or a TCP address prefixed with "tcp://".
+	Socket string `default:"/var/run/clamav/clamd.ctl" json:"socket" yaml:"socket"`
+
+	// The number of seconds to wait for clamd to scan a single file. If clamd
+	// cannot be reached or does not respond in time the upload is kept.
+	Timeout int `default:"30" json:"timeout" yaml:"timeout"`
+
+	// The action taken for a file that is flagged, either "quarantine" to move
+	// it into the quarantine directory, or "delete" to remove it.
+	Action string `default:"quarantine" json:"action" yaml:"action"`
+
+	// The directory flagged files are moved into when the action is
+	// "quarantine". Files are stored in a sub-directory for each server.
+	QuarantineDirectory string `default:"/var/lib/pterodactyl/quarantine" json:"quarantine_directory" yaml:"quarantine_directory"`
+
+	// If set to true an activity event is logged for the server whenever a
+	// file is flagged, which is sent along to the Panel.
+	NotifyPanel bool `default:"false" json:"notify_panel" yaml:"notify_panel"`
+}
+
+// FtpUploadRules defines which files are accepted when uploaded over FTP.
+// Extensions may be given with or without the leading dot and are matched
+// without regard to case.
+type FtpUploadRules struct {
+	// If not empty, only files with one of these extensions may be uploaded.
+	AllowedExtensions []string `json:"allowed_extensions" yaml:"allowed_extensions"`
+
+	// Files with any of these extensions are rejected.
+	BlockedExtensions []string `json:"blocked_extensions" yaml:"blocked_extensions"`
+
+	// Files whose sniffed content type matches one of these MIME types are
+	// rejected, regardless of their extension. For example: "application/x-elf"
+	// or "text/x-python".
+	BlockedMimeTypes []string `json:"blocked_mime_types" yaml:"blocked_mime_types"`
+}
+
+// FtpUploadConfiguration defines the node-wide upload rules for FTP along with
+// any per-Egg overrides.
+type FtpUploadConfiguration struct {
+	FtpUploadRules `yaml:",inline"`
+
+	// Eggs maps the UUID of an Egg to the upload rules used for servers running
+	// it. These replace the node-wide rules entirely rather than adding to them.
+	Eggs map[string]FtpUploadRules `json:"eggs" yaml:"eggs"`
+}
+
+// FtpResumeConfiguration defines how uploads that fail part way through are
+// kept so that they can be resumed.
+type FtpResumeConfiguration struct {
+	// If set to true, uploads are written to a hidden partial file next to
+	// the file being uploaded, which replaces it once the upload completes.
+	// Partial files left by failed uploads are recorded so that a later
+	// REST and STOR, or APPE, of the same path continues them.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// The number of hours a failed upload can be resumed for before its
+	// partial file is removed.
+	Expiry int `default:"24" json:"expiry" yaml:"expiry"`
+}
+
+// FtpIOPriorityConfiguration defines the disk I/O priority transfers run with,
+// as set by ionice.
+type FtpIOPriorityConfiguration struct {
+	// The I/O scheduling class of transfers, either "best-effort" or "idle".
+	// Idle transfers only use the disk when nothing else is. If empty,
+	// transfers run with the priority of Wings.
+	Class string `default:"" json:"class" yaml:"class"`
+
+	// The priority of transfers within the best-effort class, from 0 for the
+	// highest to 7 for the lowest.
+	Level int `default:"7" json:"level" yaml:"level"`
+}
+
+// FtpTrashConfiguration defines how deletions performed over FTP are handled
+// when the recycle-bin mode is enabled.
+type FtpTrashConfiguration struct {
+	// If set to true, DELE and RMD will move the target into a trash directory
+	// inside the server's data directory rather than removing it from the disk.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+
+	// The name of the trash directory, relative to the root of each server.
+	Directory string `default:".trash" json:"directory" yaml:"directory"`
+
+	// The number of hours that a deleted file is kept in the trash before it
+	// is permanently removed by the purge routine.
+	Retention int `default:"168" json:"retention" yaml:"retention"`
+
+	// The number of minutes between each run of the trash purge routine.
+	PurgeInterval int `default:"60" json:"purge_interval" yaml:"purge_interval"`
 }
 
 // ApiConfiguration defines the configuration for the internal API that is
@@ -611,6 +1277,32 @@ func FromFile(path string) error {
 	return nil
 }
 
+// ReloadFtp reads the FTP configuration from the configuration file on the
+// disk again and stores it in the global configuration, leaving every other
+// section as it is. The new FTP configuration is returned.
+func ReloadFtp() (FtpConfiguration, error) {
+	mu.RLock()
+	path := _config.path
+	mu.RUnlock()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return FtpConfiguration{}, err
+	}
+	c, err := NewAtPath(path)
+	if err != nil {
+		return FtpConfiguration{}, err
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return FtpConfiguration{}, err
+	}
+
+	Update(func(cfg *Configuration) {
+		cfg.System.Ftp = c.System.Ftp
+	})
+	return c.System.Ftp, nil
+}
+
 // ConfigureDirectories ensures that all the system directories exist on the
 // system. These directories are created so that only the owner can read the data,
 // and no other users.
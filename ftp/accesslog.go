@@ -0,0 +1,147 @@
+package ftp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// The access log is a file, separate from the Wings log, that every login and
+// completed transfer is written to as a single line of JSON so that it can be
+// kept for longer and fed into other tools.
+
+// accessEntry is a line in the access log.
+type accessEntry struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Session  string    `json:"session,omitempty"`
+	Username string    `json:"username"`
+	IP       string    `json:"ip"`
+	Server   string    `json:"server,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Bytes    int64     `json:"bytes"`
+	// The duration of the transfer in milliseconds.
+	Duration int64 `json:"duration_ms"`
+	// The reply code sent to the client once the login or transfer finished.
+	Code  int    `json:"code"`
+	Error string `json:"error,omitempty"`
+}
+
+// The events written to the access log.
+const accessEventLogin = "login"
+
+// logFile is a file that lines are appended to, such as the access log.
+type logFile struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// open switches the log to the given path, closing the file previously
+// written to. The file is reopened even if the path has not changed so that it
+// can be rotated by sending Wings a SIGHUP. An empty path disables the log.
+func (l *logFile) open(p string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		_ = l.f.Close()
+		l.f = nil
+	}
+	if p == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	l.f = f
+	return nil
+}
+
+// enabled reports whether the log is being written to a file.
+func (l *logFile) enabled() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f != nil
+}
+
+// writeLine appends a line to the log, if it is enabled.
+func (l *logFile) writeLine(b []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	_, err := l.f.Write(append(b, '\n'))
+	return err
+}
+
+// accessLog writes entries to the configured access log file.
+type accessLog struct {
+	logFile
+}
+
+func newAccessLog() *accessLog {
+	return &accessLog{}
+}
+
+// write adds an entry to the access log, if it is enabled.
+func (l *accessLog) write(e accessEntry) {
+	if l == nil {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := l.writeLine(b); err != nil {
+		log.WithField("error", err).Warn("failed to write to FTP access log")
+	}
+}
+
+// transferLogged notes that a transfer has finished so that it is written to
+// the access log and xferlog once the reply for it has been sent to the client.
+func (driver *FTPDriver) transferLogged(t *activeTransfer) {
+	if driver.access == nil || (!driver.access.enabled() && !driver.xferlog.enabled()) {
+		return
+	}
+	driver.pendingAccess.Store(t)
+}
+
+// logReply completes the access log and xferlog entries for a finished
+// transfer with the code of the final reply sent for it.
+func (driver *FTPDriver) logReply(p []byte) {
+	if len(p) < 4 || p[3] != ' ' || p[0] < '2' || p[0] > '5' {
+		return
+	}
+	code, err := strconv.Atoi(string(p[:3]))
+	if err != nil {
+		return
+	}
+	t := driver.pendingAccess.Swap(nil)
+	if t == nil {
+		return
+	}
+	st := t.stats.Snapshot()
+	now := time.Now()
+	driver.xferlog.write(driver, t, st, now, code)
+	driver.access.write(accessEntry{
+		Time:     now.UTC(),
+		Event:    t.direction,
+		Session:  driver.sessionID,
+		Username: driver.user,
+		IP:       driver.ip,
+		Server:   driver.server.ID(),
+		Path:     t.path,
+		Bytes:    st.BytesUploaded + st.BytesDownloaded,
+		Duration: now.Sub(t.started).Milliseconds(),
+		Code:     code,
+	})
+}
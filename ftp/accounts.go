@@ -0,0 +1,458 @@
+package ftp
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// ErrAccountNotFound is returned when an FTP account does not exist.
+var ErrAccountNotFound = errors.New("ftp: account not found")
+
+// ErrAccountExists is returned when creating an FTP account that already
+// exists.
+var ErrAccountExists = errors.New("ftp: account already exists")
+
+// ErrInvalidUsername is returned when creating an FTP account with a username
+// that cannot be used.
+var ErrInvalidUsername = errors.New("ftp: invalid username")
+
+// The characters usernames of new accounts may contain.
+var newUsernameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// The characters and length of generated passwords.
+const (
+	passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+	passwordSymbols  = "!#%+-.=?@^_~"
+	passwordLength   = 24
+)
+
+// Account is an FTP account stored on the node.
+type Account struct {
+	Username string `json:"username"`
+	// The server key from the username, which is the UUID of the server or 8
+	// characters of it.
+	Server string `json:"server"`
+	// The directory the account is jailed to, if any.
+	Root string `json:"root"`
+	accountMeta
+}
+
+// accountMeta is stored alongside the password of an account in
+// {username}.json. Accounts created before it was added do not have one, and
+// are treated as having been created when their password was last written.
+type accountMeta struct {
+	CreatedAt time.Time  `json:"created_at"`
+	LastLogin *time.Time `json:"last_login"`
+	// Whether the account can only download files.
+	ReadOnly bool `json:"read_only"`
+	// When the account stops being able to log in, if ever.
+	ExpiresAt *time.Time `json:"expires_at"`
+	// The permission scopes granted to the account, or nil if it can do
+	// everything.
+	Scopes []string `json:"scopes"`
+	// The rate in KiB/s data can be uploaded and downloaded at by all the
+	// sessions of the account combined, or 0 if it is not limited.
+	UploadLimit   int `json:"upload_limit"`
+	DownloadLimit int `json:"download_limit"`
+	// The ID of the server the account is mapped to, if its username does
+	// not name the server with a suffix.
+	ServerID string `json:"server_id"`
+}
+
+// accountMetaMu serializes updates to the metadata files of accounts.
+var accountMetaMu sync.Mutex
+
+// readAccountMeta returns the metadata stored for the account.
+func readAccountMeta(username string) (accountMeta, error) {
+	var m accountMeta
+	store := Credentials()
+	data, err := store.read(username, credentialMeta)
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+		return m, errors.WithStack(err)
+	}
+	if !os.IsNotExist(err) {
+		return m, errors.WithStack(err)
+	}
+	st, err := store.stat(username, credentialPassword)
+	if err != nil {
+		return m, errors.WithStack(err)
+	}
+	m.CreatedAt = st.ModTime().UTC()
+	return m, nil
+}
+
+// updateAccountMeta applies the change to the metadata stored for the account.
+func updateAccountMeta(username string, fn func(m *accountMeta)) error {
+	accountMetaMu.Lock()
+	defer accountMetaMu.Unlock()
+	m, err := readAccountMeta(username)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return err
+	}
+	fn(&m)
+	data, err := json.Marshal(m)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return Credentials().write(username, credentialMeta, data)
+}
+
+// accountExists reports whether there is an FTP account with the username.
+func accountExists(username string) bool {
+	if username == "" || strings.ContainsAny(username, "/\x00") {
+		return false
+	}
+	_, err := Credentials().stat(username, credentialPassword)
+	return err == nil
+}
+
+// AccountExists reports whether there is an FTP account with the username.
+func AccountExists(username string) bool {
+	return accountExists(username)
+}
+
+// mappedServer returns the ID of the server the account is mapped to, if it
+// is mapped to one rather than naming it in its username.
+func mappedServer(username string) (string, bool) {
+	if !accountExists(username) {
+		return "", false
+	}
+	m, err := readAccountMeta(username)
+	if err != nil || m.ServerID == "" {
+		return "", false
+	}
+	return m.ServerID, true
+}
+
+// canMapAccount reports whether an account with the username can be mapped to
+// the server with the given ID, which it can be if the username is not taken
+// by an account of another server.
+func canMapAccount(username, serverID string) bool {
+	if !newUsernameRegexp.MatchString(username) {
+		return false
+	}
+	return !accountExists(username) || AccountBelongsTo(username, serverID)
+}
+
+// expired reports whether the account can no longer log in.
+func (m accountMeta) expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+// Accounts returns the FTP accounts stored on the node, ordered by username.
+func Accounts() ([]Account, error) {
+	usernames, err := Credentials().usernames()
+	if err != nil {
+		return nil, err
+	}
+	var accounts []Account
+	for _, username := range usernames {
+		a := Account{Username: username}
+		a.Root, _ = accountRoot(username)
+		a.accountMeta, _ = readAccountMeta(username)
+		if a.ServerID != "" {
+			a.Server = a.ServerID
+		} else if _, key, ok := ParseFTPUsername(username); ok {
+			a.Server = key
+		}
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Username < accounts[j].Username })
+	return accounts, nil
+}
+
+// ServerAccounts returns the FTP accounts of the server with the given ID,
+// ordered by username.
+func ServerAccounts(serverID string) ([]Account, error) {
+	all, err := Accounts()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, 0)
+	for _, a := range all {
+		if a.Server != "" && matchesServerKey(serverID, a.Server) {
+			accounts = append(accounts, a)
+		}
+	}
+	return accounts, nil
+}
+
+// serverUsername returns the username with the suffix of the server with the
+// given ID, unless it already has one for the server or is the username of an
+// account mapped to it.
+func serverUsername(username, id string) string {
+	if _, key, ok := parseAccountUsername(username); ok && matchesServerKey(id, key) {
+		return username
+	}
+	return username + "_" + id[:8]
+}
+
+// ServerAccountName returns the full username of an account of the server,
+// which may be given with or without the suffix of the server.
+func ServerAccountName(serverID, name string) string {
+	return serverUsername(name, serverID)
+}
+
+// AccountBelongsTo reports whether the username is that of an account of the
+// server with the given ID, and is safe to use as the name of its files.
+func AccountBelongsTo(username, serverID string) bool {
+	if strings.ContainsAny(username, "/\x00") {
+		return false
+	}
+	_, key, ok := parseAccountUsername(username)
+	return ok && matchesServerKey(serverID, key)
+}
+
+// SetAccountBandwidth sets the rate in KiB/s data can be uploaded and
+// downloaded at by the sessions of an FTP account combined, where 0 does not
+// limit it. Sessions that are already logged in keep the limits they logged
+// in with.
+func SetAccountBandwidth(username string, upload, download int) error {
+	if !accountExists(username) {
+		return ErrAccountNotFound
+	}
+	return updateAccountMeta(username, func(m *accountMeta) {
+		m.UploadLimit = max(upload, 0)
+		m.DownloadLimit = max(download, 0)
+	})
+}
+
+// AccountOptions are the settings of a new FTP account.
+type AccountOptions struct {
+	// The directory, relative to the server root, the account is jailed to.
+	Root      string
+	ReadOnly  bool
+	ExpiresAt *time.Time
+	// The permission scopes granted to the account, or nil for all of them.
+	Scopes []string
+	// The ID of the server to map the account to, which lets the username be
+	// anything rather than having to end with the suffix of the server.
+	ServerID string
+}
+
+// CreateAccount adds an FTP account to the node with a randomly generated
+// password, which is returned. The password cannot be read back later
+// through the API.
+func CreateAccount(username string, opts AccountOptions) (string, error) {
+	if opts.ServerID != "" {
+		if !canMapAccount(username, opts.ServerID) {
+			return "", ErrInvalidUsername
+		}
+	} else if _, _, ok := ParseFTPUsername(username); !ok || !newUsernameRegexp.MatchString(username) {
+		return "", ErrInvalidUsername
+	}
+	if opts.Scopes != nil {
+		var err error
+		if opts.Scopes, err = validScopes(opts.Scopes); err != nil {
+			return "", err
+		}
+	}
+	password, err := generatePassword()
+	if err != nil {
+		return "", err
+	}
+	store := Credentials()
+	if err := store.create(username, credentialPassword, []byte(password)); err != nil {
+		if os.IsExist(err) {
+			return "", ErrAccountExists
+		}
+		return "", err
+	}
+	if opts.Root != "" && relativePath(opts.Root) != "" {
+		err = store.write(username, credentialRoot, []byte("/"+relativePath(opts.Root)))
+	}
+	if err == nil {
+		err = updateAccountMeta(username, func(m *accountMeta) {
+			m.CreatedAt = time.Now().UTC()
+			m.ReadOnly = opts.ReadOnly
+			m.ExpiresAt = opts.ExpiresAt
+			m.Scopes = opts.Scopes
+			m.ServerID = opts.ServerID
+		})
+	}
+	if err != nil {
+		_ = DeleteAccount(username)
+		return "", errors.WithStack(err)
+	}
+	return password, nil
+}
+
+// generatePassword returns a random password that meets the password policy
+// of the node.
+func generatePassword() (string, error) {
+	policy := config.Get().System.Ftp.PasswordPolicy
+	alphabet := passwordAlphabet
+	if policy.RequireSymbol {
+		alphabet += passwordSymbols
+	}
+	b := make([]byte, max(passwordLength, policy.MinLength))
+	n := big.NewInt(int64(len(alphabet)))
+	// A password missing one of the required classes of characters is rare
+	// at this length, and is simply generated again.
+	for attempt := 0; attempt < 100; attempt++ {
+		for i := range b {
+			c, err := rand.Int(rand.Reader, n)
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+			b[i] = alphabet[c.Int64()]
+		}
+		if checkPassword(policy, "", string(b)) == nil {
+			return string(b), nil
+		}
+	}
+	return "", errors.New("ftp: failed to generate a password that meets the password policy")
+}
+
+// RotatePassword replaces the password of an FTP account with a randomly
+// generated one, which is returned. FTP sessions that are already logged in
+// with the account are not disconnected, while WebDAV sessions have to log in
+// again with the new password.
+func RotatePassword(username string) (string, error) {
+	if username == "" || strings.ContainsAny(username, "/\x00") {
+		return "", ErrAccountNotFound
+	}
+	store := Credentials()
+	if _, err := store.stat(username, credentialPassword); os.IsNotExist(err) {
+		return "", ErrAccountNotFound
+	} else if err != nil {
+		return "", errors.WithStack(err)
+	}
+	password, err := generatePassword()
+	if err != nil {
+		return "", err
+	}
+	if err := store.write(username, credentialPassword, []byte(password)); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// SetPassword replaces the password of an FTP account, creating the account
+// if it does not exist. WebDAV sessions logged in with the old password have
+// to log in again.
+func SetPassword(username, password string) error {
+	if username == "" || strings.ContainsAny(username, "/\x00") {
+		return ErrInvalidUsername
+	}
+	return Credentials().write(username, credentialPassword, []byte(password))
+}
+
+// DeleteAccount removes an FTP account from the node. FTP sessions that are
+// already logged in with it are not disconnected, while WebDAV sessions end
+// on their next request.
+func DeleteAccount(username string) error {
+	if username == "" || strings.ContainsAny(username, "/\x00") {
+		return ErrAccountNotFound
+	}
+	store := Credentials()
+	err := store.remove(username, credentialPassword)
+	if os.IsNotExist(err) {
+		return ErrAccountNotFound
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, ext := range []string{credentialRoot, credentialIPs, credentialMeta} {
+		if err := store.remove(username, ext); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// LoginStep is the result of one of the checks made when an FTP client logs
+// in.
+type LoginStep struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// TestLogin runs through the checks made by AuthUser for the given
+// credentials without a running FTP server, resolving the server from the
+// data directory rather than the server manager. Checking stops at the first
+// step that fails.
+func TestLogin(username, password string) []LoginStep {
+	var steps []LoginStep
+	step := func(name string, ok bool, detail string) bool {
+		steps = append(steps, LoginStep{Name: name, OK: ok, Detail: detail})
+		return ok
+	}
+
+	_, serverKey, ok := parseAccountUsername(username)
+	if !step("username format", ok, "expected user_{server-id}, or an account mapped to a server") {
+		return steps
+	}
+	steps[len(steps)-1].Detail = "server key " + serverKey
+
+	cfg := config.Get().System
+	var id string
+	entries, _ := os.ReadDir(cfg.Data)
+	for _, entry := range entries {
+		if entry.IsDir() && matchesServerKey(entry.Name(), serverKey) {
+			id = entry.Name()
+			break
+		}
+	}
+	if !step("server", id != "", "no server directory in "+cfg.Data+" matches "+serverKey) {
+		return steps
+	}
+	steps[len(steps)-1].Detail = id
+
+	if !step("password", verifyPassword(username, password), "checked against "+Credentials().path(username, credentialPassword)) {
+		return steps
+	}
+	actualUser := strings.TrimSuffix(username, "_"+serverKey)
+	if !step("server access", hasServerAccess(username, actualUser, id), "needs a password file for "+actualUser+"_"+id[:8]+", or to be mapped to the server") {
+		return steps
+	}
+
+	meta, err := readAccountMeta(username)
+	if err != nil {
+		step("expiry", false, err.Error())
+		return steps
+	}
+	detail := "does not expire"
+	if meta.ExpiresAt != nil {
+		detail = "expires " + meta.ExpiresAt.Format(time.RFC3339)
+	}
+	if !step("expiry", !meta.expired(time.Now()), detail) {
+		return steps
+	}
+
+	root, err := accountRoot(username)
+	if err != nil {
+		step("account root", false, err.Error())
+		return steps
+	}
+	dir := filepath.Join(cfg.Data, id, root)
+	st, err := os.Stat(dir)
+	if !step("account root", err == nil && st.IsDir(), "resolves to "+dir) {
+		return steps
+	}
+
+	w := activeMaintenance(cfg.Ftp.Maintenance, id, time.Now().In(maintenanceLocation()))
+	switch {
+	case w == nil:
+		step("maintenance", true, "no window active")
+	case w.Mode == maintenanceBlocked:
+		step("maintenance", false, "logins are blocked until "+w.End)
+	default:
+		step("maintenance", true, "read-only until "+w.End)
+	}
+	return steps
+}
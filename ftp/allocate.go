@@ -0,0 +1,81 @@
+package ftp
+
+import (
+	"io"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+var (
+	errInsufficientSpace      = errors.WithMessage(ftpserver.ErrStorageExceeded, "not enough disk space available for upload")
+	errPreallocateUnsupported = errors.New("preallocation is not supported")
+)
+
+// AllocateSpace handles the ALLO command. The space is checked against both
+// the server's disk limit and the free space on the volume so that an upload
+// which cannot fit is rejected before it starts, and the size is preallocated
+// for the next upload on the session.
+func (cd *ClientDriver) AllocateSpace(size int) error {
+	err := cd.FTPDriver.allocateSpace(int64(size))
+	if s, serr := cd.getServer(); serr == nil {
+		cd.notifyQuota(s, err)
+	}
+	return cd.noteReply(err)
+}
+
+func (driver *FTPDriver) allocateSpace(size int64) error {
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	s, err := driver.getServer()
+	if err != nil {
+		return err
+	}
+	if size <= 0 {
+		return nil
+	}
+	if err := s.Filesystem().HasSpaceFor(size); err != nil {
+		return withReplyCode(ftpserver.StatusActionAborted, errInsufficientSpace)
+	}
+	if free, err := volumeFree(s.Filesystem().Path()); err == nil && free < size {
+		return withReplyCode(ftpserver.StatusActionAborted, errInsufficientSpace)
+	}
+	driver.allocate.Store(size)
+	return nil
+}
+
+// reserve makes sure the space for the next n bytes written to the upload has
+// been preallocated. Once the upload has grown past the configured chunk size
+// space is reserved a chunk at a time.
+func (f *uploadFile) reserve(n int64) error {
+	if f.chunk <= 0 || f.written < f.chunk {
+		return nil
+	}
+	pos, err := f.fd.Seek(0, io.SeekCurrent)
+	if err != nil || pos+n <= f.allocated {
+		return nil
+	}
+	start := max(pos, f.allocated)
+	end := pos + max(n, f.chunk)
+	if err := preallocate(f.fd, start, end-start); err != nil {
+		if errors.Is(err, errPreallocateUnsupported) {
+			f.chunk = 0
+			return nil
+		}
+		return err
+	}
+	f.allocated = end
+	return nil
+}
+
+// release frees any preallocated space beyond the end of the file, which is
+// left behind if an upload is aborted or was smaller than announced.
+func (f *uploadFile) release() {
+	if f.allocated == 0 {
+		return
+	}
+	if st, err := f.fd.Stat(); err == nil && st.Size() < f.allocated {
+		_ = f.fd.Truncate(st.Size())
+	}
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package ftp
+
+import (
+	"os"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves space on the disk for the file without changing its
+// size. Filesystems that do not support this are silently skipped.
+func preallocate(f *os.File, offset int64, length int64) error {
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_KEEP_SIZE, offset, length)
+	if errors.Is(err, unix.ENOSPC) || errors.Is(err, unix.EDQUOT) {
+		return withReplyCode(ftpserver.StatusActionAborted, errInsufficientSpace)
+	}
+	if err != nil {
+		return errPreallocateUnsupported
+	}
+	return nil
+}
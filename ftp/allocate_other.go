@@ -0,0 +1,11 @@
+//go:build !linux
+
+package ftp
+
+import "os"
+
+// preallocate does nothing, as space cannot be reserved without changing the
+// size of the file on this platform.
+func preallocate(*os.File, int64, int64) error {
+	return errPreallocateUnsupported
+}
@@ -0,0 +1,207 @@
+package ftp
+
+import (
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+// siteCompress handles "SITE COMPRESS <paths...> <target.tar.gz>" which creates
+// a compressed archive of the given paths on the server.
+func (s *session) siteCompress(params string) (int, string) {
+	args, err := splitParams(params)
+	if err != nil || len(args) < 2 {
+		return ftpserver.StatusSyntaxErrorParameters, "Usage: SITE COMPRESS <paths...> <target.tar.gz>"
+	}
+	target := args[len(args)-1]
+	if !strings.HasSuffix(target, ".tar.gz") && !strings.HasSuffix(target, ".tgz") {
+		return ftpserver.StatusSyntaxErrorParameters, "Archive name must end in .tar.gz or .tgz"
+	}
+	paths := make([]string, len(args)-1)
+	for i, p := range args[:len(args)-1] {
+		paths[i] = s.abs(p)
+	}
+	if err := s.driver.Compress(paths, s.abs(target)); err != nil {
+		return archiveErrorReply("Could not compress files", err)
+	}
+	return ftpserver.StatusFileOK, "Archive created"
+}
+
+// siteDecompress handles "SITE DECOMPRESS <archive> <dir>" which extracts an
+// archive on the server into the given directory.
+func (s *session) siteDecompress(params string) (int, string) {
+	args, err := splitParams(params)
+	if err != nil || len(args) != 2 {
+		return ftpserver.StatusSyntaxErrorParameters, "Usage: SITE DECOMPRESS <archive> <directory>"
+	}
+	if err := s.driver.Decompress(s.abs(args[0]), s.abs(args[1])); err != nil {
+		return archiveErrorReply("Could not decompress archive", err)
+	}
+	return ftpserver.StatusFileOK, "Archive extracted"
+}
+
+// archiveErrorReply converts an error returned while working with an archive
+// into a reply for the client.
+func archiveErrorReply(prefix string, err error) (int, string) {
+	switch {
+	case filesystem.IsErrorCode(err, filesystem.ErrCodeDiskSpace):
+		return ftpserver.StatusActionAborted, prefix + ": not enough disk space available"
+	case filesystem.IsErrorCode(err, filesystem.ErrCodeUnknownArchive):
+		return ftpserver.StatusActionNotTakenNoFile, prefix + ": unknown archive format"
+	case errors.Is(err, ftpserver.ErrFileNameNotAllowed):
+		return ftpserver.StatusActionNotTakenNoFile, prefix + ": " + err.Error()
+	case filesystem.IsErrorCode(err, filesystem.ErrCodeDenylistFile):
+		return ftpserver.StatusActionNotTaken, prefix + ": file access prohibited"
+	default:
+		return ftpserver.StatusActionNotTaken, prefix + ": " + err.Error()
+	}
+}
+
+// Compress creates a tar.gz archive at the target containing the given paths.
+func (driver *FTPDriver) Compress(paths []string, target string) error {
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeRead); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeWrite); err != nil {
+		return err
+	}
+	if err := driver.checkProtected(target, false); err != nil {
+		return err
+	}
+	if err := driver.checkNewPath(target); err != nil {
+		return err
+	}
+
+	s, err := driver.getServer()
+	if err != nil {
+		return err
+	}
+	// The archive is checked against the upload rules for the server as if it
+	// had been uploaded.
+	if err := driver.checkUploadName(s, target); err != nil {
+		return err
+	}
+	if err := checkContentType(driver.uploadRules(s).BlockedMimeTypes, mimetype.Lookup("application/gzip")); err != nil {
+		return err
+	}
+	fs := s.Filesystem()
+
+	files := make([]string, len(paths))
+	for i, p := range paths {
+		files[i] = relativePath(driver.serverPath(p))
+	}
+	if err := fs.IsIgnored(append(files, relativePath(driver.serverPath(target)))...); err != nil {
+		return err
+	}
+	if !fs.HasSpaceAvailable(true) {
+		return fs.HasSpaceErr(true)
+	}
+
+	rel := relativePath(driver.serverPath(target))
+	_, err = fs.CompressFilesTo("/", files, rel)
+	if err == nil {
+		err = driver.chownCreated(fs, rel)
+	}
+	driver.listingsChanged(s, target)
+	return err
+}
+
+// Decompress extracts the archive at the given path into a directory.
+func (driver *FTPDriver) Decompress(archive string, dir string) error {
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeRead); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeWrite); err != nil {
+		return err
+	}
+	if err := driver.checkProtected(dir, true); err != nil {
+		return err
+	}
+	if err := driver.checkNewPath(dir); err != nil {
+		return err
+	}
+
+	s, err := driver.getServer()
+	if err != nil {
+		return err
+	}
+	fs := s.Filesystem()
+
+	if err := fs.IsIgnored(relativePath(driver.serverPath(archive))); err != nil {
+		return err
+	}
+	// The archive is resolved relative to the directory it is being extracted
+	// into by the filesystem, so it does not need to live inside of it.
+	rel := relativePath(driver.serverPath(dir))
+	file, err := filepath.Rel("/"+rel, "/"+relativePath(driver.serverPath(archive)))
+	if err != nil {
+		return err
+	}
+	if err := fs.SpaceAvailableForDecompression(s.Context(), rel, file); err != nil {
+		return err
+	}
+	if err := driver.checkArchiveFiles(s, rel, file); err != nil {
+		return err
+	}
+	defer driver.listingsChanged(s, dir)
+	if err := fs.DecompressFile(s.Context(), rel, file); err != nil {
+		return err
+	}
+	return driver.chownCreated(fs, rel)
+}
+
+// checkArchiveFiles checks every file within an archive against the upload
+// rules for the server before any of them are extracted, so that an archive
+// cannot be used to get around them.
+func (driver *FTPDriver) checkArchiveFiles(s *server.Server, dir string, file string) error {
+	rules := driver.uploadRules(s)
+	if len(rules.AllowedExtensions) == 0 && len(rules.BlockedExtensions) == 0 && len(rules.BlockedMimeTypes) == 0 {
+		return nil
+	}
+	return s.Filesystem().ArchiveFiles(s.Context(), dir, file, func(name string, r io.Reader) error {
+		if err := driver.checkUploadName(s, name); err != nil {
+			return errors.WithMessage(err, name)
+		}
+		if len(rules.BlockedMimeTypes) == 0 {
+			return nil
+		}
+		head := make([]byte, sniffLength)
+		n, err := io.ReadFull(r, head)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		return errors.WithMessage(checkContentType(rules.BlockedMimeTypes, mimetype.Detect(head[:n])), name)
+	})
+}
+
+// splitParams splits a space separated parameter string, allowing values that
+// contain spaces to be wrapped in double quotes.
+func splitParams(params string) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(params))
+	r.Comma = ' '
+	fields, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	out := fields[:0]
+	for _, f := range fields {
+		if f != "" {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,51 @@
+package ftp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveUploadRules(t *testing.T) {
+	newDriver := func(t *testing.T) (*FTPDriver, string) {
+		driver, root := newServerDriver(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "site"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "site/index.txt"), []byte("<?php echo 'hello';"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "site/shell.php"), []byte("<?php system($_GET['c']);"), 0o644))
+		return driver, root
+	}
+
+	t.Run("applies the upload rules to the archive created", func(t *testing.T) {
+		driver, root := newDriver(t)
+		driver.cfg.Uploads.AllowedExtensions = []string{"txt"}
+		assert.ErrorIs(t, driver.Compress([]string{"/site"}, "/site.tar.gz"), ftpserver.ErrFileNameNotAllowed)
+		assert.NoFileExists(t, filepath.Join(root, "site.tar.gz"))
+
+		driver.cfg.Uploads.AllowedExtensions = nil
+		driver.cfg.Uploads.BlockedMimeTypes = []string{"application/gzip"}
+		assert.ErrorIs(t, driver.Compress([]string{"/site"}, "/site.tar.gz"), ftpserver.ErrFileNameNotAllowed)
+		assert.NoFileExists(t, filepath.Join(root, "site.tar.gz"))
+	})
+
+	t.Run("applies the upload rules to the files extracted", func(t *testing.T) {
+		driver, root := newDriver(t)
+		require.NoError(t, driver.Compress([]string{"/site"}, "/site.tar.gz"))
+
+		driver.cfg.Uploads.BlockedExtensions = []string{"php"}
+		assert.ErrorIs(t, driver.Decompress("/site.tar.gz", "/extracted"), ftpserver.ErrFileNameNotAllowed)
+		assert.NoDirExists(t, filepath.Join(root, "extracted/site"))
+
+		driver.cfg.Uploads.BlockedExtensions = nil
+		driver.cfg.Uploads.BlockedMimeTypes = []string{"text/x-php"}
+		assert.ErrorIs(t, driver.Decompress("/site.tar.gz", "/extracted"), ftpserver.ErrFileNameNotAllowed)
+		assert.NoDirExists(t, filepath.Join(root, "extracted/site"))
+
+		driver.cfg.Uploads.BlockedMimeTypes = []string{"application/x-elf"}
+		require.NoError(t, driver.Decompress("/site.tar.gz", "/extracted"))
+		assert.FileExists(t, filepath.Join(root, "extracted/site/shell.php"))
+	})
+}
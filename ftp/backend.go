@@ -0,0 +1,173 @@
+package ftp
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/afero"
+)
+
+// The driver reaches the files of a server only through a Backend, given the
+// real paths built by buildPath, so that servers can be kept somewhere other
+// than the local disk and the driver can be tested against an in-memory
+// filesystem.
+//
+// Files opened from the local disk are *os.File, which lets transfers use
+// sendfile, splice, io_uring, preallocation, and the page cache hints. Files
+// opened from any other backend are copied through a buffer. Checksums,
+// download snapshots, and ClamAV quarantine work on the local disk only.
+//
+// The system calls behind these are specific to Linux and kept in _linux.go
+// files. On other platforms their counterparts return errUnsupported, and
+// the transfer goes ahead without them.
+
+// errUnsupported is returned by the system calls that are not made on this
+// platform.
+var errUnsupported = errors.New("ftp: not supported on this platform")
+
+// Backend is the storage the files of servers are kept on.
+type Backend interface {
+	afero.Fs
+
+	// Lstat returns information about the file without following it if it
+	// is a symbolic link.
+	Lstat(name string) (os.FileInfo, error)
+
+	// Readlink returns the target of a symbolic link.
+	Readlink(name string) (string, error)
+
+	// EvalSymlinks returns the path with every symbolic link in it resolved,
+	// in the same way as filepath.EvalSymlinks.
+	EvalSymlinks(path string) (string, error)
+}
+
+// The backend used by drivers that are not given one.
+var localStorage = NewLocalBackend()
+
+// NewLocalBackend returns the backend that keeps files on the local disk.
+func NewLocalBackend() Backend {
+	return localBackend{}
+}
+
+type localBackend struct {
+	afero.OsFs
+}
+
+func (localBackend) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (localBackend) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (localBackend) EvalSymlinks(path string) (string, error) {
+	return filepath.EvalSymlinks(path)
+}
+
+// NewAferoBackend returns a backend that keeps files on an afero filesystem,
+// such as an afero.MemMapFs. Symbolic links are followed if the filesystem
+// is able to read them.
+func NewAferoBackend(fsys afero.Fs) Backend {
+	return aferoBackend{Fs: fsys}
+}
+
+type aferoBackend struct {
+	afero.Fs
+}
+
+// The most symbolic links followed while resolving a path, beyond which it
+// is assumed to loop.
+const maxSymlinks = 255
+
+var errSymlinkLoop = errors.New("too many levels of symbolic links")
+
+func (b aferoBackend) Lstat(name string) (os.FileInfo, error) {
+	if l, ok := b.Fs.(afero.Lstater); ok {
+		info, _, err := l.LstatIfPossible(name)
+		return info, err
+	}
+	return b.Fs.Stat(name)
+}
+
+func (b aferoBackend) Readlink(name string) (string, error) {
+	if reader, ok := b.Fs.(afero.LinkReader); ok {
+		return reader.ReadlinkIfPossible(name)
+	}
+	return "", &os.PathError{Op: "readlink", Path: name, Err: afero.ErrNoReadlink}
+}
+
+func (b aferoBackend) EvalSymlinks(path string) (string, error) {
+	reader, ok := b.Fs.(afero.LinkReader)
+	if !ok {
+		if _, err := b.Fs.Stat(path); err != nil {
+			return "", err
+		}
+		return filepath.Clean(path), nil
+	}
+	resolved := string(filepath.Separator)
+	rest := strings.Split(strings.Trim(filepath.Clean(path), string(filepath.Separator)), string(filepath.Separator))
+	for links := 0; len(rest) > 0; {
+		part := rest[0]
+		rest = rest[1:]
+		if part == "" {
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		info, err := b.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", &os.PathError{Op: "lstat", Path: path, Err: errSymlinkLoop}
+		}
+		target, err := reader.ReadlinkIfPossible(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = string(filepath.Separator)
+		}
+		rest = append(strings.Split(target, string(filepath.Separator)), rest...)
+	}
+	return resolved, nil
+}
+
+// readDirBatch reads the next n entries of a directory, along with the number
+// of entries that could not be read. Directories that can list their entries
+// without reading the information of each one, such as those on the local
+// disk, skip the entries whose information cannot be read.
+func readDirBatch(dir afero.File, n int) ([]os.FileInfo, int, error) {
+	rd, ok := dir.(interface {
+		ReadDir(n int) ([]fs.DirEntry, error)
+	})
+	if !ok {
+		infos, err := dir.Readdir(n)
+		return infos, 0, err
+	}
+	entries, err := rd.ReadDir(n)
+	infos := make([]os.FileInfo, 0, len(entries))
+	var skipped int
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			skipped++
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, skipped, err
+}
+
+// SetBackend replaces the storage the files of servers are kept on, which is
+// the local disk by default. It must be called before the server is run.
+func (c *FTPServer) SetBackend(b Backend) {
+	c.backend = b
+}
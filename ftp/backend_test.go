@@ -0,0 +1,106 @@
+package ftp
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+const testServerID = "8f2a1c3e-6b1d-4c8e-9a3f-2d7e5b0c1a94"
+
+// newMemDriver returns a driver for a server whose files are kept in memory.
+func newMemDriver(t *testing.T) (*FTPDriver, afero.Fs) {
+	config.Set(&config.Configuration{AuthenticationToken: "test"})
+	s, err := server.New(nil)
+	require.NoError(t, err)
+	require.NoError(t, s.SyncWithConfiguration(remote.ServerConfigurationResponse{
+		Settings: json.RawMessage(`{"uuid":"` + testServerID + `"}`),
+	}))
+	fsys := afero.NewMemMapFs()
+	require.NoError(t, fsys.MkdirAll("/srv/"+testServerID, 0o755))
+	return &FTPDriver{BasePath: "/srv", server: s, backend: NewAferoBackend(fsys)}, fsys
+}
+
+func TestDriverBackend(t *testing.T) {
+	driver, fsys := newMemDriver(t)
+	root := "/srv/" + testServerID
+	require.NoError(t, fsys.MkdirAll(root+"/plugins", 0o755))
+	require.NoError(t, afero.WriteFile(fsys, root+"/server.properties", []byte("motd=hello"), 0o644))
+	require.NoError(t, afero.WriteFile(fsys, root+"/plugins/a.jar", []byte("jar"), 0o644))
+
+	t.Run("stats files", func(t *testing.T) {
+		info, err := driver.Stat("/server.properties")
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), info.Size())
+
+		_, err = driver.Stat("/missing")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("lists directories", func(t *testing.T) {
+		var names []string
+		require.NoError(t, driver.ListDir("/", func(info os.FileInfo) error {
+			names = append(names, info.Name())
+			return nil
+		}))
+		sort.Strings(names)
+		assert.Equal(t, []string{"plugins", "server.properties"}, names)
+	})
+
+	t.Run("downloads files", func(t *testing.T) {
+		f, err := driver.OpenFile("/server.properties", os.O_RDONLY, 0)
+		require.NoError(t, err)
+		b, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.NoError(t, f.Close())
+		assert.Equal(t, "motd=hello", string(b))
+	})
+
+	t.Run("keeps paths within the server", func(t *testing.T) {
+		p, err := driver.buildPath(driver.server, "/../plugins/./a.jar")
+		require.NoError(t, err)
+		assert.Equal(t, root+"/plugins/a.jar", p)
+		p, err = driver.buildPath(driver.server, "/../../server.properties")
+		require.NoError(t, err)
+		assert.Equal(t, root+"/server.properties", p)
+	})
+
+	t.Run("removes trees", func(t *testing.T) {
+		var removed int64
+		require.NoError(t, removeAll(driver.storage(), root+"/plugins", func(size int64) { removed += size }))
+		assert.Equal(t, int64(3), removed)
+		_, err := fsys.Stat(root + "/plugins")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestAferoBackendEvalSymlinks(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(root+"/data/world", 0o755))
+	require.NoError(t, os.Symlink("data", root+"/link"))
+	require.NoError(t, os.Symlink("/etc", root+"/data/escape"))
+
+	b := NewAferoBackend(afero.NewOsFs())
+	p, err := b.EvalSymlinks(root + "/link/world")
+	require.NoError(t, err)
+	want, err := NewLocalBackend().EvalSymlinks(root + "/data/world")
+	require.NoError(t, err)
+	assert.Equal(t, want, p)
+
+	p, err = b.EvalSymlinks(root + "/data/escape")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc", p)
+
+	_, err = b.EvalSymlinks(root + "/missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,202 @@
+package ftp
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/ratelimit"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+)
+
+// limiter blocks until the given number of bytes may be transferred. It is
+// satisfied by *ratelimit.Bucket.
+type limiter interface {
+	Wait(n int64)
+}
+
+// bandwidth is the set of rate limits that apply to a transfer. Data is only
+// moved once every one of them allows it.
+type bandwidth []limiter
+
+// newBandwidthBucket returns a bucket allowing the given number of KiB per
+// second, or nil if the rate is not limited.
+func newBandwidthBucket(kib int) *ratelimit.Bucket {
+	if kib <= 0 {
+		return nil
+	}
+	rate := int64(kib) * 1024
+	return ratelimit.NewBucketWithRate(float64(rate), rate)
+}
+
+// with returns the limits along with the given bucket, if it is not nil.
+func (b bandwidth) with(bucket *ratelimit.Bucket) bandwidth {
+	if bucket == nil {
+		return b
+	}
+	return append(b[:len(b):len(b)], bucket)
+}
+
+// withShare returns the limits along with the given share of the node-wide
+// limit, if it is not nil.
+func (b bandwidth) withShare(share *fairShare) bandwidth {
+	if share == nil {
+		return b
+	}
+	return append(b[:len(b):len(b)], share)
+}
+
+// wait blocks until n bytes may be transferred.
+func (b bandwidth) wait(n int64) {
+	if n <= 0 {
+		return
+	}
+	for _, bucket := range b {
+		bucket.Wait(n)
+	}
+}
+
+// reader returns a reader that is limited to the rate allowed, or the reader
+// itself if there are no limits.
+func (b bandwidth) reader(r io.Reader) io.Reader {
+	if len(b) == 0 {
+		return r
+	}
+	return &throttledReader{r: r, limits: b}
+}
+
+type throttledReader struct {
+	r      io.Reader
+	limits bandwidth
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.limits.wait(int64(n))
+	return n, err
+}
+
+// serverBandwidth holds the limits shared by every FTP session of a server,
+// keyed by the ID of the server, and those shared by every session of an
+// account, keyed by the account and direction.
+type serverBandwidth struct {
+	mu      sync.Mutex
+	buckets map[string]*serverBucket
+}
+
+type serverBucket struct {
+	kib    int
+	bucket *ratelimit.Bucket
+}
+
+func newServerBandwidth() *serverBandwidth {
+	return &serverBandwidth{buckets: make(map[string]*serverBucket)}
+}
+
+// bucket returns the limit shared by the sessions of the given server, or nil
+// if the server is not limited.
+func (sb *serverBandwidth) bucket(cfg config.FtpConfiguration, s *server.Server) *ratelimit.Bucket {
+	return sb.shared(s.ID(), serverBandwidthLimit(cfg, s.ID(), s.FtpBandwidth()))
+}
+
+// serverBandwidthLimit returns the rate in KiB/s the sessions of the given
+// server are limited to, or 0 if they are not limited. The limit set by the
+// Panel in the server's configuration is used if there is one, and the
+// overrides in the node's configuration otherwise.
+func serverBandwidthLimit(cfg config.FtpConfiguration, id string, panel int) int {
+	if panel > 0 {
+		return panel
+	}
+	if n, ok := cfg.ServerBandwidthOverrides[id]; ok {
+		return n
+	}
+	return cfg.ServerBandwidth
+}
+
+// accountBuckets returns the upload and download limits shared by the
+// sessions of the account, either of which is nil if it is not limited.
+func (sb *serverBandwidth) accountBuckets(username string, upload, download int) (*ratelimit.Bucket, *ratelimit.Bucket) {
+	return sb.shared("account:"+username+":upload", upload), sb.shared("account:"+username+":download", download)
+}
+
+// shared returns the limit for the key at the given rate, or nil if the rate
+// is not limited. If the rate has changed since the limit was created,
+// sessions that start from now on use a new limit at the new rate.
+func (sb *serverBandwidth) shared(key string, kib int) *ratelimit.Bucket {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if kib <= 0 {
+		delete(sb.buckets, key)
+		return nil
+	}
+	if b, ok := sb.buckets[key]; ok && b.kib == kib {
+		return b.bucket
+	}
+	b := &serverBucket{kib: kib, bucket: newBandwidthBucket(kib)}
+	sb.buckets[key] = b
+	return b.bucket
+}
+
+// fairLimiter caps the combined rate of every FTP transfer on the node. Rather
+// than handing out tokens to whichever transfer asks first, the rate is split
+// evenly between the transfers in progress, so that one fast client cannot
+// take the whole limit for itself.
+type fairLimiter struct {
+	rate   int64
+	active atomic.Int64
+}
+
+// newFairLimiter returns a limiter allowing the given number of KiB per
+// second across the node, or nil if the rate is not limited.
+func newFairLimiter(kib int) *fairLimiter {
+	if kib <= 0 {
+		return nil
+	}
+	return &fairLimiter{rate: int64(kib) * 1024}
+}
+
+// share starts a transfer, returning its share of the limit. The share must be
+// closed once the transfer has finished.
+func (l *fairLimiter) share() *fairShare {
+	if l == nil {
+		return nil
+	}
+	l.active.Add(1)
+	return &fairShare{limiter: l}
+}
+
+// fairShare paces a single transfer to its share of the node-wide limit.
+type fairShare struct {
+	limiter *fairLimiter
+	next    time.Time
+	once    sync.Once
+}
+
+// Wait blocks until n bytes may be transferred. The share is recalculated on
+// every call so that transfers speed up as others finish.
+func (s *fairShare) Wait(n int64) {
+	active := max(s.limiter.active.Load(), 1)
+	d := time.Duration(float64(n) * float64(active) / float64(s.limiter.rate) * float64(time.Second))
+	now := time.Now()
+	// Allow up to a second of unused time to carry over, which smooths out
+	// short pauses without letting an idle transfer burst far beyond its
+	// share once it resumes.
+	if s.next.Before(now.Add(-time.Second)) {
+		s.next = now.Add(-time.Second)
+	}
+	s.next = s.next.Add(d)
+	if wait := s.next.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// close ends the transfer, giving its share back to the others.
+func (s *fairShare) close() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() { s.limiter.active.Add(-1) })
+}
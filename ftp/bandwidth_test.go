@@ -0,0 +1,56 @@
+package ftp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestFairLimiter(t *testing.T) {
+	t.Run("is disabled without a rate", func(t *testing.T) {
+		assert.Nil(t, newFairLimiter(0))
+		assert.Nil(t, newFairLimiter(0).share())
+		assert.Len(t, bandwidth(nil).withShare(nil), 0)
+	})
+
+	t.Run("splits the rate between transfers", func(t *testing.T) {
+		l := newFairLimiter(100)
+		a, b := l.share(), l.share()
+		assert.EqualValues(t, 2, l.active.Load())
+
+		// Each transfer gets 50 KiB/s, so after the second of carry over is
+		// used up another 25 KiB takes half a second.
+		start := time.Now()
+		var wg sync.WaitGroup
+		for _, s := range []*fairShare{a, b} {
+			wg.Add(1)
+			go func(s *fairShare) {
+				defer wg.Done()
+				s.Wait(50 * 1024)
+				s.Wait(25 * 1024)
+			}(s)
+		}
+		wg.Wait()
+		assert.InDelta(t, 500*time.Millisecond, time.Since(start), float64(200*time.Millisecond))
+
+		a.close()
+		a.close()
+		assert.EqualValues(t, 1, l.active.Load())
+		b.close()
+		assert.EqualValues(t, 0, l.active.Load())
+	})
+}
+
+func TestServerBandwidthLimit(t *testing.T) {
+	cfg := config.FtpConfiguration{
+		ServerBandwidth:          100,
+		ServerBandwidthOverrides: map[string]int{"a": 200},
+	}
+	assert.Equal(t, 100, serverBandwidthLimit(cfg, "b", 0))
+	assert.Equal(t, 200, serverBandwidthLimit(cfg, "a", 0))
+	assert.Equal(t, 300, serverBandwidthLimit(cfg, "a", 300), "the Panel's limit is used over the node's")
+}
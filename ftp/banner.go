@@ -0,0 +1,95 @@
+package ftp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// The greeting and welcome message are templates in which variables such as
+// {node} are replaced. The greeting is sent before the client has logged in,
+// so only the variables that do not depend on the server are available in it.
+
+// renderTemplate replaces the variables in the template with their values.
+// Unknown variables are left as they are.
+func renderTemplate(tmpl string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// formatBytes returns the size in bytes in a human readable form.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// nodeName returns the name the node is referred to by in messages.
+func nodeName() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
+
+// banner returns the greeting sent to clients when they connect.
+func (d *FTPServerDriver) banner() string {
+	return renderTemplate(d.cfg.Banner, map[string]string{
+		"node":      nodeName(),
+		"read_only": yesNo(d.readOnly),
+	})
+}
+
+// PostAuthMessage returns the message sent to the client once it has logged
+// in, or has failed to, or an empty string to send the default.
+func (d *FTPServerDriver) PostAuthMessage(cc ftpserver.ClientContext, _ string, authErr error) string {
+	if authErr != nil {
+		return authMessage(d.cfg.Messages, authErr)
+	}
+	if d.cfg.WelcomeMessage == "" {
+		return ""
+	}
+	s := d.sessions.Get(cc.RemoteAddr().String())
+	if s == nil {
+		return ""
+	}
+	return s.driver.welcome()
+}
+
+// welcome returns the message sent to the client once it has logged in.
+func (driver *FTPDriver) welcome() string {
+	s := driver.server
+	limit := "unlimited"
+	if s.DiskSpace() > 0 {
+		limit = formatBytes(s.DiskSpace())
+	}
+	return renderTemplate(driver.cfg.WelcomeMessage, map[string]string{
+		"node":        nodeName(),
+		"username":    driver.user,
+		"server_id":   s.ID(),
+		"server_name": s.Config().Meta.Name,
+		"disk_used":   formatBytes(s.Filesystem().CachedUsage()),
+		"disk_limit":  limit,
+		"read_only":   yesNo(driver.checkReadOnly() != nil),
+	})
+}
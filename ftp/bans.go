@@ -0,0 +1,175 @@
+package ftp
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// Addresses penalized for failing to log in can be listed through the API,
+// and addresses can be banned and unbanned by hand. Bans made through the API
+// are stored so that they survive restarts; penalties for failed logins are
+// not.
+
+// ErrInvalidIP is returned when banning something that is not an IP address.
+var ErrInvalidIP = errors.New("ftp: invalid IP address")
+
+// ErrBanNotFound is returned when unbanning an address that is not banned.
+var ErrBanNotFound = errors.New("ftp: address is not banned")
+
+// Ban is an address whose FTP connections are currently penalized.
+type Ban struct {
+	IP string `json:"ip"`
+	// When the ban ends, or nil if it does not.
+	Until *time.Time `json:"until"`
+	// Whether the address was banned through the API, rather than for
+	// failing to log in too many times.
+	Manual bool   `json:"manual"`
+	Reason string `json:"reason"`
+}
+
+// bansPath returns the file the bans made through the API are stored in.
+func bansPath() string {
+	return filepath.Join(config.Get().System.RootDirectory, "ftp-bans.json")
+}
+
+// list returns the addresses that are currently penalized, ordered by
+// address.
+func (o *offenders) list() []Ban {
+	now := time.Now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	bans := make([]Ban, 0)
+	for ip, of := range o.ips {
+		if !of.active(now) {
+			continue
+		}
+		b := Ban{IP: ip, Manual: of.manual, Reason: of.reason}
+		if !of.permanent {
+			until := of.until
+			b.Until = &until
+		}
+		bans = append(bans, b)
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].IP < bans[j].IP })
+	return bans
+}
+
+// ban penalizes the address for the duration, or until it is unbanned if the
+// duration is 0, replacing any penalty it already has.
+func (o *offenders) ban(ip string, d time.Duration, reason string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	of := &offender{manual: true, reason: reason, permanent: d <= 0}
+	if d > 0 {
+		of.until = time.Now().Add(d)
+	}
+	o.ips[ip] = of
+}
+
+// unban lifts the penalty of the address and forgets its failed logins,
+// returning false if it was not penalized.
+func (o *offenders) unban(ip string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	of, ok := o.ips[ip]
+	if !ok {
+		return false
+	}
+	delete(o.ips, ip)
+	return of.active(time.Now())
+}
+
+// manualBans returns the bans made through the API that are still active.
+func (o *offenders) manualBans() []Ban {
+	var bans []Ban
+	for _, b := range o.list() {
+		if b.Manual {
+			bans = append(bans, b)
+		}
+	}
+	return bans
+}
+
+// saveBans stores the bans made through the API.
+func (o *offenders) saveBans() error {
+	b, err := json.Marshal(o.manualBans())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(bansPath(), b, 0o600))
+}
+
+// loadBans restores the bans made through the API that have not ended. If
+// they cannot be read the error is logged.
+func (o *offenders) loadBans() {
+	b, err := os.ReadFile(bansPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithField("error", err).Error("failed to read FTP bans")
+		}
+		return
+	}
+	var bans []Ban
+	if err := json.Unmarshal(b, &bans); err != nil {
+		log.WithField("error", err).Error("failed to parse FTP bans")
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, b := range bans {
+		of := &offender{manual: true, reason: b.Reason, permanent: b.Until == nil}
+		if b.Until != nil {
+			of.until = *b.Until
+		}
+		if of.active(time.Now()) {
+			o.ips[b.IP] = of
+		}
+	}
+}
+
+// Bans returns the addresses whose FTP connections are currently penalized.
+func (c *FTPServer) Bans() []Ban {
+	return c.offenders.list()
+}
+
+// BanIP bans an address from FTP for the duration, or until it is unbanned if
+// the duration is 0, and closes its open connections. Banning an address
+// that is already banned replaces the ban.
+func (c *FTPServer) BanIP(ip string, d time.Duration, reason string) error {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ErrInvalidIP
+	}
+	ip = addr.String()
+	c.offenders.ban(ip, d, reason)
+	log.WithFields(log.Fields{
+		"ip":       ip,
+		"duration": d.String(),
+		"reason":   reason,
+	}).Warn("FTP address banned")
+	for _, conn := range c.sessions.connsFrom(ip) {
+		_ = conn.Close()
+	}
+	return c.offenders.saveBans()
+}
+
+// UnbanIP lifts the ban or penalty of an address.
+func (c *FTPServer) UnbanIP(ip string) error {
+	if addr := net.ParseIP(ip); addr != nil {
+		ip = addr.String()
+	}
+	if !c.offenders.unban(ip) {
+		return ErrBanNotFound
+	}
+	log.WithField("ip", ip).Info("FTP address unbanned")
+	return c.offenders.saveBans()
+}
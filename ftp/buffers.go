@@ -0,0 +1,147 @@
+package ftp
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// io.Copy allocates a new 32 KiB buffer for every transfer it cannot hand off
+// to sendfile or splice. Transfers instead borrow a larger buffer from a pool,
+// which means fewer system calls per MiB moved and less garbage while many
+// clients are uploading at once.
+//
+// A buffer is only borrowed while a chunk is read and written, and the memory
+// of the buffers borrowed across the node is kept within a budget. Once it is
+// used up, transfers wait for a buffer before reading their next chunk, which
+// leaves the data in the client's socket and slows the client down rather
+// than letting memory grow while the disk is slow.
+
+// The size of the copy buffers when none is configured, which is the size
+// used by io.Copy.
+const defaultCopyBufferSize = 32 * 1024
+
+var (
+	copyBuffers    sync.Pool
+	transferMemory = newBufferBudget()
+)
+
+// bufferBudget counts the bytes of the copy buffers that are borrowed.
+type bufferBudget struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	used int64
+}
+
+func newBufferBudget() *bufferBudget {
+	b := &bufferBudget{}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire waits until n bytes fit within the limit, and counts them as
+// borrowed. A limit of zero or less does not wait. A buffer is always allowed
+// when nothing else is borrowed, even if it is larger than the limit.
+func (b *bufferBudget) acquire(n, limit int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for limit > 0 && b.used > 0 && b.used+n > limit {
+		b.cond.Wait()
+	}
+	b.used += n
+}
+
+func (b *bufferBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// copyBufferSize returns the configured size of the copy buffers in bytes.
+func copyBufferSize() int {
+	if kib := config.Get().System.Ftp.CopyBufferSize; kib > 0 {
+		return kib * 1024
+	}
+	return defaultCopyBufferSize
+}
+
+// getCopyBuffer returns a buffer of the configured size from the pool,
+// waiting until it fits within the memory budget of the node. Buffers of
+// another size, left over from before the configuration was reloaded, are
+// dropped.
+func getCopyBuffer() *[]byte {
+	size := copyBufferSize()
+	transferMemory.acquire(int64(size), int64(config.Get().System.Ftp.TransferMemory)*1024*1024)
+	if b, ok := copyBuffers.Get().(*[]byte); ok && len(*b) == size {
+		return b
+	}
+	b := make([]byte, size)
+	return &b
+}
+
+// putCopyBuffer returns a buffer from getCopyBuffer to the pool.
+func putCopyBuffer(b *[]byte) {
+	transferMemory.release(int64(len(*b)))
+	copyBuffers.Put(b)
+}
+
+// copyBuffer copies from src to dst like io.Copy, borrowing a buffer from the
+// pool for each chunk. Sources and destinations that can copy on their own,
+// such as a file being sent to a TCP connection with sendfile, still do so.
+func copyBuffer(dst io.Writer, src io.Reader) (written int64, err error) {
+	if wt, ok := src.(io.WriterTo); ok {
+		return wt.WriteTo(dst)
+	}
+	if rf, ok := dst.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	for {
+		b := getCopyBuffer()
+		nr, rerr := src.Read(*b)
+		var werr error
+		if nr > 0 {
+			var nw int
+			nw, werr = dst.Write((*b)[:nr])
+			if nw < 0 || nw > nr {
+				nw = 0
+				if werr == nil {
+					werr = errors.New("invalid write result")
+				}
+			}
+			written += int64(nw)
+			if werr == nil && nw != nr {
+				werr = io.ErrShortWrite
+			}
+		}
+		putCopyBuffer(b)
+		if werr != nil {
+			return written, werr
+		}
+		if rerr != nil {
+			if rerr == io.EOF {
+				return written, nil
+			}
+			return written, rerr
+		}
+	}
+}
+
+// spliceable reports whether the kernel can move the data from the reader to
+// a file with splice, in which case os.File.ReadFrom is used rather than a
+// copy buffer. Any other reader would be copied by os.File.ReadFrom with a
+// buffer of its own.
+func spliceable(r io.Reader) bool {
+	if lr, ok := r.(*io.LimitedReader); ok {
+		r = lr.R
+	}
+	switch r.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		return true
+	}
+	return false
+}
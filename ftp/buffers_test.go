@@ -0,0 +1,37 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferBudget(t *testing.T) {
+	b := newBufferBudget()
+	b.acquire(60, 100)
+	b.acquire(40, 100)
+
+	acquired := make(chan struct{})
+	go func() {
+		b.acquire(10, 100)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a buffer beyond the budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.release(40)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("did not acquire a buffer once one was released")
+	}
+
+	// A buffer larger than the budget is allowed on its own.
+	b = newBufferBudget()
+	b.acquire(200, 100)
+	assert.Equal(t, int64(200), b.used)
+}
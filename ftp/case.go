@@ -0,0 +1,44 @@
+package ftp
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// resolveCase maps each component of a cleaned path relative to the server
+// root onto the name of an existing file or directory that differs from it
+// only in case. Components that exist exactly as given are used as is, and
+// once a component cannot be found the remainder of the path is returned
+// unchanged so that new files are created with the name the client asked for.
+//
+// Only names read from the directory being searched are ever substituted, so
+// the result is subject to the same jail checks as the original path.
+func resolveCase(fsys Backend, root string, rel string) string {
+	if rel == "" || rel == "." {
+		return rel
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	dir := root
+	for i, part := range parts {
+		if _, err := fsys.Lstat(filepath.Join(dir, part)); err != nil {
+			entries, err := afero.ReadDir(fsys, dir)
+			if err != nil {
+				break
+			}
+			found := false
+			for _, e := range entries {
+				if strings.EqualFold(e.Name(), part) {
+					parts[i], found = e.Name(), true
+					break
+				}
+			}
+			if !found {
+				break
+			}
+		}
+		dir = filepath.Join(dir, parts[i])
+	}
+	return strings.Join(parts, string(filepath.Separator))
+}
@@ -0,0 +1,34 @@
+package ftp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveCase(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "Plugins", "EssentialsX"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "Plugins", "config.yml"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "server.jar"), nil, 0o644))
+
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"server.jar", "server.jar"},
+		{"SERVER.JAR", "server.jar"},
+		{"plugins/CONFIG.yml", "Plugins/config.yml"},
+		{"plugins/essentialsx/new.yml", "Plugins/EssentialsX/new.yml"},
+		{"plugins/missing/File.txt", "Plugins/missing/File.txt"},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			assert.Equal(t, c.out, resolveCase(NewLocalBackend(), root, c.in))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package ftp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// The extended attribute the SHA-256 checksum of an upload is stored in. The
+// value is the hex encoded checksum followed by the modification time of the
+// file, in nanoseconds, when the checksum was recorded.
+const checksumXattr = "user.pterodactyl.sha256"
+
+// ErrNoChecksum is returned when verifying a file that does not have a
+// checksum recorded for it.
+var ErrNoChecksum = errors.New("no checksum has been recorded for this file")
+
+// errNoXattr is returned when reading or removing an extended attribute that
+// is not set, or that the filesystem does not support.
+var errNoXattr = errors.New("ftp: extended attribute is not set")
+
+// ChecksumResult is the result of verifying a file against the checksum that
+// was recorded when it was uploaded.
+type ChecksumResult struct {
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	// Valid is true if the contents of the file match the recorded checksum.
+	Valid bool `json:"valid"`
+	// Modified is true if the file has been changed since it was uploaded,
+	// in which case a mismatch is expected rather than a sign of corruption.
+	Modified bool `json:"modified"`
+}
+
+// checksumFile is an open file that can be verified. Both *os.File and the
+// files returned by a server's filesystem satisfy this.
+type checksumFile interface {
+	io.Reader
+	Fd() uintptr
+	Stat() (fs.FileInfo, error)
+}
+
+// newUploadHash returns the hash used to checksum an upload, or nil if
+// checksums are disabled.
+func (driver *FTPDriver) newUploadHash() hash.Hash {
+	if !driver.cfg.Checksums {
+		return nil
+	}
+	return sha256.New()
+}
+
+// recordChecksum stores the checksum computed for an upload on the file once
+// it has been closed. If the checksum could not be computed, because the
+// upload did not start at the beginning of the file, any previously recorded
+// checksum is removed since it no longer matches.
+func recordChecksum(p string, h hash.Hash) {
+	if h == nil {
+		if err := lremoveXattr(p, checksumXattr); err != nil && !errors.Is(err, errNoXattr) {
+			log.WithField("file", p).WithField("error", err).Debug("ftp: failed to remove stale checksum")
+		}
+		return
+	}
+	st, err := os.Lstat(p)
+	if err != nil {
+		return
+	}
+	value := hex.EncodeToString(h.Sum(nil)) + " " + strconv.FormatInt(st.ModTime().UnixNano(), 10)
+	if err := lsetXattr(p, checksumXattr, []byte(value)); err != nil {
+		log.WithField("file", p).WithField("error", err).Warn("ftp: failed to record upload checksum")
+	}
+}
+
+// VerifyChecksum hashes the contents of the file and compares them against
+// the checksum recorded when it was uploaded over FTP.
+func VerifyChecksum(f checksumFile) (*ChecksumResult, error) {
+	buf := make([]byte, 128)
+	n, err := fgetXattr(f.Fd(), checksumXattr, buf)
+	if errors.Is(err, errNoXattr) {
+		return nil, ErrNoChecksum
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	expected, mtime, _ := strings.Cut(string(buf[:n]), " ")
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res := &ChecksumResult{Expected: expected, Actual: hex.EncodeToString(h.Sum(nil))}
+	res.Valid = res.Actual == res.Expected
+	res.Modified = mtime != strconv.FormatInt(st.ModTime().UnixNano(), 10)
+	return res, nil
+}
+
+// siteCheck handles "SITE CHECK <path>" which verifies a file against the
+// checksum recorded when it was uploaded.
+func (s *session) siteCheck(params string) (int, string) {
+	if params == "" {
+		return ftpserver.StatusSyntaxErrorParameters, "Usage: SITE CHECK <path>"
+	}
+	srv, err := s.driver.getServer()
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, err.Error()
+	}
+	realPath, err := s.driver.buildPath(srv, s.abs(params))
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, "Could not check file: " + err.Error()
+	}
+	f, err := os.Open(realPath)
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, "Could not check file: " + err.Error()
+	}
+	defer f.Close()
+
+	res, err := VerifyChecksum(f)
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, "Could not check file: " + err.Error()
+	}
+	switch {
+	case res.Valid:
+		return ftpserver.StatusOK, "OK SHA-256 " + res.Actual
+	case res.Modified:
+		return ftpserver.StatusOK, fmt.Sprintf("MODIFIED SHA-256 %s, was %s when uploaded", res.Actual, res.Expected)
+	default:
+		return ftpserver.StatusOK, fmt.Sprintf("MISMATCH SHA-256 %s, expected %s", res.Actual, res.Expected)
+	}
+}
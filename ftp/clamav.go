@@ -0,0 +1,148 @@
+package ftp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/models"
+	"github.com/pterodactyl/wings/server"
+)
+
+// The size of each chunk streamed to clamd. This is well below the default
+// StreamMaxLength of clamd so a single chunk is never rejected on its own.
+const clamdChunkSize = 64 * 1024
+
+// scanUpload scans the uploaded file at the given path and handles it if it is
+// flagged by clamd, returning an error so the client is told the upload was
+// rejected. A failure to scan the file is logged but does not reject it.
+func (driver *FTPDriver) scanUpload(s *server.Server, p string) error {
+	cfg := driver.cfg.ClamAV
+	realPath, err := driver.buildPath(s, p)
+	if err != nil {
+		return err
+	}
+	logger := driver.logger().WithField("path", relativePath(driver.serverPath(p)))
+
+	signature, err := clamdScan(cfg, driver.storage(), realPath)
+	if err != nil {
+		logger.WithField("error", err).Warn("ftp: failed to scan uploaded file")
+		return nil
+	}
+	if signature == "" {
+		return nil
+	}
+
+	logger = logger.WithField("signature", signature)
+	if cfg.Action == "delete" {
+		err = driver.storage().Remove(realPath)
+	} else {
+		err = quarantine(cfg, s, realPath)
+	}
+	if err != nil {
+		logger.WithField("error", err).Error("ftp: failed to remove flagged upload")
+	} else {
+		logger.Warn("ftp: removed upload flagged as malware")
+	}
+
+	if cfg.NotifyPanel {
+		s.SaveActivity(s.NewRequestActivity("", driver.ip), server.ActivityFtpMalwareDetected, models.ActivityMeta{
+			"file":      relativePath(driver.serverPath(p)),
+			"signature": signature,
+			"username":  driver.user,
+			"action":    cfg.Action,
+		})
+	}
+
+	return errors.Wrap(ftpserver.ErrFileNameNotAllowed, "file was flagged as malware ("+signature+")")
+}
+
+// quarantine moves a flagged file into the quarantine directory for the server.
+func quarantine(cfg config.FtpClamAVConfiguration, s *server.Server, realPath string) error {
+	dir := filepath.Join(cfg.QuarantineDirectory, s.ID())
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	dst := filepath.Join(dir, time.Now().UTC().Format(trashBatchFormat)+"-"+filepath.Base(realPath))
+	return os.Rename(realPath, dst)
+}
+
+// clamdScan streams the file at the given path to clamd using the INSTREAM
+// command, returning the name of the signature that matched, or an empty
+// string if the file is clean.
+func clamdScan(cfg config.FtpClamAVConfiguration, fsys Backend, p string) (string, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	network, addr := "unix", cfg.Socket
+	if a, ok := strings.CutPrefix(cfg.Socket, "tcp://"); ok {
+		network, addr = "tcp", a
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return "", errors.Wrap(err, "clamd: failed to connect")
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return "", err
+	}
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			_, _ = w.Write(size)
+			if _, err := w.Write(buf[:n]); err != nil {
+				return "", errors.Wrap(err, "clamd: failed to stream file")
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	_, _ = w.Write(size)
+	if err := w.Flush(); err != nil {
+		return "", errors.Wrap(err, "clamd: failed to stream file")
+	}
+
+	res, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", errors.Wrap(err, "clamd: failed to read response")
+	}
+	return parseClamdResponse(res)
+}
+
+// parseClamdResponse parses a reply from clamd such as "stream: OK" or
+// "stream: Eicar-Signature FOUND".
+func parseClamdResponse(res string) (string, error) {
+	res = strings.TrimSpace(strings.TrimRight(res, "\x00"))
+	_, result, _ := strings.Cut(res, ": ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", errors.New("clamd: unexpected response: " + res)
+	}
+}
@@ -0,0 +1,130 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// The software a client identifies itself with using CLNT, and the TLS
+// version and cipher of its control connection, are recorded for each session
+// so that outdated or unencrypted clients can be found and, if configured,
+// turned away.
+
+// The actions of the client policy.
+const (
+	clientPolicyAllow = "allow"
+	clientPolicyWarn  = "warn"
+	clientPolicyBlock = "block"
+)
+
+// TLSInfo describes the TLS connection of a session.
+type TLSInfo struct {
+	Version string `json:"version"`
+	Cipher  string `json:"cipher"`
+}
+
+// captureTLS returns a copy of the TLS configuration that records the TLS
+// version and cipher of each control connection once its handshake is done.
+// Data connections use the same configuration, but are not recorded since
+// they do not belong to a control connection.
+func (c *FTPServer) captureTLS(cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		return nil
+	}
+	out := cfg.Clone()
+	out.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		addr := hello.Conn.RemoteAddr().String()
+		conf := cfg.Clone()
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			if cc := c.sessions.Conn(addr); cc != nil {
+				cc.tls.Store(&TLSInfo{Version: tls.VersionName(cs.Version), Cipher: tls.CipherSuiteName(cs.CipherSuite)})
+			}
+			return nil
+		}
+		return conf, nil
+	}
+	return out
+}
+
+// tlsInfo returns the TLS connection of the control connection from the
+// address, or nil if it is not encrypted.
+func (ss *sessionStore) tlsInfo(addr string) *TLSInfo {
+	if c := ss.Conn(addr); c != nil {
+		return c.tls.Load()
+	}
+	return nil
+}
+
+var versionRegexp = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// olderThan reports whether the version is older than the minimum. Versions
+// are compared one dot separated number at a time.
+func olderThan(version, minimum string) bool {
+	a, b := strings.Split(version, "."), strings.Split(minimum, ".")
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x, _ = strconv.Atoi(a[i])
+		}
+		if i < len(b) {
+			y, _ = strconv.Atoi(b[i])
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+// outdatedClient returns the minimum version required of the client if the
+// version it identified itself with is older, matching the name it gave
+// against the configured minimum versions without regard to case.
+func outdatedClient(client string, minimums map[string]string) (string, bool) {
+	for name, minimum := range minimums {
+		rest, ok := strings.CutPrefix(strings.ToLower(client), strings.ToLower(name))
+		if !ok {
+			continue
+		}
+		if v := versionRegexp.FindString(rest); v != "" && olderThan(v, minimum) {
+			return minimum, true
+		}
+	}
+	return "", false
+}
+
+// clientPolicy applies the client policy to a login, returning an error if
+// the login should be rejected.
+func (d *FTPServerDriver) clientPolicy(cc ftpserver.ClientContext, username string) error {
+	policy := d.cfg.Clients
+	client := cc.GetClientVersion()
+	logger := log.WithFields(log.Fields{
+		"username": username,
+		"ip":       remoteIP(cc.RemoteAddr()),
+		"client":   client,
+	})
+	if minimum, ok := outdatedClient(client, policy.MinVersions); ok {
+		switch policy.OutdatedAction {
+		case clientPolicyBlock:
+			logger.WithField("min_version", minimum).Warn("FTP login rejected: client is outdated")
+			return errors.Errorf("your FTP client is outdated, version %s or newer is required", minimum)
+		case clientPolicyWarn:
+			logger.WithField("min_version", minimum).Warn("FTP login from outdated client")
+		}
+	}
+	if !cc.HasTLSForControl() && d.sessions.tlsInfo(cc.RemoteAddr().String()) == nil {
+		switch policy.PlainAction {
+		case clientPolicyBlock:
+			logger.Warn("FTP login rejected: connection is not encrypted")
+			return errors.New("logins without TLS are not allowed, connect with FTPS")
+		case clientPolicyWarn:
+			logger.Warn("FTP login without TLS")
+		}
+	}
+	return nil
+}
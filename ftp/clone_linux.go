@@ -0,0 +1,15 @@
+//go:build linux
+
+package ftp
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile makes dst a reflink of src, sharing its blocks until either is
+// written to, where the filesystem supports it.
+func cloneFile(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
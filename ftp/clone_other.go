@@ -0,0 +1,11 @@
+//go:build !linux
+
+package ftp
+
+import "os"
+
+// cloneFile returns an error, as reflinks are not made on this platform, so
+// the file is copied in full instead.
+func cloneFile(*os.File, *os.File) error {
+	return errUnsupported
+}
@@ -0,0 +1,552 @@
+package ftp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// ftpserverlib does not expose a way to register additional commands, so the
+// control connection is wrapped and any command that Wings handles itself is
+// answered here before the line ever reaches the library. Everything else is
+// passed through untouched.
+//
+// ftpserverlib upgrades the connection it is given when a client sends AUTH
+// TLS, which would leave the wrapper reading the encrypted stream. AUTH is
+// answered here instead and the handshake done beneath the wrapper, so that
+// ftpserverlib only ever sees the decrypted commands. As it then never knows
+// the control connection is encrypted, requiring TLS on a listener is
+// enforced here too.
+
+// controlListener wraps the FTP control listener so that every accepted
+// connection is intercepted by a controlConn.
+type controlListener struct {
+	net.Listener
+	sessions *sessionStore
+	// The driver of the listener, whose settings decide the extensions that
+	// are advertised.
+	driver *FTPServerDriver
+}
+
+func (l *controlListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	cc := &controlConn{Conn: c, r: bufio.NewReaderSize(c, maxControlLine), sessions: l.sessions, driver: l.driver}
+	l.sessions.AddConn(cc)
+	return cc, nil
+}
+
+// The longest control line that will be inspected. Anything longer is passed
+// straight through to ftpserverlib which will reject it.
+const maxControlLine = 4096
+
+// controlConn reads commands from the client one line at a time and answers
+// the ones registered by Wings directly.
+type controlConn struct {
+	net.Conn
+	r        *bufio.Reader
+	sessions *sessionStore
+	driver   *FTPServerDriver
+	pending  []byte
+	err      error
+	// partial is set while the remainder of an over-long line is being read.
+	partial bool
+	// The TLS connection the client upgraded to with AUTH TLS, which commands
+	// are read from and replies written to from then on.
+	secure atomic.Pointer[tls.Conn]
+	// Whether the client has asked for data connections to be encrypted with
+	// PROT P.
+	protected bool
+	// The facts selected with OPTS MLST, or nil if the defaults are in use.
+	facts []string
+	// The algorithm selected with OPTS HASH, or empty if the default is in
+	// use.
+	hashAlgorithm string
+	// The code the greeting is sent with if the connection is being rejected
+	// before the client has logged in.
+	rejectCode atomic.Int32
+	// The delay, in nanoseconds, before each reply is written to a client
+	// that is being tarpitted.
+	tarpit atomic.Int64
+	// The TLS connection, once the client has completed a handshake.
+	tls atomic.Pointer[TLSInfo]
+	// Closed once the SITE command running in the background has replied,
+	// or nil if none is. It is only used by the goroutine reading commands.
+	busy chan struct{}
+	// Closes the connection if the client does not log in in time.
+	loginTimer *time.Timer
+	closeOnce  sync.Once
+}
+
+func (c *controlConn) Close() error {
+	c.closeOnce.Do(func() {
+		if c.loginTimer != nil {
+			c.loginTimer.Stop()
+		}
+		c.sessions.RemoveConn(c)
+	})
+	return c.Conn.Close()
+}
+
+func (c *controlConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		line, err := c.r.ReadSlice('\n')
+		partial := c.partial
+		c.partial = errors.Is(err, bufio.ErrBufferFull)
+		if c.partial {
+			err = nil
+		}
+		c.err = err
+		if err == nil && !partial && !c.partial {
+			c.await(string(line))
+			if c.intercept(string(line)) {
+				continue
+			}
+		}
+		c.pending = append(c.pending[:0], line...)
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write rewrites the code of error replies sent by ftpserverlib where the
+// driver has asked for a more specific one.
+func (c *controlConn) Write(p []byte) (int, error) {
+	if d := c.tarpit.Load(); d > 0 {
+		time.Sleep(time.Duration(d))
+	}
+	n := len(p)
+	if code := c.rejectCode.Swap(0); code != 0 && len(p) > 3 {
+		p = append([]byte(strconv.Itoa(int(code))), p[3:]...)
+	}
+	if s := c.sessions.Get(c.RemoteAddr().String()); s != nil {
+		p = s.driver.rewriteReply(p)
+		s.driver.logReply(p)
+		if port := passivePort(p); port != 0 {
+			c.sessions.SetPassivePort(s, port)
+		}
+	}
+	if _, err := c.out().Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// out returns the connection replies are written to, which is the TLS
+// connection once the client has upgraded with AUTH TLS.
+func (c *controlConn) out() net.Conn {
+	if tc := c.secure.Load(); tc != nil {
+		return tc
+	}
+	return c.Conn
+}
+
+// tlsRequired reports whether the listener only allows encrypted connections
+// and transfers.
+func (c *controlConn) tlsRequired() bool {
+	return c.driver != nil && c.driver.tlsEnabled() && tlsRequirement(c.driver.settings) == ftpserver.MandatoryEncryption
+}
+
+// startTLS answers AUTH TLS and upgrades the connection, returning false if
+// ftpserverlib should answer it instead, which it does by refusing it when
+// the listener has no certificate. Listeners with implicit TLS are already
+// encrypted beneath the wrapper.
+func (c *controlConn) startTLS() bool {
+	if c.driver == nil || !c.driver.explicitTLS() {
+		return false
+	}
+	if c.secure.Load() != nil {
+		c.reply(ftpserver.StatusBadCommandSequence, "TLS is already in use")
+		return true
+	}
+	c.reply(ftpserver.StatusAuthAccepted, "AUTH command ok. Expecting TLS Negotiation.")
+	// The handshake is done on the first read, and reads any of it the client
+	// sent along with AUTH from what has already been buffered.
+	tc := tls.Server(&bufferedConn{Conn: c.Conn, r: c.r}, c.driver.tls)
+	c.r = bufio.NewReaderSize(tc, maxControlLine)
+	c.secure.Store(tc)
+	return true
+}
+
+// bufferedConn reads a connection through a reader that may have buffered
+// some of it already.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// intercept handles the given command line if it is one that Wings answers
+// itself, returning false if the line should be passed along to ftpserverlib.
+func (c *controlConn) intercept(line string) bool {
+	command, params := parseCommandLine(line)
+	switch command {
+	case "AUTH":
+		return c.startTLS()
+	case "NOOP":
+		// Clients send these to keep the connection open while waiting for
+		// a long SITE command, as they do during transfers.
+		if c.running() {
+			c.reply(ftpserver.StatusOK, "OK")
+			return true
+		}
+		return false
+	case "PROT":
+		// The level is set by ftpserverlib, it is only noted here so that
+		// transfers can be refused if TLS is required.
+		c.protected = strings.TrimSpace(params) == "P"
+		return false
+	}
+	if c.tlsRequired() {
+		switch command {
+		case "USER":
+			if c.secure.Load() == nil {
+				c.reply(ftpserver.StatusServiceNotAvailable, "TLS is required")
+				_ = c.Close()
+				return true
+			}
+		case "LIST", "NLST", "MLSD", "RETR", "STOR", "STOU", "APPE":
+			if !c.protected {
+				c.reply(ftpserver.StatusServiceNotAvailable, "unable to open transfer: TLS is required")
+				return true
+			}
+		}
+	}
+	if command == "FEAT" {
+		c.feat()
+		return true
+	}
+	if command == "OPTS" {
+		opt, args, _ := strings.Cut(params, " ")
+		switch strings.ToUpper(opt) {
+		case "MLST":
+			c.reply(c.selectFacts(args))
+			return true
+		case "UTF8":
+			if c.driver != nil && c.driver.cfg.Features.DisableUTF8 {
+				c.reply(502, "UTF8 is not supported")
+				return true
+			}
+		case "HASH":
+			// The selection is made by ftpserverlib, it is only noted here
+			// so that FEAT can mark it.
+			if name := strings.TrimSpace(args); slices.Contains(hashAlgorithms, name) {
+				c.hashAlgorithm = name
+			}
+		}
+		return false
+	}
+	s := c.sessions.Get(c.RemoteAddr().String())
+	if s == nil {
+		return false
+	}
+	switch command {
+	case "STAT":
+		// STAT with a path lists it, which is left to ftpserverlib.
+		if strings.TrimSpace(params) != "" {
+			return false
+		}
+		c.reply(s.status())
+		return true
+	case "MLST":
+		c.reply(s.mlst(strings.TrimSpace(params), c.selectedFacts()))
+		return true
+	case "MLSD":
+		dir := s.cc.Path()
+		if p := strings.TrimSpace(params); p != "" {
+			dir = s.abs(p)
+		}
+		s.listing.Store(&mlsdListing{dir: dir, facts: c.selectedFacts()})
+		return false
+	case "LIST", "NLST", "RETR", "STOR", "STOU", "APPE":
+		s.listing.Store(nil)
+		return false
+	case "SITE":
+		if c.driver != nil && c.driver.cfg.Features.DisableSite {
+			return false
+		}
+	default:
+		return false
+	}
+	sub, args, _ := strings.Cut(params, " ")
+	name := strings.ToUpper(sub)
+	cmd, ok := s.site(name)
+	if !ok {
+		return false
+	}
+	if err := cmd.allowed(s.driver); err != nil {
+		c.reply(ftpserver.StatusActionNotTaken, err.Error())
+		return true
+	}
+	args = strings.TrimSpace(args)
+	if cmd.long {
+		c.background(name, func() (int, string) { return cmd.run(s, args) })
+		return true
+	}
+	c.reply(cmd.run(s, args))
+	return true
+}
+
+// background runs a long SITE command without blocking the goroutine reading
+// commands, which ftpserverlib would otherwise disconnect once its idle
+// timeout passes. The client is sent a preliminary reply at once and the
+// final one when the command is done.
+func (c *controlConn) background(name string, run func() (int, string)) {
+	c.reply(ftpserver.StatusFileStatusOK, "SITE "+name+" started, the result follows when it is done")
+	// ftpserverlib set a deadline for the next command before reading this
+	// one, so it is lifted until the client can be expected to send one.
+	_ = c.Conn.SetReadDeadline(time.Time{})
+	done := make(chan struct{})
+	c.busy = done
+	go func() {
+		defer close(done)
+		c.reply(run())
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.idleTimeout()))
+	}()
+}
+
+// running reports whether a SITE command is running in the background.
+func (c *controlConn) running() bool {
+	if c.busy == nil {
+		return false
+	}
+	select {
+	case <-c.busy:
+		c.busy = nil
+		return false
+	default:
+		return true
+	}
+}
+
+// await holds back every command but NOOP until the SITE command running in
+// the background has replied, so that replies are sent in order.
+func (c *controlConn) await(line string) {
+	if command, _ := parseCommandLine(line); command == "NOOP" || !c.running() {
+		return
+	}
+	<-c.busy
+	c.busy = nil
+}
+
+// idleTimeout returns how long ftpserverlib waits for the next command.
+func (c *controlConn) idleTimeout() time.Duration {
+	if c.driver == nil || c.driver.cfg.IdleTimeout <= 0 {
+		return 900 * time.Second
+	}
+	return time.Duration(c.driver.cfg.IdleTimeout) * time.Second
+}
+
+// reply writes a response to the client, splitting multi-line messages in the
+// same way that ftpserverlib does.
+func (c *controlConn) reply(code int, message string) {
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	var b strings.Builder
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		fmt.Fprintf(&b, "%d%s%s\r\n", code, sep, line)
+	}
+	c.write(b.String())
+}
+
+// write writes raw reply lines to the client.
+func (c *controlConn) write(s string) {
+	// Long-running commands may have outlived the deadline that ftpserverlib
+	// set before reading the line, so give the reply a fresh one.
+	_ = c.Conn.SetWriteDeadline(time.Now().Add(time.Minute))
+	_, _ = c.out().Write([]byte(s))
+}
+
+// parseCommandLine splits a raw control line into its upper-cased command and
+// the remaining parameters.
+func parseCommandLine(line string) (string, string) {
+	line = strings.TrimRight(line, "\r\n")
+	command, params, _ := strings.Cut(line, " ")
+	return strings.ToUpper(command), params
+}
+
+// session ties an authenticated control connection to the driver that was
+// returned to ftpserverlib for it.
+type session struct {
+	// The ID the session is identified by in the API.
+	id      string
+	cc      ftpserver.ClientContext
+	driver  *FTPDriver
+	started time.Time
+	// The TLS connection the client logged in over, if it is encrypted.
+	tls *TLSInfo
+
+	// The source path given to the last SITE CPFR command.
+	copyFrom string
+	// The port of the last passive listener opened for the session.
+	pasvPort atomic.Int32
+	// The directory being listed by an MLSD command that has not yet been
+	// written to a data connection.
+	listing atomic.Pointer[mlsdListing]
+}
+
+// abs resolves a path given as a command parameter against the current
+// working directory of the session.
+func (s *session) abs(p string) string {
+	if strings.HasPrefix(p, "/") {
+		return p
+	}
+	return strings.TrimSuffix(s.cc.Path(), "/") + "/" + p
+}
+
+// sessionStore tracks the authenticated sessions, keyed by the remote address
+// of their control connection.
+type sessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*session
+	// Every open control connection, including those that have not logged in.
+	conns map[string]*controlConn
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]*session), conns: make(map[string]*controlConn)}
+}
+
+// AddConn records an open control connection.
+func (ss *sessionStore) AddConn(c *controlConn) {
+	ss.mu.Lock()
+	ss.conns[c.RemoteAddr().String()] = c
+	ss.mu.Unlock()
+}
+
+// RemoveConn forgets a control connection once it has been closed.
+func (ss *sessionStore) RemoveConn(c *controlConn) {
+	ss.mu.Lock()
+	if ss.conns[c.RemoteAddr().String()] == c {
+		delete(ss.conns, c.RemoteAddr().String())
+	}
+	ss.mu.Unlock()
+}
+
+// Conn returns the open control connection from the given remote address.
+func (ss *sessionStore) Conn(addr string) *controlConn {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.conns[addr]
+}
+
+// ConnCount returns the number of open control connections, and the number of
+// those from the given IP address.
+func (ss *sessionStore) ConnCount(ip string) (total int, fromIP int) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	for _, c := range ss.conns {
+		if remoteIP(c.RemoteAddr()) == ip {
+			fromIP++
+		}
+	}
+	return len(ss.conns), fromIP
+}
+
+// connsFrom returns the open control connections from the given IP address.
+func (ss *sessionStore) connsFrom(ip string) []*controlConn {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	var conns []*controlConn
+	for _, c := range ss.conns {
+		if remoteIP(c.RemoteAddr()) == ip {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+func (ss *sessionStore) Get(addr string) *session {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.sessions[addr]
+}
+
+// SetPassivePort records the port of the passive listener opened for the
+// session. Ports are reused once a transfer is complete, so any other session
+// that last used the same port is cleared.
+func (ss *sessionStore) SetPassivePort(s *session, port int) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	for _, o := range ss.sessions {
+		if o != s {
+			o.pasvPort.CompareAndSwap(int32(port), 0)
+		}
+	}
+	s.pasvPort.Store(int32(port))
+}
+
+// ByPassivePort returns the session that the passive listener on the given
+// port was opened for.
+func (ss *sessionStore) ByPassivePort(port int) *session {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	for _, s := range ss.sessions {
+		if int(s.pasvPort.Load()) == port {
+			return s
+		}
+	}
+	return nil
+}
+
+// Len returns the number of sessions.
+func (ss *sessionStore) Len() int {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return len(ss.sessions)
+}
+
+func (ss *sessionStore) Put(addr string, s *session) {
+	ss.mu.Lock()
+	ss.sessions[addr] = s
+	ss.mu.Unlock()
+}
+
+// PutLimited stores the session unless there are already limit sessions for
+// the same server, in which case false is returned. A limit of 0 or less is
+// treated as no limit.
+func (ss *sessionStore) PutLimited(addr string, s *session, limit int) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if limit > 0 {
+		n := 0
+		for a, o := range ss.sessions {
+			if a != addr && o.driver.server.ID() == s.driver.server.ID() {
+				n++
+			}
+		}
+		if n >= limit {
+			return false
+		}
+	}
+	ss.sessions[addr] = s
+	return true
+}
+
+func (ss *sessionStore) Delete(addr string) {
+	ss.mu.Lock()
+	delete(ss.sessions, addr)
+	ss.mu.Unlock()
+}
@@ -0,0 +1,398 @@
+package ftp
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func newTestControlConn(t *testing.T, authenticated bool) (*controlConn, net.Conn) {
+	client, srv := net.Pipe()
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = srv.Close()
+	})
+
+	sessions := newSessionStore()
+	if authenticated {
+		sessions.Put(srv.RemoteAddr().String(), &session{driver: &FTPDriver{}})
+	}
+	return &controlConn{Conn: srv, r: bufio.NewReaderSize(srv, maxControlLine), sessions: sessions}, client
+}
+
+func TestControlConn_Intercept(t *testing.T) {
+	siteCommands["TESTING"] = siteCommand{run: func(_ *session, params string) (int, string) {
+		return 200, "params: " + params
+	}}
+	t.Cleanup(func() { delete(siteCommands, "TESTING") })
+
+	t.Run("answers registered SITE commands", func(t *testing.T) {
+		c, client := newTestControlConn(t, true)
+
+		go func() { _, _ = client.Write([]byte("site testing a b\r\nNOOP\r\n")) }()
+
+		replies := make(chan string, 1)
+		go func() {
+			line, _ := bufio.NewReader(client).ReadString('\n')
+			replies <- line
+		}()
+
+		line, err := bufio.NewReader(c).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "NOOP\r\n", line)
+		assert.Equal(t, "200 params: a b\r\n", <-replies)
+	})
+
+	t.Run("passes through unknown SITE commands", func(t *testing.T) {
+		c, client := newTestControlConn(t, true)
+
+		go func() { _, _ = client.Write([]byte("SITE CHMOD 755 file\r\n")) }()
+
+		line, err := bufio.NewReader(c).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "SITE CHMOD 755 file\r\n", line)
+	})
+
+	t.Run("passes through commands from unauthenticated clients", func(t *testing.T) {
+		c, client := newTestControlConn(t, false)
+
+		go func() {
+			_, _ = client.Write([]byte("SITE TESTING\r\n"))
+			_ = client.Close()
+		}()
+
+		b, err := io.ReadAll(c)
+		require.NoError(t, err)
+		assert.Equal(t, "SITE TESTING\r\n", string(b))
+	})
+}
+
+func TestControlConn_Write(t *testing.T) {
+	t.Run("rewrites the next error reply with the noted code", func(t *testing.T) {
+		c, client := newTestControlConn(t, true)
+		s := c.sessions.Get(c.RemoteAddr().String())
+		_ = s.driver.noteReply(withReplyCode(450, errFileBusy))
+
+		go func() {
+			_, _ = c.Write([]byte("550 Could not access file: file busy\r\n"))
+			_, _ = c.Write([]byte("550 Could not access file\r\n"))
+		}()
+
+		r := bufio.NewReader(client)
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "450 Could not access file: file busy\r\n", line)
+
+		line, err = r.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "550 Could not access file\r\n", line)
+	})
+
+	t.Run("adds the noted suffix to the next successful reply", func(t *testing.T) {
+		c, client := newTestControlConn(t, true)
+		s := c.sessions.Get(c.RemoteAddr().String())
+		s.driver.noteReplySuffix("(job abc)")
+
+		go func() {
+			_, _ = c.Write([]byte("250 Deleted dir /world\r\n"))
+			_, _ = c.Write([]byte("250 Deleted dir /logs\r\n"))
+		}()
+
+		r := bufio.NewReader(client)
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "250 Deleted dir /world (job abc)\r\n", line)
+
+		line, err = r.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "250 Deleted dir /logs\r\n", line)
+	})
+}
+
+func TestControlConn_OptsMlst(t *testing.T) {
+	c, client := newTestControlConn(t, false)
+
+	go func() { _, _ = client.Write([]byte("OPTS MLST size;unknown;PERM;\r\nNOOP\r\n")) }()
+
+	replies := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(client).ReadString('\n')
+		replies <- line
+	}()
+
+	line, err := bufio.NewReader(c).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "NOOP\r\n", line)
+	assert.Equal(t, "200 MLST OPTS size;perm;\r\n", <-replies)
+	assert.Equal(t, "type;size*;modify;create;perm*;unique;", formatFactList(c.selectedFacts(), true))
+}
+
+func TestControlConn_Feat(t *testing.T) {
+	feat := func(t *testing.T, d *FTPServerDriver, lines string) string {
+		c, client := newTestControlConn(t, false)
+		c.driver = d
+		go func() { _, _ = client.Write([]byte(lines + "FEAT\r\nNOOP\r\n")) }()
+
+		replies := make(chan string, 1)
+		go func() {
+			r := bufio.NewReader(client)
+			var b strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				b.WriteString(line)
+				if err != nil || strings.HasPrefix(line, "211 ") {
+					break
+				}
+			}
+			replies <- b.String()
+		}()
+
+		r := bufio.NewReader(c)
+		for {
+			line, err := r.ReadString('\n')
+			require.NoError(t, err)
+			if line == "NOOP\r\n" {
+				return <-replies
+			}
+		}
+	}
+
+	t.Run("lists only what is enabled", func(t *testing.T) {
+		reply := feat(t, &FTPServerDriver{}, "")
+		assert.True(t, strings.HasPrefix(reply, "211-Extensions supported:\r\n"))
+		assert.Contains(t, reply, " UTF8\r\n")
+		assert.Contains(t, reply, " MLST type*;size*;modify*;")
+		assert.Contains(t, reply, " SITE CPFR\r\n")
+		assert.NotContains(t, reply, "AUTH TLS")
+		assert.NotContains(t, reply, "HASH")
+		assert.NotContains(t, reply, "MODE Z")
+		assert.True(t, strings.HasSuffix(reply, "211 End\r\n"))
+	})
+
+	t.Run("follows the configuration", func(t *testing.T) {
+		d := &FTPServerDriver{tls: &tls.Config{}}
+		d.settings.TLS.Mode = "implicit"
+		d.cfg.Features = config.FtpFeaturesConfiguration{Hash: true, DisableSite: true, DisableUTF8: true}
+		reply := feat(t, d, "OPTS HASH MD5\r\n")
+		assert.NotContains(t, reply, "AUTH TLS")
+		assert.Contains(t, reply, " PBSZ\r\n PROT\r\n")
+		assert.Contains(t, reply, " HASH SHA-256;SHA-512;SHA-1;MD5*;CRC32;\r\n")
+		assert.NotContains(t, reply, "UTF8")
+		assert.NotContains(t, reply, "SITE")
+	})
+}
+
+func TestControlConn_LongSite(t *testing.T) {
+	release := make(chan struct{})
+	siteCommands["TESTING"] = siteCommand{long: true, run: func(_ *session, params string) (int, string) {
+		<-release
+		return 250, "done"
+	}}
+	t.Cleanup(func() { delete(siteCommands, "TESTING") })
+
+	c, client := newTestControlConn(t, true)
+	go func() { _, _ = client.Write([]byte("SITE TESTING\r\nNOOP\r\nPWD\r\n")) }()
+
+	replies := make(chan string, 3)
+	go func() {
+		r := bufio.NewReader(client)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			replies <- line
+		}
+	}()
+
+	// ftpserverlib sets a deadline for the next command before each read.
+	require.NoError(t, c.Conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	lines := make(chan string, 1)
+	go func() {
+		line, err := bufio.NewReader(c).ReadString('\n')
+		assert.NoError(t, err)
+		lines <- line
+	}()
+
+	next := func(ch chan string) string {
+		select {
+		case line := <-ch:
+			return line
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a line")
+			return ""
+		}
+	}
+	assert.Equal(t, "150 SITE TESTING started, the result follows when it is done\r\n", next(replies))
+	assert.Equal(t, "200 OK\r\n", next(replies))
+
+	// The deadline passes while the command runs, and the next command is
+	// held back until it has replied.
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case line := <-lines:
+		t.Fatalf("read %q before the command replied", line)
+	default:
+	}
+	close(release)
+
+	assert.Equal(t, "250 done\r\n", next(replies))
+	assert.Equal(t, "PWD\r\n", next(lines))
+}
+
+func TestPassivePort(t *testing.T) {
+	assert.Equal(t, 40001, passivePort([]byte("227 Entering Passive Mode (127,0,0,1,156,65)\r\n")))
+	assert.Equal(t, 40001, passivePort([]byte("229 Entering Extended Passive Mode (|||40001|)\r\n")))
+	assert.Equal(t, 0, passivePort([]byte("200 OK\r\n")))
+}
+
+func TestControlConn_KeepAlive(t *testing.T) {
+	t.Run("sends a Telnet NOP until stopped", func(t *testing.T) {
+		c, client := newTestControlConn(t, true)
+		stop := c.keepAlive(10 * time.Millisecond)
+
+		b := make([]byte, 2)
+		_, err := io.ReadFull(client, b)
+		require.NoError(t, err)
+		assert.Equal(t, telnetNOP, b)
+
+		stop()
+		stop()
+		// Drain a NOP that may have been sent while stopping.
+		_ = client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		_, _ = io.ReadFull(client, b)
+		_, err = client.Read(b)
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+
+	t.Run("sends it encrypted once upgraded", func(t *testing.T) {
+		c, client := newTestControlConn(t, true)
+		c.driver = &FTPServerDriver{tls: testTLSConfig(t)}
+		go func() { _, _ = c.Read(make([]byte, 1)) }()
+
+		tc := startTestTLS(t, client)
+		stop := c.keepAlive(10 * time.Millisecond)
+		defer stop()
+
+		b := make([]byte, 2)
+		_, err := io.ReadFull(tc, b)
+		require.NoError(t, err)
+		assert.Equal(t, telnetNOP, b)
+	})
+}
+
+// testTLSConfig returns a TLS configuration with a self-signed certificate.
+func testTLSConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wings"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// startTestTLS upgrades the client end of a control connection with AUTH TLS.
+func startTestTLS(t *testing.T, client net.Conn) *tls.Conn {
+	go func() { _, _ = client.Write([]byte("AUTH TLS\r\n")) }()
+	r := bufio.NewReader(client)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "234 AUTH command ok. Expecting TLS Negotiation.\r\n", line)
+	require.Zero(t, r.Buffered())
+	tc := tls.Client(client, &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, tc.Handshake())
+	return tc
+}
+
+func TestControlConn_AuthTLS(t *testing.T) {
+	siteCommands["TESTING"] = siteCommand{run: func(_ *session, params string) (int, string) {
+		return 200, "params: " + params
+	}}
+	t.Cleanup(func() { delete(siteCommands, "TESTING") })
+
+	t.Run("keeps intercepting commands once upgraded", func(t *testing.T) {
+		c, client := newTestControlConn(t, true)
+		c.driver = &FTPServerDriver{tls: testTLSConfig(t)}
+		lines := make(chan string, 1)
+		go func() {
+			line, _ := bufio.NewReader(c).ReadString('\n')
+			lines <- line
+		}()
+
+		tc := startTestTLS(t, client)
+		go func() { _, _ = tc.Write([]byte("SITE TESTING a\r\nNOOP\r\n")) }()
+		reply, err := bufio.NewReader(tc).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "200 params: a\r\n", reply)
+		assert.Equal(t, "NOOP\r\n", <-lines)
+	})
+
+	t.Run("is left to ftpserverlib without a certificate", func(t *testing.T) {
+		c, client := newTestControlConn(t, false)
+		c.driver = &FTPServerDriver{}
+		go func() { _, _ = client.Write([]byte("AUTH TLS\r\nFEAT\r\n")) }()
+
+		line, err := bufio.NewReader(c).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "AUTH TLS\r\n", line)
+		assert.Nil(t, c.secure.Load())
+	})
+
+	t.Run("is required when the listener requires it", func(t *testing.T) {
+		c, client := newTestControlConn(t, false)
+		c.driver = &FTPServerDriver{tls: testTLSConfig(t)}
+		c.driver.settings.TLS.Mode = "required"
+		go func() { _, _ = io.ReadAll(c) }()
+
+		go func() { _, _ = client.Write([]byte("USER alice\r\n")) }()
+		line, err := bufio.NewReader(client).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "421 TLS is required\r\n", line)
+	})
+
+	t.Run("requires encrypted transfers when the listener requires TLS", func(t *testing.T) {
+		c, client := newTestControlConn(t, true)
+		c.driver = &FTPServerDriver{tls: testTLSConfig(t)}
+		c.driver.settings.TLS.Mode = "required"
+		lines := make(chan string, 2)
+		go func() {
+			r := bufio.NewReader(c)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				lines <- line
+			}
+		}()
+
+		tc := startTestTLS(t, client)
+		go func() { _, _ = tc.Write([]byte("RETR server.jar\r\nPROT P\r\nRETR server.jar\r\n")) }()
+		reply, err := bufio.NewReader(tc).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "421 unable to open transfer: TLS is required\r\n", reply)
+		assert.Equal(t, "PROT P\r\n", <-lines)
+		assert.Equal(t, "RETR server.jar\r\n", <-lines)
+	})
+}
@@ -0,0 +1,230 @@
+package ftp
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+// siteCopyFrom handles "SITE CPFR" which marks the source of a server-side copy.
+func (s *session) siteCopyFrom(params string) (int, string) {
+	if params == "" {
+		return ftpserver.StatusSyntaxErrorParameters, "Missing source path"
+	}
+	if _, err := s.driver.Stat(s.abs(params)); err != nil {
+		s.copyFrom = ""
+		return ftpserver.StatusActionNotTaken, "Could not access source: " + err.Error()
+	}
+	s.copyFrom = s.abs(params)
+	return ftpserver.StatusFileActionPending, "Source exists, ready for destination name"
+}
+
+// siteCopyTo handles "SITE CPTO" which copies the source given to a previous
+// "SITE CPFR" to the destination path.
+func (s *session) siteCopyTo(params string) (int, string) {
+	from := s.copyFrom
+	s.copyFrom = ""
+	if from == "" {
+		return ftpserver.StatusBadCommandSequence, "Use SITE CPFR before SITE CPTO"
+	}
+	if params == "" {
+		return ftpserver.StatusSyntaxErrorParameters, "Missing destination path"
+	}
+	if err := s.driver.Copy(from, s.abs(params)); err != nil {
+		if errors.Is(err, ftpserver.ErrStorageExceeded) {
+			if srv, serr := s.driver.getServer(); serr == nil {
+				s.driver.notifyQuota(srv, err)
+			}
+			return ftpserver.StatusActionAborted, "Could not copy: " + err.Error()
+		}
+		if errors.Is(err, ftpserver.ErrFileNameNotAllowed) {
+			return ftpserver.StatusActionNotTakenNoFile, "Could not copy: " + err.Error()
+		}
+		return ftpserver.StatusActionNotTaken, "Could not copy: " + err.Error()
+	}
+	return ftpserver.StatusFileOK, "Copy successful"
+}
+
+// Copy copies a file or directory to a new location within the server's data
+// directory. Symlinks are skipped rather than followed, and the copy is only
+// started if the server has enough disk space available for all of it.
+func (driver *FTPDriver) Copy(fromPath, toPath string) error {
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeRead); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeWrite); err != nil {
+		return err
+	}
+
+	s, err := driver.getServer()
+	if err != nil {
+		return err
+	}
+
+	from, err := driver.buildPath(s, fromPath)
+	if err != nil {
+		return err
+	}
+	to, err := driver.buildPath(s, toPath)
+	if err != nil {
+		return err
+	}
+
+	fsys := driver.storage()
+	info, err := fsys.Lstat(from)
+	if err != nil {
+		return err
+	}
+	if _, err := fsys.Lstat(to); err == nil {
+		if info.IsDir() {
+			return errors.New("destination already exists")
+		}
+		// Copying over an existing file loses its contents just like
+		// deleting it.
+		if err := driver.checkScope(ScopeDelete); err != nil {
+			return err
+		}
+	}
+	if to == from || strings.HasPrefix(to, from+string(filepath.Separator)) {
+		return errors.New("cannot copy a directory into itself")
+	}
+	if err := driver.checkProtected(toPath, info.IsDir()); err != nil {
+		return err
+	}
+	if err := driver.checkNewPath(toPath); err != nil {
+		return err
+	}
+
+	// Every file copied is checked against the upload rules for the server,
+	// under the name it is given at the destination.
+	var size int64
+	err = walkTree(fsys, from, func(p string, info os.FileInfo) error {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		size += info.Size()
+		rel, err := filepath.Rel(from, p)
+		if err != nil {
+			return err
+		}
+		return driver.checkUploadName(s, path.Join(toPath, filepath.ToSlash(rel)))
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.Filesystem().HasSpaceFor(size); err != nil {
+		return errors.WithMessage(ftpserver.ErrStorageExceeded, "not enough disk space available for copy")
+	}
+
+	// Anything already at the destination is overwritten, so only the change
+	// in size is added to the disk usage of the server.
+	before, _ := copySize(fsys, to)
+	err = copyTree(s, fsys, from, to, func(rel string) (func(), error) {
+		return driver.lockWrite(s.ID(), driver.serverPath(path.Join(toPath, filepath.ToSlash(rel))))
+	})
+	driver.listings.invalidate(to)
+	if after, serr := copySize(fsys, to); serr == nil {
+		s.Filesystem().AddDiskUsage(after - before)
+	}
+	return err
+}
+
+// copySize returns the total size of the regular files at or beneath the path.
+func copySize(fsys Backend, root string) (int64, error) {
+	var size int64
+	err := walkTree(fsys, root, func(_ string, info os.FileInfo) error {
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// copyTree recursively copies the directories and regular files from one real
+// path to another. Each file is locked for writing with lock, given its path
+// relative to the destination, while it is copied.
+func copyTree(s *server.Server, fsys Backend, from, to string, lock func(rel string) (func(), error)) error {
+	return walkTree(fsys, from, func(p string, info os.FileInfo) error {
+		rel, err := filepath.Rel(from, p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(to, rel)
+		switch {
+		case info.IsDir():
+			return fsys.MkdirAll(dst, 0o755)
+		case info.Mode().IsRegular():
+			unlock, err := lock(rel)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return copyFile(fsys, p, dst)
+		default:
+			s.Log().WithField("path", p).Debug("ftp: skipping non-regular file during copy")
+			return nil
+		}
+	})
+}
+
+// walkTree calls fn for the path and everything beneath it, each directory
+// before its entries. Symbolic links are passed to fn rather than followed.
+func walkTree(fsys Backend, p string, fn func(p string, info os.FileInfo) error) error {
+	info, err := fsys.Lstat(p)
+	if err != nil {
+		return err
+	}
+	return walkEntry(fsys, p, info, fn)
+}
+
+func walkEntry(fsys Backend, p string, info os.FileInfo, fn func(p string, info os.FileInfo) error) error {
+	if err := fn(p, info); err != nil || !info.IsDir() {
+		return err
+	}
+	d, err := fsys.Open(p)
+	if err != nil {
+		return err
+	}
+	entries, err := d.Readdir(-1)
+	_ = d.Close()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := walkEntry(fsys, filepath.Join(p, entry.Name()), entry, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(fsys Backend, from, to string) error {
+	src, err := fsys.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := fsys.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	dst, err := fsys.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := copyBuffer(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
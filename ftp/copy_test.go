@@ -0,0 +1,71 @@
+package ftp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+// newServerDriver returns a driver for a server with its data in a temporary
+// directory, along with that directory.
+func newServerDriver(t *testing.T) (*FTPDriver, string) {
+	base := t.TempDir()
+	cfg := &config.Configuration{AuthenticationToken: "test"}
+	cfg.System.Data = base
+	config.Set(cfg)
+
+	s, err := server.NewEmptyManager(nil).InitServer(remote.ServerConfigurationResponse{
+		Settings: json.RawMessage(`{"uuid":"` + testServerID + `"}`),
+	})
+	require.NoError(t, err)
+	return &FTPDriver{BasePath: base, server: s, locks: newWriteLocks()}, filepath.Join(base, testServerID)
+}
+
+func TestCopy(t *testing.T) {
+	t.Run("applies the upload rules to the destination", func(t *testing.T) {
+		driver, root := newServerDriver(t)
+		driver.cfg.Uploads.BlockedExtensions = []string{"php"}
+		require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("<?php"), 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "site"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "site/index.php"), []byte("<?php"), 0o644))
+
+		assert.ErrorIs(t, driver.Copy("/a.txt", "/a.php"), ftpserver.ErrFileNameNotAllowed)
+		assert.NoFileExists(t, filepath.Join(root, "a.php"))
+		assert.ErrorIs(t, driver.Copy("/site", "/copy"), ftpserver.ErrFileNameNotAllowed)
+		assert.NoDirExists(t, filepath.Join(root, "copy"))
+		assert.NoError(t, driver.Copy("/a.txt", "/b.txt"))
+	})
+
+	t.Run("overwriting a file needs the delete scope", func(t *testing.T) {
+		driver, root := newServerDriver(t)
+		driver.scopes = []string{ScopeRead, ScopeWrite}
+		require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("new"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), []byte("old"), 0o644))
+
+		assert.Error(t, driver.Copy("/a.txt", "/b.txt"))
+		b, err := os.ReadFile(filepath.Join(root, "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "old", string(b))
+		assert.NoError(t, driver.Copy("/a.txt", "/c.txt"))
+	})
+
+	t.Run("does not write to a file being uploaded", func(t *testing.T) {
+		driver, root := newServerDriver(t)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("new"), 0o644))
+		unlock, err := driver.lockWrite(testServerID, "/b.txt")
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, driver.Copy("/a.txt", "/b.txt"), errFileBusy)
+		unlock()
+		assert.NoError(t, driver.Copy("/a.txt", "/b.txt"))
+	})
+}
@@ -0,0 +1,317 @@
+package ftp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// The FTP accounts stored on the node are kept in the credentials directory,
+// with a file for each of their password, the directory they are jailed to,
+// their settings, and the addresses they have logged in from, all named
+// after the account. Every read and write of these files goes through a
+// CredentialStore.
+//
+// The files can be encrypted at rest with AES-256-GCM, using a key derived
+// from the token of the node or from a key file of its own. Encrypted files
+// start with a marker, so the store reads both encrypted files and plain ones
+// written before encryption was turned on, and migrates them when the FTP
+// server starts. The name of each file is authenticated along with it, so an
+// encrypted file cannot be copied over the file of another account.
+
+// The extensions of the files kept for each account.
+const (
+	credentialPassword = ".txt"
+	credentialRoot     = ".root"
+	credentialMeta     = ".json"
+	credentialIPs      = ".ips"
+)
+
+// The marker encrypted files start with, followed by the nonce and the
+// sealed contents.
+var encryptedCredentialMagic = []byte("WINGSENC1\x00")
+
+// The context the key is derived with, so that it differs from any other key
+// derived from the same secret.
+const credentialKeyInfo = "pterodactyl wings ftp credentials"
+
+// CredentialStore reads and writes the files of the FTP accounts in a
+// directory.
+type CredentialStore struct {
+	dir string
+	// Whether files are encrypted when they are written.
+	encrypt bool
+	// The secret the key is derived from, or the file it is read from.
+	secret  string
+	keyFile string
+}
+
+// NewCredentialStore returns a store of the accounts in the directory, which
+// does not encrypt them.
+func NewCredentialStore(dir string) CredentialStore {
+	return CredentialStore{dir: dir}
+}
+
+// Credentials returns the store of the accounts in the configured
+// credentials directory.
+func Credentials() CredentialStore {
+	cfg := config.Get()
+	ftpCfg := cfg.System.Ftp
+	dir := ftpCfg.CredentialsPath
+	if dir == "" {
+		dir = DefaultCredentialsPath
+	}
+	return CredentialStore{
+		dir:     dir,
+		encrypt: ftpCfg.CredentialEncryption.Enabled,
+		secret:  cfg.AuthenticationToken,
+		keyFile: ftpCfg.CredentialEncryption.KeyFile,
+	}
+}
+
+// Dir returns the directory the store keeps its files in.
+func (cs CredentialStore) Dir() string {
+	return cs.dir
+}
+
+// path returns the path of the file of the account with the extension.
+func (cs CredentialStore) path(username, ext string) string {
+	return filepath.Join(cs.dir, username+ext)
+}
+
+// read returns the contents of the file of the account, decrypting it if it
+// is encrypted.
+func (cs CredentialStore) read(username, ext string) ([]byte, error) {
+	data, err := os.ReadFile(cs.path(username, ext))
+	if err != nil || !bytes.HasPrefix(data, encryptedCredentialMagic) {
+		return data, err
+	}
+	return cs.open(username+ext, data)
+}
+
+// encode returns the contents to write to the file of the account, which are
+// encrypted if the store encrypts files.
+func (cs CredentialStore) encode(username, ext string, data []byte) ([]byte, error) {
+	if !cs.encrypt {
+		return data, nil
+	}
+	return cs.seal(username+ext, data)
+}
+
+// stat returns information about the file of the account.
+func (cs CredentialStore) stat(username, ext string) (os.FileInfo, error) {
+	return os.Stat(cs.path(username, ext))
+}
+
+// write replaces the file of the account. The contents are written to a
+// temporary file first so that a login racing the write never reads a
+// partially written one.
+func (cs CredentialStore) write(username, ext string, data []byte) error {
+	if err := os.MkdirAll(cs.dir, 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	data, err := cs.encode(username, ext, data)
+	if err != nil {
+		return err
+	}
+	p := cs.path(username, ext)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// create writes the file of the account, returning an error satisfying
+// os.IsExist if it already exists.
+func (cs CredentialStore) create(username, ext string, data []byte) error {
+	data, err := cs.encode(username, ext, data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cs.dir, 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(cs.path(username, ext), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return errors.WithStack(err)
+}
+
+// remove deletes the file of the account.
+func (cs CredentialStore) remove(username, ext string) error {
+	return os.Remove(cs.path(username, ext))
+}
+
+// usernames returns the names of the accounts with a password in the store.
+func (cs CredentialStore) usernames() ([]string, error) {
+	entries, err := os.ReadDir(cs.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), credentialPassword); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// key returns the key files are encrypted with.
+func (cs CredentialStore) key() ([]byte, error) {
+	secret := []byte(cs.secret)
+	if cs.keyFile != "" {
+		b, err := os.ReadFile(cs.keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "ftp: failed to read credentials key file")
+		}
+		secret = bytes.TrimSpace(b)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("ftp: no secret to derive the credentials key from")
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(credentialKeyInfo)), key); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return key, nil
+}
+
+// aead returns the cipher files are encrypted with.
+func (cs CredentialStore) aead() (cipher.AEAD, error) {
+	key, err := cs.key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	return gcm, errors.WithStack(err)
+}
+
+// seal encrypts the contents of the file with the name.
+func (cs CredentialStore) seal(name string, data []byte) ([]byte, error) {
+	gcm, err := cs.aead()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(encryptedCredentialMagic)+gcm.NonceSize(), len(encryptedCredentialMagic)+gcm.NonceSize()+len(data)+gcm.Overhead())
+	copy(out, encryptedCredentialMagic)
+	nonce := out[len(encryptedCredentialMagic):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return gcm.Seal(out, nonce, data, []byte(name)), nil
+}
+
+// open decrypts the contents of the file with the name.
+func (cs CredentialStore) open(name string, data []byte) ([]byte, error) {
+	gcm, err := cs.aead()
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(encryptedCredentialMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ftp: encrypted credentials file is truncated")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "ftp: failed to decrypt %s", name)
+	}
+	return plain, nil
+}
+
+// migrate encrypts the files of the accounts that are not encrypted if the
+// store encrypts files, or decrypts those that are if it does not, returning
+// the number of files rewritten.
+func (cs CredentialStore) migrate() (int, error) {
+	entries, err := os.ReadDir(cs.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.WithStack(err)
+	}
+	var n int
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		switch ext {
+		case credentialPassword, credentialRoot, credentialMeta, credentialIPs:
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		username := strings.TrimSuffix(entry.Name(), ext)
+		info, err := entry.Info()
+		if err != nil {
+			return n, errors.WithStack(err)
+		}
+		raw, err := os.ReadFile(cs.path(username, ext))
+		if err != nil {
+			return n, errors.WithStack(err)
+		}
+		if bytes.HasPrefix(raw, encryptedCredentialMagic) == cs.encrypt {
+			continue
+		}
+		data, err := cs.read(username, ext)
+		if err != nil {
+			return n, err
+		}
+		if err := cs.write(username, ext, data); err != nil {
+			return n, err
+		}
+		// Accounts without settings are taken to have been created when
+		// their password was written.
+		if err := os.Chtimes(cs.path(username, ext), info.ModTime(), info.ModTime()); err != nil {
+			return n, errors.WithStack(err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// migrateCredentials encrypts or decrypts the files of the accounts to match
+// the configuration.
+func migrateCredentials() {
+	store := Credentials()
+	n, err := store.migrate()
+	if err != nil {
+		log.WithFields(log.Fields{"error": err, "dir": store.Dir()}).Error("failed to migrate FTP credentials")
+	}
+	if n > 0 {
+		action := "decrypted"
+		if store.encrypt {
+			action = "encrypted"
+		}
+		log.WithFields(log.Fields{"dir": store.Dir(), "files": n}).Info("FTP credentials " + action)
+	}
+}
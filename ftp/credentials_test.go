@@ -0,0 +1,140 @@
+package ftp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestCredentialsPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "credentials")
+	config.Set(&config.Configuration{
+		AuthenticationToken: "test",
+		System:              config.SystemConfiguration{Ftp: config.FtpConfiguration{CredentialsPath: dir}},
+	})
+
+	password, err := CreateAccount("alice_8f2a1c3e", AccountOptions{Root: "/plugins"})
+	require.NoError(t, err)
+	_, err = CreateAccount("alice_8f2a1c3e", AccountOptions{})
+	assert.ErrorIs(t, err, ErrAccountExists)
+
+	_, err = os.Stat(filepath.Join(dir, "alice_8f2a1c3e.txt"))
+	require.NoError(t, err)
+	assert.True(t, verifyPassword("alice_8f2a1c3e", password))
+	root, err := accountRoot("alice_8f2a1c3e")
+	require.NoError(t, err)
+	assert.Equal(t, "plugins", root)
+
+	assert.False(t, rememberIP("alice_8f2a1c3e", "203.0.113.7"))
+	assert.True(t, rememberIP("alice_8f2a1c3e", "203.0.113.8"))
+	assert.False(t, rememberIP("alice_8f2a1c3e", "203.0.113.7"))
+
+	require.NoError(t, SetPassword("alice_8f2a1c3e", "Creeper7Farm"))
+	assert.True(t, verifyPassword("alice_8f2a1c3e", "Creeper7Farm"))
+
+	accounts, err := Accounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "8f2a1c3e", accounts[0].Server)
+
+	require.NoError(t, DeleteAccount("alice_8f2a1c3e"))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCredentialEncryption(t *testing.T) {
+	dir := t.TempDir()
+	plain := NewCredentialStore(dir)
+	require.NoError(t, plain.write("alice_8f2a1c3e", credentialPassword, []byte("Creeper7Farm")))
+	require.NoError(t, plain.write("alice_8f2a1c3e", credentialRoot, []byte("/plugins")))
+	before, err := plain.stat("alice_8f2a1c3e", credentialPassword)
+	require.NoError(t, err)
+
+	store := CredentialStore{dir: dir, encrypt: true, secret: "node-token"}
+	n, err := store.migrate()
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	raw, err := os.ReadFile(filepath.Join(dir, "alice_8f2a1c3e.txt"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, encryptedCredentialMagic))
+	assert.NotContains(t, string(raw), "Creeper7Farm")
+	after, err := store.stat("alice_8f2a1c3e", credentialPassword)
+	require.NoError(t, err)
+	assert.Equal(t, before.ModTime(), after.ModTime())
+
+	data, err := store.read("alice_8f2a1c3e", credentialPassword)
+	require.NoError(t, err)
+	assert.Equal(t, "Creeper7Farm", string(data))
+
+	t.Run("binds files to their account", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "mallory_8f2a1c3e.txt"), raw, 0o600))
+		_, err := store.read("mallory_8f2a1c3e", credentialPassword)
+		assert.Error(t, err)
+		require.NoError(t, store.remove("mallory_8f2a1c3e", credentialPassword))
+	})
+
+	t.Run("needs the same key", func(t *testing.T) {
+		_, err := CredentialStore{dir: dir, secret: "other-token"}.read("alice_8f2a1c3e", credentialPassword)
+		assert.Error(t, err)
+
+		keyFile := filepath.Join(t.TempDir(), "key")
+		require.NoError(t, os.WriteFile(keyFile, []byte("node-token\n"), 0o600))
+		data, err := CredentialStore{dir: dir, keyFile: keyFile}.read("alice_8f2a1c3e", credentialPassword)
+		require.NoError(t, err)
+		assert.Equal(t, "Creeper7Farm", string(data))
+	})
+
+	t.Run("decrypts files when turned off", func(t *testing.T) {
+		store.encrypt = false
+		n, err := store.migrate()
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		data, err := os.ReadFile(filepath.Join(dir, "alice_8f2a1c3e.root"))
+		require.NoError(t, err)
+		assert.Equal(t, "/plugins", string(data))
+	})
+}
+
+func TestMappedAccounts(t *testing.T) {
+	config.Set(&config.Configuration{
+		AuthenticationToken: "test",
+		System:              config.SystemConfiguration{Ftp: config.FtpConfiguration{CredentialsPath: t.TempDir()}},
+	})
+	const otherServerID = "1c3e9b7d-0000-4000-8000-000000000000"
+
+	_, err := CreateAccount("build_bot", AccountOptions{})
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+	_, err = CreateAccount("build_bot", AccountOptions{ServerID: testServerID})
+	require.NoError(t, err)
+	_, err = CreateAccount("build_bot", AccountOptions{ServerID: otherServerID})
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+
+	// A username that looks like it is for another server is taken whole.
+	_, err = CreateAccount("deploy_1c3e9b7d", AccountOptions{ServerID: testServerID})
+	require.NoError(t, err)
+
+	for _, username := range []string{"build_bot", "deploy_1c3e9b7d"} {
+		user, key, ok := parseAccountUsername(username)
+		require.True(t, ok)
+		assert.Equal(t, username, user)
+		assert.Equal(t, testServerID, key)
+		assert.True(t, AccountBelongsTo(username, testServerID))
+		assert.False(t, AccountBelongsTo(username, otherServerID))
+		assert.Equal(t, username, serverUsername(username, testServerID))
+	}
+	assert.True(t, hasServerAccess("build_bot", "build_bot", testServerID))
+	assert.False(t, hasServerAccess("build_bot", "build_bot", otherServerID))
+	assert.False(t, hasServerAccess("deploy_"+otherServerID, "deploy", otherServerID))
+
+	accounts, err := ServerAccounts(testServerID)
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package ftp
+
+// DefaultCredentialsPath is the credentials directory used when none is
+// configured.
+const DefaultCredentialsPath = `C:\ProgramData\Pterodactyl\passwords`
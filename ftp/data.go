@@ -0,0 +1,117 @@
+package ftp
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// The port of a passive listener is parsed from the reply to PASV or EPSV so
+// that connections accepted on it can be tied back to the session.
+var (
+	pasvReply = regexp.MustCompile(`^227 .*\(\d+,\d+,\d+,\d+,(\d+),(\d+)\)`)
+	epsvReply = regexp.MustCompile(`^229 .*\(\|\|\|(\d+)\|\)`)
+)
+
+// passivePort returns the port given in a reply to PASV or EPSV, or zero if
+// the line is not one of those replies.
+func passivePort(line []byte) int {
+	if m := pasvReply.FindSubmatch(line); m != nil {
+		hi, _ := strconv.Atoi(string(m[1]))
+		lo, _ := strconv.Atoi(string(m[2]))
+		return hi<<8 | lo
+	}
+	if m := epsvReply.FindSubmatch(line); m != nil {
+		port, _ := strconv.Atoi(string(m[1]))
+		return port
+	}
+	return 0
+}
+
+// WrapPassiveListener wraps the listener for each passive data connection so
+// that connections accepted on it can be tied to the session that opened it.
+func (d *FTPServerDriver) WrapPassiveListener(l net.Listener) (net.Listener, error) {
+	return &dataListener{
+		Listener:     l,
+		sessions:     d.sessions,
+		stallTimeout: time.Duration(d.cfg.StalledTransferTimeout) * time.Second,
+		keepAlive:    time.Duration(d.cfg.Socket.ControlKeepAlive) * time.Second,
+		socket:       d.cfg.Socket,
+	}, nil
+}
+
+type dataListener struct {
+	net.Listener
+	sessions     *sessionStore
+	stallTimeout time.Duration
+	keepAlive    time.Duration
+	socket       config.FtpSocketConfiguration
+}
+
+func (l *dataListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tuneConn(c, l.socket, l.socket.DataDSCP)
+	addr, ok := c.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return c, nil
+	}
+	s := l.sessions.ByPassivePort(addr.Port)
+	if s == nil {
+		return c, nil
+	}
+	dc := &dataConn{Conn: c, session: s}
+	dc.watchStall(l.stallTimeout)
+	if cc := l.sessions.Conn(s.cc.RemoteAddr().String()); cc != nil && l.keepAlive > 0 {
+		dc.stopKeepAlive = cc.keepAlive(l.keepAlive)
+	}
+	return dc, nil
+}
+
+// dataConn is a passive data connection belonging to a session. If the
+// connection is used to answer an MLSD command the entries written to it are
+// rewritten to include the facts selected by the client.
+type dataConn struct {
+	net.Conn
+	session *session
+	listing *mlsdListing
+	taken   bool
+
+	// The time data was last read or written, in nanoseconds, and the timer
+	// that aborts the transfer if that is too long ago.
+	active     atomic.Int64
+	stallTimer *time.Timer
+	// Stops keeping the control connection alive, if it is being kept alive
+	// for the transfer.
+	stopKeepAlive func()
+}
+
+func (c *dataConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *dataConn) Write(p []byte) (int, error) {
+	c.touch()
+	if !c.taken {
+		c.taken = true
+		c.listing = c.session.listing.Swap(nil)
+	}
+	if c.listing == nil || !bytes.HasSuffix(p, []byte("\r\n")) {
+		return c.Conn.Write(p)
+	}
+	if _, err := c.Conn.Write(c.session.driver.rewriteListing(c.listing, p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
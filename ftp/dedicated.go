@@ -0,0 +1,212 @@
+package ftp
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// With dedicated ports enabled each server is given a listener on its own
+// port, so that clients can log in with the username alone and firewalls can
+// limit access to a server by port. The port of each server is stored so that
+// it does not change when Wings is restarted.
+
+// How often the dedicated listeners are checked against the servers on the
+// node, to catch servers added or removed other than through the API, such as
+// by a transfer.
+const dedicatedSyncInterval = time.Minute
+
+// dedicatedListener is the listener on the port assigned to a server.
+type dedicatedListener struct {
+	port int
+	srv  *ftpserver.FtpServer
+}
+
+// dedicatedPortsPath returns the file the port assigned to each server is
+// stored in.
+func dedicatedPortsPath() string {
+	return filepath.Join(config.Get().System.RootDirectory, "ftp-ports.json")
+}
+
+func loadDedicatedPorts() (map[string]int, error) {
+	ports := make(map[string]int)
+	b, err := os.ReadFile(dedicatedPortsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ports, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(b, &ports); err != nil {
+		return nil, errors.Wrap(err, "ftp: failed to parse dedicated port assignments")
+	}
+	return ports, nil
+}
+
+func saveDedicatedPorts(ports map[string]int) error {
+	b, err := json.Marshal(ports)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(dedicatedPortsPath(), b, 0o644))
+}
+
+// assignDedicatedPorts returns the port of each of the servers. Servers keep
+// the port they were assigned before if it is still within the range, and
+// the others are given the lowest port in the range that is not assigned or
+// in use. Servers that do not fit in the range are left out.
+func assignDedicatedPorts(cfg config.FtpDedicatedPortsConfiguration, assigned map[string]int, ids []string, inUse func(port int) bool) map[string]int {
+	ports := make(map[string]int)
+	taken := make(map[int]bool)
+	for _, id := range ids {
+		if p, ok := assigned[id]; ok && p >= cfg.PortStart && p <= cfg.PortEnd && !taken[p] {
+			ports[id] = p
+			taken[p] = true
+		}
+	}
+	sort.Strings(ids)
+	next := cfg.PortStart
+	for _, id := range ids {
+		if _, ok := ports[id]; ok {
+			continue
+		}
+		for next <= cfg.PortEnd && (taken[next] || inUse(next)) {
+			next++
+		}
+		if next > cfg.PortEnd {
+			log.WithField("server", id).Warn("no FTP port left in the dedicated port range for server")
+			continue
+		}
+		ports[id] = next
+		taken[next] = true
+	}
+	return ports
+}
+
+// SyncDedicatedPorts opens a listener on the dedicated port of each server
+// that does not have one yet, and closes the listeners of servers that have
+// been removed from the node. It does nothing unless dedicated ports are
+// enabled and the FTP server is running.
+func (c *FTPServer) SyncDedicatedPorts() {
+	c.mu.Lock()
+	cfg, node := c.cfg, c.node
+	c.mu.Unlock()
+
+	c.dmu.Lock()
+	defer c.dmu.Unlock()
+	if !cfg.DedicatedPorts.Enabled || !c.dedicatedOpen {
+		return
+	}
+
+	var ids []string
+	for _, s := range c.manager.All() {
+		ids = append(ids, s.ID())
+	}
+	assigned, err := loadDedicatedPorts()
+	if err != nil {
+		log.WithField("error", err).Error("failed to load FTP dedicated port assignments")
+		return
+	}
+	// Ports used by game servers and containers, or by the passive ranges
+	// and control ports of the FTP server, are not assigned.
+	used := c.usedPorts()
+	lcs := append(slices.Clip(listenerConfigs(cfg)), cfg.DedicatedPorts.Listener)
+	inUse := func(port int) bool {
+		if _, ok := used[port]; ok {
+			return true
+		}
+		for _, lc := range lcs {
+			r := passivePortRange(lc)
+			if port == lc.Port || (port >= r.Start && port <= r.End) {
+				return true
+			}
+		}
+		return false
+	}
+	ports := assignDedicatedPorts(cfg.DedicatedPorts, assigned, ids, inUse)
+	if !maps.Equal(ports, assigned) {
+		if err := saveDedicatedPorts(ports); err != nil {
+			log.WithField("error", err).Error("failed to save FTP dedicated port assignments")
+		}
+	}
+
+	for id, l := range c.dedicated {
+		if ports[id] != l.port {
+			_ = l.srv.Stop()
+			delete(c.dedicated, id)
+		}
+	}
+	for id, port := range ports {
+		if _, ok := c.dedicated[id]; ok {
+			continue
+		}
+		lc := cfg.DedicatedPorts.Listener
+		lc.Port = port
+		s, err := c.listen(cfg, lc, node, id)
+		if err != nil {
+			log.WithFields(log.Fields{"server": id, "port": port, "error": err}).Error("failed to open dedicated FTP listener for server")
+			continue
+		}
+		c.dedicated[id] = &dedicatedListener{port: port, srv: s}
+		go func(id string, s *ftpserver.FtpServer) {
+			if err := s.Serve(); err != nil {
+				log.WithFields(log.Fields{"server": id, "error": err}).Error("dedicated FTP listener for server stopped")
+			}
+		}(id, s)
+	}
+}
+
+// DedicatedPort returns the port dedicated to the server, or 0 if it does
+// not have a listener of its own.
+func (c *FTPServer) DedicatedPort(id string) int {
+	c.dmu.Lock()
+	defer c.dmu.Unlock()
+	if l, ok := c.dedicated[id]; ok {
+		return l.port
+	}
+	return 0
+}
+
+// closeDedicated closes the listeners of every server until they are opened
+// again by runDedicatedPorts. Sessions that are already connected are not
+// disconnected.
+func (c *FTPServer) closeDedicated() {
+	c.dmu.Lock()
+	defer c.dmu.Unlock()
+	c.dedicatedOpen = false
+	for id, l := range c.dedicated {
+		_ = l.srv.Stop()
+		delete(c.dedicated, id)
+	}
+}
+
+// runDedicatedPorts opens the dedicated listeners and keeps them in sync
+// with the servers on the node until the context is canceled.
+func (c *FTPServer) runDedicatedPorts(ctx context.Context) {
+	c.dmu.Lock()
+	// The listeners may have been closed before this started.
+	c.dedicatedOpen = ctx.Err() == nil
+	c.dmu.Unlock()
+	c.SyncDedicatedPorts()
+	ticker := time.NewTicker(dedicatedSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.SyncDedicatedPorts()
+		}
+	}
+}
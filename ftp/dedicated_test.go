@@ -0,0 +1,43 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestAssignDedicatedPorts(t *testing.T) {
+	cfg := config.FtpDedicatedPortsConfiguration{PortStart: 2100, PortEnd: 2103}
+	none := func(int) bool { return false }
+
+	t.Run("assigns the lowest free ports", func(t *testing.T) {
+		ports := assignDedicatedPorts(cfg, nil, []string{"b", "a"}, func(p int) bool { return p == 2100 })
+		assert.Equal(t, map[string]int{"a": 2101, "b": 2102}, ports)
+	})
+
+	t.Run("keeps existing assignments", func(t *testing.T) {
+		ports := assignDedicatedPorts(cfg, map[string]int{"b": 2100, "gone": 2101}, []string{"a", "b"}, none)
+		assert.Equal(t, map[string]int{"a": 2101, "b": 2100}, ports)
+	})
+
+	t.Run("reassigns ports outside the range", func(t *testing.T) {
+		ports := assignDedicatedPorts(cfg, map[string]int{"a": 3000}, []string{"a"}, none)
+		assert.Equal(t, map[string]int{"a": 2100}, ports)
+	})
+
+	t.Run("leaves out servers that do not fit", func(t *testing.T) {
+		ports := assignDedicatedPorts(cfg, nil, []string{"a", "b", "c", "d", "e"}, none)
+		assert.Len(t, ports, 4)
+		assert.NotContains(t, ports, "e")
+	})
+}
+
+func TestServerUsername(t *testing.T) {
+	id := "8f2c1d3e-0000-4000-8000-000000000000"
+	assert.Equal(t, "alice_8f2c1d3e", serverUsername("alice", id))
+	assert.Equal(t, "alice_8f2c1d3e", serverUsername("alice_8f2c1d3e", id))
+	assert.Equal(t, "alice_"+id, serverUsername("alice_"+id, id))
+	assert.Equal(t, "alice_other123_8f2c1d3e", serverUsername("alice_other123", id))
+}
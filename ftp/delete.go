@@ -0,0 +1,299 @@
+package ftp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/google/uuid"
+	"github.com/spf13/afero"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+// Directories being removed in the background are moved to the root of the
+// server under a hidden name starting with this prefix so that they disappear
+// from the server straight away. Anything with the prefix is left out of
+// directory listings, and any left behind by a restart is removed when the FTP
+// server starts.
+const deletePrefix = ".ftp-delete-"
+
+// How long a finished delete job can still be queried with SITE DELSTAT.
+const deleteJobRetention = time.Hour
+
+var errTooManyToCount = errors.New("directory exceeds background delete threshold")
+
+// deleteJob is a directory that is being removed in the background.
+type deleteJob struct {
+	ID     string
+	Server string
+	Path   string
+
+	deleted atomic.Int64
+
+	mu       sync.Mutex
+	finished time.Time
+	err      error
+}
+
+// status returns a description of the progress of the job.
+func (j *deleteJob) status() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state := "running"
+	if !j.finished.IsZero() {
+		state = "completed"
+		if j.err != nil {
+			state = "failed: " + j.err.Error()
+		}
+	}
+	return fmt.Sprintf("%s %s: %s, %d entries deleted", j.ID, j.Path, state, j.deleted.Load())
+}
+
+// deleteJobs tracks the background delete jobs for every server on the node.
+type deleteJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*deleteJob
+}
+
+func newDeleteJobs() *deleteJobs {
+	return &deleteJobs{jobs: make(map[string]*deleteJob)}
+}
+
+// add starts tracking a job, and stops tracking any that finished long enough
+// ago.
+func (d *deleteJobs) add(job *deleteJob) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, j := range d.jobs {
+		j.mu.Lock()
+		if !j.finished.IsZero() && time.Since(j.finished) > deleteJobRetention {
+			delete(d.jobs, id)
+		}
+		j.mu.Unlock()
+	}
+	d.jobs[job.ID] = job
+}
+
+// has reports whether a job with the ID is being tracked.
+func (d *deleteJobs) has(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.jobs[id]
+	return ok
+}
+
+// forServer returns the jobs for a server, ordered by their ID.
+func (d *deleteJobs) forServer(server string) []*deleteJob {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var jobs []*deleteJob
+	for _, j := range d.jobs {
+		if j.Server == server {
+			jobs = append(jobs, j)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].ID < jobs[b].ID })
+	return jobs
+}
+
+// removeDir removes a directory from the server. Directories with more entries
+// than the configured threshold are moved out of the way and removed in the
+// background so that the client is not left waiting, and the ID of the job is
+// added to the reply. If removing it fails, what is left of the directory is
+// put back where it was.
+func (driver *FTPDriver) removeDir(s *server.Server, p string, realPath string) error {
+	threshold := driver.cfg.BackgroundDeleteThreshold
+	fsys := driver.storage()
+	if threshold <= 0 || driver.deletes == nil || !exceedsEntries(fsys, realPath, threshold) {
+		return removeAll(fsys, realPath, usageRemoved(s))
+	}
+
+	job := &deleteJob{ID: uuid.New().String()[:8], Server: s.ID(), Path: driver.serverPath(p)}
+	tmp := filepath.Join(driver.BasePath, s.ID(), deletePrefix+job.ID)
+	if err := fsys.Rename(realPath, tmp); err != nil {
+		return err
+	}
+	driver.deletes.add(job)
+	driver.noteReplySuffix("(deleting in the background, see SITE DELSTAT " + job.ID + ")")
+
+	go func() {
+		usage := usageRemoved(s)
+		err := removeTree(fsys, tmp, func(size int64) {
+			job.deleted.Add(1)
+			usage(size)
+		})
+		if err != nil {
+			driver.logger().WithFields(log.Fields{
+				"path":  job.Path,
+				"job":   job.ID,
+				"error": err,
+			}).Error("ftp: background delete failed")
+			driver.restoreDir(tmp, realPath)
+		}
+		job.mu.Lock()
+		job.finished = time.Now()
+		job.err = err
+		job.mu.Unlock()
+	}()
+	return nil
+}
+
+// restoreDir moves a directory that failed to be removed in the background
+// back to where it was, unless something else has been put there since. If it
+// cannot be moved back it is removed the next time the FTP server starts.
+func (driver *FTPDriver) restoreDir(tmp, realPath string) {
+	fsys := driver.storage()
+	if _, err := fsys.Lstat(realPath); !os.IsNotExist(err) {
+		return
+	}
+	if err := fsys.Rename(tmp, realPath); err != nil {
+		driver.logger().WithFields(log.Fields{"path": realPath, "error": err}).Warn("ftp: failed to restore directory after background delete failed")
+		return
+	}
+	driver.listings.invalidate(realPath)
+}
+
+// sweepDeletes removes the directories at the root of each server that were
+// being removed in the background when Wings stopped. Directories of the jobs
+// that are still tracked are left alone.
+func sweepDeletes(m *server.Manager, fsys Backend, basePath string, jobs *deleteJobs) {
+	for _, s := range m.All() {
+		root := filepath.Join(basePath, s.ID())
+		entries, err := afero.ReadDir(fsys, root)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				s.Log().WithField("error", err).Warn("ftp: failed to read server directory")
+			}
+			continue
+		}
+		for _, e := range entries {
+			id, ok := strings.CutPrefix(e.Name(), deletePrefix)
+			if !ok || !e.IsDir() || jobs.has(id) {
+				continue
+			}
+			if err := removeTree(fsys, filepath.Join(root, e.Name()), usageRemoved(s)); err != nil {
+				s.Log().WithField("error", err).Warn("ftp: failed to remove directory left by background delete")
+			}
+		}
+	}
+}
+
+// exceedsEntries reports whether there are more than n entries beneath the
+// directory, stopping as soon as the answer is known.
+func exceedsEntries(fsys Backend, root string, n int) bool {
+	count := 0
+	err := afero.Walk(fsys, root, func(_ string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		count++
+		if count > n {
+			return errTooManyToCount
+		}
+		return nil
+	})
+	return errors.Is(err, errTooManyToCount)
+}
+
+// removeAll removes the file or directory at the given path and everything
+// beneath it, calling removed with the size of each entry that is removed.
+func removeAll(fsys Backend, p string, removed func(size int64)) error {
+	st, err := fsys.Lstat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if st.IsDir() {
+		return removeTree(fsys, p, removed)
+	}
+	if err := fsys.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removed(st.Size())
+	return nil
+}
+
+// removeTree removes a directory and everything beneath it, reading the
+// entries a batch at a time and calling removed with the size of each one
+// that is removed.
+func removeTree(fsys Backend, dir string, removed func(size int64)) error {
+	for {
+		d, err := fsys.Open(dir)
+		if err != nil {
+			return err
+		}
+		entries, err := d.Readdir(listBatchSize)
+		_ = d.Close()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			p := filepath.Join(dir, entry.Name())
+			if entry.IsDir() {
+				if err := removeTree(fsys, p, removed); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := fsys.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removed(entry.Size())
+		}
+	}
+	if err := fsys.Remove(dir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removed(0)
+	return nil
+}
+
+// usageRemoved returns a function that removes the size of each removed entry
+// from the disk usage tracked for the server.
+func usageRemoved(s *server.Server) func(size int64) {
+	return func(size int64) {
+		if size > 0 {
+			s.Filesystem().AddDiskUsage(-size)
+		}
+	}
+}
+
+// siteDeleteStatus handles "SITE DELSTAT [id]" which returns the progress of
+// the background deletes on the server, or of a single one.
+func (s *session) siteDeleteStatus(params string) (int, string) {
+	srv, err := s.driver.getServer()
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, err.Error()
+	}
+	var lines []string
+	for _, j := range s.driver.deletes.forServer(srv.ID()) {
+		if params == "" || strings.EqualFold(params, j.ID) {
+			lines = append(lines, j.status())
+		}
+	}
+	if len(lines) == 0 {
+		if params != "" {
+			return ftpserver.StatusActionNotTaken, "No such delete job: " + params
+		}
+		return ftpserver.StatusOK, "No background deletes"
+	}
+	if len(lines) == 1 {
+		return ftpserver.StatusOK, lines[0]
+	}
+	return ftpserver.StatusOK, "Background deletes:\n " + strings.Join(lines, "\n ") + "\nEnd"
+}
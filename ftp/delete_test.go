@@ -0,0 +1,42 @@
+package ftp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+func TestSweepDeletes(t *testing.T) {
+	driver, root := newServerDriver(t)
+	m := server.NewEmptyManager(nil)
+	m.Add(driver.server)
+	for _, dir := range []string{".ftp-delete-aaaa1111/world", ".ftp-delete-bbbb2222/world", "world"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	jobs := newDeleteJobs()
+	jobs.add(&deleteJob{ID: "bbbb2222", Server: testServerID})
+
+	sweepDeletes(m, NewLocalBackend(), driver.BasePath, jobs)
+	assert.NoDirExists(t, filepath.Join(root, ".ftp-delete-aaaa1111"), "left behind by a restart")
+	assert.DirExists(t, filepath.Join(root, ".ftp-delete-bbbb2222"), "still being removed")
+	assert.DirExists(t, filepath.Join(root, "world"))
+}
+
+func TestRestoreDir(t *testing.T) {
+	driver, root := newServerDriver(t)
+	tmp := filepath.Join(root, ".ftp-delete-aaaa1111")
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "region"), 0o755))
+
+	driver.restoreDir(tmp, filepath.Join(root, "world"))
+	assert.NoDirExists(t, tmp)
+	assert.DirExists(t, filepath.Join(root, "world/region"))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "region"), 0o755))
+	driver.restoreDir(tmp, filepath.Join(root, "world"))
+	assert.DirExists(t, tmp, "the directory is not moved over one created since")
+}
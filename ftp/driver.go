@@ -4,24 +4,114 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/juju/ratelimit"
 	"github.com/spf13/afero"
 
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/server"
 ) // NOTE: keep io import for PutFile, use afero.File for Create method
 
 // FTPDriver implements the FTP driver interface.
 type FTPDriver struct {
 	manager  *server.Manager
+	client   remote.Client
 	BasePath string
 	ReadOnly bool
 	user     string
+	ip       string
 	server   *server.Server // Cache server to avoid repeated lookups
+	// The transfer counters for the session, and the statistics of the server
+	// finished transfers are recorded in.
+	stats   *transferCounters
+	history *serverCounters
+	// The files currently being written to on this node.
+	locks *writeLocks
+	// The data transfers in progress on this node.
+	transfers *transferSlots
+	// The reply code to use for the next error sent to the client.
+	replyCode atomic.Int32
+	// Text added to the end of the next successful reply sent to the client.
+	replySuffix atomic.Pointer[string]
+	// The directories being removed in the background on this node.
+	deletes *deleteJobs
+	// The size given to the last ALLO command, which is preallocated for the
+	// next upload.
+	allocate atomic.Int64
+	// The Panel permissions granted to the user. A nil slice means the user
+	// was authenticated locally and is not restricted by Panel permissions.
+	permissions []string
+	// The FTP configuration at the time the session was authenticated.
+	cfg config.FtpConfiguration
+	// The directory, relative to the server root, that the account is jailed
+	// to. Empty if the account has access to the whole server.
+	root string
+	// The limits on the rate data is transferred at by the session, and those
+	// that only apply to uploads or downloads.
+	bandwidth         bandwidth
+	uploadBandwidth   *ratelimit.Bucket
+	downloadBandwidth *ratelimit.Bucket
+	// The rates the limits above were created with, reported by STAT.
+	bandwidthLimits bandwidthLimits
+	// The node-wide bandwidth limit, shared between every transfer.
+	node *fairLimiter
+	// The file currently being transferred.
+	transfer atomic.Pointer[activeTransfer]
+	// Delivers events to the configured webhooks.
+	webhooks *webhooks
+	// The access log and xferlog, and the finished transfer waiting for its
+	// reply to be sent before it is written to them.
+	access        *accessLog
+	xferlog       *xferLog
+	pendingAccess atomic.Pointer[activeTransfer]
+	// Starts the spans of the session's logins, paths, and transfers.
+	tracing *tracing
+	// The ID of the session in the API and the access log.
+	sessionID string
+	// The servers made read-only through the API.
+	readOnlyServers *readOnlyServers
+	// The permission scopes granted to the account, or nil if it can do
+	// everything.
+	scopes []string
+	// The directory listings cached for every session on the node.
+	listings *listingCache
+	// The client of the session.
+	cc ftpserver.ClientContext
+	// The logger of the session, with its ID, user, server, and address.
+	log *log.Entry
+	// The storage the files of the server are kept on.
+	backend Backend
+	// The uploads that failed and can be resumed.
+	resume *resumeJournal
+}
+
+// logger returns the logger of the session, or of the FTP subsystem if the
+// session has not logged in yet.
+func (driver *FTPDriver) logger() *log.Entry {
+	if driver.log == nil {
+		return log.WithField("subsystem", "ftp")
+	}
+	return driver.log
+}
+
+// can determines if the user has been granted the given Panel permission.
+func (driver *FTPDriver) can(permission string) bool {
+	if driver.permissions == nil {
+		return true
+	}
+	for _, p := range driver.permissions {
+		if p == permission || p == "*" {
+			return true
+		}
+	}
+	return false
 }
 
 // getServer retrieves the server for the current user.
@@ -35,39 +125,12 @@ func (driver *FTPDriver) getServer() (*server.Server, error) {
 		return nil, errors.New("no user set")
 	}
 
-	// Usernames follow the format: user_{server-id}
-	validUsernameRegexp := regexp.MustCompile(`^(?i)(.+)_([a-z0-9]{8}|[a-z0-9-]{36})$`)
-	
-	if !validUsernameRegexp.MatchString(driver.user) {
-		return nil, errors.New("invalid username format")
-	}
-
-	// Extract server ID from username
-	parts := strings.Split(driver.user, "_")
-	if len(parts) < 2 {
+	_, serverKey, ok := parseAccountUsername(driver.user)
+	if !ok {
 		return nil, errors.New("invalid username format")
 	}
 
-	serverKey := parts[len(parts)-1]
-
-	// Find the server - try by UUID first, then by short ID
-	s := driver.manager.Find(func(srv *server.Server) bool {
-		srvID := srv.ID()
-		// Try exact match (full UUID)
-		if srvID == serverKey {
-			return true
-		}
-		// Try short ID match (first 8 chars)
-		if len(srvID) >= 8 && srvID[:8] == serverKey {
-			return true
-		}
-		// Try last 8 chars match
-		if len(srvID) >= 8 && strings.HasSuffix(srvID, serverKey) {
-			return true
-		}
-		return false
-	})
-
+	s := driver.manager.Lookup(serverKey)
 	if s == nil {
 		return nil, errors.New("server not found")
 	}
@@ -93,40 +156,135 @@ func (driver *FTPDriver) Stat(path string) (os.FileInfo, error) {
 		return nil, err
 	}
 
-	realPath := driver.buildPath(s, path)
-	return os.Stat(realPath)
+	if name, ok := driver.virtualPath(path); ok {
+		return driver.statVirtual(s, name)
+	}
+
+	realPath, err := driver.buildPath(s, path)
+	if err != nil {
+		return nil, err
+	}
+	return driver.storage().Stat(realPath)
 }
 
-// ListDir lists directory contents.
-func (driver *FTPDriver) ListDir(path string) ([]os.FileInfo, error) {
+// The number of directory entries read from the disk at a time when listing a
+// directory.
+const listBatchSize = 1024
+
+var errTooManyEntries = errors.New("too many entries in directory")
+
+// ListDir streams the contents of a directory to the callback, reading the
+// entries from the disk in batches so that large directories are not held in
+// memory twice. Entries that cannot be read are skipped and logged once for
+// the listing. If the directory contains more entries than the configured
+// limit the listing is aborted with a 450 reply.
+func (driver *FTPDriver) ListDir(path string, callback func(os.FileInfo) error) error {
 	s, err := driver.getServer()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	realPath := driver.buildPath(s, path)
+	if err := driver.checkBlocked(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeRead); err != nil {
+		return err
+	}
+	driver.labelCommand(s)
+	if name, ok := driver.virtualPath(path); ok {
+		if name != "" {
+			return errors.New("not a directory")
+		}
+		return driver.listVirtual(s, callback)
+	}
+	// The virtual backups directory is listed in the root in place of
+	// anything on the server with the same name, unless the session is not
+	// allowed to see it.
+	root := false
+	if _, ok := driver.virtualPath("/" + backupsDir); ok && relativePath(path) == "" {
+		root = true
+		if driver.checkBackupAccess() == nil {
+			if err := callback(virtualDirInfo{name: backupsDir}); err != nil {
+				return err
+			}
+		}
+	}
+
+	realPath, err := driver.buildPath(s, path)
+	if err != nil {
+		return err
+	}
+	infos, ok := driver.listings.get(realPath)
+	if !ok {
+		if infos, err = driver.readDir(s, path, realPath); err != nil {
+			return err
+		}
+	}
+	for _, info := range infos {
+		if root && info.Name() == backupsDir {
+			continue
+		}
+		if err := callback(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	entries, err := os.ReadDir(realPath)
+// readDir reads the entries of a directory, caching them for the configured
+// time. Directories with more entries than the configured maximum are
+// rejected.
+func (driver *FTPDriver) readDir(s *server.Server, path string, realPath string) ([]os.FileInfo, error) {
+	gen := driver.listings.generation()
+	dir, err := driver.storage().Open(realPath)
 	if err != nil {
 		return nil, err
 	}
+	defer dir.Close()
 
-	var files []os.FileInfo
-	for _, entry := range entries {
-		info, err := entry.Info()
+	var infos []os.FileInfo
+	var skipped int
+	defer func() {
+		if skipped > 0 {
+			driver.logger().WithFields(log.Fields{
+				"path":    path,
+				"skipped": skipped,
+			}).Warn("FTP directory listing skipped unreadable entries")
+		}
+	}()
+	for {
+		batch, n, err := readDirBatch(dir, listBatchSize)
+		skipped += n
+		for _, info := range batch {
+			if strings.HasPrefix(info.Name(), deletePrefix) || strings.HasPrefix(info.Name(), partialPrefix) {
+				continue
+			}
+			if driver.cfg.MaxListEntries > 0 && len(infos) >= driver.cfg.MaxListEntries {
+				return nil, withReplyCode(ftpserver.StatusFileActionNotTaken, errTooManyEntries)
+			}
+			infos = append(infos, info)
+		}
+		if errors.Is(err, io.EOF) {
+			driver.listings.put(realPath, infos, time.Duration(driver.cfg.ListCacheTTL)*time.Second, gen)
+			return infos, nil
+		}
 		if err != nil {
-			continue
+			return nil, err
 		}
-		files = append(files, info)
 	}
-
-	return files, nil
 }
 
 // DeleteDir deletes a directory.
 func (driver *FTPDriver) DeleteDir(path string) error {
-	if driver.ReadOnly {
-		return errors.New("read-only server")
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeDelete); err != nil {
+		return err
+	}
+
+	if err := driver.checkProtected(path, true); err != nil {
+		return err
 	}
 
 	s, err := driver.getServer()
@@ -134,14 +292,33 @@ func (driver *FTPDriver) DeleteDir(path string) error {
 		return err
 	}
 
-	realPath := driver.buildPath(s, path)
-	return os.RemoveAll(realPath)
+	realPath, err := driver.buildPath(s, path)
+	if err != nil {
+		return err
+	}
+	if driver.cfg.Trash.Enabled {
+		err = driver.moveToTrash(s, realPath)
+	} else {
+		err = driver.removeDir(s, path, realPath)
+	}
+	if err != nil {
+		return err
+	}
+	driver.fileChanged(s, fileActionDelete, path)
+	return nil
 }
 
 // DeleteFile deletes a file.
 func (driver *FTPDriver) DeleteFile(path string) error {
-	if driver.ReadOnly {
-		return errors.New("read-only server")
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeDelete); err != nil {
+		return err
+	}
+
+	if err := driver.checkProtected(path, false); err != nil {
+		return err
 	}
 
 	s, err := driver.getServer()
@@ -149,14 +326,29 @@ func (driver *FTPDriver) DeleteFile(path string) error {
 		return err
 	}
 
-	realPath := driver.buildPath(s, path)
-	return os.Remove(realPath)
+	realPath, err := driver.buildPath(s, path)
+	if err != nil {
+		return err
+	}
+	if driver.cfg.Trash.Enabled {
+		err = driver.moveToTrash(s, realPath)
+	} else {
+		err = removeAll(driver.storage(), realPath, usageRemoved(s))
+	}
+	if err != nil {
+		return err
+	}
+	driver.fileChanged(s, fileActionDelete, path)
+	return nil
 }
 
 // Rename renames a file or directory.
 func (driver *FTPDriver) Rename(fromPath, toPath string) error {
-	if driver.ReadOnly {
-		return errors.New("read-only server")
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeRename); err != nil {
+		return err
 	}
 
 	s, err := driver.getServer()
@@ -164,16 +356,56 @@ func (driver *FTPDriver) Rename(fromPath, toPath string) error {
 		return err
 	}
 
-	from := driver.buildPath(s, fromPath)
-	to := driver.buildPath(s, toPath)
+	from, err := driver.buildPath(s, fromPath)
+	if err != nil {
+		return err
+	}
+	to, err := driver.buildPath(s, toPath)
+	if err != nil {
+		return err
+	}
+
+	// Renaming a protected path is just as destructive as deleting it, and
+	// renaming something on top of one overwrites it.
+	info, err := driver.storage().Stat(from)
+	if err != nil {
+		return err
+	}
+	if err := driver.checkProtected(fromPath, info.IsDir()); err != nil {
+		return err
+	}
+	if err := driver.checkProtected(toPath, info.IsDir()); err != nil {
+		return err
+	}
+	if err := driver.checkNewPath(toPath); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if err := driver.checkUploadName(s, toPath); err != nil {
+			return err
+		}
+	}
 
-	return os.Rename(from, to)
+	if err := driver.storage().Rename(from, to); err != nil {
+		return err
+	}
+	driver.fileChanged(s, fileActionRename, fromPath, toPath)
+	return nil
 }
 
 // MakeDir creates a directory.
 func (driver *FTPDriver) MakeDir(path string) error {
-	if driver.ReadOnly {
-		return errors.New("read-only server")
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeMkdir); err != nil {
+		return err
+	}
+	if err := driver.checkProtected(path, false); err != nil {
+		return err
+	}
+	if err := driver.checkNewPath(path); err != nil {
+		return err
 	}
 
 	s, err := driver.getServer()
@@ -181,20 +413,20 @@ func (driver *FTPDriver) MakeDir(path string) error {
 		return err
 	}
 
-	realPath := driver.buildPath(s, path)
-	return os.MkdirAll(realPath, 0755)
+	realPath, err := driver.buildPath(s, path)
+	if err != nil {
+		return err
+	}
+	if err := driver.storage().MkdirAll(realPath, 0755); err != nil {
+		return err
+	}
+	driver.fileChanged(s, fileActionCreateDirectory, path)
+	return nil
 }
 
 // GetFile retrieves a file for reading.
 func (driver *FTPDriver) GetFile(path string, offset int64) (int64, io.ReadCloser, error) {
-	s, err := driver.getServer()
-	if err != nil {
-		return 0, nil, err
-	}
-
-	realPath := driver.buildPath(s, path)
-
-	f, err := os.Open(realPath)
+	f, err := driver.OpenFile(path, os.O_RDONLY, 0)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -218,46 +450,26 @@ func (driver *FTPDriver) GetFile(path string, offset int64) (int64, io.ReadClose
 
 // PutFile stores a file.
 func (driver *FTPDriver) PutFile(path string, data io.Reader, offset int64) (int64, error) {
-	if driver.ReadOnly {
-		return 0, errors.New("read-only server")
+	flag := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		// Create/truncate mode
+		flag |= os.O_TRUNC
 	}
 
-	s, err := driver.getServer()
+	f, err := driver.OpenFile(path, flag, 0644)
 	if err != nil {
 		return 0, err
 	}
-
-	realPath := driver.buildPath(s, path)
-
-	// Create directory if needed
-	dir := filepath.Dir(realPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return 0, err
-	}
-
-	var f *os.File
+	defer f.Close()
 
 	if offset > 0 {
 		// Append mode
-		f, err = os.OpenFile(realPath, os.O_WRONLY|os.O_CREATE, 0644)
-		if err != nil {
-			return 0, err
-		}
-		defer f.Close()
-
 		if _, err = f.Seek(offset, io.SeekStart); err != nil {
 			return 0, err
 		}
-	} else {
-		// Create/truncate mode
-		f, err = os.Create(realPath)
-		if err != nil {
-			return 0, err
-		}
-		defer f.Close()
 	}
 
-	bytes, err := io.Copy(f, data)
+	bytes, err := copyBuffer(f, data)
 	if err != nil {
 		return 0, err
 	}
@@ -265,63 +477,171 @@ func (driver *FTPDriver) PutFile(path string, data io.Reader, offset int64) (int
 	return bytes, nil
 }
 
-// buildPath constructs the real filesystem path for a server with security checks.
-// Prevents directory traversal and symlink attacks.
-func (driver *FTPDriver) buildPath(s *server.Server, requestPath string) string {
-	// Clean the path to prevent directory traversal
-	cleaned := filepath.Clean(requestPath)
-
-	// Remove leading slash
-	cleaned = strings.TrimPrefix(cleaned, "/")
-
-	// Build full path: /var/lib/pterodactyl/volumes/{uuid}/{path}
-	serverRoot := filepath.Join(driver.BasePath, s.ID())
-	fullPath := filepath.Join(serverRoot, cleaned)
-
-	// Security check 1: Ensure the resulting path is within the server root
-	// This prevents ../../../ attacks
-	absServerRoot, _ := filepath.Abs(serverRoot)
-	absFullPath, _ := filepath.Abs(fullPath)
-	
-	if !strings.HasPrefix(absFullPath, absServerRoot+string(filepath.Separator)) && absFullPath != absServerRoot {
-		log.WithFields(log.Fields{
-			"server":       s.ID(),
-			"request_path": requestPath,
-			"real_path":    fullPath,
-			"resolved":     absFullPath,
-		}).Warn("FTP path traversal attempt blocked")
-		// Return a path that doesn't exist to prevent access
-		return filepath.Join(serverRoot, ".blocked")
-	}
-
-	// Security check 2: Resolve symlinks and ensure we're still within server root
-	// This prevents symlink attacks to access files outside the server directory
-	realPath, err := filepath.EvalSymlinks(fullPath)
+// OpenFile opens a file within the server's data directory using the given
+// flags. Any open that is able to modify the file is subject to the same
+// checks as every other write operation, and missing parent directories are
+// created when the file is being created.
+func (driver *FTPDriver) OpenFile(path string, flag int, perm os.FileMode) (afero.File, error) {
+	s, err := driver.getServer()
+	if err != nil {
+		return nil, err
+	}
+	driver.labelCommand(s)
+
+	if name, ok := driver.virtualPath(path); ok {
+		if err := driver.checkBlocked(); err != nil {
+			return nil, err
+		}
+		return driver.openVirtual(s, name, flag)
+	}
+
+	realPath, err := driver.buildPath(s, path)
 	if err != nil {
-		// File might not exist yet, but we already validated the path
-		realPath = fullPath
+		return nil, err
 	}
-	
-	realPath, _ = filepath.Abs(realPath)
-	absServerRoot, _ = filepath.Abs(serverRoot)
-	
-	if !strings.HasPrefix(realPath, absServerRoot+string(filepath.Separator)) && realPath != absServerRoot {
-		log.WithFields(log.Fields{
-			"server":       s.ID(),
-			"request_path": requestPath,
-			"real_path":    realPath,
-		}).Warn("FTP symlink attack attempt blocked")
-		// Return a path that doesn't exist to prevent access
-		return filepath.Join(serverRoot, ".blocked")
+	if !isWriteFlag(flag) {
+		if err := driver.checkBlocked(); err != nil {
+			return nil, err
+		}
+		if err := driver.checkScope(ScopeRead); err != nil {
+			return nil, err
+		}
+		if !driver.can("file.read-content") {
+			return nil, withReplyCode(ftpserver.StatusActionNotTaken, errors.New("permission denied: this account cannot download files"))
+		}
+		release, err := driver.acquireTransfer()
+		if err != nil {
+			return nil, err
+		}
+		f, err := driver.storage().OpenFile(realPath, flag, perm)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if fd, ok := f.(*os.File); ok && driver.shouldSnapshot(path) {
+			if snap, err := driver.snapshot(fd); err != nil {
+				driver.logger().WithFields(log.Fields{"path": path, "error": err}).Warn("FTP download snapshot failed, serving file directly")
+			} else {
+				_ = f.Close()
+				f = snap
+			}
+		}
+		return driver.newDownload(f, path, release), nil
 	}
 
-	log.WithFields(log.Fields{
-		"server":       s.ID(),
-		"request_path": requestPath,
-		"real_path":    fullPath,
-	}).Debug("FTP path mapping")
+	if err := driver.checkReadOnly(); err != nil {
+		return nil, err
+	}
+	if err := driver.checkScope(ScopeWrite); err != nil {
+		return nil, err
+	}
+	if err := driver.checkProtected(path, false); err != nil {
+		return nil, err
+	}
 
-	return fullPath
+	// Uploads that truncate or create a file are written from the start, so
+	// are checked against the upload rules for the server.
+	sniff := flag&os.O_TRUNC != 0
+	var size int64
+	st, statErr := driver.storage().Stat(realPath)
+	if statErr == nil {
+		size = st.Size()
+		// Writing over an existing file loses its contents just like
+		// deleting it.
+		if flag&os.O_TRUNC != 0 {
+			if err := driver.checkScope(ScopeDelete); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if flag&os.O_CREATE != 0 {
+		if os.IsNotExist(statErr) {
+			if err := driver.checkNewPath(path); err != nil {
+				return nil, err
+			}
+			sniff = true
+		}
+		if _, err := driver.storage().Stat(filepath.Dir(realPath)); os.IsNotExist(err) {
+			if err := driver.checkScope(ScopeMkdir); err != nil {
+				return nil, err
+			}
+		}
+		if err := driver.storage().MkdirAll(filepath.Dir(realPath), 0755); err != nil {
+			return nil, err
+		}
+	}
+	if sniff {
+		if err := driver.checkUploadName(s, path); err != nil {
+			return nil, err
+		}
+	}
+
+	release, err := driver.acquireTransfer()
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := driver.lockWrite(s.ID(), driver.serverPath(path))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	f, partial, err := driver.openPartial(s, path, realPath, flag, perm)
+	if err == nil && f == nil {
+		f, err = driver.storage().OpenFile(realPath, flag, perm)
+	}
+	if err != nil {
+		unlock()
+		release()
+		return nil, err
+	}
+	if partial != nil {
+		size = partial.Size
+	}
+	driver.listings.invalidate(realPath)
+	// Files on the local disk are written to directly by the kernel where
+	// they can be, and preallocated as they grow.
+	fd, _ := f.(*os.File)
+	upload := &uploadFile{File: f, driver: driver, server: s, path: path, unlock: unlock, releaseSlot: release, fd: fd, size: size}
+	upload.share = driver.node.share()
+	upload.transfer = driver.startTransfer(path, transferUpload)
+	upload.bandwidth = driver.bandwidth.with(driver.uploadBandwidth).withShare(upload.share)
+	upload.cache = newUploadDropper(fd, driver.cfg.DropCacheSize)
+	if sniff {
+		upload.File = driver.sniffUploads(s, f)
+		upload.hash = driver.newUploadHash()
+	}
+	if partial != nil {
+		upload.partial = partial
+		if partial.Size > 0 {
+			upload.hash = driver.restoreHash(partial)
+		}
+	}
+	if ring := fileRing(); ring != nil && fd != nil && upload.File == f {
+		upload.File = &uringFile{File: fd, ring: ring}
+	}
+	if fd != nil {
+		upload.chunk = int64(driver.cfg.PreallocateSize) * 1024 * 1024
+	}
+	// Space announced with ALLO is reserved up front.
+	if size := driver.allocate.Swap(0); size > 0 && fd != nil {
+		if err := preallocate(fd, 0, size); err != nil && !errors.Is(err, errPreallocateUnsupported) {
+			_ = f.Close()
+			upload.share.close()
+			driver.endTransfer(upload.transfer)
+			unlock()
+			release()
+			return nil, err
+		}
+		upload.allocated = size
+	}
+	upload.restoreIO = driver.lowerIOPriority()
+	return upload, nil
+}
+
+// isWriteFlag reports whether opening a file with the given flags could
+// modify it.
+func isWriteFlag(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
 }
 
 // ClientDriver implements ftpserver.ClientDriver interface.
@@ -333,51 +653,72 @@ func (cd *ClientDriver) Init(cc interface{}) {
 }
 
 func (cd *ClientDriver) ChangeDir(path string) error {
-	return cd.FTPDriver.ChangeDir(path)
+	return cd.noteReply(cd.FTPDriver.ChangeDir(path))
 }
 
 func (cd *ClientDriver) Stat(path string) (os.FileInfo, error) {
-	return cd.FTPDriver.Stat(path)
+	// The library stats paths it expects not to exist, such as before an
+	// upload, so the reply code is not noted.
+	info, err := cd.FTPDriver.Stat(path)
+	if partial, ok := cd.statPartial(path); ok {
+		return partial, nil
+	}
+	return info, translateError(err)
 }
 
 func (cd *ClientDriver) ListDir(path string, callback func(os.FileInfo) error) error {
-	files, err := cd.FTPDriver.ListDir(path)
-	if err != nil {
-		return err
-	}
+	return cd.noteReply(cd.FTPDriver.ListDir(path, callback))
+}
 
-	for _, f := range files {
-		if err := callback(f); err != nil {
-			return err
-		}
+// ReadDir is used by ftpserverlib for LIST, NLST, and MLSD in place of reading
+// the directory through Open, so that listings go through ListDir.
+func (cd *ClientDriver) ReadDir(path string) ([]os.FileInfo, error) {
+	var files []os.FileInfo
+	err := cd.ListDir(path, func(info os.FileInfo) error {
+		files = append(files, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return files, nil
 }
 
 func (cd *ClientDriver) DeleteDir(path string) error {
-	return cd.FTPDriver.DeleteDir(path)
+	return cd.noteReply(cd.FTPDriver.DeleteDir(path))
+}
+
+// RemoveDir implements ftpserver.ClientDriverExtensionRemoveDir so that RMD is
+// handled separately from DELE.
+func (cd *ClientDriver) RemoveDir(path string) error {
+	return cd.noteReply(cd.FTPDriver.DeleteDir(path))
 }
 
 func (cd *ClientDriver) DeleteFile(path string) error {
-	return cd.FTPDriver.DeleteFile(path)
+	return cd.noteReply(cd.FTPDriver.DeleteFile(path))
 }
 
 func (cd *ClientDriver) Rename(from, to string) error {
-	return cd.FTPDriver.Rename(from, to)
+	return cd.noteReply(cd.FTPDriver.Rename(from, to))
 }
 
 // MakeDir retained for backward naming, Mkdir added per interface.
-func (cd *ClientDriver) MakeDir(path string) error { return cd.FTPDriver.MakeDir(path) }
-func (cd *ClientDriver) Mkdir(path string, mode os.FileMode) error { return cd.FTPDriver.MakeDir(path) }
-func (cd *ClientDriver) MkdirAll(path string, mode os.FileMode) error { return cd.FTPDriver.MakeDir(path) }
+func (cd *ClientDriver) MakeDir(path string) error { return cd.noteReply(cd.FTPDriver.MakeDir(path)) }
+func (cd *ClientDriver) Mkdir(path string, mode os.FileMode) error {
+	return cd.noteReply(cd.FTPDriver.MakeDir(path))
+}
+func (cd *ClientDriver) MkdirAll(path string, mode os.FileMode) error {
+	return cd.noteReply(cd.FTPDriver.MakeDir(path))
+}
 
 func (cd *ClientDriver) GetFile(path string, offset int64) (int64, io.ReadCloser, error) {
-	return cd.FTPDriver.GetFile(path, offset)
+	n, r, err := cd.FTPDriver.GetFile(path, offset)
+	return n, r, cd.noteReply(err)
 }
 
 func (cd *ClientDriver) PutFile(path string, data io.Reader, offset int64) (int64, error) {
-	return cd.FTPDriver.PutFile(path, data, offset)
+	n, err := cd.FTPDriver.PutFile(path, data, offset)
+	return n, cd.noteReply(err)
 }
 
 func (cd *ClientDriver) Chmod(path string, mode os.FileMode) error {
@@ -396,24 +737,8 @@ func (cd *ClientDriver) Chtimes(path string, atime, mtime time.Time) error {
 }
 
 func (cd *ClientDriver) Create(path string) (afero.File, error) {
-	if cd.FTPDriver.ReadOnly {
-		return nil, errors.New("read-only server")
-	}
-	// Resolve server
-	s, err := cd.FTPDriver.getServer()
-	if err != nil {
-		return nil, err
-	}
-	realPath := cd.FTPDriver.buildPath(s, path)
-	// Ensure parent dirs
-	if err := os.MkdirAll(filepath.Dir(realPath), 0755); err != nil {
-		return nil, err
-	}
-	f, err := os.Create(realPath)
-	if err != nil {
-		return nil, err
-	}
-	return f, nil
+	f, err := cd.FTPDriver.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	return f, cd.noteReply(err)
 }
 
 func (cd *ClientDriver) Name() string {
@@ -421,43 +746,22 @@ func (cd *ClientDriver) Name() string {
 }
 
 func (cd *ClientDriver) Open(path string) (afero.File, error) {
-	s, err := cd.FTPDriver.getServer()
-	if err != nil {
-		return nil, err
-	}
-	realPath := cd.FTPDriver.buildPath(s, path)
-	return os.Open(realPath)
+	f, err := cd.FTPDriver.OpenFile(path, os.O_RDONLY, 0)
+	return f, cd.noteReply(err)
 }
 
+// OpenFile is used by ftpserverlib for every upload and download. The mode
+// requested by the library is ignored in favor of the same permissions used
+// by the rest of Wings for newly created files.
 func (cd *ClientDriver) OpenFile(path string, flag int, mode os.FileMode) (afero.File, error) {
-	s, err := cd.FTPDriver.getServer()
-	if err != nil {
-		return nil, err
-	}
-	realPath := cd.FTPDriver.buildPath(s, path)
-	return os.OpenFile(realPath, flag, mode)
+	f, err := cd.FTPDriver.OpenFile(path, flag, 0644)
+	return f, cd.noteReply(err)
 }
 
 func (cd *ClientDriver) Remove(path string) error {
-	if cd.FTPDriver.ReadOnly {
-		return errors.New("read-only server")
-	}
-	s, err := cd.FTPDriver.getServer()
-	if err != nil {
-		return err
-	}
-	realPath := cd.FTPDriver.buildPath(s, path)
-	return os.Remove(realPath)
+	return cd.noteReply(cd.FTPDriver.DeleteFile(path))
 }
 
 func (cd *ClientDriver) RemoveAll(path string) error {
-	if cd.FTPDriver.ReadOnly {
-		return errors.New("read-only server")
-	}
-	s, err := cd.FTPDriver.getServer()
-	if err != nil {
-		return err
-	}
-	realPath := cd.FTPDriver.buildPath(s, path)
-	return os.RemoveAll(realPath)
+	return cd.noteReply(cd.FTPDriver.DeleteDir(path))
 }
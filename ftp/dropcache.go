@@ -0,0 +1,92 @@
+package ftp
+
+import "os"
+
+// Streaming a world archive of tens of GiB through the page cache evicts the
+// pages the running game servers depend on, which shows up as lag spikes on
+// every server on the node. The pages of files larger than the configured
+// size are dropped from the cache as soon as they have been transferred.
+// O_DIRECT is not used, since it rules out sendfile and needs aligned
+// buffers.
+
+// The number of bytes transferred between each time the pages behind the
+// position of the transfer are dropped.
+const dropCacheChunk = 8 * 1024 * 1024
+
+// cacheDropper drops the pages of a file from the page cache once they have
+// been transferred.
+type cacheDropper struct {
+	f     *os.File
+	write bool
+	// The number of bytes that have to be transferred before any pages are
+	// dropped, for uploads whose final size is not known.
+	threshold int64
+	// The position of the transfer, the position pages were last dropped or
+	// written back up to, and the position pages have been dropped up to.
+	pos, mark, dropped int64
+}
+
+// newDownloadDropper returns a dropper for a download of a file of the given
+// size, or nil if the file is not larger than the limit in MiB or is not on
+// the local disk.
+func newDownloadDropper(f *os.File, size int64, limit int) *cacheDropper {
+	if f == nil || limit <= 0 || size < int64(limit)*1024*1024 {
+		return nil
+	}
+	return &cacheDropper{f: f}
+}
+
+// newUploadDropper returns a dropper for an upload that starts dropping pages
+// once more than the limit in MiB has been written, or nil if it is disabled
+// or the file is not on the local disk.
+func newUploadDropper(f *os.File, limit int) *cacheDropper {
+	if f == nil || limit <= 0 {
+		return nil
+	}
+	return &cacheDropper{f: f, write: true, threshold: int64(limit) * 1024 * 1024}
+}
+
+// advance records that n more bytes were transferred.
+func (d *cacheDropper) advance(n int64) {
+	if d == nil {
+		return
+	}
+	d.pos += n
+	if d.pos < d.threshold || d.pos-d.mark < dropCacheChunk {
+		return
+	}
+	d.drop()
+}
+
+// drop drops the pages behind the position of the transfer. Pages that were
+// written cannot be dropped until they are on the disk, so writeback of each
+// chunk is started when it has been written and it is dropped along with the
+// next one.
+func (d *cacheDropper) drop() {
+	if d.write {
+		_ = startWriteback(d.f, d.mark, d.pos-d.mark)
+		_ = fadvise(d.f, d.dropped, d.mark-d.dropped, fadvDontNeed)
+		d.dropped = d.mark
+	} else {
+		_ = fadvise(d.f, d.dropped, d.pos-d.dropped, fadvDontNeed)
+		d.dropped = d.pos
+	}
+	d.mark = d.pos
+}
+
+// seek moves the position of the transfer, such as when the client resumes
+// it with REST.
+func (d *cacheDropper) seek(pos int64) {
+	if d == nil {
+		return
+	}
+	d.pos, d.mark, d.dropped = pos, pos, pos
+}
+
+// close drops the pages of the rest of the transfer, as far as they can be.
+func (d *cacheDropper) close() {
+	if d == nil || d.pos < d.threshold || d.pos == d.dropped {
+		return
+	}
+	d.drop()
+}
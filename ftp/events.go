@@ -0,0 +1,89 @@
+package ftp
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterodactyl/wings/internal/models"
+	"github.com/pterodactyl/wings/server"
+)
+
+// The actions reported in a file changed event.
+const (
+	fileActionUpload          = "upload"
+	fileActionDelete          = "delete"
+	fileActionRename          = "rename"
+	fileActionCreateDirectory = "create-directory"
+)
+
+// fileActivity maps each action to the event logged for it in the activity log.
+var fileActivity = map[string]models.Event{
+	fileActionUpload:          server.ActivityFtpWrite,
+	fileActionDelete:          server.ActivityFtpDelete,
+	fileActionRename:          server.ActivityFtpRename,
+	fileActionCreateDirectory: server.ActivityFtpCreateDirectory,
+}
+
+// fileChange is the data published with a server.FileChangedEvent.
+type fileChange struct {
+	Action string   `json:"action"`
+	User   string   `json:"user"`
+	Paths  []string `json:"paths"`
+}
+
+// fileChanged publishes an event on the server's event bus for a change made
+// over FTP so that connected clients can refresh their view of the files, and
+// records it in the activity log which is sent along to the Panel in batches.
+// When console notifications are enabled the change is also written to the
+// console, and it is sent to any webhooks subscribed to it. The cached
+// listings of the directories affected are dropped.
+func (driver *FTPDriver) fileChanged(s *server.Server, action string, paths ...string) {
+	driver.listingsChanged(s, paths...)
+	change := fileChange{Action: action, User: driver.user, Paths: make([]string, len(paths))}
+	for i, p := range paths {
+		change.Paths[i] = driver.serverPath(p)
+	}
+	s.Events().Publish(server.FileChangedEvent, change)
+	s.SaveActivity(s.NewRequestActivity("", driver.ip), fileActivity[action], activityMeta(change))
+
+	if driver.cfg.ConsoleNotifications {
+		s.PublishConsoleOutputFromDaemon(consoleMessage(change))
+	}
+	driver.notify(s, action, change.Paths...)
+}
+
+// consoleMessage returns a human-readable description of a file change.
+func consoleMessage(c fileChange) string {
+	var verb string
+	switch c.Action {
+	case fileActionUpload:
+		verb = "uploaded"
+	case fileActionDelete:
+		verb = "deleted"
+	case fileActionRename:
+		return fmt.Sprintf("%s renamed %s to %s over FTP", c.User, c.Paths[0], c.Paths[1])
+	case fileActionCreateDirectory:
+		verb = "created directory"
+	default:
+		verb = c.Action
+	}
+	return fmt.Sprintf("%s %s %s over FTP", c.User, verb, strings.Join(c.Paths, ", "))
+}
+
+// activityMeta returns the activity log metadata for a file change, using the
+// same layout as the metadata logged for SFTP events.
+func activityMeta(c fileChange) models.ActivityMeta {
+	meta := models.ActivityMeta{"username": c.User}
+	if c.Action == fileActionRename {
+		dir := path.Dir(c.Paths[0])
+		to, _ := filepath.Rel(dir, c.Paths[1])
+		to = filepath.ToSlash(to)
+		meta["directory"] = dir
+		meta["files"] = []map[string]string{{"from": path.Base(c.Paths[0]), "to": to}}
+		return meta
+	}
+	meta["files"] = c.Paths
+	return meta
+}
@@ -0,0 +1,145 @@
+package ftp
+
+import (
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The accounts of a server can be exported and imported on another node, such
+// as when the server is transferred, without their users having to be given
+// new passwords. Passwords are exported as bcrypt hashes rather than in
+// plaintext, and imported accounts keep the hash as their password, which is
+// checked the same way when they log in.
+
+// ErrInvalidPasswordHash is returned when importing an account whose password
+// is not a bcrypt hash.
+var ErrInvalidPasswordHash = errors.New("ftp: invalid password hash")
+
+// ExportedAccount is an FTP account as it is exported from a node.
+type ExportedAccount struct {
+	Account
+	PasswordHash string `json:"password_hash"`
+}
+
+// ImportResult lists the accounts that were imported, and those that were
+// skipped because they already exist on the node.
+type ImportResult struct {
+	Imported []string `json:"imported"`
+	Skipped  []string `json:"skipped"`
+}
+
+// isPasswordHash reports whether the stored password is a bcrypt hash rather
+// than the password itself.
+func isPasswordHash(stored string) bool {
+	if !strings.HasPrefix(stored, "$2") {
+		return false
+	}
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+// passwordMatches reports whether the password matches the one stored for an
+// account, which is either the password itself or a bcrypt hash of it.
+func passwordMatches(stored, password string) bool {
+	if isPasswordHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+// VerifyPassword reports whether the password is that of the FTP account.
+func VerifyPassword(username, password string) bool {
+	return verifyPassword(username, password)
+}
+
+// ExportAccounts returns the FTP accounts of the server with the given ID,
+// with a hash of their password.
+func ExportAccounts(serverID string) ([]ExportedAccount, error) {
+	accounts, err := ServerAccounts(serverID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ExportedAccount, 0, len(accounts))
+	for _, a := range accounts {
+		data, err := Credentials().read(a.Username, credentialPassword)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.WithStack(err)
+		}
+		hash := strings.TrimSpace(string(data))
+		if !isPasswordHash(hash) {
+			b, err := bcrypt.GenerateFromPassword([]byte(hash), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			hash = string(b)
+		}
+		out = append(out, ExportedAccount{Account: a, PasswordHash: hash})
+	}
+	return out, nil
+}
+
+// ImportAccounts adds the exported accounts to the node for the server with
+// the given ID. Accounts that already exist are skipped, unless overwrite is
+// set. Every account is checked before any of them are imported.
+func ImportAccounts(serverID string, accounts []ExportedAccount, overwrite bool) (ImportResult, error) {
+	res := ImportResult{Imported: []string{}, Skipped: []string{}}
+	for _, a := range accounts {
+		// Accounts mapped to the server may have any username not taken by
+		// an account of another server.
+		mapped := a.ServerID == serverID && canMapAccount(a.Username, serverID)
+		if !mapped && !AccountBelongsTo(a.Username, serverID) {
+			return res, errors.WithMessage(ErrInvalidUsername, a.Username)
+		}
+		if !isPasswordHash(a.PasswordHash) {
+			return res, errors.WithMessage(ErrInvalidPasswordHash, a.Username)
+		}
+		if a.Scopes != nil {
+			if _, err := validScopes(a.Scopes); err != nil {
+				return res, err
+			}
+		}
+	}
+	for _, a := range accounts {
+		if accountExists(a.Username) {
+			if !overwrite {
+				res.Skipped = append(res.Skipped, a.Username)
+				continue
+			}
+			if err := DeleteAccount(a.Username); err != nil && !errors.Is(err, ErrAccountNotFound) {
+				return res, err
+			}
+		}
+		if err := importAccount(a); err != nil {
+			return res, err
+		}
+		res.Imported = append(res.Imported, a.Username)
+	}
+	return res, nil
+}
+
+// importAccount writes the files of an exported account.
+func importAccount(a ExportedAccount) error {
+	store := Credentials()
+	if err := store.write(a.Username, credentialPassword, []byte(a.PasswordHash)); err != nil {
+		return err
+	}
+	if root := relativePath(a.Root); root != "" {
+		if err := store.write(a.Username, credentialRoot, []byte("/"+root)); err != nil {
+			return err
+		}
+	}
+	meta := a.accountMeta
+	if meta.Scopes != nil {
+		meta.Scopes, _ = validScopes(meta.Scopes)
+	}
+	return updateAccountMeta(a.Username, func(m *accountMeta) {
+		*m = meta
+	})
+}
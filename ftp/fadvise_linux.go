@@ -0,0 +1,32 @@
+//go:build linux
+
+package ftp
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// The advice given to the kernel about how a file is going to be read.
+const (
+	fadvSequential = unix.FADV_SEQUENTIAL
+	fadvWillNeed   = unix.FADV_WILLNEED
+	fadvDontNeed   = unix.FADV_DONTNEED
+)
+
+// fadvise gives the kernel advice about a range of the file. The file is
+// held open for the duration of the call.
+func fadvise(f *os.File, off, length int64, advice int) error {
+	return controlFile(f, func(fd int) error {
+		return unix.Fadvise(fd, off, length, advice)
+	})
+}
+
+// startWriteback starts writing the dirty pages of a range of the file to the
+// disk, without waiting for them to be written.
+func startWriteback(f *os.File, off, length int64) error {
+	return controlFile(f, func(fd int) error {
+		return unix.SyncFileRange(fd, off, length, unix.SYNC_FILE_RANGE_WRITE)
+	})
+}
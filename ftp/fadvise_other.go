@@ -0,0 +1,21 @@
+//go:build !linux
+
+package ftp
+
+import "os"
+
+// The page cache cannot be advised on this platform, so downloads read ahead
+// by reading the file themselves and transferred pages are left cached.
+const (
+	fadvSequential = iota
+	fadvWillNeed
+	fadvDontNeed
+)
+
+func fadvise(*os.File, int64, int64, int) error {
+	return errUnsupported
+}
+
+func startWriteback(*os.File, int64, int64) error {
+	return errUnsupported
+}
@@ -0,0 +1,135 @@
+package ftp
+
+import (
+	"sort"
+	"strings"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// ftpserverlib advertises a fixed list of extensions in reply to FEAT,
+// including some that are turned off, so clients try commands that are then
+// refused. FEAT is answered here instead, listing only the extensions in
+// ftpFeatures that are enabled for the listener.
+//
+// MODE Z is never listed, as ftpserverlib cannot compress transfers.
+
+// ftpFeature is an extension advertised in reply to FEAT.
+type ftpFeature struct {
+	// The line the extension is listed with.
+	name string
+	// Whether the extension is enabled on the listener, or nil if it always
+	// is.
+	enabled func(d *FTPServerDriver) bool
+	// The lines the extension is listed with instead of its name, if they
+	// depend on the connection.
+	lines func(c *controlConn) []string
+}
+
+// ftpFeatures contains every extension that can be advertised, in the order
+// they are listed.
+var ftpFeatures = []ftpFeature{
+	{name: "AUTH TLS", enabled: (*FTPServerDriver).explicitTLS},
+	{name: "PBSZ", enabled: (*FTPServerDriver).tlsEnabled},
+	{name: "PROT", enabled: (*FTPServerDriver).tlsEnabled},
+	{name: "UTF8", enabled: func(d *FTPServerDriver) bool { return !d.cfg.Features.DisableUTF8 }},
+	{name: "CLNT"},
+	{name: "SIZE"},
+	{name: "MDTM"},
+	{name: "MFMT"},
+	{name: "REST STREAM"},
+	{name: "EPRT"},
+	{name: "EPSV"},
+	{name: "AVBL"},
+	{name: "MLSD"},
+	{name: "MLST", lines: func(c *controlConn) []string {
+		return []string{"MLST " + formatFactList(c.selectedFacts(), true)}
+	}},
+	{name: "HASH", enabled: func(d *FTPServerDriver) bool { return d.cfg.Features.Hash }, lines: (*controlConn).hashFeatures},
+	{name: "SITE", enabled: func(d *FTPServerDriver) bool { return !d.cfg.Features.DisableSite }, lines: siteFeatures},
+}
+
+// The algorithms HASH can compute, by the names they are selected with, and
+// the one selected until the client picks another with OPTS HASH.
+var hashAlgorithms = []string{"SHA-256", "SHA-512", "SHA-1", "MD5", "CRC32"}
+
+const defaultHashAlgorithm = "SHA-256"
+
+// tlsEnabled reports whether connections to the listener can be encrypted.
+func (d *FTPServerDriver) tlsEnabled() bool {
+	return d.tls != nil
+}
+
+// explicitTLS reports whether clients can upgrade connections to the listener
+// with AUTH TLS, which is not the case for those encrypted from the start.
+func (d *FTPServerDriver) explicitTLS() bool {
+	return d.tlsEnabled() && tlsRequirement(d.settings) != ftpserver.ImplicitEncryption
+}
+
+// features returns the lines listed in reply to FEAT on the connection.
+func (c *controlConn) features() []string {
+	d := c.driver
+	if d == nil {
+		d = &FTPServerDriver{}
+	}
+	var lines []string
+	for _, f := range ftpFeatures {
+		if f.enabled != nil && !f.enabled(d) {
+			continue
+		}
+		if f.lines != nil {
+			lines = append(lines, f.lines(c)...)
+		} else {
+			lines = append(lines, f.name)
+		}
+	}
+	return lines
+}
+
+// feat answers FEAT with the extensions that are enabled.
+func (c *controlConn) feat() {
+	var b strings.Builder
+	b.WriteString("211-Extensions supported:\r\n")
+	for _, line := range c.features() {
+		b.WriteString(" " + line + "\r\n")
+	}
+	b.WriteString("211 End\r\n")
+	c.write(b.String())
+}
+
+// hashFeatures returns the lines HASH is listed with, marking the algorithm
+// selected on the connection, followed by the commands that compute a single
+// algorithm.
+func (c *controlConn) hashFeatures() []string {
+	selected := c.hashAlgorithm
+	if selected == "" {
+		selected = defaultHashAlgorithm
+	}
+	var b strings.Builder
+	b.WriteString("HASH ")
+	for _, name := range hashAlgorithms {
+		b.WriteString(name)
+		if name == selected {
+			b.WriteString("*")
+		}
+		b.WriteString(";")
+	}
+	return []string{b.String(), "XCRC", "MD5", "XMD5", "XSHA", "XSHA1", "XSHA256", "XSHA512"}
+}
+
+// siteFeatures returns a line for each of the SITE commands handled by Wings
+// that is enabled.
+func siteFeatures(c *controlConn) []string {
+	d := &FTPDriver{}
+	if c.driver != nil {
+		d.cfg = c.driver.cfg
+	}
+	lines := make([]string, 0, len(siteCommands))
+	for name, cmd := range siteCommands {
+		if cmd.enabled == nil || cmd.enabled(d) {
+			lines = append(lines, "SITE "+name)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
@@ -0,0 +1,86 @@
+package ftp
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// windowsReserved are the device names that cannot be used as the name of a
+// file on Windows, with or without an extension.
+var windowsReserved = map[string]struct{}{
+	"con": {}, "prn": {}, "aux": {}, "nul": {},
+	"com1": {}, "com2": {}, "com3": {}, "com4": {}, "com5": {}, "com6": {}, "com7": {}, "com8": {}, "com9": {},
+	"lpt1": {}, "lpt2": {}, "lpt3": {}, "lpt4": {}, "lpt5": {}, "lpt6": {}, "lpt7": {}, "lpt8": {}, "lpt9": {},
+}
+
+// checkNewPath returns an error if a file or directory may not be created at
+// the given path, either because of its name or because the path is longer or
+// more deeply nested than the configured limits.
+func (driver *FTPDriver) checkNewPath(p string) error {
+	if err := checkFileName(p); err != nil {
+		return err
+	}
+	rel := relativePath(driver.serverPath(p))
+	depth := 0
+	if rel != "" {
+		depth = strings.Count(rel, "/") + 1
+	}
+	var reason string
+	switch {
+	case driver.cfg.MaxPathLength > 0 && len(rel) > driver.cfg.MaxPathLength:
+		reason = "path is too long"
+	case driver.cfg.MaxPathDepth > 0 && depth > driver.cfg.MaxPathDepth:
+		reason = "path is nested too deeply"
+	default:
+		return nil
+	}
+	driver.logger().WithFields(log.Fields{
+		"path":   "/" + rel,
+		"length": len(rel),
+		"depth":  depth,
+	}).Warn("FTP path exceeding limits rejected")
+	return withReplyCode(ftpserver.StatusActionNotTakenNoFile, errors.Wrap(ftpserver.ErrFileNameNotAllowed, reason))
+}
+
+// checkFileName returns an error if a file or directory could not be safely
+// created at the given path. Names containing control characters or invalid
+// UTF-8, names ending in a space or dot, and reserved Windows device names are
+// all rejected since they cannot be managed through the Panel, or downloaded
+// to every operating system. The error is sent to the client as a 553 reply.
+func checkFileName(p string) error {
+	for _, name := range strings.Split(relativePath(p), "/") {
+		if name == "" {
+			continue
+		}
+		if reason := unsafeName(name); reason != "" {
+			return withReplyCode(ftpserver.StatusActionNotTakenNoFile, errors.Wrapf(ftpserver.ErrFileNameNotAllowed, "%q %s", name, reason))
+		}
+	}
+	return nil
+}
+
+// unsafeName returns the reason the given name is not allowed, or an empty
+// string if it is.
+func unsafeName(name string) string {
+	if !utf8.ValidString(name) {
+		return "is not valid UTF-8"
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "contains a control character"
+		}
+	}
+	if strings.HasSuffix(name, " ") || strings.HasSuffix(name, ".") {
+		return "ends with a space or dot"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if _, ok := windowsReserved[strings.ToLower(strings.TrimRight(base, " "))]; ok {
+		return "is a reserved name"
+	}
+	return ""
+}
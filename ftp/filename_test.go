@@ -0,0 +1,41 @@
+package ftp
+
+import (
+	"errors"
+	"testing"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFileName(t *testing.T) {
+	cases := []struct {
+		in string
+		ok bool
+	}{
+		{"/server.jar", true},
+		{"/plugins/.env", true},
+		{"/world/region/r.0.0.mca", true},
+		{"/console.log", true},
+		{"/données/café.txt", true},
+		{"/bad\x00name", false},
+		{"/line\nbreak.txt", false},
+		{"/invalid\xff.txt", false},
+		{"/trailing ", false},
+		{"/trailing.", false},
+		{"/CON", false},
+		{"/plugins/nul.txt", false},
+		{"/Lpt1 .log", false},
+		{"/bad./file.txt", false},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			err := checkFileName(c.in)
+			if c.ok {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ftpserver.ErrFileNameNotAllowed))
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package ftptest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// Client is a minimal FTP client for tests, which transfers files in binary
+// mode over extended passive data connections.
+type Client struct {
+	conn *textproto.Conn
+	raw  net.Conn
+	host string
+	// The configuration data connections are encrypted with, once the
+	// session has been upgraded with AuthTLS.
+	tls *tls.Config
+}
+
+// The port in the reply to EPSV, such as "Entering Extended Passive Mode
+// (|||40123|)".
+var epsvRegexp = regexp.MustCompile(`\(\|\|\|(\d+)\|\)`)
+
+// Dial connects to the FTP server at the address and reads its greeting.
+func Dial(addr string) (*Client, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: textproto.NewConn(raw), raw: raw, host: host}
+	if _, _, err := c.conn.ReadResponse(220); err != nil {
+		_ = c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// AuthTLS upgrades the control connection with AUTH TLS, and has data
+// connections encrypted from then on with PROT P.
+func (c *Client) AuthTLS(cfg *tls.Config) error {
+	if _, _, err := c.Cmd(234, "AUTH TLS"); err != nil {
+		return err
+	}
+	tc := tls.Client(c.raw, cfg)
+	if err := tc.Handshake(); err != nil {
+		return err
+	}
+	c.conn = textproto.NewConn(tc)
+	if _, _, err := c.Cmd(200, "PBSZ 0"); err != nil {
+		return err
+	}
+	if _, _, err := c.Cmd(200, "PROT P"); err != nil {
+		return err
+	}
+	c.tls = cfg
+	return nil
+}
+
+// Cmd sends a command and reads the reply, returning an error if its code
+// does not start with the digits of expectCode, as for
+// textproto.Conn.ReadResponse. A preliminary reply, as sent before the result
+// of a long SITE command, is skipped unless expectCode asks for one.
+func (c *Client) Cmd(expectCode int, format string, args ...any) (int, string, error) {
+	id, err := c.conn.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	c.conn.StartResponse(id)
+	defer c.conn.EndResponse(id)
+	for {
+		code, msg, err := c.conn.ReadResponse(expectCode)
+		if code/100 != 1 || strings.HasPrefix(strconv.Itoa(expectCode), "1") {
+			return code, msg, err
+		}
+	}
+}
+
+// Login logs in with the username and password and switches to binary mode.
+func (c *Client) Login(username, password string) error {
+	if _, _, err := c.Cmd(331, "USER %s", username); err != nil {
+		return err
+	}
+	if _, _, err := c.Cmd(230, "PASS %s", password); err != nil {
+		return err
+	}
+	_, _, err := c.Cmd(200, "TYPE I")
+	return err
+}
+
+// Store uploads the contents of the reader to the path.
+func (c *Client) Store(path string, r io.Reader) error {
+	return c.transfer("STOR "+path, func(conn net.Conn) error {
+		_, err := io.Copy(conn, r)
+		return err
+	})
+}
+
+// StoreInterrupted uploads the contents of the reader to the path and then
+// resets the data connection, as when the connection of a client drops part
+// way through an upload. An error is returned unless the transfer fails.
+func (c *Client) StoreInterrupted(path string, r io.Reader) error {
+	err := c.transfer("STOR "+path, func(conn net.Conn) error {
+		if _, err := io.Copy(conn, r); err != nil {
+			return err
+		}
+		return conn.(*net.TCPConn).SetLinger(0)
+	})
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return nil
+	}
+	if err == nil {
+		return errors.New("ftptest: interrupted upload succeeded")
+	}
+	return err
+}
+
+// Retrieve downloads the file at the path.
+func (c *Client) Retrieve(path string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := c.transfer("RETR "+path, func(conn net.Conn) error {
+		_, err := io.Copy(&buf, conn)
+		return err
+	})
+	return buf.Bytes(), err
+}
+
+// List returns the names of the files in the directory at the path, which
+// must not contain spaces.
+func (c *Client) List(path string) ([]string, error) {
+	var buf bytes.Buffer
+	err := c.transfer("NLST "+path, func(conn net.Conn) error {
+		_, err := io.Copy(&buf, conn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(buf.String()), nil
+}
+
+// Close ends the session and closes the connection.
+func (c *Client) Close() error {
+	_, _, err := c.Cmd(221, "QUIT")
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// transfer opens a data connection, sends the command, and hands the
+// connection to fn before reading the reply to the transfer.
+func (c *Client) transfer(cmd string, fn func(conn net.Conn) error) error {
+	_, msg, err := c.Cmd(229, "EPSV")
+	if err != nil {
+		return err
+	}
+	m := epsvRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		return errors.Errorf("ftptest: unexpected reply to EPSV: %s", msg)
+	}
+	data, err := net.Dial("tcp", net.JoinHostPort(c.host, m[1]))
+	if err != nil {
+		return err
+	}
+	if c.tls != nil {
+		data = tls.Client(data, c.tls)
+	}
+	id, err := c.conn.Cmd("%s", cmd)
+	if err != nil {
+		_ = data.Close()
+		return err
+	}
+	c.conn.StartResponse(id)
+	defer c.conn.EndResponse(id)
+	if _, _, err := c.conn.ReadResponse(1); err != nil {
+		_ = data.Close()
+		return err
+	}
+	err = fn(data)
+	if cerr := data.Close(); err == nil {
+		err = cerr
+	}
+	if _, _, rerr := c.conn.ReadResponse(2); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+// Package ftptest runs the FTP server for integration tests, with the files of
+// a server kept in memory and logins validated by a fake Panel, so that whole
+// sessions can be tested without a Pterodactyl install or Docker.
+//
+// The server is started with the default configuration, on a free port on the
+// loopback interface, and with logins validated by the Panel:
+//
+//	srv := ftptest.NewServer(t)
+//	srv.Panel.AddUser("alice", "secret")
+//	c, err := ftptest.Dial(srv.Addr)
+//	err = c.Login(srv.Username("alice"), "secret")
+//
+// The configuration is global, so tests using the package must not run in
+// parallel.
+package ftptest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/google/uuid"
+	"github.com/spf13/afero"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/ftp"
+	"github.com/pterodactyl/wings/internal/database"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+// How long the server is given to bind its listeners.
+const startTimeout = 5 * time.Second
+
+// Server is a running FTP server with a single game server on it.
+type Server struct {
+	// FTP is the FTP server itself.
+	FTP *ftp.FTPServer
+	// Manager holds the game server the FTP server serves.
+	Manager *server.Manager
+	// GameServer is the game server whose files are served.
+	GameServer *server.Server
+	// Panel validates the logins to the FTP server.
+	Panel *Panel
+	// Files are the files of the game server, rooted at its directory.
+	Files afero.Fs
+	// Addr is the address of the FTP listener.
+	Addr string
+}
+
+// The activity log of servers is written to a database that can only be
+// opened once in a process, so it is shared by every test.
+var (
+	dbOnce sync.Once
+	dbErr  error
+)
+
+// NewServer starts an FTP server for the test, which is stopped when the test
+// ends. The configure functions are called with the configuration before the
+// server is started, to change the defaults.
+func NewServer(t testing.TB, configure ...func(*config.Configuration)) *Server {
+	t.Helper()
+	root := t.TempDir()
+
+	cfg := &config.Configuration{}
+	if err := defaults.Set(cfg); err != nil {
+		t.Fatalf("ftptest: failed to set configuration defaults: %v", err)
+	}
+	cfg.AuthenticationToken = "ftptest"
+	cfg.System.RootDirectory = root
+	cfg.System.Data = filepath.Join(root, "volumes")
+	cfg.System.Ftp.CredentialsPath = filepath.Join(root, "passwords")
+	cfg.System.Ftp.Address = "127.0.0.1"
+	cfg.System.Ftp.Port = 0
+	cfg.System.Ftp.Authentication = "panel"
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	if err := initDatabase(cfg); err != nil {
+		t.Fatalf("ftptest: failed to initialize activity database: %v", err)
+	}
+	config.Set(cfg)
+
+	id := uuid.NewString()
+	panel := NewPanel(id)
+	m := server.NewEmptyManager(panel)
+	s, err := m.InitServer(remote.ServerConfigurationResponse{
+		Settings: json.RawMessage(`{"uuid":"` + id + `"}`),
+	})
+	if err != nil {
+		t.Fatalf("ftptest: failed to create server: %v", err)
+	}
+	m.Add(s)
+
+	mem := afero.NewMemMapFs()
+	dir := filepath.Join(cfg.System.Data, id)
+	if err := mem.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("ftptest: failed to create server directory: %v", err)
+	}
+
+	srv := &Server{
+		FTP:        ftp.New(m, panel),
+		Manager:    m,
+		GameServer: s,
+		Panel:      panel,
+		Files:      afero.NewBasePathFs(mem, dir),
+	}
+	srv.FTP.SetBackend(ftp.NewAferoBackend(mem))
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.FTP.Run()
+	}()
+	t.Cleanup(func() {
+		if err := srv.FTP.Shutdown(context.Background()); err != nil {
+			t.Errorf("ftptest: failed to stop FTP server: %v", err)
+		}
+		<-errs
+	})
+
+	deadline := time.Now().Add(startTimeout)
+	for {
+		if addrs := srv.FTP.Addrs(); len(addrs) > 0 {
+			srv.Addr = addrs[0]
+			return srv
+		}
+		select {
+		case err := <-errs:
+			errs <- err
+			t.Fatalf("ftptest: failed to start FTP server: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ftptest: FTP server did not start within %s", startTimeout)
+		}
+	}
+}
+
+// Username returns the FTP username the user logs in to the game server with.
+func (s *Server) Username(user string) string {
+	return user + "_" + s.GameServer.ID()[:8]
+}
+
+// initDatabase opens the activity database, if it has not been opened yet, in
+// a directory of its own rather than that of the test that opens it, which is
+// removed when the test ends.
+func initDatabase(cfg *config.Configuration) error {
+	dbOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "ftptest-")
+		if err != nil {
+			dbErr = err
+			return
+		}
+		root := cfg.System.RootDirectory
+		cfg.System.RootDirectory = dir
+		config.Set(cfg)
+		dbErr = database.Initialize()
+		cfg.System.RootDirectory = root
+	})
+	return dbErr
+}
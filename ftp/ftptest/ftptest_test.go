@@ -0,0 +1,405 @@
+package ftptest_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/ftp/ftptest"
+	"github.com/pterodactyl/wings/remote"
+)
+
+func TestSession(t *testing.T) {
+	srv := ftptest.NewServer(t)
+	srv.Panel.AddUser("alice", "secret")
+	srv.Panel.AddUser("bob", "secret", "file.read", "file.read-content")
+	require.NoError(t, afero.WriteFile(srv.Files, "/server.properties", []byte("motd=hello"), 0o644))
+
+	t.Run("rejects bad passwords", func(t *testing.T) {
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		defer c.Close()
+		_, _, err = c.Cmd(331, "USER %s", srv.Username("alice"))
+		require.NoError(t, err)
+		code, _, err := c.Cmd(230, "PASS wrong")
+		assert.Error(t, err)
+		assert.Equal(t, 530, code)
+	})
+
+	t.Run("uploads and downloads files", func(t *testing.T) {
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		defer c.Close()
+		require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+
+		b, err := c.Retrieve("/server.properties")
+		require.NoError(t, err)
+		assert.Equal(t, "motd=hello", string(b))
+
+		require.NoError(t, c.Store("/world.dat", strings.NewReader("level")))
+		b, err = afero.ReadFile(srv.Files, "/world.dat")
+		require.NoError(t, err)
+		assert.Equal(t, "level", string(b))
+
+		names, err := c.List("/")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"server.properties", "world.dat"}, names)
+	})
+
+	t.Run("applies Panel permissions", func(t *testing.T) {
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		defer c.Close()
+		require.NoError(t, c.Login(srv.Username("bob"), "secret"))
+
+		_, err = c.Retrieve("/server.properties")
+		assert.NoError(t, err)
+		assert.Error(t, c.Store("/bob.txt", strings.NewReader("hi")))
+		_, err = srv.Files.Stat("/bob.txt")
+		assert.Error(t, err)
+	})
+}
+
+func TestStatus(t *testing.T) {
+	srv := ftptest.NewServer(t, func(cfg *config.Configuration) {
+		cfg.System.Ftp.PerConnectionBandwidth = 4096
+	})
+	srv.Panel.AddUser("alice", "secret")
+
+	c, err := ftptest.Dial(srv.Addr)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+	require.NoError(t, c.Store("/world.dat", strings.NewReader("level")))
+
+	_, msg, err := c.Cmd(211, "STAT")
+	require.NoError(t, err)
+	assert.Contains(t, msg, "Logged in as "+srv.Username("alice"))
+	assert.Contains(t, msg, "Server: "+srv.GameServer.ID())
+	assert.Contains(t, msg, "Connection: not encrypted")
+	assert.Contains(t, msg, "Transferred: 5 B uploaded in 1 file, 0 B downloaded in 0 files")
+	assert.Contains(t, msg, "Bandwidth limits: connection 4096 KiB/s, server unlimited,")
+}
+
+func TestQuota(t *testing.T) {
+	srv := ftptest.NewServer(t)
+	srv.Panel.AddUser("alice", "secret")
+	srv.GameServer.Filesystem().SetDiskLimit(1 << 20)
+
+	c, err := ftptest.Dial(srv.Addr)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+
+	_, msg, err := c.Cmd(200, "SITE QUOTA")
+	require.NoError(t, err)
+	assert.Equal(t, "QUOTA limit=1048576 used=0 available=1048576", msg)
+
+	_, msg, err = c.Cmd(213, "AVBL")
+	require.NoError(t, err)
+	assert.Equal(t, "1048576", msg)
+}
+
+func TestSiteHelp(t *testing.T) {
+	srv := ftptest.NewServer(t)
+	srv.Panel.AddUser("alice", "secret")
+	srv.Panel.AddUser("bob", "secret", "file.read", "file.read-content")
+
+	help := func(username string) string {
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		defer c.Close()
+		require.NoError(t, c.Login(srv.Username(username), "secret"))
+		_, msg, err := c.Cmd(214, "SITE HELP")
+		require.NoError(t, err)
+		return msg
+	}
+
+	msg := help("alice")
+	for _, name := range []string{"CHECK", "COMPRESS", "CPTO", "QUOTA", "HELP"} {
+		assert.Contains(t, msg, "SITE "+name)
+	}
+	assert.NotContains(t, msg, "SITE BACKUP", "backups are created through the Panel")
+	assert.NotContains(t, msg, "EMPTYTRASH", "trash is not enabled")
+
+	msg = help("bob")
+	assert.Contains(t, msg, "SITE CHECK <path>")
+	assert.Contains(t, msg, "SITE QUOTA")
+	for _, name := range []string{"COMPRESS", "CPTO", "DECOMPRESS"} {
+		assert.NotContains(t, msg, "SITE "+name)
+	}
+
+	c, err := ftptest.Dial(srv.Addr)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Login(srv.Username("bob"), "secret"))
+	_, msg, err = c.Cmd(214, "SITE HELP quota")
+	require.NoError(t, err)
+	assert.Equal(t, "SITE QUOTA: Show the disk limit, usage, and space left in bytes", msg)
+	code, _, err := c.Cmd(2, "SITE DECOMPRESS world.zip world")
+	assert.Error(t, err)
+	assert.Equal(t, 550, code)
+}
+
+func TestResumeUpload(t *testing.T) {
+	srv := ftptest.NewServer(t, func(cfg *config.Configuration) {
+		cfg.System.Ftp.Resume.Enabled = true
+	})
+	srv.Panel.AddUser("alice", "secret")
+	content := strings.Repeat("0123456789", 100000)
+
+	c, err := ftptest.Dial(srv.Addr)
+	require.NoError(t, err)
+	require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+	require.NoError(t, c.StoreInterrupted("/world.zip", strings.NewReader(content[:500000])))
+	require.NoError(t, c.Close())
+
+	_, err = srv.Files.Stat("/world.zip")
+	assert.True(t, os.IsNotExist(err), "the upload is kept in a partial file")
+
+	c, err = ftptest.Dial(srv.Addr)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+
+	names, err := c.List("/")
+	require.NoError(t, err)
+	assert.Empty(t, names, "partial files are hidden")
+
+	_, msg, err := c.Cmd(213, "SIZE /world.zip")
+	require.NoError(t, err)
+	received, err := strconv.Atoi(msg)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, received, 500000)
+
+	_, _, err = c.Cmd(350, "REST %d", received+1)
+	require.NoError(t, err)
+	assert.Error(t, c.Store("/world.zip", strings.NewReader(content[received+1:])), "cannot resume beyond the bytes received")
+	_, msg, err = c.Cmd(213, "SIZE /world.zip")
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(received), msg, "the partial file is kept")
+
+	_, _, err = c.Cmd(350, "REST %d", received)
+	require.NoError(t, err)
+	require.NoError(t, c.Store("/world.zip", strings.NewReader(content[received:])))
+
+	b, err := afero.ReadFile(srv.Files, "/world.zip")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(b))
+	names, err = c.List("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"world.zip"}, names)
+}
+
+func TestSync(t *testing.T) {
+	srv := ftptest.NewServer(t)
+	srv.Panel.AddUser("alice", "secret")
+
+	c, err := ftptest.Dial(srv.Addr)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+
+	old := strings.Repeat("a", 4096) + strings.Repeat("b", 4096) + strings.Repeat("c", 100)
+	require.NoError(t, c.Store("/world.dat", strings.NewReader(old)))
+
+	_, msg, err := c.Cmd(213, "SITE SYNC SUMS /world.dat 4096")
+	require.NoError(t, err)
+	lines := strings.Split(msg, "\n")
+	require.Len(t, lines, 5)
+	assert.Equal(t, "SYNC 8292 4096", lines[0])
+	sum := sha256.Sum256([]byte(strings.Repeat("b", 4096)))
+	assert.Equal(t, hex.EncodeToString(sum[:]), lines[2])
+
+	updated := old[:4096] + strings.Repeat("B", 4096) + strings.Repeat("d", 200)
+	sum = sha256.Sum256([]byte(updated))
+	delta := fmt.Sprintf("WINGS-SYNC 1 4096 %d %x\n1 4096\n%s2 200\n%s",
+		len(updated), sum, strings.Repeat("B", 4096), strings.Repeat("d", 200))
+
+	t.Run("applies the changed blocks", func(t *testing.T) {
+		require.NoError(t, c.Store("/world.dat.delta", strings.NewReader(delta)))
+		_, _, err := c.Cmd(250, "SITE SYNC APPLY /world.dat.delta /world.dat")
+		require.NoError(t, err)
+
+		b, err := afero.ReadFile(srv.Files, "/world.dat")
+		require.NoError(t, err)
+		assert.Equal(t, updated, string(b))
+		_, err = srv.Files.Stat("/world.dat.delta")
+		assert.True(t, os.IsNotExist(err), "the delta is removed once applied")
+	})
+
+	t.Run("keeps the file if the result does not match", func(t *testing.T) {
+		require.NoError(t, c.Store("/world.dat.delta", strings.NewReader(strings.Replace(delta, "1 4096\n", "0 4096\n", 1))))
+		_, _, err := c.Cmd(550, "SITE SYNC APPLY /world.dat.delta /world.dat")
+		require.NoError(t, err)
+
+		b, err := afero.ReadFile(srv.Files, "/world.dat")
+		require.NoError(t, err)
+		assert.Equal(t, updated, string(b))
+		names, err := c.List("/")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"world.dat", "world.dat.delta"}, names)
+	})
+
+	t.Run("requires permission to delete the delta", func(t *testing.T) {
+		srv.Panel.AddUser("carol", "secret", "file.read", "file.create")
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		defer c.Close()
+		require.NoError(t, c.Login(srv.Username("carol"), "secret"))
+
+		_, msg, err := c.Cmd(550, "SITE SYNC APPLY /world.dat.delta /world.dat")
+		require.NoError(t, err)
+		assert.Contains(t, msg, "cannot delete")
+		_, err = srv.Files.Stat("/world.dat.delta")
+		assert.NoError(t, err)
+	})
+
+	t.Run("cannot apply a delta to itself", func(t *testing.T) {
+		_, msg, err := c.Cmd(550, "SITE SYNC APPLY /world.dat.delta /world.dat.delta")
+		require.NoError(t, err)
+		assert.Contains(t, msg, "itself")
+	})
+}
+
+func TestSyncProtectedDelta(t *testing.T) {
+	srv := ftptest.NewServer(t, func(cfg *config.Configuration) {
+		cfg.System.Ftp.ProtectedPaths = []string{"*.delta"}
+	})
+	srv.Panel.AddUser("alice", "secret")
+	require.NoError(t, afero.WriteFile(srv.Files, "/world.dat", []byte("level"), 0o644))
+	sum := sha256.Sum256([]byte("LEVEL"))
+	delta := fmt.Sprintf("WINGS-SYNC 1 4096 5 %x\n0 5\nLEVEL", sum)
+	require.NoError(t, afero.WriteFile(srv.Files, "/world.dat.delta", []byte(delta), 0o644))
+
+	c, err := ftptest.Dial(srv.Addr)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+
+	_, msg, err := c.Cmd(550, "SITE SYNC APPLY /world.dat.delta /world.dat")
+	require.NoError(t, err)
+	assert.Contains(t, msg, "protected")
+	b, err := afero.ReadFile(srv.Files, "/world.dat")
+	require.NoError(t, err)
+	assert.Equal(t, "level", string(b))
+	_, err = srv.Files.Stat("/world.dat.delta")
+	assert.NoError(t, err)
+}
+
+func TestPower(t *testing.T) {
+	srv := ftptest.NewServer(t)
+	srv.Panel.AddUser("alice", "secret")
+	srv.Panel.AddUser("bob", "secret", "file.read", "control.stop")
+
+	t.Run("requires the Panel permission", func(t *testing.T) {
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		defer c.Close()
+		require.NoError(t, c.Login(srv.Username("bob"), "secret"))
+
+		_, msg, err := c.Cmd(550, "SITE START")
+		require.NoError(t, err)
+		assert.Contains(t, msg, "control.start")
+	})
+
+	t.Run("refuses to start a suspended server", func(t *testing.T) {
+		require.NoError(t, srv.GameServer.SyncWithConfiguration(remote.ServerConfigurationResponse{
+			Settings: json.RawMessage(`{"uuid":"` + srv.GameServer.ID() + `","suspended":true}`),
+		}))
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		defer c.Close()
+		require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+
+		for _, cmd := range []string{"SITE START", "SITE RESTART"} {
+			_, msg, err := c.Cmd(550, cmd)
+			require.NoError(t, err)
+			assert.Contains(t, msg, "suspended")
+		}
+	})
+}
+
+func TestRequiredTLS(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+	srv := ftptest.NewServer(t, func(cfg *config.Configuration) {
+		cfg.System.Ftp.Listeners = []config.FtpListenerConfiguration{{
+			Address: "127.0.0.1",
+			TLS:     config.FtpTLSConfiguration{Mode: "required", CertificateFile: certFile, KeyFile: keyFile},
+		}}
+	})
+	srv.Panel.AddUser("alice", "secret")
+
+	t.Run("refuses logins before AUTH TLS", func(t *testing.T) {
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		_, msg, err := c.Cmd(421, "USER %s", srv.Username("alice"))
+		require.NoError(t, err)
+		assert.Equal(t, "TLS is required", msg)
+	})
+
+	t.Run("intercepts commands once upgraded", func(t *testing.T) {
+		c, err := ftptest.Dial(srv.Addr)
+		require.NoError(t, err)
+		defer c.Close()
+		require.NoError(t, c.AuthTLS(&tls.Config{InsecureSkipVerify: true}))
+		require.NoError(t, c.Login(srv.Username("alice"), "secret"))
+
+		_, msg, err := c.Cmd(211, "FEAT")
+		require.NoError(t, err)
+		assert.Contains(t, msg, "SITE QUOTA")
+		_, msg, err = c.Cmd(211, "STAT")
+		require.NoError(t, err)
+		assert.Contains(t, msg, "Connection: TLS")
+		_, _, err = c.Cmd(200, "SITE QUOTA")
+		require.NoError(t, err)
+
+		require.NoError(t, c.Store("/world.dat", strings.NewReader("level")))
+		b, err := c.Retrieve("/world.dat")
+		require.NoError(t, err)
+		assert.Equal(t, "level", string(b))
+	})
+}
+
+// writeCertificate writes a self-signed certificate and its key for the test,
+// returning the paths of the files.
+func writeCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wings"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certFile, keyFile
+}
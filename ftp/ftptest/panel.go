@@ -0,0 +1,109 @@
+package ftptest
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/internal/models"
+	"github.com/pterodactyl/wings/remote"
+)
+
+var errNotImplemented = errors.New("ftptest: not implemented by the fake Panel")
+
+// Panel is a remote.Client that validates logins against the users added to
+// it, in place of a real Panel. Requests the FTP server does not make return
+// an error.
+type Panel struct {
+	mu     sync.Mutex
+	server string
+	users  map[string]panelUser
+}
+
+type panelUser struct {
+	password    string
+	permissions []string
+}
+
+var _ remote.Client = (*Panel)(nil)
+
+// NewPanel returns a Panel that grants the users added to it access to the
+// server with the given ID.
+func NewPanel(server string) *Panel {
+	return &Panel{server: server, users: make(map[string]panelUser)}
+}
+
+// AddUser adds a user who may log in with the password, and is granted the
+// Panel permissions given, or every permission if none are.
+func (p *Panel) AddUser(username, password string, permissions ...string) {
+	if len(permissions) == 0 {
+		permissions = []string{"*"}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users[username] = panelUser{password: password, permissions: permissions}
+}
+
+// ValidateSftpCredentials validates the username, in the user.{server-id}
+// format, and password of a login.
+func (p *Panel) ValidateSftpCredentials(_ context.Context, request remote.SftpAuthRequest) (remote.SftpAuthResponse, error) {
+	i := strings.LastIndexByte(request.User, '.')
+	if i < 0 || request.User[i+1:] != p.server[:8] {
+		return remote.SftpAuthResponse{}, &remote.SftpInvalidCredentialsError{}
+	}
+	user := request.User[:i]
+	p.mu.Lock()
+	u, ok := p.users[user]
+	p.mu.Unlock()
+	if !ok || u.password != request.Pass {
+		return remote.SftpAuthResponse{}, &remote.SftpInvalidCredentialsError{}
+	}
+	return remote.SftpAuthResponse{Server: p.server, User: user, Permissions: u.permissions}, nil
+}
+
+// SendActivityLogs discards the activity logs.
+func (p *Panel) SendActivityLogs(context.Context, []models.Activity) error {
+	return nil
+}
+
+func (p *Panel) GetBackupRemoteUploadURLs(context.Context, string, int64) (remote.BackupRemoteUploadResponse, error) {
+	return remote.BackupRemoteUploadResponse{}, errNotImplemented
+}
+
+func (p *Panel) GetInstallationScript(context.Context, string) (remote.InstallationScript, error) {
+	return remote.InstallationScript{}, errNotImplemented
+}
+
+func (p *Panel) GetServerConfiguration(context.Context, string) (remote.ServerConfigurationResponse, error) {
+	return remote.ServerConfigurationResponse{}, errNotImplemented
+}
+
+func (p *Panel) GetServers(context.Context, int) ([]remote.RawServerData, error) {
+	return nil, errNotImplemented
+}
+
+func (p *Panel) ResetServersState(context.Context) error {
+	return errNotImplemented
+}
+
+func (p *Panel) SetArchiveStatus(context.Context, string, bool) error {
+	return errNotImplemented
+}
+
+func (p *Panel) SetBackupStatus(context.Context, string, remote.BackupRequest) error {
+	return errNotImplemented
+}
+
+func (p *Panel) SendRestorationStatus(context.Context, string, bool) error {
+	return errNotImplemented
+}
+
+func (p *Panel) SetInstallationStatus(context.Context, string, remote.InstallStatusRequest) error {
+	return errNotImplemented
+}
+
+func (p *Panel) SetTransferStatus(context.Context, string, bool) error {
+	return errNotImplemented
+}
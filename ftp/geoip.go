@@ -0,0 +1,125 @@
+package ftp
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/oschwald/geoip2-golang"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// The decisions made for a connection based on the country it comes from.
+const (
+	geoAllow      = "allow"
+	geoBlock      = "block"
+	geoScrutinize = "scrutinize"
+)
+
+// geoIP looks up the country of an address in the configured MaxMind
+// database.
+type geoIP struct {
+	mu sync.RWMutex
+	db *geoip2.Reader
+}
+
+func newGeoIP() *geoIP {
+	return &geoIP{}
+}
+
+// open switches to the database at the given path, closing the one
+// previously used. An empty path disables the lookups.
+func (g *geoIP) open(p string) error {
+	var db *geoip2.Reader
+	if p != "" {
+		var err error
+		if db, err = geoip2.Open(p); err != nil {
+			return err
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.db != nil {
+		_ = g.db.Close()
+	}
+	g.db = db
+	return nil
+}
+
+// country returns the ISO code of the country of the address, or an empty
+// string if it is not known, such as for private addresses.
+func (g *geoIP) country(ip string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.db == nil {
+		return ""
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	c, err := g.db.Country(addr)
+	if err != nil {
+		return ""
+	}
+	return c.Country.IsoCode
+}
+
+// geoDecision returns what is done with a connection from the country.
+// Connections whose country is not known are allowed.
+func geoDecision(cfg config.FtpGeoIPConfiguration, country string) string {
+	if country == "" {
+		return geoAllow
+	}
+	match := func(codes []string) bool {
+		return slices.ContainsFunc(codes, func(c string) bool { return strings.EqualFold(c, country) })
+	}
+	switch {
+	case match(cfg.BlockedCountries), len(cfg.AllowedCountries) > 0 && !match(cfg.AllowedCountries):
+		return geoBlock
+	case match(cfg.ScrutinizedCountries):
+		return geoScrutinize
+	default:
+		return geoAllow
+	}
+}
+
+// geoBlocked returns the reason a new connection is rejected if it comes
+// from a country that is not allowed, and arranges for the greeting to be
+// sent with a 421 reply.
+func (d *FTPServerDriver) geoBlocked(cc ftpserver.ClientContext) string {
+	ip := remoteIP(cc.RemoteAddr())
+	country := d.geoip.country(ip)
+	if geoDecision(d.cfg.GeoIP, country) != geoBlock {
+		return ""
+	}
+	log.WithFields(log.Fields{"ip": ip, "country": country}).Warn("FTP connection rejected: country is not allowed")
+	if c := d.sessions.Conn(cc.RemoteAddr().String()); c != nil {
+		c.rejectCode.Store(ftpserver.StatusServiceNotAvailable)
+	}
+	return "Connections from your location are not allowed"
+}
+
+// scrutinizeLogin logs a login attempt from a scrutinized country and, if it
+// failed, waits before the reply is sent.
+func (d *FTPServerDriver) scrutinizeLogin(cc ftpserver.ClientContext, username string, err error) {
+	ip := remoteIP(cc.RemoteAddr())
+	country := d.geoip.country(ip)
+	if geoDecision(d.cfg.GeoIP, country) != geoScrutinize {
+		return
+	}
+	log.WithFields(log.Fields{
+		"username": username,
+		"ip":       ip,
+		"country":  country,
+		"success":  err == nil,
+	}).Warn("FTP login attempt from scrutinized country")
+	if err != nil && d.cfg.GeoIP.FailedLoginDelay > 0 {
+		time.Sleep(time.Duration(d.cfg.GeoIP.FailedLoginDelay) * time.Second)
+	}
+}
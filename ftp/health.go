@@ -0,0 +1,128 @@
+package ftp
+
+import (
+	"sync"
+	"time"
+)
+
+// How long errors are counted for in the health of the FTP server.
+const healthErrorWindow = time.Hour
+
+// Health is a summary of the state of the FTP server, used by the Panel and
+// monitoring to detect a listener that has stopped.
+type Health struct {
+	Listening bool             `json:"listening"`
+	Listeners []ListenerHealth `json:"listeners"`
+	// When the listeners were last started, and how long ago in seconds. These
+	// are reset when the configuration is reloaded.
+	StartedAt *time.Time `json:"started_at"`
+	Uptime    int64      `json:"uptime"`
+	// The number of authenticated sessions.
+	Sessions int `json:"sessions"`
+	// The number of errors of each kind in the last hour.
+	Errors    map[string]int `json:"errors"`
+	LastError *HealthError   `json:"last_error"`
+}
+
+// ListenerHealth is the state of a single listener.
+type ListenerHealth struct {
+	Address   string `json:"address"`
+	TLS       string `json:"tls"`
+	Listening bool   `json:"listening"`
+}
+
+// HealthError is an error that was recorded by the FTP server.
+type HealthError struct {
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// The kinds of errors counted in the health of the FTP server.
+const (
+	healthErrorListener = "listener"
+	healthErrorLogin    = "login"
+)
+
+// healthState tracks the information reported in the health of the server.
+type healthState struct {
+	mu        sync.Mutex
+	listening bool
+	startedAt time.Time
+	errors    []HealthError
+}
+
+func newHealthState() *healthState {
+	return &healthState{}
+}
+
+// started records that the listeners are accepting connections.
+func (h *healthState) started() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listening = true
+	h.startedAt = time.Now()
+}
+
+// stopped records that the listeners are no longer accepting connections.
+func (h *healthState) stopped() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listening = false
+}
+
+// error records an error of the given kind, and forgets those that happened
+// too long ago to be counted.
+func (h *healthState) error(kind string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prune()
+	h.errors = append(h.errors, HealthError{Kind: kind, Message: err.Error(), Time: time.Now()})
+}
+
+func (h *healthState) prune() {
+	cutoff := time.Now().Add(-healthErrorWindow)
+	i := 0
+	for i < len(h.errors) && h.errors[i].Time.Before(cutoff) {
+		i++
+	}
+	h.errors = h.errors[i:]
+}
+
+// Health returns the current state of the FTP server.
+func (c *FTPServer) Health() Health {
+	c.mu.Lock()
+	cfg := c.cfg
+	c.mu.Unlock()
+
+	h := c.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prune()
+
+	res := Health{
+		Listening: h.listening,
+		Sessions:  c.sessions.Len(),
+		Errors:    map[string]int{healthErrorListener: 0, healthErrorLogin: 0},
+	}
+	for _, l := range listenerConfigs(cfg) {
+		res.Listeners = append(res.Listeners, ListenerHealth{
+			Address:   listenAddress(l),
+			TLS:       l.TLS.Mode,
+			Listening: h.listening,
+		})
+	}
+	if h.listening {
+		started := h.startedAt
+		res.StartedAt = &started
+		res.Uptime = int64(time.Since(started).Seconds())
+	}
+	for _, e := range h.errors {
+		res.Errors[e.Kind]++
+	}
+	if n := len(h.errors); n > 0 {
+		last := h.errors[n-1]
+		res.LastError = &last
+	}
+	return res
+}
@@ -0,0 +1,78 @@
+package ftp
+
+import (
+	"net"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// ConnectionInfo is what a user needs to connect to a server over FTP, shown
+// by the Panel alongside the server.
+type ConnectionInfo struct {
+	Listeners []ListenerInfo `json:"listeners"`
+	// The username to log in with, where {username} is the name of the
+	// account without the suffix of the server.
+	UsernameFormat string `json:"username_format"`
+	// Whether FTP is read-only for the server.
+	ReadOnly bool `json:"read_only"`
+}
+
+// ListenerInfo is a listener the server can be connected to on.
+type ListenerInfo struct {
+	// The host to connect to. Empty if the listener is bound to every address
+	// and no public host is configured, in which case the address of the node
+	// should be used.
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// The TLS mode of the listener: "none", "explicit", "required", or
+	// "implicit".
+	TLS string `json:"tls"`
+	// Whether the listener is dedicated to the server, in which case the
+	// username can be given without the suffix of the server.
+	Dedicated        bool `json:"dedicated"`
+	PassivePortStart int  `json:"passive_port_start"`
+	PassivePortEnd   int  `json:"passive_port_end"`
+}
+
+// Info returns the connection details of the server with the given ID.
+func (c *FTPServer) Info(id string) ConnectionInfo {
+	c.mu.Lock()
+	cfg := c.cfg
+	c.mu.Unlock()
+
+	info := ConnectionInfo{
+		UsernameFormat: serverUsername("{username}", id),
+		ReadOnly:       cfg.ReadOnly || c.ServerReadOnly(id),
+	}
+	for _, l := range listenerConfigs(cfg) {
+		info.Listeners = append(info.Listeners, listenerInfo(l, false))
+	}
+	if port := c.DedicatedPort(id); port > 0 {
+		l := cfg.DedicatedPorts.Listener
+		l.Port = port
+		info.Listeners = append(info.Listeners, listenerInfo(l, true))
+	}
+	return info
+}
+
+// listenerInfo returns the connection details of a listener.
+func listenerInfo(l config.FtpListenerConfiguration, dedicated bool) ListenerInfo {
+	r := passivePortRange(l)
+	info := ListenerInfo{
+		Host:             l.PublicHost,
+		Port:             l.Port,
+		TLS:              l.TLS.Mode,
+		Dedicated:        dedicated,
+		PassivePortStart: r.Start,
+		PassivePortEnd:   r.End,
+	}
+	if info.Host == "" {
+		if ip := net.ParseIP(l.Address); ip != nil && !ip.IsUnspecified() {
+			info.Host = l.Address
+		}
+	}
+	if info.TLS == "" {
+		info.TLS = "none"
+	}
+	return info
+}
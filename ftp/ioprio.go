@@ -0,0 +1,43 @@
+package ftp
+
+import (
+	"github.com/pterodactyl/wings/config"
+)
+
+// Game servers and FTP transfers share the same disks, and a few large
+// uploads or downloads can take most of their bandwidth. The thread each
+// transfer runs on is given a lower I/O priority while the file is open, as
+// with ionice, which disk schedulers that support priorities such as BFQ use
+// to favor the servers. The controllers of cgroups cannot be applied to
+// single threads, and placing all of Wings in a cgroup would throttle
+// backups and everything else it does, so they are not used.
+
+// The I/O scheduling classes transfers can run in, as used by ioprio_set.
+const (
+	ioprioClassBestEffort = 2
+	ioprioClassIdle       = 3
+	ioprioClassShift      = 13
+)
+
+// ioPriority returns the I/O priority configured for transfers, or false if
+// transfers keep the priority of Wings.
+func ioPriority(cfg config.FtpIOPriorityConfiguration) (int, bool) {
+	switch cfg.Class {
+	case "best-effort":
+		return ioprioClassBestEffort<<ioprioClassShift | min(max(cfg.Level, 0), 7), true
+	case "idle":
+		return ioprioClassIdle << ioprioClassShift, true
+	default:
+		return 0, false
+	}
+}
+
+// lowerIOPriority gives the thread of the calling goroutine the I/O priority
+// configured for transfers, returning a function that restores it.
+func (driver *FTPDriver) lowerIOPriority() func() {
+	prio, ok := ioPriority(driver.cfg.IOPriority)
+	if !ok {
+		return func() {}
+	}
+	return setThreadIOPriority(prio)
+}
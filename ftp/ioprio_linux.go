@@ -0,0 +1,33 @@
+//go:build linux
+
+package ftp
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprioWhoProcess selects a single thread by its ID in ioprio_set.
+const ioprioWhoProcess = 1
+
+// setThreadIOPriority sets the I/O priority of the current thread, locking
+// the goroutine to it until the returned function restores the priority it
+// had. The priority is restored on the same thread even if the function is
+// called from another goroutine.
+func setThreadIOPriority(prio int) func() {
+	runtime.LockOSThread()
+	tid := unix.Gettid()
+	prev, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(tid), 0)
+	if errno == 0 {
+		_, _, errno = unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio))
+	}
+	if errno != 0 {
+		runtime.UnlockOSThread()
+		return func() {}
+	}
+	return func() {
+		_, _, _ = unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prev)
+		runtime.UnlockOSThread()
+	}
+}
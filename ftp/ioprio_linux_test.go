@@ -0,0 +1,40 @@
+package ftp
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func threadIOPriority(t *testing.T) int {
+	prio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(unix.Gettid()), 0)
+	if errno != 0 {
+		t.Fatalf("ioprio_get: %v", errno)
+	}
+	return int(prio)
+}
+
+func TestIOPriority(t *testing.T) {
+	prio, ok := ioPriority(config.FtpIOPriorityConfiguration{Class: "best-effort", Level: 9})
+	assert.True(t, ok)
+	assert.Equal(t, ioprioClassBestEffort<<ioprioClassShift|7, prio, "the level is clamped")
+
+	_, ok = ioPriority(config.FtpIOPriorityConfiguration{Class: "realtime"})
+	assert.False(t, ok, "transfers cannot be given a higher priority")
+
+	t.Run("restores the priority of the thread", func(t *testing.T) {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		before := threadIOPriority(t)
+
+		prio, _ := ioPriority(config.FtpIOPriorityConfiguration{Class: "idle"})
+		restore := setThreadIOPriority(prio)
+		assert.Equal(t, prio, threadIOPriority(t))
+		restore()
+		assert.Equal(t, before, threadIOPriority(t))
+	})
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package ftp
+
+// setThreadIOPriority does nothing, as the I/O priority of a thread cannot be
+// set on this platform.
+func setThreadIOPriority(int) func() {
+	return func() {}
+}
@@ -0,0 +1,39 @@
+package ftp
+
+import (
+	"sync"
+	"time"
+)
+
+// Routers that track connections by the data they carry drop the control
+// connection while it is idle during a long transfer, so the client never
+// receives the final reply and marks the transfer failed even though it
+// completed. TCP keepalives, set with the socket options, carry no data and
+// are not enough for them. While a passive data connection is open the
+// control connection can also be sent a Telnet NOP at an interval, which
+// RFC 959 clients strip from the replies they read.
+
+// telnetNOP is the Telnet "no operation" command, IAC NOP.
+var telnetNOP = []byte{0xff, 0xf1}
+
+// keepAlive sends a Telnet NOP to the client at the interval until the
+// returned function is called.
+func (c *controlConn) keepAlive(interval time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if _, err := c.out().Write(telnetNOP); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
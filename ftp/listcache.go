@@ -0,0 +1,119 @@
+package ftp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+// Listing a directory with tens of thousands of files, such as the root of a
+// modpack, takes seconds, and clients list the directory they are in again
+// every time they refresh. Listings are cached for a short time, shared by
+// every session on the node, and dropped as soon as anything beneath the
+// directory is changed over FTP. Changes made outside of FTP, such as by the
+// game itself, are seen once the listing expires.
+
+// The most directories whose listings are cached at once. Further listings
+// are not cached until some of them expire.
+const maxCachedListings = 1024
+
+// listingCache holds the entries of recently listed directories, keyed by
+// their real path.
+type listingCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedListing
+	// Incremented whenever a listing is invalidated, so that a listing read
+	// while a change was being made is not cached.
+	gen uint64
+}
+
+type cachedListing struct {
+	infos   []os.FileInfo
+	expires time.Time
+}
+
+func newListingCache() *listingCache {
+	return &listingCache{entries: make(map[string]*cachedListing)}
+}
+
+// get returns the cached entries of the directory, if they have not expired.
+func (lc *listingCache) get(dir string) ([]os.FileInfo, bool) {
+	if lc == nil {
+		return nil, false
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	l, ok := lc.entries[dir]
+	if !ok || time.Now().After(l.expires) {
+		return nil, false
+	}
+	return l.infos, true
+}
+
+// generation returns the generation to pass to put for a listing about to be
+// read.
+func (lc *listingCache) generation() uint64 {
+	if lc == nil {
+		return 0
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.gen
+}
+
+// put caches the entries of the directory for the given time, unless a
+// listing was invalidated since the generation was taken.
+func (lc *listingCache) put(dir string, infos []os.FileInfo, ttl time.Duration, gen uint64) {
+	if lc == nil || ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if gen != lc.gen {
+		return
+	}
+	if len(lc.entries) >= maxCachedListings {
+		for k, l := range lc.entries {
+			if now.After(l.expires) {
+				delete(lc.entries, k)
+			}
+		}
+		if len(lc.entries) >= maxCachedListings {
+			return
+		}
+	}
+	lc.entries[dir] = &cachedListing{infos: infos, expires: now.Add(ttl)}
+}
+
+// invalidate drops the listings of the directory containing the real path,
+// of the path itself, and of everything beneath it.
+func (lc *listingCache) invalidate(p string) {
+	if lc == nil {
+		return
+	}
+	prefix := p + string(filepath.Separator)
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.gen++
+	delete(lc.entries, filepath.Dir(p))
+	for k := range lc.entries {
+		if k == p || strings.HasPrefix(k, prefix) {
+			delete(lc.entries, k)
+		}
+	}
+}
+
+// listingsChanged drops the cached listings affected by a change to the given
+// request paths.
+func (driver *FTPDriver) listingsChanged(s *server.Server, paths ...string) {
+	for _, p := range paths {
+		if realPath, err := driver.buildPath(s, p); err == nil {
+			driver.listings.invalidate(realPath)
+		}
+	}
+}
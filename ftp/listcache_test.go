@@ -0,0 +1,51 @@
+package ftp
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListingCache(t *testing.T) {
+	infos := []os.FileInfo{virtualDirInfo{name: "world"}}
+
+	t.Run("expires listings", func(t *testing.T) {
+		lc := newListingCache()
+		lc.put("/srv/a", infos, time.Minute, lc.generation())
+		lc.put("/srv/b", infos, -time.Minute, lc.generation())
+		got, ok := lc.get("/srv/a")
+		assert.True(t, ok)
+		assert.Equal(t, infos, got)
+		_, ok = lc.get("/srv/b")
+		assert.False(t, ok)
+	})
+
+	t.Run("invalidates the parent and everything beneath", func(t *testing.T) {
+		lc := newListingCache()
+		for _, dir := range []string{"/srv", "/srv/world", "/srv/world/region", "/srv/worlds", "/srv/plugins"} {
+			lc.put(dir, infos, time.Minute, lc.generation())
+		}
+		lc.invalidate("/srv/world")
+		for dir, cached := range map[string]bool{
+			"/srv":              false,
+			"/srv/world":        false,
+			"/srv/world/region": false,
+			"/srv/worlds":       true,
+			"/srv/plugins":      true,
+		} {
+			_, ok := lc.get(dir)
+			assert.Equal(t, cached, ok, dir)
+		}
+	})
+
+	t.Run("does not cache listings read during a change", func(t *testing.T) {
+		lc := newListingCache()
+		gen := lc.generation()
+		lc.invalidate("/srv/world/level.dat")
+		lc.put("/srv/world", infos, time.Minute, gen)
+		_, ok := lc.get("/srv/world")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,195 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"net"
+	"strconv"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// The passive port range used by listeners that do not set their own.
+const (
+	defaultPassivePortStart = 40000
+	defaultPassivePortEnd   = 50000
+)
+
+// listenerConfigs returns the listeners configured for the FTP server. If
+// none are configured a single plain listener on the bind address and port is
+// used.
+func listenerConfigs(cfg config.FtpConfiguration) []config.FtpListenerConfiguration {
+	if len(cfg.Listeners) > 0 {
+		return cfg.Listeners
+	}
+	return []config.FtpListenerConfiguration{{Address: cfg.Address, Port: cfg.Port}}
+}
+
+// listenAddress returns the address a listener is bound to.
+func listenAddress(l config.FtpListenerConfiguration) string {
+	return net.JoinHostPort(l.Address, strconv.Itoa(l.Port))
+}
+
+// listenAddresses returns the addresses of every configured listener.
+func listenAddresses(cfg config.FtpConfiguration) []string {
+	var addrs []string
+	for _, l := range listenerConfigs(cfg) {
+		addrs = append(addrs, listenAddress(l))
+	}
+	return addrs
+}
+
+// passivePortRange returns the range of ports used for passive data
+// connections on a listener.
+func passivePortRange(l config.FtpListenerConfiguration) *ftpserver.PortRange {
+	r := &ftpserver.PortRange{Start: l.PassivePortStart, End: l.PassivePortEnd}
+	if r.Start <= 0 || r.End < r.Start {
+		r.Start, r.End = defaultPassivePortStart, defaultPassivePortEnd
+	}
+	return r
+}
+
+// tlsRequirement returns the TLS mode of a listener.
+func tlsRequirement(l config.FtpListenerConfiguration) ftpserver.TLSRequirement {
+	switch l.TLS.Mode {
+	case "required":
+		return ftpserver.MandatoryEncryption
+	case "implicit":
+		return ftpserver.ImplicitEncryption
+	default:
+		return ftpserver.ClearOrEncrypted
+	}
+}
+
+// libraryTLSRequirement returns the TLS mode ftpserverlib is told a listener
+// has. The control interceptor upgrades connections with AUTH TLS beneath
+// ftpserverlib, which never sees them as encrypted, so a listener requiring
+// TLS is enforced by the interceptor rather than by ftpserverlib.
+func libraryTLSRequirement(l config.FtpListenerConfiguration) ftpserver.TLSRequirement {
+	if r := tlsRequirement(l); r != ftpserver.MandatoryEncryption {
+		return r
+	}
+	return ftpserver.ClearOrEncrypted
+}
+
+// loadTLSConfig loads the certificate for a listener, returning nil if TLS is
+// not enabled for it.
+func loadTLSConfig(l config.FtpListenerConfiguration) (*tls.Config, error) {
+	switch l.TLS.Mode {
+	case "":
+		return nil, nil
+	case "explicit", "required", "implicit":
+	default:
+		return nil, errors.Errorf("ftp: unknown tls mode %q for listener %s", l.TLS.Mode, listenAddress(l))
+	}
+	cert, err := tls.LoadX509KeyPair(l.TLS.CertificateFile, l.TLS.KeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ftp: failed to load tls certificate for listener %s", listenAddress(l))
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// bind opens every listener in the configuration and returns the
+// ftpserverlib instances serving them. If any listener cannot be opened the
+// ones that were already opened are closed again.
+func (c *FTPServer) bind(cfg config.FtpConfiguration) ([]*ftpserver.FtpServer, error) {
+	if err := c.checkPassivePorts(cfg); err != nil {
+		return nil, err
+	}
+	var servers []*ftpserver.FtpServer
+	// The node-wide bandwidth limit is shared by every listener.
+	node := newFairLimiter(cfg.NodeBandwidth)
+	for _, lc := range listenerConfigs(cfg) {
+		s, err := c.listen(cfg, lc, node, "")
+		if err != nil {
+			for _, s := range servers {
+				_ = s.Stop()
+			}
+			return nil, err
+		}
+		servers = append(servers, s)
+	}
+	dav, err := c.listenWebDAV(cfg, node)
+	if err != nil {
+		for _, s := range servers {
+			_ = s.Stop()
+		}
+		return nil, err
+	}
+	c.mu.Lock()
+	c.node = node
+	c.dav = dav
+	c.mu.Unlock()
+	return servers, nil
+}
+
+// listen opens a listener and returns the ftpserverlib instance serving it.
+// If dedicated is set the listener only accepts logins to the server with
+// that ID.
+func (c *FTPServer) listen(cfg config.FtpConfiguration, lc config.FtpListenerConfiguration, node *fairLimiter, dedicated string) (*ftpserver.FtpServer, error) {
+	tlsConfig, err := loadTLSConfig(lc)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig = c.captureTLS(tlsConfig)
+	addr := listenAddress(lc)
+	l, err := listenTCP(lc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ftp: failed to bind control listener %s", addr)
+	}
+	l = &tunedListener{Listener: l, cfg: cfg.Socket, dscp: cfg.Socket.ControlDSCP}
+	var ln net.Listener = l
+	// ftpserverlib only wraps listeners it creates itself for implicit TLS, so
+	// it is done here, beneath the control interceptor.
+	if tlsRequirement(lc) == ftpserver.ImplicitEncryption {
+		ln = tls.NewListener(l, tlsConfig)
+	}
+	d := c.serverDriver(cfg, node)
+	d.listen = addr
+	d.listener = &controlListener{Listener: ln, sessions: c.sessions, driver: d}
+	d.settings = lc
+	d.tls = tlsConfig
+	d.dedicated = dedicated
+	s := ftpserver.NewFtpServer(d)
+	if cfg.LogCommands {
+		s.Logger = NewFTPLogger()
+	}
+	if err := s.Listen(); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// serverDriver returns a driver sharing the state of the FTPServer, for a
+// listener to set its own address and settings on.
+func (c *FTPServer) serverDriver(cfg config.FtpConfiguration, node *fairLimiter) *FTPServerDriver {
+	return &FTPServerDriver{
+		manager:   c.manager,
+		client:    c.client,
+		basePath:  c.BasePath,
+		readOnly:  cfg.ReadOnly,
+		sessions:  c.sessions,
+		stats:     c.stats,
+		locks:     c.locks,
+		transfers: c.transfers,
+		deletes:   c.deletes,
+		health:    c.health,
+		limits:    c.limits,
+		node:      node,
+		webhooks:  c.webhooks,
+		access:    c.access,
+		xferlog:   c.xferlog,
+		tracing:   c.tracing,
+		geoip:     c.geoip,
+		offenders: c.offenders,
+		cfg:       cfg,
+
+		readOnlyServers: c.readOnly,
+		listings:        c.listings,
+		backend:         c.backend,
+		resume:          c.resume,
+	}
+}
@@ -0,0 +1,71 @@
+package ftp
+
+import (
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+var errFileBusy = errors.New("file busy: another upload to this file is in progress")
+
+// writeLocks tracks the files that are currently being written to, keyed by
+// the server and the path relative to its root. This stops two writers from
+// interleaving their writes to the same file.
+type writeLocks struct {
+	mu    sync.Mutex
+	paths map[string]struct{}
+}
+
+func newWriteLocks() *writeLocks {
+	return &writeLocks{paths: make(map[string]struct{})}
+}
+
+// tryLock locks the given path on a server for writing, returning a function
+// that releases the lock. If the path is already locked false is returned.
+// When fold is true paths that differ only in case are treated as the same.
+func (l *writeLocks) tryLock(server string, p string, fold bool) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	key := server + ":" + relativePath(p)
+	if fold {
+		key = strings.ToLower(key)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.paths[key]; ok {
+		return nil, false
+	}
+	l.paths[key] = struct{}{}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.paths, key)
+			l.mu.Unlock()
+		})
+	}, true
+}
+
+// LockWrite locks a file on the server so that it cannot be uploaded to over
+// FTP while it is being written elsewhere. The returned function must be
+// called to release the lock. If the file is already being written to over
+// FTP false is returned.
+func (c *FTPServer) LockWrite(server string, p string) (func(), bool) {
+	c.mu.Lock()
+	fold := c.cfg.CaseInsensitive
+	c.mu.Unlock()
+	return c.locks.tryLock(server, p, fold)
+}
+
+// lockWrite locks the given path for writing by the session, returning an
+// error that is sent to the client as a 450 reply if it is already locked.
+func (driver *FTPDriver) lockWrite(server string, p string) (func(), error) {
+	unlock, ok := driver.locks.tryLock(server, p, driver.cfg.CaseInsensitive)
+	if !ok {
+		return nil, withReplyCode(ftpserver.StatusFileActionNotTaken, errFileBusy)
+	}
+	return unlock, nil
+}
@@ -4,31 +4,54 @@ import (
 	"fmt"
 
 	"github.com/apex/log"
+	golog "github.com/fclairamb/go-log"
 )
 
-// FTPLogger implements the FTP logger interface.
-type FTPLogger struct{}
+// FTPLogger passes the logs of ftpserverlib, which include every command
+// received and reply sent, to the log of Wings. Lines and parameters are
+// redacted before they are logged. It is only used when log_commands is
+// enabled, since it logs at least two lines for every command.
+type FTPLogger struct {
+	entry *log.Entry
+}
+
+var _ golog.Logger = (*FTPLogger)(nil)
+
+// NewFTPLogger returns a logger of ftpserverlib commands.
+func NewFTPLogger() *FTPLogger {
+	return &FTPLogger{entry: log.WithField("subsystem", "ftp")}
+}
+
+func (l *FTPLogger) with(keyvals []interface{}) *log.Entry {
+	keyvals = redactKeyvals(keyvals)
+	fields := make(log.Fields, len(keyvals)/2)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fields[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+	return l.entry.WithFields(fields)
+}
+
+func (l *FTPLogger) Debug(event string, keyvals ...interface{}) {
+	l.with(keyvals).Debug(event)
+}
+
+func (l *FTPLogger) Info(event string, keyvals ...interface{}) {
+	l.with(keyvals).Info(event)
+}
 
-func (l *FTPLogger) Print(sessionID string, message interface{}) {
-	log.WithField("session", sessionID).Debug(fmt.Sprint(message))
+func (l *FTPLogger) Warn(event string, keyvals ...interface{}) {
+	l.with(keyvals).Warn(event)
 }
 
-func (l *FTPLogger) Printf(sessionID string, format string, v ...interface{}) {
-	log.WithField("session", sessionID).Debugf(format, v...)
+func (l *FTPLogger) Error(event string, keyvals ...interface{}) {
+	l.with(keyvals).Error(event)
 }
 
-func (l *FTPLogger) PrintCommand(sessionID string, command string, params string) {
-	log.WithFields(log.Fields{
-		"session": sessionID,
-		"command": command,
-		"params":  params,
-	}).Debug("ftp command")
+func (l *FTPLogger) Panic(event string, keyvals ...interface{}) {
+	l.with(keyvals).Error(event)
+	panic(event)
 }
 
-func (l *FTPLogger) PrintResponse(sessionID string, code int, message string) {
-	log.WithFields(log.Fields{
-		"session": sessionID,
-		"code":    code,
-		"message": message,
-	}).Debug("ftp response")
+func (l *FTPLogger) With(keyvals ...interface{}) golog.Logger {
+	return &FTPLogger{entry: l.with(keyvals)}
 }
@@ -0,0 +1,138 @@
+package ftp
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// The mode of a maintenance window that blocks all access to the server,
+// rather than only making it read-only.
+const maintenanceBlocked = "blocked"
+
+// maintenanceLocation returns the timezone maintenance windows are defined in.
+var maintenanceLocation = sync.OnceValue(func() *time.Location {
+	loc, err := time.LoadLocation(config.Get().System.Timezone)
+	if err != nil {
+		log.WithField("error", err).Warn("ftp: failed to load timezone for maintenance windows, using UTC")
+		return time.UTC
+	}
+	return loc
+})
+
+// activeMaintenance returns the maintenance window for the server that is
+// active at the given time, or nil if there is none. A window that blocks
+// access takes priority over one that only makes the server read-only.
+func activeMaintenance(cfg config.FtpMaintenanceConfiguration, server string, t time.Time) *config.FtpMaintenanceWindow {
+	windows := append(append([]config.FtpMaintenanceWindow{}, cfg.Windows...), cfg.Servers[server]...)
+	var active *config.FtpMaintenanceWindow
+	for i, w := range windows {
+		if !windowActive(w, t) {
+			continue
+		}
+		if w.Mode == maintenanceBlocked {
+			return &windows[i]
+		}
+		if active == nil {
+			active = &windows[i]
+		}
+	}
+	return active
+}
+
+// windowActive reports whether the window is active at the given time.
+func windowActive(w config.FtpMaintenanceWindow, t time.Time) bool {
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	day := t.Weekday()
+	switch {
+	case from <= to && (now < from || now >= to):
+		return false
+	case from > to && now < to:
+		// The window started the day before and runs past midnight.
+		day = (day + 6) % 7
+	case from > to && now < from:
+		return false
+	}
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if len(d) >= 3 && strings.HasPrefix(strings.ToLower(day.String()), strings.ToLower(d[:3])) {
+			return true
+		}
+	}
+	return false
+}
+
+var errReadOnly = errors.New("read-only server")
+
+// maintenanceError returns the error sent to clients during a maintenance
+// window. Blocked windows are reported with a 421 reply.
+func maintenanceError(w *config.FtpMaintenanceWindow) error {
+	msg := w.Message
+	if w.Mode == maintenanceBlocked {
+		if msg == "" {
+			msg = "FTP is unavailable during scheduled maintenance"
+		}
+		return withReplyCode(ftpserver.StatusServiceNotAvailable, errors.New(msg))
+	}
+	if msg == "" {
+		msg = "server is read-only during scheduled maintenance"
+	}
+	return errors.New(msg)
+}
+
+// maintenance returns the maintenance window currently active for the server
+// the session is attached to, if any.
+func (driver *FTPDriver) maintenance() *config.FtpMaintenanceWindow {
+	if len(driver.cfg.Maintenance.Windows) == 0 && len(driver.cfg.Maintenance.Servers) == 0 {
+		return nil
+	}
+	s, err := driver.getServer()
+	if err != nil {
+		return nil
+	}
+	return activeMaintenance(driver.cfg.Maintenance, s.ID(), time.Now().In(maintenanceLocation()))
+}
+
+// checkReadOnly returns an error if the session is not currently allowed to
+// write to the server, either because FTP is read-only for the node, the
+// account or the server, or because of a maintenance window.
+func (driver *FTPDriver) checkReadOnly() error {
+	if driver.ReadOnly {
+		return errReadOnly
+	}
+	if driver.readOnlyServers != nil && driver.server != nil && driver.readOnlyServers.has(driver.server.ID()) {
+		return errReadOnly
+	}
+	if w := driver.maintenance(); w != nil {
+		return maintenanceError(w)
+	}
+	return nil
+}
+
+// checkBlocked returns an error if all access to the server is blocked by a
+// maintenance window.
+func (driver *FTPDriver) checkBlocked() error {
+	if w := driver.maintenance(); w != nil && w.Mode == maintenanceBlocked {
+		return maintenanceError(w)
+	}
+	return nil
+}
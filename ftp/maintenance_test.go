@@ -0,0 +1,57 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestActiveMaintenance(t *testing.T) {
+	cfg := config.FtpMaintenanceConfiguration{
+		Windows: []config.FtpMaintenanceWindow{
+			{Start: "03:00", End: "04:00", Mode: "read-only"},
+			{Days: []string{"sun"}, Start: "23:30", End: "01:00", Mode: "blocked"},
+		},
+		Servers: map[string][]config.FtpMaintenanceWindow{
+			"abc": {{Days: []string{"Wednesday"}, Start: "12:00", End: "12:30", Mode: "blocked"}},
+		},
+	}
+
+	// 2024-06-02 is a Sunday.
+	at := func(day int, clock string) time.Time {
+		c, _ := time.Parse("15:04", clock)
+		return time.Date(2024, 6, day, c.Hour(), c.Minute(), 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name   string
+		server string
+		t      time.Time
+		mode   string
+	}{
+		{"before daily window", "xyz", at(3, "02:59"), ""},
+		{"daily window", "xyz", at(3, "03:00"), "read-only"},
+		{"end of daily window", "xyz", at(3, "04:00"), ""},
+		{"overnight window start", "xyz", at(2, "23:45"), "blocked"},
+		{"overnight window after midnight", "xyz", at(3, "00:30"), "blocked"},
+		{"overnight window on other day", "xyz", at(4, "00:30"), ""},
+		{"overnight window before start", "xyz", at(2, "23:00"), ""},
+		{"server window", "abc", at(5, "12:15"), "blocked"},
+		{"server window for other server", "xyz", at(5, "12:15"), ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := activeMaintenance(cfg, c.server, c.t)
+			if c.mode == "" {
+				assert.Nil(t, w)
+				return
+			}
+			if assert.NotNil(t, w) {
+				assert.Equal(t, c.mode, w.Mode)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package ftp
+
+import (
+	"syscall"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+// Hosts selling FTP under their own brand, or to customers who do not speak
+// English, can replace the text of the replies clients see most often. The
+// messages of the configured language are used, falling back to the ones
+// given without a language, and then to the built-in ones.
+
+var errTooManySessions = errors.New("too many sessions for this server, try again later")
+
+// customMessage returns the configured text of a message, or an empty string
+// if it has not been replaced.
+func customMessage(cfg config.FtpMessagesConfiguration, pick func(config.FtpMessages) string) string {
+	if m, ok := cfg.Languages[cfg.Language]; ok {
+		if text := pick(m); text != "" {
+			return text
+		}
+	}
+	return pick(cfg.FtpMessages)
+}
+
+func loginFailedMessage(m config.FtpMessages) string   { return m.LoginFailed }
+func readOnlyMessage(m config.FtpMessages) string      { return m.ReadOnly }
+func quotaExceededMessage(m config.FtpMessages) string { return m.QuotaExceeded }
+func serverBusyMessage(m config.FtpMessages) string    { return m.ServerBusy }
+
+// customizeReply replaces the message of a reply with the configured one, if
+// the error is one whose message can be replaced.
+func customizeReply(cfg config.FtpMessagesConfiguration, err error) error {
+	var re *replyError
+	if !errors.As(err, &re) {
+		return err
+	}
+	var msg string
+	switch {
+	case errors.Is(err, ftpserver.ErrStorageExceeded), errors.Is(err, syscall.EDQUOT),
+		filesystem.IsErrorCode(err, filesystem.ErrCodeDiskSpace):
+		msg = customMessage(cfg, quotaExceededMessage)
+	case errors.Is(err, errReadOnly):
+		msg = customMessage(cfg, readOnlyMessage)
+	case errors.Is(err, errTooManyTransfers):
+		msg = customMessage(cfg, serverBusyMessage)
+	}
+	if msg == "" {
+		return err
+	}
+	return &replyError{code: re.code, err: re.err, msg: msg}
+}
+
+// authMessage returns the configured reply to a failed login, or an empty
+// string to send the default one.
+func authMessage(cfg config.FtpMessagesConfiguration, err error) string {
+	var ce *credentialError
+	switch {
+	case errors.As(err, &ce), errors.Is(err, errTarpitted):
+		return customMessage(cfg, loginFailedMessage)
+	case errors.Is(err, errTooManySessions):
+		return customMessage(cfg, serverBusyMessage)
+	}
+	return ""
+}
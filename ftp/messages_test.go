@@ -0,0 +1,40 @@
+package ftp
+
+import (
+	"testing"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestMessages(t *testing.T) {
+	cfg := config.FtpMessagesConfiguration{
+		Language:    "de",
+		FtpMessages: config.FtpMessages{LoginFailed: "Login failed.", ReadOnly: "Read-only."},
+		Languages: map[string]config.FtpMessages{
+			"de": {LoginFailed: "Anmeldung fehlgeschlagen."},
+		},
+	}
+
+	t.Run("prefers the configured language", func(t *testing.T) {
+		assert.Equal(t, "Anmeldung fehlgeschlagen.", authMessage(cfg, badCredentials("invalid password")))
+		assert.Equal(t, "Anmeldung fehlgeschlagen.", authMessage(cfg, errTarpitted))
+		assert.Equal(t, "Read-only.", customizeReply(cfg, translateError(errReadOnly)).Error())
+	})
+
+	t.Run("falls back to the built-in messages", func(t *testing.T) {
+		assert.Empty(t, authMessage(cfg, errTooManySessions))
+		err := customizeReply(cfg, translateError(errInsufficientSpace))
+		assert.Equal(t, "disk space limit of the server exceeded", err.Error())
+		assert.Empty(t, authMessage(config.FtpMessagesConfiguration{}, badCredentials("invalid password")))
+	})
+
+	t.Run("keeps the reply code", func(t *testing.T) {
+		cfg := config.FtpMessagesConfiguration{FtpMessages: config.FtpMessages{ServerBusy: "Busy."}}
+		err := customizeReply(cfg, translateError(withReplyCode(ftpserver.StatusFileActionNotTaken, errTooManyTransfers)))
+		assert.Equal(t, "Busy.", err.Error())
+		assert.Equal(t, ftpserver.StatusFileActionNotTaken, err.(*replyError).code)
+	})
+}
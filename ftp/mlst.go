@@ -0,0 +1,215 @@
+package ftp
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// ftpserverlib only returns the type, size, and modify facts for MLST and MLSD
+// entries. Wings answers MLST itself, and rewrites the entries that the
+// library sends over the data connection for MLSD, so that the full set of
+// facts below is available to clients.
+
+// mlstFacts are the facts supported by Wings, in the order they are written.
+var mlstFacts = []string{"Type", "Size", "Modify", "Create", "Perm", "Unique"}
+
+// mlsxEntry matches an entry written by ftpserverlib for MLSD.
+var mlsxEntry = regexp.MustCompile(`^Type=(?:file|dir);Size=\d+;Modify=\d{14}; (.+)\r\n$`)
+
+const mlsxTimeFormat = "20060102150405"
+
+// mlsdListing is the directory being listed by an MLSD command along with the
+// facts that were selected when it was issued.
+type mlsdListing struct {
+	dir   string
+	facts []string
+}
+
+// selectFacts handles "OPTS MLST" which selects the facts returned in MLST and
+// MLSD entries. Unsupported facts are ignored.
+func (c *controlConn) selectFacts(params string) (int, string) {
+	facts := []string{}
+	for _, f := range strings.Split(params, ";") {
+		for _, fact := range mlstFacts {
+			if strings.EqualFold(strings.TrimSpace(f), fact) {
+				facts = append(facts, fact)
+			}
+		}
+	}
+	c.facts = facts
+	return ftpserver.StatusOK, "MLST OPTS " + formatFactList(facts, false)
+}
+
+// selectedFacts returns the facts currently selected on the connection.
+func (c *controlConn) selectedFacts() []string {
+	if c.facts == nil {
+		return mlstFacts
+	}
+	return c.facts
+}
+
+// formatFactList formats a list of facts as they are returned by FEAT and
+// OPTS MLST. When all is true every supported fact is listed and the selected
+// ones are marked.
+func formatFactList(selected []string, all bool) string {
+	var b strings.Builder
+	facts := selected
+	if all {
+		facts = mlstFacts
+	}
+	for _, f := range facts {
+		b.WriteString(strings.ToLower(f))
+		if all && containsFact(selected, f) {
+			b.WriteByte('*')
+		}
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+func containsFact(facts []string, fact string) bool {
+	for _, f := range facts {
+		if f == fact {
+			return true
+		}
+	}
+	return false
+}
+
+// mlst handles "MLST" which returns the facts for a single file or directory
+// over the control connection.
+func (s *session) mlst(params string, facts []string) (int, string) {
+	p := s.cc.Path()
+	if params != "" {
+		p = s.abs(params)
+	}
+	entry, err := s.driver.mlsxEntry(path.Clean(p), facts)
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, "Could not list: " + err.Error()
+	}
+	return ftpserver.StatusFileOK, "File details\n " + entry + " " + path.Clean(p) + "\nEnd"
+}
+
+// mlsxEntry returns the facts for the file at the given path, without the
+// file name.
+func (driver *FTPDriver) mlsxEntry(p string, facts []string) (string, error) {
+	s, err := driver.getServer()
+	if err != nil {
+		return "", err
+	}
+	realPath, err := driver.buildPath(s, p)
+	if err != nil {
+		return "", err
+	}
+	st, err := statFacts(realPath)
+	if err != nil {
+		return "", err
+	}
+
+	dir := st.dir
+	var b strings.Builder
+	for _, fact := range facts {
+		var value string
+		switch fact {
+		case "Type":
+			value = "file"
+			if dir {
+				value = "dir"
+			}
+		case "Size":
+			value = fmt.Sprintf("%d", st.size)
+		case "Modify":
+			value = st.modify.Format(mlsxTimeFormat)
+		case "Create":
+			if st.create.IsZero() {
+				continue
+			}
+			value = st.create.Format(mlsxTimeFormat)
+		case "Perm":
+			value = driver.permFact(p, dir)
+		case "Unique":
+			if st.unique == "" {
+				continue
+			}
+			value = st.unique
+		}
+		fmt.Fprintf(&b, "%s=%s;", fact, value)
+	}
+	return b.String(), nil
+}
+
+// fileFacts are the facts of a file read from the disk, which differ in what
+// is known about the file between platforms.
+type fileFacts struct {
+	dir    bool
+	size   int64
+	modify time.Time
+	// When the file was created, or the zero time if it is not known.
+	create time.Time
+	// An identifier unique to the file on the node, or empty if there is
+	// none.
+	unique string
+}
+
+// permFact returns the value of the perm fact for a path, describing what the
+// user is able to do with it over FTP.
+func (driver *FTPDriver) permFact(p string, dir bool) string {
+	var b strings.Builder
+	if dir {
+		b.WriteString("e")
+		if driver.allowed(ScopeRead) {
+			b.WriteString("l")
+		}
+	} else if driver.can("file.read-content") && driver.allowed(ScopeRead) {
+		b.WriteString("r")
+	}
+	if driver.checkReadOnly() != nil {
+		return b.String()
+	}
+	protected := driver.checkProtected(p, dir) != nil
+	if dir && driver.can("file.create") {
+		if driver.allowed(ScopeWrite) {
+			b.WriteString("c")
+		}
+		if driver.allowed(ScopeMkdir) {
+			b.WriteString("m")
+		}
+	}
+	if !dir && !protected && driver.can("file.update") && driver.allowed(ScopeWrite) {
+		if driver.allowed(ScopeDelete) {
+			b.WriteString("w")
+		}
+		b.WriteString("a")
+	}
+	if !protected && driver.can("file.delete") && driver.allowed(ScopeDelete) {
+		b.WriteString("d")
+		if dir {
+			b.WriteString("p")
+		}
+	}
+	if !protected && driver.can("file.update") && driver.allowed(ScopeRename) {
+		b.WriteString("f")
+	}
+	return b.String()
+}
+
+// rewriteListing replaces the facts of an MLSD entry written by ftpserverlib
+// with the facts selected for the listing. Anything that is not an MLSD entry
+// is returned unchanged.
+func (driver *FTPDriver) rewriteListing(l *mlsdListing, line []byte) []byte {
+	m := mlsxEntry.FindSubmatch(line)
+	if m == nil {
+		return line
+	}
+	name := string(m[1])
+	entry, err := driver.mlsxEntry(path.Join(l.dir, name), l.facts)
+	if err != nil {
+		return line
+	}
+	return []byte(entry + " " + name + "\r\n")
+}
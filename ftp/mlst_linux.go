@@ -0,0 +1,35 @@
+//go:build linux
+
+package ftp
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// statFacts reads the facts of the file at the real path, without following
+// it if it is a symbolic link. The creation time is read with statx where the
+// filesystem records it.
+func statFacts(realPath string) (fileFacts, error) {
+	var st unix.Statx_t
+	mask := unix.STATX_TYPE | unix.STATX_SIZE | unix.STATX_MTIME | unix.STATX_BTIME | unix.STATX_INO
+	if err := unix.Statx(unix.AT_FDCWD, realPath, unix.AT_SYMLINK_NOFOLLOW, mask, &st); err != nil {
+		return fileFacts{}, err
+	}
+	f := fileFacts{
+		dir:    st.Mode&unix.S_IFMT == unix.S_IFDIR,
+		size:   int64(st.Size),
+		modify: statxTime(st.Mtime),
+		unique: fmt.Sprintf("%xg%x", unix.Mkdev(st.Dev_major, st.Dev_minor), st.Ino),
+	}
+	if st.Mask&unix.STATX_BTIME != 0 {
+		f.create = statxTime(st.Btime)
+	}
+	return f, nil
+}
+
+func statxTime(t unix.StatxTimestamp) time.Time {
+	return time.Unix(t.Sec, int64(t.Nsec)).UTC()
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package ftp
+
+import "os"
+
+// statFacts reads the facts of the file at the real path, without following
+// it if it is a symbolic link. Only Linux reports the creation time and a
+// unique identifier of a file, so those facts are left out elsewhere.
+func statFacts(realPath string) (fileFacts, error) {
+	st, err := os.Lstat(realPath)
+	if err != nil {
+		return fileFacts{}, err
+	}
+	return fileFacts{
+		dir:    st.IsDir(),
+		size:   st.Size(),
+		modify: st.ModTime().UTC(),
+	}, nil
+}
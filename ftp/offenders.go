@@ -0,0 +1,196 @@
+package ftp
+
+import (
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// Addresses that fail to log in too many times are penalized for a while,
+// either by rejecting their connections or by tarpitting them: their
+// connections are accepted but answered slowly, with a fake banner, and
+// every login fails, so that password sprays are slowed down without them
+// noticing they have been caught.
+
+// The ways an address can be penalized.
+const (
+	penaltyBan    = "ban"
+	penaltyTarpit = "tarpit"
+)
+
+// How often addresses that are no longer of interest are forgotten.
+const offenderSweepInterval = time.Minute
+
+// credentialError is returned by AuthUser for logins that fail because of the
+// credentials given, which count towards the failed login threshold.
+type credentialError struct {
+	msg string
+}
+
+func (e *credentialError) Error() string {
+	return e.msg
+}
+
+// errTarpitted is returned for every login of a tarpitted connection, looking
+// the same to the client as a wrong password.
+var errTarpitted = errors.New("invalid password")
+
+func badCredentials(msg string) error {
+	return &credentialError{msg: msg}
+}
+
+// offenders tracks the failed logins of each address and the addresses that
+// are penalized.
+type offenders struct {
+	mu        sync.Mutex
+	ips       map[string]*offender
+	lastSweep time.Time
+}
+
+type offender struct {
+	failures []time.Time
+	until    time.Time
+	// Set for addresses banned through the API, which are always rejected
+	// rather than tarpitted, and for bans without an end.
+	manual    bool
+	permanent bool
+	reason    string
+}
+
+// active reports whether the address is penalized at the given time.
+func (of *offender) active(now time.Time) bool {
+	return of.permanent || now.Before(of.until)
+}
+
+func newOffenders() *offenders {
+	return &offenders{ips: make(map[string]*offender)}
+}
+
+// loadOffenders returns the offenders with the stored bans made through the
+// API.
+func loadOffenders() *offenders {
+	o := newOffenders()
+	o.loadBans()
+	return o
+}
+
+// failed records a failed login from the address, returning true if it has
+// now reached the threshold and is penalized.
+func (o *offenders) failed(ip string, cfg config.FtpBruteForceConfiguration) bool {
+	if cfg.MaxFailures <= 0 {
+		return false
+	}
+	now := time.Now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sweep(now, cfg)
+	of, ok := o.ips[ip]
+	if !ok {
+		of = &offender{}
+		o.ips[ip] = of
+	}
+	of.failures = append(recentFailures(of.failures, now, cfg), now)
+	if len(of.failures) < cfg.MaxFailures || of.active(now) {
+		return false
+	}
+	of.until = now.Add(time.Duration(cfg.Penalty) * time.Second)
+	of.failures = nil
+	of.manual, of.reason = false, ""
+	return true
+}
+
+// penalized reports whether the address is currently penalized.
+func (o *offenders) penalized(ip string) bool {
+	penalized, _ := o.penalty(ip)
+	return penalized
+}
+
+// penalty reports whether the address is currently penalized, and whether it
+// was banned through the API.
+func (o *offenders) penalty(ip string) (penalized bool, manual bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	of, ok := o.ips[ip]
+	if !ok || !of.active(time.Now()) {
+		return false, false
+	}
+	return true, of.manual
+}
+
+// sweep forgets addresses that are not penalized and have no recent
+// failures. It must be called with the lock held.
+func (o *offenders) sweep(now time.Time, cfg config.FtpBruteForceConfiguration) {
+	if now.Sub(o.lastSweep) < offenderSweepInterval {
+		return
+	}
+	o.lastSweep = now
+	for ip, of := range o.ips {
+		of.failures = recentFailures(of.failures, now, cfg)
+		if len(of.failures) == 0 && !of.active(now) {
+			delete(o.ips, ip)
+		}
+	}
+}
+
+// recentFailures returns the failures that are still within the window.
+func recentFailures(failures []time.Time, now time.Time, cfg config.FtpBruteForceConfiguration) []time.Time {
+	cutoff := now.Add(-time.Duration(cfg.Window) * time.Second)
+	i := 0
+	for i < len(failures) && failures[i].Before(cutoff) {
+		i++
+	}
+	return failures[i:]
+}
+
+// penalize returns the reason a new connection is rejected if it comes from
+// a banned address. Connections from tarpitted addresses are accepted, but
+// their replies are delayed and the fake banner is returned for the greeting.
+// Addresses banned through the API are always rejected.
+func (d *FTPServerDriver) penalize(cc ftpserver.ClientContext) (banner string, reject bool) {
+	ip := remoteIP(cc.RemoteAddr())
+	penalized, manual := d.offenders.penalty(ip)
+	if !penalized {
+		return "", false
+	}
+	c := d.sessions.Conn(cc.RemoteAddr().String())
+	if d.cfg.BruteForce.Mode == penaltyTarpit && !manual {
+		log.WithField("ip", ip).Debug("FTP connection tarpitted: too many failed logins")
+		if c != nil {
+			c.tarpit.Store(int64(time.Duration(d.cfg.BruteForce.TarpitDelay) * time.Second))
+		}
+		return d.cfg.BruteForce.TarpitBanner, false
+	}
+	log.WithField("ip", ip).Info("FTP connection rejected: too many failed logins")
+	if c != nil {
+		c.rejectCode.Store(ftpserver.StatusServiceNotAvailable)
+	}
+	return "Too many failed logins, try again later", true
+}
+
+// tarpitted reports whether the connection is being tarpitted.
+func (d *FTPServerDriver) tarpitted(cc ftpserver.ClientContext) bool {
+	c := d.sessions.Conn(cc.RemoteAddr().String())
+	return c != nil && c.tarpit.Load() > 0
+}
+
+// loginFailed records a failed login towards the threshold of the address,
+// if it failed because of the credentials given.
+func (d *FTPServerDriver) loginFailed(cc ftpserver.ClientContext, err error) {
+	var ce *credentialError
+	if !errors.As(err, &ce) {
+		return
+	}
+	ip := remoteIP(cc.RemoteAddr())
+	if d.offenders.failed(ip, d.cfg.BruteForce) {
+		log.WithFields(log.Fields{
+			"ip":      ip,
+			"mode":    d.cfg.BruteForce.Mode,
+			"penalty": (time.Duration(d.cfg.BruteForce.Penalty) * time.Second).String(),
+		}).Warn("FTP address penalized: too many failed logins")
+	}
+}
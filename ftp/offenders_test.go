@@ -0,0 +1,65 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestOffenders(t *testing.T) {
+	cfg := config.FtpBruteForceConfiguration{MaxFailures: 3, Window: 60, Penalty: 60}
+
+	t.Run("penalizes an address at the threshold", func(t *testing.T) {
+		o := newOffenders()
+		assert.False(t, o.failed("192.0.2.1", cfg))
+		assert.False(t, o.failed("192.0.2.1", cfg))
+		assert.False(t, o.penalized("192.0.2.1"))
+		assert.True(t, o.failed("192.0.2.1", cfg))
+		assert.True(t, o.penalized("192.0.2.1"))
+		assert.False(t, o.penalized("192.0.2.2"))
+
+		// Further failures while penalized do not report it again.
+		assert.False(t, o.failed("192.0.2.1", cfg))
+	})
+
+	t.Run("forgets failures outside the window", func(t *testing.T) {
+		o := newOffenders()
+		o.ips["192.0.2.1"] = &offender{failures: []time.Time{time.Now().Add(-2 * time.Minute), time.Now().Add(-90 * time.Second)}}
+		assert.False(t, o.failed("192.0.2.1", cfg))
+		assert.Len(t, o.ips["192.0.2.1"].failures, 1)
+	})
+
+	t.Run("is disabled without a threshold", func(t *testing.T) {
+		o := newOffenders()
+		for i := 0; i < 10; i++ {
+			assert.False(t, o.failed("192.0.2.1", config.FtpBruteForceConfiguration{}))
+		}
+		assert.False(t, o.penalized("192.0.2.1"))
+	})
+
+	t.Run("bans and unbans by hand", func(t *testing.T) {
+		o := newOffenders()
+		o.ban("192.0.2.1", 0, "abuse")
+		o.ban("192.0.2.2", time.Minute, "")
+		penalized, manual := o.penalty("192.0.2.1")
+		assert.True(t, penalized)
+		assert.True(t, manual)
+
+		bans := o.list()
+		assert.Len(t, bans, 2)
+		assert.Nil(t, bans[0].Until)
+		assert.Equal(t, "abuse", bans[0].Reason)
+		assert.NotNil(t, bans[1].Until)
+
+		// A ban without an end is not forgotten by the sweep.
+		o.sweep(time.Now().Add(time.Hour), cfg)
+		assert.True(t, o.penalized("192.0.2.1"))
+
+		assert.True(t, o.unban("192.0.2.1"))
+		assert.False(t, o.penalized("192.0.2.1"))
+		assert.False(t, o.unban("192.0.2.1"))
+	})
+}
@@ -0,0 +1,100 @@
+package ftp
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+// Logins can be validated by the Panel in the same way as SFTP, so that the
+// password of a Panel user works for both protocols and the user is limited
+// to the file permissions they were given for the server. With the "panel"
+// mode every login is sent to the Panel, while with "both" the FTP accounts
+// stored on the node are checked first and logins for anything else are sent
+// to the Panel.
+
+// The ways logins are validated.
+const (
+	authLocal = "local"
+	authPanel = "panel"
+	authBoth  = "both"
+)
+
+// How long the Panel is given to validate a login.
+const panelAuthTimeout = 10 * time.Second
+
+// The Panel permissions needed for each permission scope, as checked by SFTP.
+var scopePermissions = map[string]string{
+	ScopeRead:   "file.read",
+	ScopeWrite:  "file.create",
+	ScopeDelete: "file.delete",
+	ScopeRename: "file.update",
+	ScopeMkdir:  "file.create",
+}
+
+// usesPanel reports whether the login with the username is validated by the
+// Panel.
+func (d *FTPServerDriver) usesPanel(username string) bool {
+	switch d.cfg.Authentication {
+	case authPanel:
+		return true
+	case authBoth:
+		return !accountExists(username)
+	}
+	return false
+}
+
+// parseSFTPUsername splits a username in the format of SFTP, if logins may be
+// validated by the Panel.
+func (d *FTPServerDriver) parseSFTPUsername(username string) (string, string, bool) {
+	if d.cfg.Authentication != authPanel && d.cfg.Authentication != authBoth {
+		return "", "", false
+	}
+	return ParseSFTPUsername(username)
+}
+
+// authPanel validates the credentials with the Panel, returning the
+// permissions the user has been granted on the server.
+func (d *FTPServerDriver) authPanel(cc ftpserver.ClientContext, user, password string, s *server.Server) ([]string, error) {
+	ip := remoteIP(cc.RemoteAddr())
+	logger := log.WithFields(log.Fields{"subsystem": "ftp", "username": user, "server_id": s.ID(), "ip": ip})
+	if d.client == nil {
+		logger.Error("cannot validate FTP credentials: no Panel client")
+		return nil, errors.New("failed to validate credentials")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), panelAuthTimeout)
+	defer cancel()
+	resp, err := d.client.ValidateSftpCredentials(ctx, remote.SftpAuthRequest{
+		Type:          remote.SftpAuthPassword,
+		User:          user + "." + s.ID()[:8],
+		Pass:          password,
+		IP:            ip,
+		ClientVersion: []byte(cc.GetClientVersion()),
+	})
+	if err != nil {
+		var ice *remote.SftpInvalidCredentialsError
+		if errors.As(err, &ice) {
+			logger.Warn("failed to validate FTP credentials with the Panel (invalid credentials)")
+			return nil, badCredentials("invalid password")
+		}
+		logger.WithField("error", err).Error("failed to validate FTP credentials with the Panel")
+		return nil, errors.New("failed to validate credentials")
+	}
+	if resp.Server != s.ID() {
+		logger.WithField("panel_server", resp.Server).Warn("FTP access denied: Panel validated the credentials for another server")
+		return nil, badCredentials("access denied: you do not have permission to access this server")
+	}
+	// An empty slice, rather than nil, marks the session as authenticated by
+	// the Panel even if it was granted nothing.
+	if resp.Permissions == nil {
+		resp.Permissions = []string{}
+	}
+	return resp.Permissions, nil
+}
@@ -0,0 +1,149 @@
+package ftp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/nbutton23/zxcvbn-go"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// PasswordPolicyError is returned when a password does not meet the password
+// policy of the node. The message is meant to be shown to the user.
+type PasswordPolicyError struct {
+	msg string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.msg
+}
+
+func policyError(format string, args ...interface{}) error {
+	return &PasswordPolicyError{msg: fmt.Sprintf(format, args...)}
+}
+
+// commonPasswords are rejected when the policy denies common passwords.
+var commonPasswords = map[string]struct{}{}
+
+func init() {
+	for _, p := range strings.Fields(`
+		123456 123456789 12345678 12345 1234567 1234567890 123123 111111 000000
+		654321 666666 121212 112233 123321 987654321 1q2w3e4r 1q2w3e4r5t
+		qwerty qwerty123 qwertyuiop 1qaz2wsx zaq12wsx asdfgh asdfghjkl zxcvbnm
+		password password1 password123 passw0rd p@ssw0rd admin admin123 root
+		toor letmein welcome welcome1 iloveyou monkey dragon master login
+		abc123 abcdef princess sunshine football baseball shadow superman
+		batman trustno1 starwars minecraft minecraft1 server changeme secret
+		test test123 guest default pterodactyl`) {
+		commonPasswords[p] = struct{}{}
+	}
+}
+
+// denylist caches the passwords from the configured denylist file, which is
+// read again if it changes.
+type denylist struct {
+	mu       sync.Mutex
+	path     string
+	modified time.Time
+	words    map[string]struct{}
+}
+
+var passwordDenylist denylist
+
+// contains reports whether the password is in the denylist file at the path.
+func (d *denylist) contains(p, password string) (bool, error) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.path != p || !d.modified.Equal(st.ModTime()) {
+		words, err := readDenylist(p)
+		if err != nil {
+			return false, err
+		}
+		d.path, d.modified, d.words = p, st.ModTime(), words
+	}
+	_, ok := d.words[strings.ToLower(password)]
+	return ok, nil
+}
+
+func readDenylist(p string) (map[string]struct{}, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	words := make(map[string]struct{})
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if w := strings.TrimSpace(s.Text()); w != "" {
+			words[strings.ToLower(w)] = struct{}{}
+		}
+	}
+	return words, errors.WithStack(s.Err())
+}
+
+// CheckPassword returns a *PasswordPolicyError if the password cannot be
+// given to the FTP account under the password policy of the node.
+func CheckPassword(username, password string) error {
+	return checkPassword(config.Get().System.Ftp.PasswordPolicy, username, password)
+}
+
+func checkPassword(policy config.FtpPasswordPolicyConfiguration, username, password string) error {
+	if n := len([]rune(password)); n < policy.MinLength {
+		return policyError("password must be at least %d characters long", policy.MinLength)
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	switch {
+	case policy.RequireUppercase && !upper:
+		return policyError("password must contain an uppercase letter")
+	case policy.RequireLowercase && !lower:
+		return policyError("password must contain a lowercase letter")
+	case policy.RequireDigit && !digit:
+		return policyError("password must contain a digit")
+	case policy.RequireSymbol && !symbol:
+		return policyError("password must contain a symbol")
+	}
+	if policy.DenyCommon {
+		if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+			return policyError("password is too common")
+		}
+	}
+	if policy.Denylist != "" {
+		ok, err := passwordDenylist.contains(policy.Denylist, password)
+		if err != nil {
+			// A missing denylist should not stop every password change.
+			log.WithFields(log.Fields{"denylist": policy.Denylist, "error": err}).Warn("failed to read FTP password denylist")
+		} else if ok {
+			return policyError("password is too common")
+		}
+	}
+	if policy.MinScore > 0 {
+		if res := zxcvbn.PasswordStrength(password, []string{username}); res.Score < policy.MinScore {
+			return policyError("password is too weak, use a longer password that is harder to guess")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package ftp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestCheckPassword(t *testing.T) {
+	denylist := filepath.Join(t.TempDir(), "denylist.txt")
+	assert.NoError(t, os.WriteFile(denylist, []byte("Hunter2Hunter2\n"), 0o600))
+
+	policy := config.FtpPasswordPolicyConfiguration{
+		MinLength:        8,
+		RequireUppercase: true,
+		RequireDigit:     true,
+		DenyCommon:       true,
+		Denylist:         denylist,
+	}
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"Ab1", false},
+		{"abcdefg1", false},
+		{"Abcdefgh", false},
+		{"Creeper7Farm", true},
+		{"hunter2hunter2", false},
+		{"HUNTER2HUNTER2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			err := checkPassword(policy, "alice_8f2c1d3e", tt.password)
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, &PasswordPolicyError{}, err)
+			}
+		})
+	}
+
+	t.Run("common passwords", func(t *testing.T) {
+		assert.Error(t, checkPassword(config.FtpPasswordPolicyConfiguration{DenyCommon: true}, "", "Password1"))
+		assert.NoError(t, checkPassword(config.FtpPasswordPolicyConfiguration{}, "", "Password1"))
+	})
+
+	t.Run("zxcvbn score", func(t *testing.T) {
+		policy := config.FtpPasswordPolicyConfiguration{MinScore: 3}
+		assert.Error(t, checkPassword(policy, "", "abcabcabc"))
+		assert.NoError(t, checkPassword(policy, "", "correct-horse-battery-staple"))
+	})
+}
@@ -0,0 +1,142 @@
+package ftp
+
+import (
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The packages that are built for every platform, with the calls specific to
+// Linux kept in _linux.go files.
+var portablePackages = []string{
+	"github.com/pterodactyl/wings/ftp",
+	"github.com/pterodactyl/wings/server/backup",
+}
+
+// TestPlatforms type-checks the portable packages as they are built for other
+// platforms. The rest of Wings is Linux only and does not build elsewhere, so
+// the packages imported are only checked for what they declare, and errors in
+// them are ignored.
+func TestPlatforms(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type-checking for other platforms reads the source of every dependency")
+	}
+	for _, goos := range []string{"darwin", "windows"} {
+		t.Run(goos, func(t *testing.T) {
+			imp := newPlatformImporter(t, goos)
+			for _, path := range portablePackages {
+				errs := imp.check(path)
+				assert.Empty(t, errs, "%s does not build on %s", path, goos)
+			}
+		})
+	}
+}
+
+// listedPackage is the part of the output of "go list -json" that is used.
+type listedPackage struct {
+	ImportPath string
+	Dir        string
+	GoFiles    []string
+	ImportMap  map[string]string
+}
+
+// platformImporter type-checks packages from their source, as selected by
+// "go list" for the platform.
+type platformImporter struct {
+	t      *testing.T
+	fset   *token.FileSet
+	listed map[string]*listedPackage
+	pkgs   map[string]*types.Package
+}
+
+func newPlatformImporter(t *testing.T, goos string) *platformImporter {
+	cmd := exec.Command("go", append([]string{"list", "-e", "-deps", "-json"}, portablePackages...)...)
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH=amd64", "CGO_ENABLED=0")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	imp := &platformImporter{
+		t:      t,
+		fset:   token.NewFileSet(),
+		listed: make(map[string]*listedPackage),
+		pkgs:   make(map[string]*types.Package),
+	}
+	dec := json.NewDecoder(strings.NewReader(string(out)))
+	for {
+		var p listedPackage
+		if err := dec.Decode(&p); errors.Is(err, io.EOF) {
+			break
+		} else {
+			require.NoError(t, err)
+		}
+		imp.listed[p.ImportPath] = &p
+	}
+	return imp
+}
+
+func (imp *platformImporter) parse(p *listedPackage) []*ast.File {
+	var files []*ast.File
+	for _, name := range p.GoFiles {
+		f, err := parser.ParseFile(imp.fset, filepath.Join(p.Dir, name), nil, parser.SkipObjectResolution)
+		require.NoError(imp.t, err)
+		files = append(files, f)
+	}
+	return files
+}
+
+// check type-checks the package in full and returns the errors found.
+func (imp *platformImporter) check(path string) []string {
+	p, ok := imp.listed[path]
+	require.True(imp.t, ok, "%s was not listed", path)
+	var errs []string
+	conf := types.Config{
+		Importer: importerFrom(func(dep string) (*types.Package, error) { return imp.importDep(p, dep) }),
+		Error:    func(err error) { errs = append(errs, err.Error()) },
+	}
+	_, _ = conf.Check(path, imp.fset, imp.parse(p), nil)
+	return errs
+}
+
+// importDep returns a package imported by p, checking only what it declares.
+func (imp *platformImporter) importDep(p *listedPackage, path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if mapped, ok := p.ImportMap[path]; ok {
+		path = mapped
+	}
+	if pkg, ok := imp.pkgs[path]; ok {
+		return pkg, nil
+	}
+	dep, ok := imp.listed[path]
+	if !ok {
+		return nil, errors.New("package " + path + " was not listed")
+	}
+	conf := types.Config{
+		Importer:         importerFrom(func(path string) (*types.Package, error) { return imp.importDep(dep, path) }),
+		IgnoreFuncBodies: true,
+		FakeImportC:      true,
+		Error:            func(error) {},
+	}
+	pkg, _ := conf.Check(path, imp.fset, imp.parse(dep), nil)
+	imp.pkgs[path] = pkg
+	return pkg, nil
+}
+
+type importerFrom func(path string) (*types.Package, error)
+
+func (f importerFrom) Import(path string) (*types.Package, error) {
+	return f(path)
+}
@@ -0,0 +1,115 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+// A port in the passive range of a listener that is also allocated to a game
+// server, or published by a Docker container, causes PASV to fail whenever
+// that port is picked for a data connection while the other is using it. The
+// ranges are checked whenever the listeners are bound so the overlap is
+// reported rather than showing up as intermittent transfer failures.
+
+// portConflict is a port in a passive range that is used by something else.
+type portConflict struct {
+	Port     int
+	Listener string
+	// The server the port is allocated to, or the container publishing it.
+	Owner string
+}
+
+func (p portConflict) String() string {
+	return fmt.Sprintf("%d (listener %s, used by %s)", p.Port, p.Listener, p.Owner)
+}
+
+// usedPorts returns the ports allocated to game servers on the node and the
+// ports published by running Docker containers, mapped to what is using
+// them.
+func (c *FTPServer) usedPorts() map[int]string {
+	used := make(map[int]string)
+	for _, s := range c.manager.All() {
+		for _, ports := range s.Config().Allocations.Mappings {
+			for _, port := range ports {
+				used[port] = "server " + s.ID()
+			}
+		}
+	}
+
+	cli, err := environment.Docker()
+	if err != nil {
+		log.WithField("error", err).Debug("unable to check Docker containers for FTP passive port conflicts")
+		return used
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	containers, err := cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		log.WithField("error", err).Debug("unable to check Docker containers for FTP passive port conflicts")
+		return used
+	}
+	for _, ct := range containers {
+		name := ct.ID
+		if len(ct.Names) > 0 {
+			name = strings.TrimPrefix(ct.Names[0], "/")
+		}
+		for _, p := range ct.Ports {
+			// Game server containers are already counted by their allocations.
+			if _, ok := used[int(p.PublicPort)]; p.PublicPort == 0 || ok {
+				continue
+			}
+			used[int(p.PublicPort)] = "container " + name
+		}
+	}
+	return used
+}
+
+// passivePortConflicts returns the ports in the passive ranges of the
+// listeners that are used by game servers or containers.
+func passivePortConflicts(cfg config.FtpConfiguration, used map[int]string) []portConflict {
+	var conflicts []portConflict
+	for _, lc := range listenerConfigs(cfg) {
+		r := passivePortRange(lc)
+		for port, owner := range used {
+			if port >= r.Start && port <= r.End {
+				conflicts = append(conflicts, portConflict{Port: port, Listener: listenAddress(lc), Owner: owner})
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Port < conflicts[j].Port })
+	return conflicts
+}
+
+// checkPassivePorts reports passive ports that are used by game servers or
+// containers. If the configuration refuses conflicts an error is returned so
+// that the listeners are not bound.
+func (c *FTPServer) checkPassivePorts(cfg config.FtpConfiguration) error {
+	conflicts := passivePortConflicts(cfg, c.usedPorts())
+	if len(conflicts) == 0 {
+		return nil
+	}
+	var list []string
+	for i, p := range conflicts {
+		if i == 10 {
+			list = append(list, fmt.Sprintf("and %d more", len(conflicts)-i))
+			break
+		}
+		list = append(list, p.String())
+	}
+	if cfg.RefusePassivePortConflicts {
+		return errors.Errorf("ftp: %d passive ports are used by game servers or containers: %s", len(conflicts), strings.Join(list, ", "))
+	}
+	log.WithFields(log.Fields{"count": len(conflicts), "ports": strings.Join(list, ", ")}).
+		Warn("FTP passive port range overlaps ports used by game servers or containers, PASV will fail intermittently for these ports")
+	return nil
+}
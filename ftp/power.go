@@ -0,0 +1,66 @@
+package ftp
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/internal/models"
+	"github.com/pterodactyl/wings/server"
+)
+
+// The Panel permissions required to run "SITE START", "SITE STOP", and
+// "SITE RESTART".
+const (
+	PermissionControlStart   = "control.start"
+	PermissionControlStop    = "control.stop"
+	PermissionControlRestart = "control.restart"
+)
+
+// powerWaitSeconds is how long a power action waits for one already running
+// on the server to finish, as for those sent through the API.
+const powerWaitSeconds = 30
+
+// sitePower returns the handler of the SITE command that sends the power
+// action to the server, so that a user uploading a new jar can restart the
+// server from their FTP client. The action runs in the background since
+// stopping a server can take minutes, so the reply only tells the client that
+// it was sent, and the Panel console shows how it went.
+func sitePower(action server.PowerAction) siteHandler {
+	return func(s *session, _ string) (int, string) {
+		srv, err := s.driver.getServer()
+		if err != nil {
+			return ftpserver.StatusActionNotTaken, err.Error()
+		}
+		// These are checked here as well as by HandlePowerAction, since its
+		// error cannot be replied with once the action runs in the background.
+		switch {
+		case action.IsStart() && srv.IsSuspended():
+			return ftpserver.StatusActionNotTaken, "Cannot start or restart a server that is suspended"
+		case srv.IsInstalling():
+			return ftpserver.StatusActionNotTaken, server.ErrServerIsInstalling.Error()
+		case srv.IsTransferring():
+			return ftpserver.StatusActionNotTaken, server.ErrServerIsTransferring.Error()
+		case srv.IsRestoring():
+			return ftpserver.StatusActionNotTaken, server.ErrServerIsRestoring.Error()
+		}
+
+		srv.SaveActivity(srv.NewRequestActivity("", s.driver.ip), models.Event(server.ActivityPowerPrefix+string(action)), nil)
+		go func(srv *server.Server, user string) {
+			err := srv.HandlePowerAction(action, powerWaitSeconds)
+			if err == nil || errors.Is(err, server.ErrIsRunning) {
+				return
+			}
+			fields := log.Fields{"action": action, "username": user, "error": err}
+			if errors.Is(err, context.DeadlineExceeded) {
+				srv.Log().WithFields(fields).Warn("ftp: could not process server power action")
+			} else {
+				srv.Log().WithFields(fields).Error("ftp: failed to process server power action")
+			}
+		}(srv, s.driver.user)
+
+		return ftpserver.StatusOK, "Server " + string(action) + " requested"
+	}
+}
@@ -0,0 +1,133 @@
+package ftp
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+// Wings usually runs as root, so every file FTP sessions open, create, or
+// remove is checked against the permissions of root. With drop_privileges
+// enabled each of those operations is instead run on a thread whose
+// filesystem UID and GID are those of the container user, and the kernel
+// refuses anything that user could not do itself. Reading and writing files
+// that are already open is not checked again, so transfers are not slowed
+// down. Files created this way are owned by the container user, as they are
+// when created by the server itself.
+//
+// A few operations do not go through storage and run as root:
+//   - SITE COMPRESS and DECOMPRESS use the server's filesystem, which checks
+//     paths itself, and what they write is given to the container user with
+//     chownCreated.
+//   - Download snapshots are created in the data directory, which only root
+//     can write to, and are unlinked before they are used, so they are never
+//     seen by the server.
+//   - Local backups listed in /.backups are outside of the server's directory
+//     and only readable by root. They are never written to.
+
+// userBackend is a backend whose operations run as the given user.
+type userBackend struct {
+	Backend
+	uid, gid int
+}
+
+// storage returns the backend the files of the session are kept on, acting
+// as the container user if privileges are dropped.
+func (driver *FTPDriver) storage() Backend {
+	b := driver.backend
+	if b == nil {
+		b = localStorage
+	}
+	if driver.cfg.DropPrivileges && canDropPrivileges {
+		user := config.Get().System.User
+		return userBackend{Backend: b, uid: user.Uid, gid: user.Gid}
+	}
+	return b
+}
+
+// chownCreated gives the container user the path and everything beneath it,
+// if privileges are dropped. Archives are created and extracted by the
+// server's filesystem rather than through storage, so what they write is
+// otherwise owned by root.
+func (driver *FTPDriver) chownCreated(fs *filesystem.Filesystem, p string) error {
+	if _, ok := driver.storage().(userBackend); !ok {
+		return nil
+	}
+	return fs.Chown(p)
+}
+
+// run runs fn as the user of the backend.
+func (b userBackend) run(fn func() error) error {
+	return asUser(b.uid, b.gid, fn)
+}
+
+func (b userBackend) Create(name string) (f afero.File, err error) {
+	err = b.run(func() error { f, err = b.Backend.Create(name); return err })
+	return f, err
+}
+
+func (b userBackend) Mkdir(name string, perm os.FileMode) error {
+	return b.run(func() error { return b.Backend.Mkdir(name, perm) })
+}
+
+func (b userBackend) MkdirAll(path string, perm os.FileMode) error {
+	return b.run(func() error { return b.Backend.MkdirAll(path, perm) })
+}
+
+func (b userBackend) Open(name string) (f afero.File, err error) {
+	err = b.run(func() error { f, err = b.Backend.Open(name); return err })
+	return f, err
+}
+
+func (b userBackend) OpenFile(name string, flag int, perm os.FileMode) (f afero.File, err error) {
+	err = b.run(func() error { f, err = b.Backend.OpenFile(name, flag, perm); return err })
+	return f, err
+}
+
+func (b userBackend) Remove(name string) error {
+	return b.run(func() error { return b.Backend.Remove(name) })
+}
+
+func (b userBackend) RemoveAll(path string) error {
+	return b.run(func() error { return b.Backend.RemoveAll(path) })
+}
+
+func (b userBackend) Rename(oldname, newname string) error {
+	return b.run(func() error { return b.Backend.Rename(oldname, newname) })
+}
+
+func (b userBackend) Stat(name string) (info os.FileInfo, err error) {
+	err = b.run(func() error { info, err = b.Backend.Stat(name); return err })
+	return info, err
+}
+
+func (b userBackend) Lstat(name string) (info os.FileInfo, err error) {
+	err = b.run(func() error { info, err = b.Backend.Lstat(name); return err })
+	return info, err
+}
+
+func (b userBackend) Readlink(name string) (target string, err error) {
+	err = b.run(func() error { target, err = b.Backend.Readlink(name); return err })
+	return target, err
+}
+
+func (b userBackend) EvalSymlinks(path string) (resolved string, err error) {
+	err = b.run(func() error { resolved, err = b.Backend.EvalSymlinks(path); return err })
+	return resolved, err
+}
+
+func (b userBackend) Chmod(name string, mode os.FileMode) error {
+	return b.run(func() error { return b.Backend.Chmod(name, mode) })
+}
+
+func (b userBackend) Chown(name string, uid, gid int) error {
+	return b.run(func() error { return b.Backend.Chown(name, uid, gid) })
+}
+
+func (b userBackend) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return b.run(func() error { return b.Backend.Chtimes(name, atime, mtime) })
+}
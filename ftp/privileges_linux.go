@@ -0,0 +1,45 @@
+//go:build linux
+
+package ftp
+
+import (
+	"runtime"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+)
+
+// canDropPrivileges reports whether filesystem operations can be run as
+// another user on this platform.
+const canDropPrivileges = true
+
+// asUser runs fn with the filesystem UID and GID of its thread set to the
+// user. These only apply to the thread, which is locked to the goroutine
+// until they have been restored; threads the runtime starts meanwhile are
+// not cloned from it. If they cannot be restored the thread is left locked,
+// so the runtime discards it once the goroutine exits rather than reusing it.
+func asUser(uid, gid int, fn func() error) error {
+	runtime.LockOSThread()
+	prevGid, _ := unix.SetfsgidRetGid(gid)
+	prevUid, _ := unix.SetfsuidRetUid(uid)
+	// setfsuid and setfsgid return the previous ID whether or not they
+	// succeed, so they are called again with an invalid ID to read them back.
+	curUid, _ := unix.SetfsuidRetUid(-1)
+	curGid, _ := unix.SetfsgidRetGid(-1)
+	var err error
+	if curUid != uid || curGid != gid {
+		err = errors.Errorf("ftp: could not switch to user %d:%d for filesystem access", uid, gid)
+	} else {
+		err = fn()
+	}
+	_, _ = unix.SetfsuidRetUid(prevUid)
+	_, _ = unix.SetfsgidRetGid(prevGid)
+	if u, _ := unix.SetfsuidRetUid(-1); u != prevUid {
+		return err
+	}
+	if g, _ := unix.SetfsgidRetGid(-1); g != prevGid {
+		return err
+	}
+	runtime.UnlockOSThread()
+	return err
+}
@@ -0,0 +1,128 @@
+package ftp
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+func TestAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching the filesystem user requires root")
+	}
+	p := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(p, []byte("root only"), 0o600))
+
+	err := asUser(65534, 65534, func() error {
+		_, err := os.ReadFile(p)
+		return err
+	})
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	uid, _ := unix.SetfsuidRetUid(-1)
+	assert.Equal(t, 0, uid, "the filesystem user is restored")
+	_, err = os.ReadFile(p)
+	assert.NoError(t, err)
+
+	err = asUser(65534, 65534, func() error {
+		return os.WriteFile(filepath.Join(filepath.Dir(p), "created"), nil, 0o600)
+	})
+	assert.ErrorIs(t, err, os.ErrPermission, "the directory is only writable by root")
+}
+
+// newPrivilegedDriver returns a driver with privileges dropped to an
+// unprivileged user that owns the server directory, along with that directory.
+func newPrivilegedDriver(t *testing.T) (*FTPDriver, string) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching the filesystem user requires root")
+	}
+	base := t.TempDir()
+	// The data directory is only writable by root, but the user has to be
+	// able to reach the server directory within it.
+	require.NoError(t, os.Chmod(filepath.Dir(base), 0o755))
+	require.NoError(t, os.Chmod(base, 0o755))
+	cfg := &config.Configuration{AuthenticationToken: "test"}
+	cfg.System.Data = base
+	cfg.System.User.Uid, cfg.System.User.Gid = 65534, 65534
+	config.Set(cfg)
+
+	s, err := server.NewEmptyManager(nil).InitServer(remote.ServerConfigurationResponse{
+		Settings: json.RawMessage(`{"uuid":"` + testServerID + `"}`),
+	})
+	require.NoError(t, err)
+	root := filepath.Join(base, testServerID)
+	require.NoError(t, os.Chown(root, 65534, 65534))
+	return &FTPDriver{BasePath: base, server: s, cfg: config.FtpConfiguration{DropPrivileges: true}}, root
+}
+
+// assertOwner asserts that the file at the path is owned by the user.
+func assertOwner(t *testing.T, p string, uid int) {
+	var st unix.Stat_t
+	require.NoError(t, unix.Lstat(p, &st))
+	assert.Equal(t, uint32(uid), st.Uid, "%s is owned by %d", p, uid)
+}
+
+func TestDropPrivilegesCopy(t *testing.T) {
+	driver, root := newPrivilegedDriver(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "world/region"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "world/region/r.0.0.mca"), []byte("region"), 0o644))
+	require.NoError(t, os.Chown(filepath.Join(root, "world"), 65534, 65534))
+	require.NoError(t, os.Chown(filepath.Join(root, "world/region"), 65534, 65534))
+	require.NoError(t, os.Chown(filepath.Join(root, "world/region/r.0.0.mca"), 65534, 65534))
+
+	require.NoError(t, driver.Copy("/world", "/backup/world"))
+	for _, p := range []string{"backup", "backup/world", "backup/world/region", "backup/world/region/r.0.0.mca"} {
+		assertOwner(t, filepath.Join(root, p), 65534)
+	}
+
+	t.Run("refuses what the user cannot read", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "secret"), nil, 0o600))
+		assert.ErrorIs(t, driver.Copy("/secret", "/copied"), os.ErrPermission)
+	})
+}
+
+func TestDropPrivilegesArchive(t *testing.T) {
+	driver, root := newPrivilegedDriver(t)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "server.properties"), []byte("motd=hello"), 0o644))
+	require.NoError(t, os.Chown(filepath.Join(root, "server.properties"), 65534, 65534))
+
+	require.NoError(t, driver.Compress([]string{"/server.properties"}, "/config.tar.gz"))
+	assertOwner(t, filepath.Join(root, "config.tar.gz"), 65534)
+
+	require.NoError(t, driver.Decompress("/config.tar.gz", "/restored"))
+	assertOwner(t, filepath.Join(root, "restored"), 65534)
+	assertOwner(t, filepath.Join(root, "restored/server.properties"), 65534)
+}
+
+func TestDropPrivilegesSnapshot(t *testing.T) {
+	driver, root := newPrivilegedDriver(t)
+	p := filepath.Join(root, "world.dat")
+	require.NoError(t, os.WriteFile(p, []byte("level"), 0o644))
+	f, err := os.Open(p)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// Snapshots are made as root in the data directory, which the user
+	// cannot write to, and are never seen by the server.
+	snap, err := driver.snapshot(f)
+	require.NoError(t, err)
+	defer snap.Close()
+	b, err := io.ReadAll(snap)
+	require.NoError(t, err)
+	assert.Equal(t, "level", string(b))
+
+	entries, err := os.ReadDir(driver.BasePath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, testServerID, entries[0].Name())
+}
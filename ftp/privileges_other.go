@@ -0,0 +1,13 @@
+//go:build !linux
+
+package ftp
+
+// canDropPrivileges reports whether filesystem operations can be run as
+// another user on this platform.
+const canDropPrivileges = false
+
+// asUser runs fn, as the filesystem UID of a thread cannot be changed on this
+// platform.
+func asUser(_, _ int, fn func() error) error {
+	return fn()
+}
@@ -0,0 +1,83 @@
+package ftp
+
+import (
+	"context"
+	"io"
+	"runtime/pprof"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+// The goroutines of each session are labeled with the session, server, and
+// user, and those moving data with the command being run, so that the time
+// and memory spent by FTP can be attributed in a profile of a busy node, for
+// example with "go tool pprof -tagfocus session=1a2b3c4d". Profiles can be
+// captured through the API when profiling is enabled.
+
+// ErrProfilingDisabled is returned when a profile is requested while
+// profiling is not enabled in the configuration.
+var ErrProfilingDisabled = errors.New("ftp: profiling is not enabled")
+
+// ErrProfileInProgress is returned when a CPU profile is requested while
+// another one is being captured.
+var ErrProfileInProgress = errors.New("ftp: a CPU profile is already being captured")
+
+// The longest CPU profile that can be captured.
+const maxProfileDuration = 5 * time.Minute
+
+// setLabels labels the current goroutine, and any it starts, with the session
+// and the given command, if any.
+func (driver *FTPDriver) setLabels(s *server.Server, command string) {
+	labels := []string{"subsystem", "ftp", "session", driver.sessionID, "server", s.ID(), "user", driver.user}
+	if command != "" {
+		labels = append(labels, "command", command)
+	}
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels(labels...)))
+}
+
+// labelCommand labels the current goroutine with the command being run by
+// the session.
+func (driver *FTPDriver) labelCommand(s *server.Server) {
+	var command string
+	if driver.cc != nil {
+		command = driver.cc.GetLastCommand()
+	}
+	driver.setLabels(s, command)
+}
+
+func (c *FTPServer) profiling() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg.Profiling
+}
+
+// CPUProfile captures a CPU profile of Wings for the given duration, or until
+// the context is done, and writes it to w in the pprof format.
+func (c *FTPServer) CPUProfile(ctx context.Context, w io.Writer, d time.Duration) error {
+	if !c.profiling() {
+		return ErrProfilingDisabled
+	}
+	if err := pprof.StartCPUProfile(w); err != nil {
+		return ErrProfileInProgress
+	}
+	defer pprof.StopCPUProfile()
+	t := time.NewTimer(min(d, maxProfileDuration))
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// HeapProfile writes a profile of the memory allocated by Wings to w in the
+// pprof format.
+func (c *FTPServer) HeapProfile(w io.Writer) error {
+	if !c.profiling() {
+		return ErrProfilingDisabled
+	}
+	return pprof.Lookup("heap").WriteTo(w, 0)
+}
@@ -0,0 +1,76 @@
+package ftp
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// protectedPathError is returned when an FTP user attempts to delete or write
+// over a path that matches one of the protected globs configured on the node.
+type protectedPathError struct {
+	path string
+}
+
+func (e *protectedPathError) Error() string {
+	return fmt.Sprintf("%s is protected and cannot be modified over FTP", e.path)
+}
+
+// relativePath returns the cleaned request path relative to the server root,
+// without any leading slash.
+func relativePath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// serverPath returns the path relative to the root of the server for a request
+// path, which differs from the request path when the account is jailed to a
+// directory on the server.
+func (driver *FTPDriver) serverPath(p string) string {
+	return path.Join("/", driver.root, path.Clean("/"+p))
+}
+
+// checkProtected returns an error if the given request path matches one of the
+// protected globs, or is outside of the writable subtrees for the server. When
+// dir is true the path is treated as a directory and is also rejected if it
+// could contain a protected path.
+func (driver *FTPDriver) checkProtected(p string, dir bool) error {
+	if _, ok := driver.virtualPath(p); ok {
+		return errVirtualReadOnly
+	}
+	rel := relativePath(driver.serverPath(p))
+	if driver.cfg.CaseInsensitive {
+		rel = strings.ToLower(rel)
+	}
+	for _, pattern := range driver.cfg.ProtectedPaths {
+		pattern = relativePath(pattern)
+		if driver.cfg.CaseInsensitive {
+			pattern = strings.ToLower(pattern)
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return &protectedPathError{path: "/" + rel}
+		}
+		if dir && containsProtected(rel, pattern) {
+			return &protectedPathError{path: "/" + rel}
+		}
+	}
+	return driver.checkWritable(rel)
+}
+
+// containsProtected reports whether anything matching the pattern could exist
+// underneath the given directory.
+func containsProtected(dir string, pattern string) bool {
+	if dir == "" {
+		return true
+	}
+	dirParts := strings.Split(dir, "/")
+	patternParts := strings.Split(pattern, "/")
+	if len(patternParts) <= len(dirParts) {
+		return false
+	}
+	for i, part := range dirParts {
+		if ok, _ := path.Match(patternParts[i], part); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestFTPDriver_CheckProtected(t *testing.T) {
+	driver := &FTPDriver{cfg: config.FtpConfiguration{
+		ProtectedPaths: []string{"server.jar", "world/level.dat", "plugins/*.jar"},
+	}}
+
+	cases := []struct {
+		path      string
+		dir       bool
+		protected bool
+	}{
+		{"/server.jar", false, true},
+		{"server.jar", false, true},
+		{"/./world/../server.jar", false, true},
+		{"/world/level.dat", false, true},
+		{"/plugins/Essentials.jar", false, true},
+		{"/plugins/config.yml", false, false},
+		{"/other/server.jar", false, false},
+		{"/world", true, true},
+		{"/plugins", true, true},
+		{"/", true, true},
+		{"/world/region", true, false},
+		{"/logs", true, false},
+	}
+
+	for _, tc := range cases {
+		err := driver.checkProtected(tc.path, tc.dir)
+		if tc.protected {
+			assert.Error(t, err, tc.path)
+		} else {
+			assert.NoError(t, err, tc.path)
+		}
+	}
+}
+
+func TestFTPDriver_CheckWritable(t *testing.T) {
+	driver := &FTPDriver{cfg: config.FtpConfiguration{
+		WritablePaths: []string{"/plugins", "world*", "config/mods/"},
+	}}
+
+	cases := []struct {
+		path     string
+		dir      bool
+		writable bool
+	}{
+		{"/plugins", true, true},
+		{"/plugins/Essentials.jar", false, true},
+		{"/plugins/Essentials/config.yml", false, true},
+		{"/world_nether/region/r.0.0.mca", false, true},
+		{"/config/mods/jei.toml", false, true},
+		{"/config/server.properties", false, false},
+		{"/server.jar", false, false},
+		{"/pluginsx/file", false, false},
+		{"/", true, false},
+	}
+
+	for _, tc := range cases {
+		err := driver.checkProtected(tc.path, tc.dir)
+		if tc.writable {
+			assert.NoError(t, err, tc.path)
+		} else {
+			assert.Error(t, err, tc.path)
+		}
+	}
+}
+
+func TestFTPDriver_ServerPath(t *testing.T) {
+	driver := &FTPDriver{root: "world/builds", cfg: config.FtpConfiguration{
+		ProtectedPaths: []string{"world/builds/spawn.schem"},
+	}}
+
+	assert.Equal(t, "/world/builds", driver.serverPath("/"))
+	assert.Equal(t, "/world/builds/house.schem", driver.serverPath("house.schem"))
+	assert.Equal(t, "/world/builds/server.jar", driver.serverPath("/../../server.jar"))
+	assert.Error(t, driver.checkProtected("/spawn.schem", false))
+	assert.NoError(t, driver.checkProtected("/house.schem", false))
+}
@@ -0,0 +1,67 @@
+package ftp
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+// diskQuota is the disk space of a server, in bytes.
+type diskQuota struct {
+	// The disk limit of the server, or 0 if it is not limited.
+	Limit int64
+	Used  int64
+	// The space that can still be written, which is the least of what is
+	// left within the limit and what is free on the volume, or -1 if it is
+	// not known.
+	Available int64
+}
+
+// serverQuota returns the disk space of the server. The usage may be up to the
+// disk check interval old, so that asking for it does not walk the server.
+func serverQuota(s *server.Server) diskQuota {
+	fs := s.Filesystem()
+	used, err := fs.DiskUsage(true)
+	if err != nil {
+		used = fs.CachedUsage()
+	}
+	q := diskQuota{Limit: fs.MaxDisk(), Used: used, Available: -1}
+	if q.Limit > 0 {
+		q.Available = max(q.Limit-used, 0)
+	}
+	if free, err := volumeFree(fs.Path()); err == nil && (q.Available < 0 || free < q.Available) {
+		q.Available = free
+	}
+	return q
+}
+
+// GetAvailableSpace handles the AVBL command with the space that can still be
+// written to the server, which is the same wherever on the server it is asked
+// for.
+func (cd *ClientDriver) GetAvailableSpace(string) (int64, error) {
+	s, err := cd.getServer()
+	if err != nil {
+		return 0, err
+	}
+	q := serverQuota(s)
+	if q.Available < 0 {
+		return 0, errors.New("available space is not known")
+	}
+	return q.Available, nil
+}
+
+// siteQuota handles SITE QUOTA, replying with the disk limit of the server, its
+// usage, and the space left in bytes on a single line of key=value pairs that
+// scripts can parse. A limit of 0 means the server is not limited, and an
+// available space of -1 that it is not known.
+func (s *session) siteQuota(string) (int, string) {
+	srv, err := s.driver.getServer()
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, err.Error()
+	}
+	q := serverQuota(srv)
+	return ftpserver.StatusOK, fmt.Sprintf("QUOTA limit=%d used=%d available=%d", q.Limit, q.Used, q.Available)
+}
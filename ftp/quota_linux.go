@@ -0,0 +1,15 @@
+//go:build linux
+
+package ftp
+
+import "golang.org/x/sys/unix"
+
+// volumeFree returns the space, in bytes, that can be written to the volume
+// the path is on by users without special privileges.
+func volumeFree(p string) (int64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(p, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * st.Bsize, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package ftp
+
+// volumeFree returns an error, as the free space of a volume is not read on
+// this platform.
+func volumeFree(string) (int64, error) {
+	return 0, errUnsupported
+}
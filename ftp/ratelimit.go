@@ -0,0 +1,92 @@
+package ftp
+
+import (
+	"sync"
+	"time"
+)
+
+// How often keys with no requests left within their window are forgotten.
+const rateLimitSweepInterval = time.Minute
+
+// requestLimiter counts the requests made for each key within a sliding
+// window of time.
+type requestLimiter struct {
+	mu        sync.Mutex
+	keys      map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newRequestLimiter() *requestLimiter {
+	return &requestLimiter{keys: make(map[string][]time.Time)}
+}
+
+// allow records a request for each of the keys, unless any of them have
+// already reached their limit within the window, in which case nothing is
+// recorded and the time until another request will be allowed is returned.
+// A limit of 0 or less does not limit the key.
+func (l *requestLimiter) allow(window time.Duration, keys map[string]int) (time.Duration, bool) {
+	now := time.Now()
+	cutoff := now.Add(-window)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now, window)
+	var wait time.Duration
+	for key, limit := range keys {
+		if limit <= 0 {
+			continue
+		}
+		times := recentRequests(l.keys[key], cutoff)
+		l.keys[key] = times
+		if len(times) >= limit {
+			if w := times[len(times)-limit].Sub(cutoff); w > wait {
+				wait = w
+			}
+		}
+	}
+	if wait > 0 {
+		return wait, false
+	}
+	for key, limit := range keys {
+		if limit > 0 {
+			l.keys[key] = append(l.keys[key], now)
+		}
+	}
+	return 0, true
+}
+
+// sweep forgets keys with no requests within the window. It must be called
+// with the lock held.
+func (l *requestLimiter) sweep(now time.Time, window time.Duration) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	cutoff := now.Add(-window)
+	for key, times := range l.keys {
+		if len(recentRequests(times, cutoff)) == 0 {
+			delete(l.keys, key)
+		}
+	}
+}
+
+// recentRequests returns the requests made after the cutoff.
+func recentRequests(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// AllowPasswordChange records an attempt to change the password of an
+// account from the address, returning false along with how long to wait if
+// either has made too many attempts recently.
+func (c *FTPServer) AllowPasswordChange(ip, username string) (time.Duration, bool) {
+	c.mu.Lock()
+	cfg := c.cfg.PasswordChangeLimit
+	c.mu.Unlock()
+	return c.passwordChanges.allow(time.Duration(cfg.Window)*time.Second, map[string]int{
+		"ip:" + ip:             cfg.PerIP,
+		"username:" + username: cfg.PerUsername,
+	})
+}
@@ -0,0 +1,35 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	l := newRequestLimiter()
+	keys := map[string]int{"ip:192.0.2.1": 3, "username:alice_8f2c1d3e": 2}
+
+	_, ok := l.allow(time.Minute, keys)
+	assert.True(t, ok)
+	_, ok = l.allow(time.Minute, keys)
+	assert.True(t, ok)
+
+	// The username has reached its limit, so the address is not charged for
+	// the rejected request.
+	wait, ok := l.allow(time.Minute, keys)
+	assert.False(t, ok)
+	assert.InDelta(t, time.Minute, wait, float64(time.Second))
+	assert.Len(t, l.keys["ip:192.0.2.1"], 2)
+
+	_, ok = l.allow(time.Minute, map[string]int{"ip:192.0.2.1": 3, "username:bob_8f2c1d3e": 2})
+	assert.True(t, ok)
+	_, ok = l.allow(time.Minute, map[string]int{"ip:192.0.2.1": 3, "username:carol_8f2c1d3e": 2})
+	assert.False(t, ok)
+
+	// Keys without a limit are not counted.
+	_, ok = l.allow(time.Minute, map[string]int{"ip:192.0.2.2": 0})
+	assert.True(t, ok)
+	assert.NotContains(t, l.keys, "ip:192.0.2.2")
+}
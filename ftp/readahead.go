@@ -0,0 +1,133 @@
+package ftp
+
+import (
+	"os"
+	"sync"
+)
+
+// Volumes backed by spinning disks stall a download every time the kernel's
+// own read-ahead runs out, since the next read waits for the disk to seek.
+// Downloads tell the kernel the file is read sequentially, and a goroutine
+// asks it to fetch the next part of the file into the page cache while the
+// current part is being sent, so that reads are served from memory. Where
+// the filesystem ignores the hint, the goroutine reads the part itself.
+
+// readAhead keeps the page cache filled ahead of the position of a download.
+// The position is only changed by the transfer, while the goroutine fetches
+// the ranges it is sent.
+type readAhead struct {
+	f      *os.File
+	window int64
+	// The position of the download, and the end of the range last sent to be
+	// fetched.
+	pos     int64
+	fetched int64
+
+	ranges chan int64
+	done   chan struct{}
+	once   sync.Once
+}
+
+// newReadAhead starts reading ahead of a download by the given number of
+// bytes, returning nil if the window is not positive or the file is not on the
+// local disk.
+func newReadAhead(f *os.File, window int64) *readAhead {
+	if f == nil || window <= 0 {
+		return nil
+	}
+	ra := &readAhead{
+		f:      f,
+		window: window,
+		ranges: make(chan int64, 1),
+		done:   make(chan struct{}),
+	}
+	_ = fadvise(f, 0, 0, fadvSequential)
+	go ra.run()
+	ra.advance(0)
+	return ra
+}
+
+func (ra *readAhead) run() {
+	for {
+		select {
+		case <-ra.done:
+			return
+		case off := <-ra.ranges:
+			if err := fadvise(ra.f, off, ra.window, fadvWillNeed); err != nil {
+				ra.prefetch(off)
+			}
+		}
+	}
+}
+
+// controlFile calls fn with the descriptor of the file, which is held open
+// for the duration of the call.
+func controlFile(f *os.File, fn func(fd int) error) error {
+	raw, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := raw.Control(func(fd uintptr) {
+		ferr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return ferr
+}
+
+// prefetch reads a range of the file so that it is in the page cache when
+// the download reaches it. The data read is thrown away.
+func (ra *readAhead) prefetch(off int64) {
+	b := getCopyBuffer()
+	defer putCopyBuffer(b)
+	end := off + ra.window
+	for off < end {
+		select {
+		case <-ra.done:
+			return
+		default:
+		}
+		n, err := ra.f.ReadAt(*b, off)
+		off += int64(n)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// advance records that n more bytes of the file were sent, fetching the next
+// window once the download is half way through the current one. A range is
+// skipped rather than waited for if the goroutine is still busy.
+func (ra *readAhead) advance(n int64) {
+	if ra == nil {
+		return
+	}
+	ra.pos += n
+	if ra.pos+ra.window/2 < ra.fetched {
+		return
+	}
+	start := max(ra.pos, ra.fetched)
+	select {
+	case ra.ranges <- start:
+		ra.fetched = start + ra.window
+	default:
+	}
+}
+
+// seek moves the position of the download, such as when the client resumes
+// it with REST.
+func (ra *readAhead) seek(pos int64) {
+	if ra == nil {
+		return
+	}
+	ra.pos, ra.fetched = pos, pos
+	ra.advance(0)
+}
+
+func (ra *readAhead) stop() {
+	if ra == nil {
+		return
+	}
+	ra.once.Do(func() { close(ra.done) })
+}
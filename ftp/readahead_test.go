@@ -0,0 +1,55 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadAheadAdvance(t *testing.T) {
+	// The goroutine is not started, so the ranges sent are left in the channel.
+	ra := &readAhead{window: 100, ranges: make(chan int64, 1), done: make(chan struct{})}
+	next := func() (int64, bool) {
+		select {
+		case off := <-ra.ranges:
+			return off, true
+		default:
+			return 0, false
+		}
+	}
+
+	ra.advance(0)
+	off, ok := next()
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), off)
+
+	// Nothing is fetched until half of the window has been sent.
+	ra.advance(40)
+	_, ok = next()
+	assert.False(t, ok)
+
+	ra.advance(10)
+	off, ok = next()
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), off)
+
+	// A range is skipped while the goroutine is busy, and fetched later.
+	ra.advance(100)
+	ra.advance(110)
+	off, ok = next()
+	assert.True(t, ok)
+	assert.Equal(t, int64(200), off)
+	ra.advance(0)
+	off, ok = next()
+	assert.True(t, ok)
+	assert.Equal(t, int64(300), off)
+
+	ra.seek(1000)
+	off, ok = next()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1000), off)
+
+	var none *readAhead
+	none.advance(10)
+	none.stop()
+}
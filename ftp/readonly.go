@@ -0,0 +1,92 @@
+package ftp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// A single server can be made read-only over FTP through the API, such as
+// while an incident is looked into, without making the whole node read-only.
+// The servers are stored so that they stay read-only when Wings is restarted,
+// and the change applies to sessions that are already logged in.
+
+// readOnlyServers is the set of servers made read-only through the API.
+type readOnlyServers struct {
+	mu  sync.RWMutex
+	ids map[string]bool
+}
+
+// readOnlyServersPath returns the file the read-only servers are stored in.
+func readOnlyServersPath() string {
+	return filepath.Join(config.Get().System.RootDirectory, "ftp-read-only.json")
+}
+
+// loadReadOnlyServers returns the stored read-only servers. If they cannot be
+// read the error is logged and none are read-only.
+func loadReadOnlyServers() *readOnlyServers {
+	r := &readOnlyServers{ids: make(map[string]bool)}
+	b, err := os.ReadFile(readOnlyServersPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithField("error", err).Error("failed to read FTP read-only servers")
+		}
+		return r
+	}
+	var ids []string
+	if err := json.Unmarshal(b, &ids); err != nil {
+		log.WithField("error", err).Error("failed to parse FTP read-only servers")
+		return r
+	}
+	for _, id := range ids {
+		r.ids[id] = true
+	}
+	return r
+}
+
+// has reports whether the server is read-only.
+func (r *readOnlyServers) has(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ids[id]
+}
+
+// set makes the server read-only or writable again, storing the change.
+func (r *readOnlyServers) set(id string, readOnly bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ids[id] == readOnly {
+		return nil
+	}
+	if readOnly {
+		r.ids[id] = true
+	} else {
+		delete(r.ids, id)
+	}
+	ids := make([]string, 0, len(r.ids))
+	for id := range r.ids {
+		ids = append(ids, id)
+	}
+	b, err := json.Marshal(ids)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(readOnlyServersPath(), b, 0o644))
+}
+
+// ServerReadOnly reports whether the server has been made read-only over FTP
+// through the API. It does not account for the node-wide setting.
+func (c *FTPServer) ServerReadOnly(id string) bool {
+	return c.readOnly.has(id)
+}
+
+// SetServerReadOnly makes a server read-only over FTP, or writable again.
+func (c *FTPServer) SetServerReadOnly(id string, readOnly bool) error {
+	return c.readOnly.set(id, readOnly)
+}
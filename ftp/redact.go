@@ -0,0 +1,86 @@
+package ftp
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Debug logs end up in support tickets and log aggregators, so nothing
+// logged by FTP may contain a password, and the password files of accounts,
+// whose names give away the accounts of every server, are only logged with
+// their name masked. Parameters sent by clients are truncated, so that a
+// client cannot fill the logs with a single command.
+
+// The most bytes of a parameter that are logged.
+const maxLoggedParam = 128
+
+const redacted = "********"
+
+// Commands whose parameters are secrets and never logged.
+var secretCommands = map[string]bool{
+	"PASS": true,
+	"ACCT": true,
+}
+
+// redactParams returns the parameters of a command as they may be logged.
+func redactParams(command, params string) string {
+	if params != "" && secretCommands[strings.ToUpper(command)] {
+		return redacted
+	}
+	return truncateParam(params)
+}
+
+// redactLine returns a line of the control connection as it may be logged.
+func redactLine(line string) string {
+	line = strings.TrimRight(line, "\r\n")
+	command, params, ok := strings.Cut(line, " ")
+	if !ok {
+		return truncateParam(line)
+	}
+	return command + " " + redactParams(command, params)
+}
+
+// truncateParam shortens a parameter to the most bytes that are logged,
+// noting how long it was.
+func truncateParam(s string) string {
+	if len(s) <= maxLoggedParam {
+		return s
+	}
+	cut := maxLoggedParam
+	// Avoid splitting a multibyte character.
+	for cut > 0 && !isRuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (%d bytes)", s[:cut], len(s))
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// maskPasswordFile returns the path of a file kept alongside an account's
+// password with the name of the account masked.
+func maskPasswordFile(p string) string {
+	return filepath.Join(filepath.Dir(p), redacted+filepath.Ext(p))
+}
+
+// redactKeyvals returns the key/value pairs logged by ftpserverlib with the
+// lines of the control connection redacted, and any value whose key mentions
+// a password removed.
+func redactKeyvals(keyvals []interface{}) []interface{} {
+	out := make([]interface{}, len(keyvals))
+	copy(out, keyvals)
+	for i := 0; i+1 < len(out); i += 2 {
+		key := strings.ToLower(fmt.Sprint(out[i]))
+		switch {
+		case strings.Contains(key, "pass"):
+			out[i+1] = redacted
+		case key == "line":
+			out[i+1] = redactLine(fmt.Sprint(out[i+1]))
+		case key == "param" || key == "params":
+			out[i+1] = truncateParam(fmt.Sprint(out[i+1]))
+		}
+	}
+	return out
+}
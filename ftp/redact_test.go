@@ -0,0 +1,36 @@
+package ftp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	t.Run("never logs passwords", func(t *testing.T) {
+		assert.Equal(t, "PASS ********", redactLine("PASS hunter2\r\n"))
+		assert.Equal(t, "pass ********", redactLine("pass hunter2"))
+		assert.Equal(t, "ACCT ********", redactLine("ACCT secret"))
+		assert.Equal(t, "USER admin_1a2b3c4d", redactLine("USER admin_1a2b3c4d"))
+		assert.Equal(t, "NOOP", redactLine("NOOP\r\n"))
+
+		kv := redactKeyvals([]interface{}{"line", "PASS hunter2", "password", "hunter2", "clientId", 1})
+		assert.Equal(t, []interface{}{"line", "PASS ********", "password", "********", "clientId", 1}, kv)
+	})
+
+	t.Run("truncates long parameters", func(t *testing.T) {
+		long := strings.Repeat("a", 500)
+		out := redactLine("STOR " + long)
+		assert.True(t, strings.HasPrefix(out, "STOR "+strings.Repeat("a", maxLoggedParam)+"..."))
+		assert.Contains(t, out, "(500 bytes)")
+
+		// Multibyte characters are not split.
+		out = truncateParam("a" + strings.Repeat("é", 100))
+		assert.True(t, strings.HasPrefix(out, "a"+strings.Repeat("é", 63)+"..."))
+	})
+
+	t.Run("masks password files", func(t *testing.T) {
+		assert.Equal(t, "/var/lib/pterodactyl/passwords/********.txt", maskPasswordFile("/var/lib/pterodactyl/passwords/admin_1a2b3c4d.txt"))
+	})
+}
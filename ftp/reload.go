@@ -0,0 +1,65 @@
+package ftp
+
+import (
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// Reload reads the FTP configuration from the disk again and restarts the
+// control listeners with it. Sessions that are already connected keep the
+// configuration they were started with, and only new connections use the
+// reloaded one.
+func (c *FTPServer) Reload() error {
+	cfg, err := config.ReloadFtp()
+	if err != nil {
+		return err
+	}
+	log.WithField("listen", listenAddresses(cfg)).Info("reloading FTP server configuration")
+
+	c.setConfig(cfg)
+	c.mu.Lock()
+	running := len(c.servers) > 0
+	c.mu.Unlock()
+	if !running {
+		return nil
+	}
+	c.reloading.Store(true)
+	if err := c.stop(); err != nil {
+		c.reloading.Store(false)
+		return err
+	}
+	return nil
+}
+
+// ErrNotRunning is returned when restarting the FTP server before its
+// listeners have been started.
+var ErrNotRunning = errors.New("ftp: server is not running")
+
+// Restart closes the control listeners and opens them again with the current
+// configuration, which also loads the TLS certificates again. Sessions that
+// are already connected are not disconnected.
+func (c *FTPServer) Restart() error {
+	c.mu.Lock()
+	running := len(c.servers) > 0
+	c.mu.Unlock()
+	if !running {
+		return ErrNotRunning
+	}
+	log.Info("restarting FTP server")
+	c.reloading.Store(true)
+	if err := c.stop(); err != nil {
+		c.reloading.Store(false)
+		return err
+	}
+	return nil
+}
+
+// setConfig replaces the configuration used for new connections.
+func (c *FTPServer) setConfig(cfg config.FtpConfiguration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg
+	c.ReadOnly = cfg.ReadOnly
+}
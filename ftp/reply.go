@@ -0,0 +1,146 @@
+package ftp
+
+import (
+	"bytes"
+	"io/fs"
+	"syscall"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+// ftpserverlib replies to every failed driver call with a fixed code, most
+// often 550. Errors that should be reported with a different code are wrapped
+// in a replyError, and the code is noted on the driver when the error is
+// handed back to the library so that the controlConn can rewrite the reply as
+// it is written to the client.
+
+// The reply code for a file action that was not taken because there is not
+// enough storage, which ftpserverlib has no constant for.
+const statusInsufficientStorage = 452
+
+// replyError is an error that should be sent to the client with a specific
+// reply code, and optionally a message of its own in place of the error's.
+type replyError struct {
+	code int
+	err  error
+	msg  string
+}
+
+func (e *replyError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	return e.err.Error()
+}
+
+func (e *replyError) Unwrap() error {
+	return e.err
+}
+
+// withReplyCode wraps an error so that it is sent to the client with the given
+// reply code.
+func withReplyCode(code int, err error) error {
+	return &replyError{code: code, err: err}
+}
+
+// translateError gives errors from the filesystem, and the rejections of the
+// driver, the reply code that tells the client whether to skip the file or
+// retry it later, along with a message that does not reveal the real path of
+// the file on the node. Errors that already have a reply code, and those that
+// are not recognized, are returned unchanged.
+func translateError(err error) error {
+	var re *replyError
+	if err == nil || errors.As(err, &re) {
+		return err
+	}
+	code, msg := ftpserver.StatusActionNotTaken, ""
+	switch {
+	case errors.Is(err, ftpserver.ErrStorageExceeded), errors.Is(err, syscall.EDQUOT),
+		filesystem.IsErrorCode(err, filesystem.ErrCodeDiskSpace):
+		code, msg = ftpserver.StatusActionAborted, "disk space limit of the server exceeded"
+	case errors.Is(err, syscall.ENOSPC):
+		code, msg = statusInsufficientStorage, "insufficient storage space on the node, try again later"
+	case errors.Is(err, errReadOnly), errors.Is(err, syscall.EROFS):
+		msg = "permission denied: the server is read-only"
+	case errors.Is(err, fs.ErrNotExist):
+		msg = "no such file or directory"
+	case errors.Is(err, fs.ErrExist):
+		msg = "file already exists"
+	case errors.Is(err, syscall.ENOTEMPTY):
+		msg = "directory not empty"
+	case errors.Is(err, syscall.EISDIR), filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory):
+		msg = "is a directory"
+	case errors.Is(err, syscall.ENOTDIR):
+		msg = "not a directory"
+	case errors.Is(err, fs.ErrPermission), filesystem.IsErrorCode(err, filesystem.ErrCodeDenylistFile):
+		msg = "permission denied"
+	case errors.Is(err, ftpserver.ErrFileNameNotAllowed):
+		code = ftpserver.StatusActionNotTakenNoFile
+	case errors.Is(err, syscall.ENAMETOOLONG), errors.Is(err, syscall.EILSEQ):
+		code, msg = ftpserver.StatusActionNotTakenNoFile, "file name not allowed"
+	case errors.Is(err, syscall.EBUSY), errors.Is(err, syscall.ETXTBSY), errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR):
+		code, msg = ftpserver.StatusFileActionNotTaken, "file is busy, try again later"
+	case errors.Is(err, syscall.EIO):
+		code, msg = ftpserver.StatusFileActionNotTaken, "input/output error, try again later"
+	default:
+		return err
+	}
+	return &replyError{code: code, err: err, msg: msg}
+}
+
+// noteReply translates the error into a reply and records the reply code, if
+// it has one, so that it is used for the next error reply sent to the
+// client.
+func (driver *FTPDriver) noteReply(err error) error {
+	if err == nil {
+		return nil
+	}
+	err = customizeReply(driver.cfg.Messages, translateError(err))
+	logger := driver.logger().WithField("error", err)
+	if driver.cc != nil {
+		logger = logger.WithField("command", driver.cc.GetLastCommand())
+	}
+	logger.Debug("FTP command failed")
+	var re *replyError
+	if errors.As(err, &re) {
+		driver.replyCode.Store(int32(re.code))
+	}
+	return err
+}
+
+// noteReplySuffix records text that is added to the end of the next
+// successful reply sent to the client.
+func (driver *FTPDriver) noteReplySuffix(suffix string) {
+	driver.replySuffix.Store(&suffix)
+}
+
+// rewriteReply replaces the code of an error reply line with the code noted
+// by the driver, if there is one. The noted code is cleared once the final
+// line of the reply has been rewritten. Any noted suffix is added to the next
+// successful reply.
+func (driver *FTPDriver) rewriteReply(p []byte) []byte {
+	if len(p) > 5 && p[0] == '2' && p[3] == ' ' && bytes.HasSuffix(p, []byte("\r\n")) {
+		if suffix := driver.replySuffix.Swap(nil); suffix != nil {
+			return append(append(append([]byte{}, p[:len(p)-2]...), " "+*suffix...), "\r\n"...)
+		}
+	}
+	code := driver.replyCode.Load()
+	if code == 0 || len(p) < 4 || (p[0] != '4' && p[0] != '5') || (p[3] != ' ' && p[3] != '-') {
+		return p
+	}
+	for _, b := range p[1:3] {
+		if b < '0' || b > '9' {
+			return p
+		}
+	}
+	if p[3] == ' ' {
+		driver.replyCode.CompareAndSwap(code, 0)
+	}
+	out := make([]byte, len(p))
+	copy(out, p)
+	out[0], out[1], out[2] = byte('0'+code/100), byte('0'+code/10%10), byte('0'+code%10)
+	return out
+}
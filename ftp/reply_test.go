@@ -0,0 +1,56 @@
+package ftp
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	pathError := func(err error) error {
+		return &fs.PathError{Op: "open", Path: "/var/lib/pterodactyl/volumes/8f2a1c3e/server.jar", Err: err}
+	}
+	tests := []struct {
+		err  error
+		code int
+		msg  string
+	}{
+		{pathError(syscall.ENOENT), ftpserver.StatusActionNotTaken, "no such file or directory"},
+		{pathError(syscall.EACCES), ftpserver.StatusActionNotTaken, "permission denied"},
+		{pathError(syscall.EDQUOT), ftpserver.StatusActionAborted, "disk space limit of the server exceeded"},
+		{errInsufficientSpace, ftpserver.StatusActionAborted, "disk space limit of the server exceeded"},
+		{pathError(syscall.ENOSPC), statusInsufficientStorage, "insufficient storage space on the node, try again later"},
+		{errReadOnly, ftpserver.StatusActionNotTaken, "permission denied: the server is read-only"},
+		{pathError(syscall.ENAMETOOLONG), ftpserver.StatusActionNotTakenNoFile, "file name not allowed"},
+		{pathError(syscall.EBUSY), ftpserver.StatusFileActionNotTaken, "file is busy, try again later"},
+	}
+	for _, tc := range tests {
+		err := translateError(tc.err)
+		var re *replyError
+		if assert.True(t, errors.As(err, &re), tc.err.Error()) {
+			assert.Equal(t, tc.code, re.code, tc.err.Error())
+			assert.Equal(t, tc.msg, err.Error())
+			assert.True(t, errors.Is(err, tc.err))
+		}
+	}
+
+	t.Run("keeps errors that have a reply code", func(t *testing.T) {
+		err := withReplyCode(ftpserver.StatusFileActionNotTaken, errFileBusy)
+		assert.Same(t, err, translateError(err))
+	})
+
+	t.Run("keeps unknown errors", func(t *testing.T) {
+		err := errors.New("something else")
+		assert.Equal(t, err, translateError(err))
+		assert.Nil(t, translateError(nil))
+	})
+
+	t.Run("is still recognized as a missing file", func(t *testing.T) {
+		assert.True(t, errors.Is(translateError(pathError(syscall.ENOENT)), os.ErrNotExist))
+	})
+}
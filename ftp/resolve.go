@@ -0,0 +1,211 @@
+package ftp
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+// Every path a client sends is resolved to a real path within the directory
+// of the server, or the root directory of the account if it is jailed to one,
+// by a PathResolver. The path is rejected if it leads outside of the root once
+// cleaned, or once the symbolic links in it are followed. Links are followed
+// for the part of the path that exists, and through links that point to files
+// that do not exist yet, so that a file cannot be created outside of the root
+// through a link either.
+//
+// The resolver only depends on the root and the storage it is given, which
+// lets it be fuzzed on its own.
+
+// PathRejection is the reason a path was rejected by a PathResolver.
+type PathRejection int
+
+const (
+	// PathAllowed is the rejection of a path that was not rejected.
+	PathAllowed PathRejection = iota
+	// PathInvalid rejects paths that no file can have, such as those
+	// containing a NUL byte.
+	PathInvalid
+	// PathTraversal rejects paths that lead outside of the root once they
+	// are cleaned.
+	PathTraversal
+	// PathSymlinkEscape rejects paths that lead outside of the root through
+	// a symbolic link.
+	PathSymlinkEscape
+)
+
+func (r PathRejection) String() string {
+	switch r {
+	case PathAllowed:
+		return "allowed"
+	case PathInvalid:
+		return "invalid path"
+	case PathTraversal:
+		return "path traversal"
+	case PathSymlinkEscape:
+		return "symbolic link outside of the root"
+	}
+	return "unknown"
+}
+
+// PathRejectedError is returned for paths a PathResolver rejects. It is
+// reported to clients as a permission error.
+type PathRejectedError struct {
+	Reason PathRejection
+}
+
+func (e *PathRejectedError) Error() string {
+	return "path rejected: " + e.Reason.String()
+}
+
+func (e *PathRejectedError) Is(target error) bool {
+	return target == fs.ErrPermission
+}
+
+// ResolvedPath is the result of resolving a path.
+type ResolvedPath struct {
+	// Path is the real path the requested path maps to, without following
+	// symbolic links. It is empty if the path was rejected.
+	Path string
+	// Real is the path with the symbolic links in it followed. If the path
+	// was rejected it is the path outside of the root it led to, if any.
+	Real string
+	// Rejection is the reason the path was rejected, or PathAllowed.
+	Rejection PathRejection
+}
+
+// Err returns a *PathRejectedError if the path was rejected.
+func (r ResolvedPath) Err() error {
+	if r.Rejection == PathAllowed {
+		return nil
+	}
+	return &PathRejectedError{Reason: r.Rejection}
+}
+
+// PathResolver resolves the paths requested by clients to real paths within
+// a root directory.
+type PathResolver struct {
+	// Root is the directory paths are resolved within.
+	Root string
+	// Storage is the backend symbolic links and names are looked up on.
+	Storage Backend
+	// CaseInsensitive maps each part of a path onto an existing name that
+	// differs from it only in case.
+	CaseInsensitive bool
+}
+
+// Resolve resolves the path requested by a client, which is taken to be
+// relative to the root whether or not it starts with a slash.
+func (r PathResolver) Resolve(requestPath string) ResolvedPath {
+	if strings.IndexByte(requestPath, 0) >= 0 {
+		return ResolvedPath{Rejection: PathInvalid}
+	}
+	root := filepath.Clean(r.Root)
+	cleaned := strings.TrimPrefix(filepath.Clean(requestPath), string(filepath.Separator))
+	if r.CaseInsensitive {
+		cleaned = resolveCase(r.Storage, root, cleaned)
+	}
+	fullPath := filepath.Join(root, cleaned)
+	if !within(root, fullPath) {
+		return ResolvedPath{Real: fullPath, Rejection: PathTraversal}
+	}
+
+	realRoot, err := r.Storage.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+	realPath, err := r.evalSymlinks(fullPath, 0)
+	if err != nil {
+		// The path could not be followed because of a link loop, or a file
+		// being used as a directory, which the operation on the path fails
+		// with as well.
+		realPath = fullPath
+	}
+	if !within(realRoot, realPath) && !within(root, realPath) {
+		return ResolvedPath{Real: realPath, Rejection: PathSymlinkEscape}
+	}
+	return ResolvedPath{Path: fullPath, Real: realPath}
+}
+
+// evalSymlinks follows the symbolic links in the part of the path that
+// exists, along with any dangling link in it, leaving the rest of the path as
+// it is.
+func (r PathResolver) evalSymlinks(p string, links int) (string, error) {
+	real, err := r.Storage.EvalSymlinks(p)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return real, err
+	}
+	dir := filepath.Dir(p)
+	if dir == p {
+		return p, nil
+	}
+	parent, err := r.evalSymlinks(dir, links)
+	if err != nil {
+		return "", err
+	}
+	next := filepath.Join(parent, filepath.Base(p))
+	info, err := r.Storage.Lstat(next)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return next, nil
+	}
+	// A link to a file that does not exist, which would be created wherever
+	// the link points to.
+	if links++; links > maxSymlinks {
+		return "", &os.PathError{Op: "lstat", Path: p, Err: errSymlinkLoop}
+	}
+	target, err := r.Storage.Readlink(next)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(parent, target)
+	}
+	return r.evalSymlinks(target, links)
+}
+
+// within reports whether the path is the root or inside of it.
+func within(root, p string) bool {
+	return p == root || strings.HasPrefix(p, root+string(filepath.Separator)) || root == string(filepath.Separator)
+}
+
+// pathResolver returns the resolver of the paths of the session on the
+// server.
+func (driver *FTPDriver) pathResolver(s *server.Server) PathResolver {
+	return PathResolver{
+		Root:            filepath.Join(driver.BasePath, s.ID(), driver.root),
+		Storage:         driver.storage(),
+		CaseInsensitive: driver.cfg.CaseInsensitive,
+	}
+}
+
+// buildPath returns the real path of a path requested by the client on the
+// server, or an error if it leads outside of the server.
+func (driver *FTPDriver) buildPath(s *server.Server, requestPath string) (_ string, err error) {
+	span := driver.startSpan("ftp.resolve_path", attribute.String("ftp.path", truncateParam(requestPath)))
+	defer func() { endSpan(span, err) }()
+
+	res := driver.pathResolver(s).Resolve(requestPath)
+	if err = res.Err(); err != nil {
+		driver.logger().WithFields(log.Fields{
+			"request_path": truncateParam(requestPath),
+			"resolved":     res.Real,
+			"reason":       res.Rejection.String(),
+		}).Warn("FTP path rejected")
+		return "", err
+	}
+
+	if driver.cfg.LogCommands {
+		driver.logger().WithFields(log.Fields{
+			"request_path": truncateParam(requestPath),
+			"real_path":    truncateParam(res.Path),
+		}).Debug("FTP path mapping")
+	}
+	return res.Path, nil
+}
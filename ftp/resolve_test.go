@@ -0,0 +1,160 @@
+package ftp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSymlinkRoot returns a server root on the local disk with symbolic links
+// inside of it and leading out of it, along with the directory it is in.
+func newSymlinkRoot(t testing.TB) (string, string) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	root := filepath.Join(dir, "root")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "data", "world"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "outside"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outside", "secret"), nil, 0o644))
+	for name, target := range map[string]string{
+		"link":         "data",
+		"escape":       filepath.Join(dir, "outside"),
+		"rel-escape":   "../outside",
+		"dangling-in":  "data/new",
+		"dangling-out": "../outside/new",
+		"loop":         "loop",
+	} {
+		require.NoError(t, os.Symlink(target, filepath.Join(root, name)))
+	}
+	return dir, root
+}
+
+func TestPathResolver(t *testing.T) {
+	_, root := newSymlinkRoot(t)
+	r := PathResolver{Root: root, Storage: NewLocalBackend()}
+
+	cases := []struct {
+		in        string
+		path      string
+		rejection PathRejection
+	}{
+		{"/", "", PathAllowed},
+		{"/data/world", "data/world", PathAllowed},
+		{"/../../data", "data", PathAllowed},
+		{"data/../../x", "", PathTraversal},
+		{"/link/world", "link/world", PathAllowed},
+		{"/link/new/file", "link/new/file", PathAllowed},
+		{"/dangling-in", "dangling-in", PathAllowed},
+		{"/loop/file", "loop/file", PathAllowed},
+		{"/escape", "", PathSymlinkEscape},
+		{"/escape/secret", "", PathSymlinkEscape},
+		{"/rel-escape/new", "", PathSymlinkEscape},
+		{"/dangling-out", "", PathSymlinkEscape},
+		{"/dangling-out/../x", "x", PathAllowed},
+		{"/data\x00/world", "", PathInvalid},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			res := r.Resolve(c.in)
+			assert.Equal(t, c.rejection, res.Rejection)
+			if c.rejection != PathAllowed {
+				assert.Empty(t, res.Path)
+				assert.ErrorIs(t, res.Err(), os.ErrPermission)
+				return
+			}
+			assert.NoError(t, res.Err())
+			assert.Equal(t, filepath.Join(root, c.path), res.Path)
+		})
+	}
+
+	t.Run("resolves case", func(t *testing.T) {
+		r := r
+		r.CaseInsensitive = true
+		assert.Equal(t, filepath.Join(root, "data/world"), r.Resolve("/DATA/World").Path)
+		assert.Equal(t, PathSymlinkEscape, r.Resolve("/ESCAPE/secret").Rejection)
+	})
+}
+
+// checkResolved checks the properties every resolved path has: a rejected
+// path has no path, and an allowed one is within the root.
+func checkResolved(t *testing.T, root string, res ResolvedPath) {
+	if res.Rejection != PathAllowed {
+		require.Empty(t, res.Path)
+		require.Error(t, res.Err())
+		return
+	}
+	require.NoError(t, res.Err())
+	require.True(t, within(root, res.Path), "%q is outside of %q", res.Path, root)
+}
+
+func FuzzPathResolverTraversal(f *testing.F) {
+	for _, seed := range []string{"", "/", ".", "..", "/..", "../..", "a/../../b", "//a//b/", "/a/./b/../../..", `..\..\windows`, "a/..../b", "...", "/./../."} {
+		f.Add(seed)
+	}
+	root := "/srv/" + testServerID
+	fsys := afero.NewMemMapFs()
+	require.NoError(f, fsys.MkdirAll(root+"/plugins", 0o755))
+	r := PathResolver{Root: root, Storage: NewAferoBackend(fsys)}
+
+	f.Fuzz(func(t *testing.T, p string) {
+		res := r.Resolve(p)
+		checkResolved(t, root, res)
+		if res.Rejection == PathAllowed {
+			// Resolving the path within the root again leads to itself.
+			again := r.Resolve(strings.TrimPrefix(res.Path, root))
+			require.Equal(t, res.Path, again.Path)
+		}
+	})
+}
+
+func FuzzPathResolverSymlinks(f *testing.F) {
+	for _, seed := range []string{"/link", "/link/world/../..", "/escape", "/escape/../root", "/rel-escape/new", "/dangling-in", "/dangling-out", "/dangling-out/x", "/loop", "/loop/../escape", "/data/../link/../escape/secret"} {
+		f.Add(seed)
+	}
+	dir, root := newSymlinkRoot(f)
+	r := PathResolver{Root: root, Storage: NewLocalBackend()}
+
+	f.Fuzz(func(t *testing.T, p string) {
+		res := r.Resolve(p)
+		checkResolved(t, root, res)
+		if res.Rejection != PathAllowed {
+			return
+		}
+		// Whatever part of the path exists leads somewhere within the root
+		// once its links are followed by the kernel.
+		for q := res.Path; within(root, q); q = filepath.Dir(q) {
+			if _, err := os.Lstat(q); err != nil {
+				continue
+			}
+			if real, err := filepath.EvalSymlinks(q); err == nil {
+				require.False(t, within(dir+"/outside", real), "%q leads to %q", p, real)
+			}
+			break
+		}
+	})
+}
+
+func FuzzPathResolverUnicode(f *testing.F) {
+	for _, seed := range []string{"/Ünïcode/file", "/ünïcode/FILE", "/K", "/k", "/STRASSE", "/straße", "/\xff\xfe", "/ﬁle", "/İ", "/i̇", "/‮..‭/x", "/．．/x"} {
+		f.Add(seed)
+	}
+	root := "/srv/" + testServerID
+	fsys := afero.NewMemMapFs()
+	for _, name := range []string{"Ünïcode/file", "K", "straße", "ﬁle", "İ"} {
+		require.NoError(f, afero.WriteFile(fsys, root+"/"+name, nil, 0o644))
+	}
+	r := PathResolver{Root: root, Storage: NewAferoBackend(fsys), CaseInsensitive: true}
+
+	f.Fuzz(func(t *testing.T, p string) {
+		res := r.Resolve(p)
+		checkResolved(t, root, res)
+		if res.Rejection == PathAllowed {
+			again := r.Resolve(strings.TrimPrefix(res.Path, root))
+			require.Equal(t, res.Path, again.Path)
+		}
+	})
+}
@@ -0,0 +1,333 @@
+package ftp
+
+import (
+	"encoding"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/google/uuid"
+	"github.com/spf13/afero"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+)
+
+// Uploads are normally written straight to the file, so when the connection
+// drops part way through a large upload the client either starts over or
+// resumes against a file nothing marks as incomplete. With resumable uploads
+// enabled, uploads that start at the beginning of a file are written to a
+// hidden partial file next to it, which replaces the file once the upload
+// completes. If the transfer fails the partial file is kept and recorded in a
+// journal that survives restarts, along with the bytes received and the state
+// of their checksum, and SIZE reports the bytes received for the path. A REST
+// at that offset followed by STOR, or an APPE, continues the partial file.
+
+// partialPrefix is the prefix of the partial files uploads are written to,
+// which are hidden from listings.
+const partialPrefix = ".ftp-partial-"
+
+// partialUpload is an upload being written to a partial file.
+type partialUpload struct {
+	Server string `json:"server"`
+	// The path of the file being uploaded, relative to the root of the server.
+	Path string `json:"path"`
+	// The real path of the partial file.
+	Temp string `json:"temp"`
+	// The number of bytes received when the transfer failed.
+	Size int64 `json:"size"`
+	// The state of the checksum of the bytes received, or empty if it is not
+	// known.
+	Hash    []byte    `json:"hash,omitempty"`
+	Updated time.Time `json:"updated"`
+
+	// The real path of the file being uploaded, and whether the partial file
+	// has been moved to it.
+	target string
+	done   bool
+}
+
+// resumeJournal contains the uploads that failed and can be resumed, by the
+// key returned by partialKey.
+type resumeJournal struct {
+	mu      sync.Mutex
+	uploads map[string]partialUpload
+}
+
+// resumeJournalPath returns the file the journal is stored in.
+func resumeJournalPath() string {
+	return filepath.Join(config.Get().System.RootDirectory, "ftp-resume.json")
+}
+
+// loadResumeJournal returns the stored journal. If it cannot be read the
+// error is logged and no uploads can be resumed.
+func loadResumeJournal() *resumeJournal {
+	j := &resumeJournal{uploads: make(map[string]partialUpload)}
+	b, err := os.ReadFile(resumeJournalPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithField("error", err).Error("failed to read FTP resume journal")
+		}
+		return j
+	}
+	if err := json.Unmarshal(b, &j.uploads); err != nil {
+		log.WithField("error", err).Error("failed to parse FTP resume journal")
+	}
+	return j
+}
+
+// get returns the upload recorded for the key.
+func (j *resumeJournal) get(key string) (partialUpload, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	u, ok := j.uploads[key]
+	return u, ok
+}
+
+// put records the upload for the key and stores the journal.
+func (j *resumeJournal) put(key string, u partialUpload) {
+	u.Updated = time.Now().UTC()
+	j.mu.Lock()
+	j.uploads[key] = u
+	j.mu.Unlock()
+	j.save()
+}
+
+// remove forgets the upload recorded for the key and stores the journal.
+func (j *resumeJournal) remove(key string) {
+	j.mu.Lock()
+	_, ok := j.uploads[key]
+	delete(j.uploads, key)
+	j.mu.Unlock()
+	if ok {
+		j.save()
+	}
+}
+
+// save stores the journal, logging the error if it cannot be written.
+func (j *resumeJournal) save() {
+	j.mu.Lock()
+	b, err := json.Marshal(j.uploads)
+	j.mu.Unlock()
+	if err == nil {
+		tmp := resumeJournalPath() + ".tmp"
+		if err = os.WriteFile(tmp, b, 0o600); err == nil {
+			err = os.Rename(tmp, resumeJournalPath())
+		}
+	}
+	if err != nil {
+		log.WithField("error", errors.WithStack(err)).Error("failed to store FTP resume journal")
+	}
+}
+
+// expire removes the partial files of the uploads that have not been resumed
+// within the configured expiry, along with those of servers that no longer
+// exist.
+func (j *resumeJournal) expire(m *server.Manager, fsys Backend, cfg config.FtpResumeConfiguration) {
+	cutoff := time.Now().Add(-time.Duration(cfg.Expiry) * time.Hour)
+	j.mu.Lock()
+	var expired []partialUpload
+	for key, u := range j.uploads {
+		if u.Updated.Before(cutoff) {
+			expired = append(expired, u)
+			delete(j.uploads, key)
+		}
+	}
+	j.mu.Unlock()
+	if len(expired) == 0 {
+		return
+	}
+	for _, u := range expired {
+		st, err := fsys.Stat(u.Temp)
+		if err != nil {
+			continue
+		}
+		if err := fsys.Remove(u.Temp); err != nil {
+			log.WithFields(log.Fields{"server": u.Server, "path": u.Path, "error": err}).Warn("ftp: failed to remove expired partial upload")
+			continue
+		}
+		if s, ok := m.Get(u.Server); ok {
+			usageRemoved(s)(st.Size())
+		}
+	}
+	j.save()
+}
+
+// partialKey returns the key uploads to the path are recorded by in the
+// journal.
+func (driver *FTPDriver) partialKey(s *server.Server, p string) string {
+	p = driver.serverPath(p)
+	if driver.cfg.CaseInsensitive {
+		p = strings.ToLower(p)
+	}
+	return s.ID() + ":" + p
+}
+
+// resumable reports whether uploads are written to partial files.
+func (driver *FTPDriver) resumable() bool {
+	return driver.cfg.Resume.Enabled && driver.resume != nil
+}
+
+// openPartial opens the partial file an upload to the path is written to. An
+// upload from the start of the file is given a new partial file, replacing any
+// failed upload to the path, while one that continues the file picks up the
+// partial file of the failed upload. A nil file is returned for uploads that
+// should be written to the file itself.
+func (driver *FTPDriver) openPartial(s *server.Server, p string, realPath string, flag int, perm os.FileMode) (afero.File, *partialUpload, error) {
+	if !driver.resumable() {
+		return nil, nil, nil
+	}
+	driver.resume.expire(driver.manager, driver.storage(), driver.cfg.Resume)
+	fsys := driver.storage()
+	key := driver.partialKey(s, p)
+	u, ok := driver.resume.get(key)
+	if flag&os.O_TRUNC == 0 {
+		if !ok {
+			return nil, nil, nil
+		}
+		st, err := fsys.Stat(u.Temp)
+		if err != nil {
+			driver.resume.remove(key)
+			return nil, nil, nil
+		}
+		f, err := fsys.OpenFile(u.Temp, flag&^(os.O_CREATE|os.O_TRUNC), perm)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The partial file is longer than recorded if Wings stopped while the
+		// upload was running, in which case the checksum is not known.
+		if st.Size() != u.Size {
+			u.Size, u.Hash = st.Size(), nil
+		}
+		u.target = realPath
+		return f, &u, nil
+	}
+
+	if ok {
+		if st, err := fsys.Stat(u.Temp); err == nil && fsys.Remove(u.Temp) == nil {
+			usageRemoved(s)(st.Size())
+		}
+	}
+	u = partialUpload{
+		Server: s.ID(),
+		Path:   driver.serverPath(p),
+		Temp:   filepath.Join(filepath.Dir(realPath), partialPrefix+uuid.New().String()[:8]),
+		target: realPath,
+	}
+	f, err := fsys.OpenFile(u.Temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The upload is recorded from the start, so that its partial file is
+	// cleaned up even if Wings stops before the transfer ends.
+	driver.resume.put(key, u)
+	return f, &u, nil
+}
+
+// statPartial returns the partial file of a failed upload to the path under
+// the name of the path, so that SIZE tells the client where to resume from.
+func (driver *FTPDriver) statPartial(p string) (os.FileInfo, bool) {
+	if !driver.resumable() {
+		return nil, false
+	}
+	s, err := driver.getServer()
+	if err != nil {
+		return nil, false
+	}
+	u, ok := driver.resume.get(driver.partialKey(s, p))
+	if !ok {
+		return nil, false
+	}
+	st, err := driver.storage().Stat(u.Temp)
+	if err != nil {
+		return nil, false
+	}
+	return partialInfo{FileInfo: st, name: filepath.Base(p)}, true
+}
+
+// partialInfo is a partial file reported under the name of the file being
+// uploaded.
+type partialInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i partialInfo) Name() string {
+	return i.name
+}
+
+// restoreHash returns the checksum of the bytes received by the upload, or nil
+// if checksums are disabled or it is not known.
+func (driver *FTPDriver) restoreHash(u *partialUpload) hash.Hash {
+	h := driver.newUploadHash()
+	if h == nil || len(u.Hash) == 0 {
+		return nil
+	}
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(u.Hash); err != nil {
+		return nil
+	}
+	return h
+}
+
+// TransferError is called by ftpserverlib when the transfer of the upload
+// fails, so that its partial file is kept to be resumed.
+func (f *uploadFile) TransferError(error) {
+	f.failed = true
+}
+
+// seekPartial checks that a resumed upload continues from no further than the
+// bytes received, dropping anything beyond the offset it continues from.
+func (f *uploadFile) seekPartial(offset int64, whence int) error {
+	if f.partial == nil || whence != io.SeekStart {
+		return nil
+	}
+	if offset > f.partial.Size {
+		// The partial file is kept as it was for the client to try again.
+		f.failed = true
+		return errors.Errorf("cannot resume upload at %d, only %d bytes were received", offset, f.partial.Size)
+	}
+	if offset < f.partial.Size && f.fd != nil {
+		return f.fd.Truncate(offset)
+	}
+	return nil
+}
+
+// finishPartial moves the partial file over the file being uploaded once the
+// upload completes, or records it in the journal to be resumed if it failed.
+func (f *uploadFile) finishPartial(failed bool) error {
+	u := f.partial
+	key := f.driver.partialKey(f.server, f.path)
+	fsys := f.driver.storage()
+	if failed {
+		st, err := fsys.Stat(u.Temp)
+		if err != nil {
+			// The upload was rejected and its partial file removed.
+			f.driver.resume.remove(key)
+			return nil
+		}
+		u.Size, u.Hash = st.Size(), nil
+		if f.hash != nil {
+			u.Hash, _ = f.hash.(encoding.BinaryMarshaler).MarshalBinary()
+		}
+		f.driver.resume.put(key, *u)
+		return nil
+	}
+	// The usage of the file being replaced is counted along with that of the
+	// partial file.
+	if st, err := fsys.Stat(u.target); err == nil {
+		f.size += st.Size()
+	}
+	if err := fsys.Rename(u.Temp, u.target); err != nil {
+		return err
+	}
+	u.done = true
+	f.driver.resume.remove(key)
+	return nil
+}
@@ -0,0 +1,119 @@
+package ftp
+
+import (
+	"slices"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// The permission scopes of an FTP account limit what it can do on the server,
+// such as an upload-only account that players can send files to without
+// being able to see or change anything else. Accounts without any scopes
+// stored can do everything.
+
+// The permission scopes an FTP account can be granted.
+const (
+	// Listing directories and downloading files.
+	ScopeRead = "read"
+	// Uploading new files, and appending to existing ones.
+	ScopeWrite = "write"
+	// Deleting files and directories, and overwriting existing files.
+	ScopeDelete = "delete"
+	// Renaming and moving files and directories.
+	ScopeRename = "rename"
+	// Creating directories.
+	ScopeMkdir = "mkdir"
+)
+
+// Scopes are every permission scope, in the order they are listed in.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeDelete, ScopeRename, ScopeMkdir}
+
+// ErrInvalidScope is returned when setting a permission scope that does not
+// exist.
+var ErrInvalidScope = errors.New("ftp: invalid permission scope")
+
+// validScopes returns the scopes sorted in the order they are listed in,
+// without duplicates, or ErrInvalidScope if any of them do not exist.
+func validScopes(scopes []string) ([]string, error) {
+	out := make([]string, 0, len(scopes))
+	for _, s := range Scopes {
+		if slices.Contains(scopes, s) {
+			out = append(out, s)
+		}
+	}
+	for _, s := range scopes {
+		if !slices.Contains(Scopes, s) {
+			return nil, errors.WithMessage(ErrInvalidScope, s)
+		}
+	}
+	return out, nil
+}
+
+// SetAccountScopes replaces the permission scopes of an FTP account. A nil
+// slice removes them, allowing the account to do everything again. Sessions
+// that are already logged in keep the scopes they logged in with.
+func SetAccountScopes(username string, scopes []string) ([]string, error) {
+	if !accountExists(username) {
+		return nil, ErrAccountNotFound
+	}
+	if scopes != nil {
+		var err error
+		if scopes, err = validScopes(scopes); err != nil {
+			return nil, err
+		}
+	}
+	err := updateAccountMeta(username, func(m *accountMeta) {
+		m.Scopes = scopes
+	})
+	return scopes, err
+}
+
+// AccountScopes returns the permission scopes of an FTP account, or nil if it
+// is not restricted.
+func AccountScopes(username string) ([]string, error) {
+	if !accountExists(username) {
+		return nil, ErrAccountNotFound
+	}
+	m, err := readAccountMeta(username)
+	if err != nil {
+		return nil, err
+	}
+	return m.Scopes, nil
+}
+
+// allowed reports whether the account the session logged in with has been
+// granted the permission scope, and for logins validated by the Panel, the
+// Panel permission SFTP requires for it.
+func (driver *FTPDriver) allowed(scope string) bool {
+	if p, ok := scopePermissions[scope]; ok && !driver.can(p) {
+		return false
+	}
+	return driver.scopes == nil || slices.Contains(driver.scopes, scope)
+}
+
+// checkScope returns an error if the account the session logged in with has
+// not been granted the permission scope.
+func (driver *FTPDriver) checkScope(scope string) error {
+	if driver.allowed(scope) {
+		return nil
+	}
+	return withReplyCode(ftpserver.StatusActionNotTaken, errors.Errorf("permission denied: this account cannot %s", scopeAction(scope)))
+}
+
+// scopeAction describes what a permission scope allows, for error replies.
+func scopeAction(scope string) string {
+	switch scope {
+	case ScopeRead:
+		return "list or download files"
+	case ScopeWrite:
+		return "upload files"
+	case ScopeDelete:
+		return "delete or overwrite files"
+	case ScopeRename:
+		return "rename files"
+	case ScopeMkdir:
+		return "create directories"
+	}
+	return scope
+}
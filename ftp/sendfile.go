@@ -0,0 +1,42 @@
+package ftp
+
+import (
+	"io"
+	"net"
+)
+
+// Downloads over a plain passive data connection are handed to the kernel
+// with sendfile, so that the file is never copied into Wings. The data
+// connection is wrapped to watch for stalls and rewrite listings, which hides
+// the TCP connection from io.Copy, so the file is sent to it directly instead.
+// Downloads over TLS are encrypted by Wings, and downloads with a limited rate
+// are read a chunk at a time, so both are still copied through a buffer.
+
+// The number of bytes given to sendfile at once. The progress of the transfer
+// and the activity of the data connection are recorded between chunks, so
+// this is kept small enough that slow clients are not mistaken for stalled
+// ones.
+const sendfileChunk = 512 * 1024
+
+// sendfile sends the rest of the file over the data connection, falling back
+// to a buffered copy where the connection is not TCP.
+func (f *downloadFile) sendfile(dc *dataConn) (int64, error) {
+	tc, ok := dc.Conn.(*net.TCPConn)
+	if !ok {
+		return copyBuffer(dc, struct{ io.Reader }{f.fd})
+	}
+	var total int64
+	for {
+		// TCPConn.ReadFrom uses sendfile when given a file, or a file behind an
+		// io.LimitedReader.
+		n, err := tc.ReadFrom(&io.LimitedReader{R: f.fd, N: sendfileChunk})
+		dc.touch()
+		f.progress(n)
+		total += n
+		f.n += n
+		f.stats.download(n)
+		if err != nil || n < sendfileChunk {
+			return total, err
+		}
+	}
+}
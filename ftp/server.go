@@ -4,15 +4,19 @@ import (
 	"context"
 	"crypto/tls"
 	stderrors "errors"
+	"net"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/remote"
@@ -21,136 +25,536 @@ import (
 
 //goland:noinspection GoNameStartsWithPackageName
 type FTPServer struct {
-	manager  *server.Manager
-	BasePath string
-	ReadOnly bool
-	Listen   string
-	server   *ftpserver.FtpServer
-	client   remote.Client
+	manager   *server.Manager
+	BasePath  string
+	ReadOnly  bool
+	servers   []*ftpserver.FtpServer
+	client    remote.Client
+	cfg       config.FtpConfiguration
+	sessions  *sessionStore
+	stats     *statsRegistry
+	locks     *writeLocks
+	transfers *transferSlots
+	deletes   *deleteJobs
+	health    *healthState
+	limits    *serverBandwidth
+	webhooks  *webhooks
+	access    *accessLog
+	xferlog   *xferLog
+	tracing   *tracing
+	geoip     *geoIP
+	offenders *offenders
+	// The servers made read-only through the API.
+	readOnly *readOnlyServers
+	// The directory listings cached for every session.
+	listings *listingCache
+	// The storage the files of servers are kept on.
+	backend Backend
+	// The uploads that failed and can be resumed.
+	resume *resumeJournal
+	// The recent attempts to change the password of an account.
+	passwordChanges *requestLimiter
+	cancel          context.CancelFunc
+	// The node-wide bandwidth limiter of the running listeners.
+	node *fairLimiter
+	// The running WebDAV listener, if it is enabled.
+	dav *davServer
+
+	// The listeners on the ports dedicated to each server, and whether they
+	// should be open, guarded by dmu.
+	dedicated     map[string]*dedicatedListener
+	dedicatedOpen bool
+	dmu           sync.Mutex
+
+	// mu guards the configuration and the running ftpserverlib instances,
+	// which are replaced when the configuration is reloaded.
+	mu sync.Mutex
+	// reloading is set when the running instances are stopped so that they
+	// can be started again with the reloaded configuration.
+	reloading atomic.Bool
 }
 
 func New(m *server.Manager, client remote.Client) *FTPServer {
 	cfg := config.Get().System
 	ftpCfg := cfg.Ftp
 	return &FTPServer{
-		manager:  m,
-		client:   client,
-		BasePath: cfg.Data,
-		ReadOnly: ftpCfg.ReadOnly,
-		Listen:   ftpCfg.Address + ":" + strconv.Itoa(ftpCfg.Port),
+		manager:   m,
+		client:    client,
+		BasePath:  cfg.Data,
+		ReadOnly:  ftpCfg.ReadOnly,
+		cfg:       ftpCfg,
+		sessions:  newSessionStore(),
+		stats:     loadStatsRegistry(),
+		locks:     newWriteLocks(),
+		transfers: &transferSlots{},
+		deletes:   newDeleteJobs(),
+		health:    newHealthState(),
+		limits:    newServerBandwidth(),
+		webhooks:  newWebhooks(),
+		access:    newAccessLog(),
+		xferlog:   newXferLog(),
+		tracing:   newTracing(),
+		geoip:     newGeoIP(),
+		offenders: loadOffenders(),
+		readOnly:  loadReadOnlyServers(),
+		listings:  newListingCache(),
+		backend:   NewLocalBackend(),
+		resume:    loadResumeJournal(),
+
+		passwordChanges: newRequestLimiter(),
+
+		dedicated: make(map[string]*dedicatedListener),
 	}
 }
 
-// Run starts the FTP server and adds a persistent listener to handle inbound
-// FTP connections.
+// Run starts the FTP server and adds a persistent listener for each configured
+// address to handle inbound FTP connections. When the configuration is
+// reloaded the listeners are started again with it, while existing sessions
+// carry on with the configuration they were started with.
 func (c *FTPServer) Run() error {
-	ftpServer := ftpserver.NewFtpServer(&FTPServerDriver{
-		manager:  c.manager,
-		client:   c.client,
-		basePath: c.BasePath,
-		readOnly: c.ReadOnly,
-		listen:   c.Listen,
-	})
+	var previous *config.FtpConfiguration
+	for {
+		c.mu.Lock()
+		cfg := c.cfg
+		c.mu.Unlock()
 
-	c.server = ftpServer
+		if err := c.access.open(cfg.AccessLog); err != nil {
+			log.WithField("error", err).Error("failed to open FTP access log")
+		}
+		if err := c.xferlog.open(cfg.XferLog); err != nil {
+			log.WithField("error", err).Error("failed to open FTP xferlog")
+		}
+		if err := c.tracing.open(cfg.Tracing); err != nil {
+			log.WithField("error", err).Error("failed to set up FTP tracing")
+		}
+		if err := c.geoip.open(cfg.GeoIP.Database); err != nil {
+			log.WithField("error", err).Error("failed to open FTP GeoIP database")
+		}
+		migrateCredentials()
+		c.resume.expire(c.manager, c.backend, cfg.Resume)
+		go sweepDeletes(c.manager, c.backend, c.BasePath, c.deletes)
+		if _, ok := ioPriority(cfg.IOPriority); !ok && cfg.IOPriority.Class != "" {
+			log.WithField("class", cfg.IOPriority.Class).Warn("unknown FTP I/O priority class, transfers keep the priority of Wings")
+		}
+		if cfg.DropPrivileges && !canDropPrivileges {
+			log.Warn("FTP drop_privileges is only supported on Linux, files are accessed as the Wings user")
+		}
+		servers, err := c.bind(cfg)
+		if err != nil {
+			c.health.error(healthErrorListener, err)
+			if previous == nil {
+				return err
+			}
+			// Keep serving with the configuration that was working before the
+			// reload rather than leaving the node without FTP.
+			log.WithField("error", err).Error("failed to bind FTP listeners for reloaded configuration, reverting")
+			c.setConfig(*previous)
+			previous = nil
+			continue
+		}
 
-	log.WithField("listen", c.Listen).Info("starting FTP server")
+		ctx, cancel := context.WithCancel(context.Background())
+		c.mu.Lock()
+		c.servers = servers
+		c.cancel = cancel
+		dav := c.dav
+		c.mu.Unlock()
+		if dav != nil {
+			go dav.serve(ctx)
+		}
+		if cfg.Trash.Enabled {
+			go c.runTrashPurge(ctx, cfg.Trash)
+		}
+		if cfg.DedicatedPorts.Enabled {
+			go c.runDedicatedPorts(ctx)
+		}
+		go c.runStatsSave(ctx)
 
-	if err := ftpServer.ListenAndServe(); err != nil {
-		log.WithField("error", err).Error("FTP server error")
-		return err
+		log.WithField("listen", listenAddresses(cfg)).Info("starting FTP server")
+
+		c.health.started()
+		err = c.serve(servers)
+		c.health.stopped()
+		cancel()
+		c.closeDedicated()
+		if c.reloading.CompareAndSwap(true, false) {
+			previous = &cfg
+			continue
+		}
+		if err != nil {
+			c.health.error(healthErrorListener, err)
+			log.WithField("error", err).Error("FTP server error")
+			return err
+		}
+		return nil
 	}
+}
 
-	return nil
+// serve accepts connections on each of the listeners until they have all been
+// stopped. If one of them fails the others are stopped as well.
+func (c *FTPServer) serve(servers []*ftpserver.FtpServer) error {
+	errs := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s *ftpserver.FtpServer) {
+			errs <- s.Serve()
+		}(s)
+	}
+	var err error
+	for range servers {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			c.stop()
+		}
+	}
+	return err
+}
+
+// stop closes the listeners of the running ftpserverlib instances, and of the
+// WebDAV listener.
+func (c *FTPServer) stop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for _, s := range c.servers {
+		if e := s.Stop(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := c.dav.close(); e != nil && err == nil {
+		err = e
+	}
+	c.dav = nil
+	return err
 }
 
 // Shutdown gracefully stops the FTP server.
 func (c *FTPServer) Shutdown(ctx context.Context) error {
-	if c.server != nil {
-		return c.server.Stop()
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.mu.Unlock()
+	c.closeDedicated()
+	if err := c.stats.save(); err != nil {
+		log.WithField("error", err).Error("failed to save FTP transfer statistics")
+	}
+	if err := c.tracing.close(); err != nil {
+		log.WithField("error", err).Warn("failed to export remaining FTP spans")
 	}
-	return nil
+	return c.stop()
 }
 
-// FTPServerDriver implements ftpserver.MainDriver interface.
+// Addrs returns the addresses the running listeners are bound to, which is
+// how the port is found if the bind port is set to 0 so that any free port is
+// used, such as in tests. It is empty until the listeners are bound.
+func (c *FTPServer) Addrs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	addrs := make([]string, 0, len(c.servers))
+	for _, s := range c.servers {
+		addrs = append(addrs, s.Addr())
+	}
+	return addrs
+}
+
+// FTPServerDriver implements ftpserver.MainDriver interface. An instance is
+// created for each listener, sharing the state of the FTPServer.
 type FTPServerDriver struct {
 	manager  *server.Manager
 	client   remote.Client
 	basePath string
 	readOnly bool
 	listen   string
+	listener net.Listener
+	settings config.FtpListenerConfiguration
+	tls      *tls.Config
+	// The ID of the server the listener is dedicated to, if any.
+	dedicated string
+	sessions  *sessionStore
+	stats     *statsRegistry
+	locks     *writeLocks
+	transfers *transferSlots
+	deletes   *deleteJobs
+	health    *healthState
+	limits    *serverBandwidth
+	node      *fairLimiter
+	webhooks  *webhooks
+	access    *accessLog
+	xferlog   *xferLog
+	tracing   *tracing
+	geoip     *geoIP
+	offenders *offenders
+	// The servers made read-only through the API.
+	readOnlyServers *readOnlyServers
+	listings        *listingCache
+	backend         Backend
+	resume          *resumeJournal
+	cfg             config.FtpConfiguration
 }
 
 func (d *FTPServerDriver) GetSettings() (*ftpserver.Settings, error) {
 	return &ftpserver.Settings{
+		Listener:                 d.listener,
 		ListenAddr:               d.listen,
-		PublicHost:               "",
-		PassiveTransferPortRange: &ftpserver.PortRange{Start: 40000, End: 50000},
+		PublicHost:               d.settings.PublicHost,
+		PassiveTransferPortRange: passivePortRange(d.settings),
+		TLSRequired:              libraryTLSRequirement(d.settings),
+		IdleTimeout:              d.cfg.IdleTimeout,
 		DisableMLSD:              false,
 		DisableMLST:              false,
-		Banner:                   "Pterodactyl FTP Server",
+		DisableSite:              d.cfg.Features.DisableSite,
+		EnableHASH:               d.cfg.Features.Hash,
+		Banner:                   d.banner(),
 	}, nil
 }
 
 func (d *FTPServerDriver) ClientConnected(cc ftpserver.ClientContext) (string, error) {
 	log.WithField("remote_addr", cc.RemoteAddr()).Debug("FTP client connected")
-	return "Welcome to Pterodactyl FTP Server", nil
+	if msg := d.connectionLimit(cc); msg != "" {
+		return msg, errors.New(msg)
+	}
+	if msg := d.geoBlocked(cc); msg != "" {
+		return msg, errors.New(msg)
+	}
+	banner, reject := d.penalize(cc)
+	if reject {
+		return banner, errors.New(banner)
+	}
+	d.startLoginTimeout(cc)
+	if banner != "" {
+		return banner, nil
+	}
+	return d.banner(), nil
 }
 
-func (d *FTPServerDriver) ClientDisconnected(cc ftpserver.ClientContext) {
-	log.WithField("remote_addr", cc.RemoteAddr()).Debug("FTP client disconnected")
+// connectionLimit returns the reason a new connection is rejected if it would
+// exceed the configured connection limits, and arranges for the greeting to
+// be sent with a 421 reply.
+func (d *FTPServerDriver) connectionLimit(cc ftpserver.ClientContext) string {
+	ip := remoteIP(cc.RemoteAddr())
+	total, fromIP := d.sessions.ConnCount(ip)
+	var msg, reason string
+	switch {
+	case d.cfg.MaxConnections > 0 && total > d.cfg.MaxConnections:
+		msg, reason = "Too many connections to the server, try again later", "max_connections"
+	case d.cfg.MaxConnectionsPerIP > 0 && fromIP > d.cfg.MaxConnectionsPerIP:
+		msg, reason = "Too many connections from your IP address", "max_connections_per_ip"
+	default:
+		return ""
+	}
+	if busy := customMessage(d.cfg.Messages, serverBusyMessage); busy != "" {
+		msg = busy
+	}
+	log.WithFields(log.Fields{
+		"ip":          ip,
+		"connections": total,
+		"from_ip":     fromIP,
+		"limit":       reason,
+	}).Warn("FTP connection rejected: connection limit reached")
+	if c := d.sessions.Conn(cc.RemoteAddr().String()); c != nil {
+		c.rejectCode.Store(ftpserver.StatusServiceNotAvailable)
+	}
+	return msg
 }
 
-func (d *FTPServerDriver) AuthUser(cc ftpserver.ClientContext, username, password string) (ftpserver.ClientDriver, error) {
-	// Usernames follow the format: user_{server-id}
-	// Validate format first
-	validUsernameRegexp := regexp.MustCompile(`^(?i)(.+)_([a-z0-9]{8}|[a-z0-9-]{36})$`)
-	
-	if !validUsernameRegexp.MatchString(username) {
+func (d *FTPServerDriver) ClientDisconnected(cc ftpserver.ClientContext) {
+	if s := d.sessions.Get(cc.RemoteAddr().String()); s != nil {
+		d.sessions.Delete(cc.RemoteAddr().String())
+		st := s.driver.stats.Snapshot()
 		log.WithFields(log.Fields{
-			"username": username,
-			"ip":       cc.RemoteAddr().String(),
-		}).Warn("failed to validate FTP credentials: invalid username format")
-		return nil, errors.New("invalid username format")
+			"subsystem":        "ftp",
+			"username":         s.driver.user,
+			"ip":               s.driver.ip,
+			"duration":         time.Since(s.started).Round(time.Second).String(),
+			"bytes_uploaded":   st.BytesUploaded,
+			"bytes_downloaded": st.BytesDownloaded,
+			"files_uploaded":   st.FilesUploaded,
+			"files_downloaded": st.FilesDownloaded,
+			"client":           s.cc.GetClientVersion(),
+			"tls":              s.tls != nil,
+		}).Info("FTP session closed")
 	}
+	log.WithField("remote_addr", cc.RemoteAddr()).Debug("FTP client disconnected")
+}
 
-	parts := strings.Split(username, "_")
-	if len(parts) < 2 {
-		log.WithField("username", username).Warn("failed to validate FTP credentials: invalid username format")
-		return nil, errors.New("invalid username format")
+func (d *FTPServerDriver) AuthUser(cc ftpserver.ClientContext, username, password string) (_ ftpserver.ClientDriver, err error) {
+	// Tarpitted clients never log in, whatever credentials they give.
+	if d.tarpitted(cc) {
+		return nil, errTarpitted
 	}
 
-	// Last part is server key, everything before is user
-	serverKey := parts[len(parts)-1]
-
-	// Find the server
-	var s *server.Server
-	s = d.manager.Find(func(srv *server.Server) bool {
-		srvID := srv.ID()
-		// Try exact match (full UUID)
-		if srvID == serverKey {
-			return true
+	// When logins are validated by the Panel the user.{server-id} format of
+	// SFTP is accepted as well, except for accounts mapped to a server whose
+	// username may look like it.
+	if _, mapped := mappedServer(username); !mapped {
+		if user, key, ok := d.parseSFTPUsername(username); ok {
+			username = user + "_" + key
 		}
-		// Try short ID match (first 8 chars)
-		if len(srvID) >= 8 && srvID[:8] == serverKey {
-			return true
+	}
+	// On a port dedicated to a server the server suffix may be left out.
+	if d.dedicated != "" {
+		username = serverUsername(username, d.dedicated)
+	}
+	entry := accessEntry{Event: accessEventLogin, Username: username, IP: remoteIP(cc.RemoteAddr()), Code: ftpserver.StatusUserLoggedIn}
+	_, span := d.tracing.start(context.Background(), "ftp.auth",
+		attribute.String("ftp.username", username),
+		attribute.String("client.address", entry.IP),
+	)
+	defer func() {
+		if entry.Server != "" {
+			span.SetAttributes(attribute.String("ftp.server", entry.Server), attribute.String("ftp.session", entry.Session))
 		}
-		// Try last 8 chars match
-		if len(srvID) >= 8 && strings.HasSuffix(srvID, serverKey) {
-			return true
+		endSpan(span, err)
+		if err != nil {
+			d.health.error(healthErrorLogin, err)
+			entry.Code, entry.Error = ftpserver.StatusNotLoggedIn, err.Error()
+			d.loginFailed(cc, err)
 		}
-		return false
-	})
+		entry.Time = time.Now().UTC()
+		d.access.write(entry)
+		d.scrutinizeLogin(cc, username, err)
+	}()
 
+	if err := d.clientPolicy(cc, username); err != nil {
+		return nil, err
+	}
+
+	// Usernames follow the format: user_{server-id}, unless the account is
+	// mapped to a server.
+	actualUser, serverKey, ok := parseAccountUsername(username)
+	if !ok {
+		log.WithFields(log.Fields{
+			"username": username,
+			"ip":       cc.RemoteAddr().String(),
+		}).Warn("failed to validate FTP credentials: invalid username format")
+		return nil, badCredentials("invalid username format")
+	}
+
+	s := d.manager.Lookup(serverKey)
 	if s == nil {
 		log.WithFields(log.Fields{
 			"username":   username,
 			"server_key": serverKey,
 			"ip":         cc.RemoteAddr().String(),
 		}).Warn("failed to validate FTP credentials: server not found")
-		return nil, errors.New("server not found")
+		return nil, badCredentials("server not found")
+	}
+
+	if s.FtpDisabled() {
+		log.WithFields(log.Fields{
+			"username":  username,
+			"server_id": s.ID(),
+			"ip":        cc.RemoteAddr().String(),
+		}).Info("FTP login rejected: FTP is disabled for this server")
+		return nil, errors.New("FTP access is not enabled for this server")
 	}
 
+	var meta accountMeta
+	var root string
+	var permissions []string
+	if d.usesPanel(username) {
+		permissions, err = d.authPanel(cc, actualUser, password, s)
+	} else {
+		meta, root, err = d.authLocal(cc, username, actualUser, password, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if w := activeMaintenance(d.cfg.Maintenance, s.ID(), time.Now().In(maintenanceLocation())); w != nil && w.Mode == maintenanceBlocked {
+		log.WithFields(log.Fields{
+			"username":  username,
+			"server_id": s.ID(),
+			"ip":        cc.RemoteAddr().String(),
+		}).Info("FTP login rejected during maintenance window")
+		return nil, maintenanceError(w)
+	}
+
+	driver := &FTPDriver{
+		manager:   d.manager,
+		client:    d.client,
+		BasePath:  d.basePath,
+		ReadOnly:  d.readOnly || meta.ReadOnly,
+		user:      username,
+		ip:        remoteIP(cc.RemoteAddr()),
+		server:    s, // Cache the server to avoid repeated lookups
+		cfg:       d.cfg,
+		stats:     d.stats.session(s.ID()),
+		history:   d.stats.server(s.ID()),
+		locks:     d.locks,
+		transfers: d.transfers,
+		deletes:   d.deletes,
+		root:      root,
+		node:      d.node,
+		webhooks:  d.webhooks,
+		access:    d.access,
+		xferlog:   d.xferlog,
+		tracing:   d.tracing,
+
+		readOnlyServers: d.readOnlyServers,
+		scopes:          meta.Scopes,
+		permissions:     permissions,
+		listings:        d.listings,
+		backend:         d.backend,
+		resume:          d.resume,
+		cc:              cc,
+	}
+	driver.bandwidth = driver.bandwidth.
+		with(newBandwidthBucket(d.cfg.PerConnectionBandwidth)).
+		with(d.limits.bucket(d.cfg, s))
+	driver.uploadBandwidth, driver.downloadBandwidth = d.limits.accountBuckets(username, meta.UploadLimit, meta.DownloadLimit)
+	driver.bandwidthLimits = bandwidthLimits{
+		Connection: d.cfg.PerConnectionBandwidth,
+		Server:     serverBandwidthLimit(d.cfg, s.ID(), s.FtpBandwidth()),
+		Upload:     meta.UploadLimit,
+		Download:   meta.DownloadLimit,
+	}
+	limit := d.cfg.MaxSessionsPerServer
+	if n, ok := d.cfg.ServerMaxSessions[s.ID()]; ok {
+		limit = n
+	}
+	driver.sessionID = uuid.New().String()[:8]
+	driver.log = log.WithFields(log.Fields{
+		"subsystem": "ftp",
+		"session":   driver.sessionID,
+		"username":  username,
+		"server":    s.ID(),
+		"ip":        driver.ip,
+	})
+	entry.Server, entry.Session = s.ID(), driver.sessionID
+	if !d.sessions.PutLimited(cc.RemoteAddr().String(), &session{id: driver.sessionID, cc: cc, driver: driver, started: time.Now(), tls: d.sessions.tlsInfo(cc.RemoteAddr().String())}, limit) {
+		log.WithFields(log.Fields{
+			"username":  username,
+			"server_id": s.ID(),
+			"ip":        cc.RemoteAddr().String(),
+			"limit":     limit,
+		}).Warn("FTP login rejected: too many sessions for server")
+		return nil, errTooManySessions
+	}
+
+	driver.setLabels(s, "")
+
+	// Logins validated by the Panel have no account on the node to record
+	// them in.
+	if permissions == nil {
+		if rememberIP(username, driver.ip) {
+			driver.notify(s, webhookLoginNewIP)
+		}
+		if err := updateAccountMeta(username, func(m *accountMeta) {
+			now := time.Now().UTC()
+			m.LastLogin = &now
+		}); err != nil {
+			driver.logger().WithField("error", err).Warn("failed to record FTP account login")
+		}
+	}
+
+	// Return client driver
+	return &ClientDriver{FTPDriver: driver}, nil
+}
+
+// authLocal validates the credentials against the FTP account stored on the
+// node, returning its metadata and the directory it is jailed to.
+func (d *FTPServerDriver) authLocal(cc ftpserver.ClientContext, username, actualUser, password string, s *server.Server) (accountMeta, string, error) {
 	// Verify password against /etc/passwd
 	logger := log.WithFields(log.Fields{
 		"subsystem": "ftp",
@@ -161,33 +565,98 @@ func (d *FTPServerDriver) AuthUser(cc ftpserver.ClientContext, username, passwor
 
 	if !verifyPassword(username, password) {
 		logger.Warn("failed to validate FTP credentials (invalid password)")
-		return nil, errors.New("invalid password")
+		return accountMeta{}, "", badCredentials("invalid password")
 	}
 
-	// Extract actual username from full username (without server id)
-	actualUser := strings.Join(parts[:len(parts)-1], "_")
-	
 	// Security check: Verify user has access to the server
 	// Load server ACL from config or database
-	if !userHasAccessToServer(actualUser, s.ID()) {
+	if !hasServerAccess(username, actualUser, s.ID()) {
 		log.WithFields(log.Fields{
 			"username":  username,
 			"server_id": s.ID(),
 			"ip":        cc.RemoteAddr().String(),
 		}).Warn("FTP access denied: user does not have permission for this server")
-		return nil, errors.New("access denied: you do not have permission to access this server")
+		return accountMeta{}, "", badCredentials("access denied: you do not have permission to access this server")
 	}
 
-	// Return client driver
-	return &ClientDriver{
-		FTPDriver: &FTPDriver{
-			manager:  d.manager,
-			BasePath: d.basePath,
-			ReadOnly: d.readOnly,
-			user:     username,
-			server:   s, // Cache the server to avoid repeated lookups
-		},
-	}, nil
+	meta, err := readAccountMeta(username)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"username": username,
+			"error":    err,
+		}).Error("failed to read FTP account metadata")
+		return accountMeta{}, "", errors.New("failed to load account")
+	}
+	if meta.expired(time.Now()) {
+		log.WithFields(log.Fields{
+			"username":   username,
+			"expires_at": meta.ExpiresAt,
+		}).Info("FTP login rejected: account has expired")
+		return accountMeta{}, "", errors.New("account has expired")
+	}
+
+	root, err := accountRoot(username)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"username": username,
+			"error":    err,
+		}).Error("failed to read FTP account root directory")
+		return accountMeta{}, "", errors.New("failed to load account")
+	}
+	if root != "" {
+		if st, err := os.Stat(filepath.Join(d.basePath, s.ID(), root)); err != nil || !st.IsDir() {
+			log.WithFields(log.Fields{
+				"username": username,
+				"root":     "/" + root,
+			}).Warn("FTP access denied: account root directory does not exist")
+			return accountMeta{}, "", errors.New("account root directory does not exist")
+		}
+	}
+	return meta, root, nil
+}
+
+// startLoginTimeout disconnects the client if it has not logged in within the
+// configured time, so that port scanners and stuck clients do not hold on to a
+// connection.
+func (d *FTPServerDriver) startLoginTimeout(cc ftpserver.ClientContext) {
+	if d.cfg.LoginTimeout <= 0 {
+		return
+	}
+	addr := cc.RemoteAddr().String()
+	c := d.sessions.Conn(addr)
+	if c == nil {
+		return
+	}
+	c.loginTimer = time.AfterFunc(time.Duration(d.cfg.LoginTimeout)*time.Second, func() {
+		if d.sessions.Get(addr) != nil {
+			return
+		}
+		log.WithField("ip", remoteIP(cc.RemoteAddr())).Debug("FTP client disconnected: login timeout")
+		c.reply(ftpserver.StatusServiceNotAvailable, "Login timeout, closing control connection")
+		_ = c.Conn.Close()
+	})
+}
+
+// remoteIP returns the IP address of a remote address without its port.
+func remoteIP(addr net.Addr) string {
+	if a, ok := addr.(*net.TCPAddr); ok {
+		return a.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// hasServerAccess reports whether the account has access to the server, which
+// it has if it is mapped to the server or, if it is not mapped to one, if the
+// user has an account for the server.
+func hasServerAccess(username, actualUser, serverID string) bool {
+	if id, ok := mappedServer(username); ok {
+		return id == serverID
+	}
+	return userHasAccessToServer(actualUser, serverID)
 }
 
 // userHasAccessToServer checks if a user has permission to access a specific server.
@@ -196,56 +665,75 @@ func (d *FTPServerDriver) AuthUser(cc ftpserver.ClientContext, username, passwor
 func userHasAccessToServer(username, serverID string) bool {
 	// Security: Check if password file exists for this user_serverid combination
 	// This implicitly means the user has been granted access
-	passwordDir := "/var/lib/pterodactyl/passwords"
 	fullUsername := username + "_" + serverID[:8]
-	passwordFile := filepath.Join(passwordDir, fullUsername+".txt")
-	
-	_, err := os.Stat(passwordFile)
-	if err != nil {
+	if _, err := Credentials().stat(fullUsername, credentialPassword); err != nil {
 		log.WithFields(log.Fields{
-			"username": username,
+			"username":  username,
 			"server_id": serverID,
 		}).Debug("FTP access denied: no password file found for user_server combination")
 		return false
 	}
-	
+	// An account whose username looks like it is for the server may be
+	// mapped to another one.
+	if id, ok := mappedServer(fullUsername); ok && id != serverID {
+		return false
+	}
+
 	return true
 }
 
+// accountRoot returns the directory, relative to the server root, that the
+// account is jailed to. This is read from {username}.root in the credentials
+// directory alongside the password, and is empty if the account is not jailed.
+func accountRoot(username string) (string, error) {
+	data, err := Credentials().read(username, credentialRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return relativePath(strings.TrimSpace(string(data))), nil
+}
+
 // verifyPassword checks if the password is correct by reading from file
-// Reads from /var/lib/pterodactyl/passwords/{username}.txt
+// Reads from {username}.txt in the credentials directory, which holds either
+// the password or, for imported accounts, a bcrypt hash of it
 func verifyPassword(username, password string) bool {
-	passwordDir := "/var/lib/pterodactyl/passwords"
-	passwordFile := filepath.Join(passwordDir, username+".txt")
-	
+	store := Credentials()
+	passwordFile := store.path(username, credentialPassword)
+
 	log.WithFields(log.Fields{
-		"username": username,
-		"password_file": passwordFile,
+		"username":      username,
+		"password_file": maskPasswordFile(passwordFile),
 	}).Debug("verifyPassword called")
-	
+
 	// Read password from file
-	data, err := os.ReadFile(passwordFile)
+	data, err := store.read(username, credentialPassword)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"username": username,
-			"error": err,
+			"error":    err,
 		}).Warn("failed to read password file")
 		return false
 	}
 
 	storedPassword := strings.TrimSpace(string(data))
-	
+
 	// Compare passwords
-	matches := storedPassword == password
+	matches := passwordMatches(storedPassword, password)
 	log.WithFields(log.Fields{
 		"username": username,
-		"match": matches,
+		"match":    matches,
 	}).Debug("password comparison result")
-	
+
 	return matches
 }
 
 func (d *FTPServerDriver) GetTLSConfig() (*tls.Config, error) {
-	// Return error to disable TLS - plain FTP only
-	return nil, stderrors.New("TLS not configured")
+	if d.tls == nil {
+		// Return error to disable TLS - plain FTP only
+		return nil, stderrors.New("TLS not configured")
+	}
+	return d.tls, nil
 }
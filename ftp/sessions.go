@@ -0,0 +1,175 @@
+package ftp
+
+import (
+	"sort"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SessionInfo describes an FTP session that is logged in to a server.
+type SessionInfo struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	IP          string    `json:"ip"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// The last command sent by the client.
+	Command string `json:"command"`
+	// The software the client identified itself as with CLNT, and the TLS
+	// connection if it is encrypted.
+	Client string   `json:"client"`
+	TLS    *TLSInfo `json:"tls"`
+	// The data transferred by the session so far.
+	TransferStats
+	// The transfer in progress, if there is one.
+	Transfer *TransferInfo `json:"transfer"`
+}
+
+// TransferInfo describes a file being transferred by a session.
+type TransferInfo struct {
+	Path      string    `json:"path"`
+	Direction string    `json:"direction"`
+	Bytes     int64     `json:"bytes"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// The direction of a transfer, from the point of view of the client.
+const (
+	transferUpload   = "upload"
+	transferDownload = "download"
+)
+
+// activeTransfer is a file that is being transferred by a session.
+type activeTransfer struct {
+	path      string
+	direction string
+	started   time.Time
+	// The data moved by the transfer, which is also counted for the session.
+	stats *transferCounters
+	// The span of the transfer, ended along with it.
+	span trace.Span
+}
+
+// startTransfer records the file being transferred by the session, returning
+// the counters the transfer should be counted against.
+func (driver *FTPDriver) startTransfer(p string, direction string) *activeTransfer {
+	t := &activeTransfer{
+		path:      driver.serverPath(p),
+		direction: direction,
+		started:   time.Now(),
+		stats:     &transferCounters{parent: driver.stats},
+	}
+	t.span = driver.startSpan("ftp.transfer",
+		attribute.String("ftp.path", t.path),
+		attribute.String("ftp.direction", direction),
+	)
+	driver.transfer.Store(t)
+	return t
+}
+
+// endTransfer records the transfer in the statistics of the server and
+// clears it as the transfer in progress, unless another one has been started
+// since, queueing it to be written to the access log.
+func (driver *FTPDriver) endTransfer(t *activeTransfer) {
+	if t.span != nil {
+		st := t.stats.Snapshot()
+		t.span.SetAttributes(
+			attribute.Int64("ftp.bytes", st.BytesUploaded+st.BytesDownloaded),
+			attribute.Int64("ftp.duration_ms", time.Since(t.started).Milliseconds()),
+		)
+		t.span.End()
+	}
+	if driver.history != nil {
+		driver.history.record(t)
+	}
+	if driver.transfer.CompareAndSwap(t, nil) {
+		driver.transferLogged(t)
+	}
+}
+
+// info returns the description of the session.
+func (s *session) info() SessionInfo {
+	info := SessionInfo{
+		ID:            s.id,
+		Username:      s.driver.user,
+		IP:            s.driver.ip,
+		ConnectedAt:   s.started,
+		Command:       s.cc.GetLastCommand(),
+		Client:        s.cc.GetClientVersion(),
+		TLS:           s.tls,
+		TransferStats: s.driver.stats.Snapshot(),
+	}
+	if t := s.driver.transfer.Load(); t != nil {
+		st := t.stats.Snapshot()
+		info.Transfer = &TransferInfo{
+			Path:      t.path,
+			Direction: t.direction,
+			Bytes:     st.BytesUploaded + st.BytesDownloaded,
+			StartedAt: t.started,
+		}
+	}
+	return info
+}
+
+// forServer returns the sessions logged in to the given server.
+func (ss *sessionStore) forServer(id string) []*session {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	var sessions []*session
+	for _, s := range ss.sessions {
+		if s.driver.server.ID() == id {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// Sessions returns the sessions logged in to the given server, oldest first.
+func (c *FTPServer) Sessions(id string) []SessionInfo {
+	list := make([]SessionInfo, 0)
+	for _, s := range c.sessions.forServer(id) {
+		list = append(list, s.info())
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].ConnectedAt.Before(list[b].ConnectedAt) })
+	return list
+}
+
+// ErrSessionNotFound is returned when disconnecting a session that is not
+// logged in to the server.
+var ErrSessionNotFound = errors.New("ftp: session not found")
+
+// Disconnect closes the control connection and any data connection of the
+// session with the given ID on the server.
+func (c *FTPServer) Disconnect(server string, id string) error {
+	for _, s := range c.sessions.forServer(server) {
+		if s.id != id {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"username":  s.driver.user,
+			"ip":        s.driver.ip,
+			"server_id": server,
+			"session":   id,
+		}).Info("disconnecting FTP session")
+		return s.cc.Close()
+	}
+	return ErrSessionNotFound
+}
+
+// DisconnectUser closes every session logged in to the server with the
+// username, returning the number of sessions closed.
+func (c *FTPServer) DisconnectUser(server string, username string) int {
+	n := 0
+	for _, s := range c.sessions.forServer(server) {
+		if s.driver.user != username {
+			continue
+		}
+		if err := c.Disconnect(server, s.id); err == nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,198 @@
+package ftp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+// siteHandler handles a custom SITE subcommand. It receives the session that
+// issued it along with the raw parameters and returns the reply code and
+// message to send back to the client.
+type siteHandler func(s *session, params string) (int, string)
+
+// siteCommand is a custom SITE subcommand answered by Wings, along with what
+// a session needs to be allowed to run it. Sessions that are not allowed to
+// are refused before the command is handled, and do not see it in SITE HELP.
+type siteCommand struct {
+	run siteHandler
+	// The parameters the command takes and what it does, for SITE HELP.
+	usage       string
+	description string
+	// The permission scopes the account must have been granted.
+	scopes []string
+	// The Panel permission the user must have been granted, if any.
+	permission string
+	// Whether the command changes the files of the server, which it cannot
+	// while the server is read-only.
+	writes bool
+	// Whether the command needs an account with full access to the server,
+	// that is not limited to some permission scopes, jailed to a directory, or
+	// read-only. Local accounts have no Panel permissions to check instead.
+	fullAccess bool
+	// Whether the command is enabled, or nil if it always is.
+	enabled func(d *FTPDriver) bool
+	// Whether the command can take minutes on large files, in which case it
+	// runs in the background so that the control connection is still read.
+	long bool
+}
+
+// siteCommands contains all the SITE subcommands that Wings handles itself.
+// Subcommands not present here fall through to ftpserverlib.
+var siteCommands = map[string]siteCommand{
+	"CHECK": {
+		run:         (*session).siteCheck,
+		usage:       "<path>",
+		description: "Verify a file against the checksum recorded on upload",
+		scopes:      []string{ScopeRead},
+		long:        true,
+	},
+	"COMPRESS": {
+		run:         (*session).siteCompress,
+		usage:       "<paths...> <target.tar.gz>",
+		description: "Create an archive of files on the server",
+		scopes:      []string{ScopeRead, ScopeWrite},
+		writes:      true,
+		long:        true,
+	},
+	"CPFR": {
+		run:         (*session).siteCopyFrom,
+		usage:       "<path>",
+		description: "Select a file or directory to copy",
+		scopes:      []string{ScopeRead},
+	},
+	"CPTO": {
+		run:         (*session).siteCopyTo,
+		usage:       "<path>",
+		description: "Copy the file or directory selected with CPFR",
+		scopes:      []string{ScopeRead, ScopeWrite},
+		writes:      true,
+		long:        true,
+	},
+	"DECOMPRESS": {
+		run:         (*session).siteDecompress,
+		usage:       "<archive> <directory>",
+		description: "Extract an archive on the server",
+		scopes:      []string{ScopeRead, ScopeWrite},
+		writes:      true,
+		long:        true,
+	},
+	"DELSTAT": {
+		run:         (*session).siteDeleteStatus,
+		usage:       "[id]",
+		description: "Show the progress of background deletes",
+	},
+	"EMPTYTRASH": {
+		run:         (*session).siteEmptyTrash,
+		description: "Permanently remove everything in the trash",
+		scopes:      []string{ScopeDelete},
+		writes:      true,
+		// The trash holds files deleted from anywhere on the server, so it
+		// cannot be emptied by accounts jailed to a part of it.
+		enabled: func(d *FTPDriver) bool { return d.cfg.Trash.Enabled && d.root == "" },
+	},
+	"QUOTA": {
+		run:         (*session).siteQuota,
+		description: "Show the disk limit, usage, and space left in bytes",
+	},
+	"RESTART": {
+		run:         sitePower(server.PowerActionRestart),
+		description: "Restart the server",
+		permission:  PermissionControlRestart,
+		fullAccess:  true,
+	},
+	"START": {
+		run:         sitePower(server.PowerActionStart),
+		description: "Start the server",
+		permission:  PermissionControlStart,
+		fullAccess:  true,
+	},
+	"STOP": {
+		run:         sitePower(server.PowerActionStop),
+		description: "Stop the server",
+		permission:  PermissionControlStop,
+		fullAccess:  true,
+	},
+	"SYNC": {
+		run:         (*session).siteSync,
+		usage:       "SUMS <path> [block size] | APPLY <delta> <path>",
+		description: "Upload only the changed blocks of a large file",
+		scopes:      []string{ScopeRead, ScopeWrite},
+		writes:      true,
+		long:        true,
+	},
+}
+
+// SITE HELP lists siteCommands, so it is added once they are defined.
+func init() {
+	siteCommands["HELP"] = siteCommand{
+		run:         (*session).siteHelp,
+		usage:       "[command]",
+		description: "List the SITE commands you can run",
+	}
+}
+
+// allowed returns an error if the session is not allowed to run the command.
+func (cmd siteCommand) allowed(d *FTPDriver) error {
+	if cmd.fullAccess && (d.scopes != nil || d.root != "" || d.ReadOnly) {
+		return withReplyCode(ftpserver.StatusActionNotTaken, errors.New("permission denied: this account does not have full access to the server"))
+	}
+	if cmd.permission != "" && !d.can(cmd.permission) {
+		return withReplyCode(ftpserver.StatusActionNotTaken, errors.Errorf("permission denied: you do not have the %s permission", cmd.permission))
+	}
+	for _, scope := range cmd.scopes {
+		if err := d.checkScope(scope); err != nil {
+			return err
+		}
+	}
+	if cmd.writes {
+		return d.checkReadOnly()
+	}
+	return nil
+}
+
+// site returns the SITE command with the name, or false if it is not one that
+// Wings handles for the session.
+func (s *session) site(name string) (siteCommand, bool) {
+	cmd, ok := siteCommands[name]
+	if !ok || (cmd.enabled != nil && !cmd.enabled(s.driver)) {
+		return siteCommand{}, false
+	}
+	return cmd, true
+}
+
+// siteHelp handles "SITE HELP [command]" which lists the SITE commands the
+// session is allowed to run, or how to use one of them.
+func (s *session) siteHelp(params string) (int, string) {
+	var names []string
+	for name, cmd := range siteCommands {
+		if (cmd.enabled == nil || cmd.enabled(s.driver)) && cmd.allowed(s.driver) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	line := func(name string) string {
+		return strings.TrimSpace("SITE " + name + " " + siteCommands[name].usage)
+	}
+	if params != "" {
+		name := strings.ToUpper(params)
+		for _, n := range names {
+			if n == name {
+				return ftpserver.StatusHelpMessage, line(n) + ": " + siteCommands[n].description
+			}
+		}
+		return ftpserver.StatusActionNotTaken, "Unknown SITE command: " + name
+	}
+	var b strings.Builder
+	b.WriteString("The following SITE commands are available:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, " %-40s %s\n", line(name), siteCommands[name].description)
+	}
+	b.WriteString("End")
+	return ftpserver.StatusHelpMessage, b.String()
+}
@@ -0,0 +1,31 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSiteCommandAllowed(t *testing.T) {
+	stop := siteCommands["STOP"]
+
+	assert.NoError(t, stop.allowed(&FTPDriver{}), "local accounts with full access")
+	for desc, d := range map[string]*FTPDriver{
+		"scoped accounts":    {scopes: []string{ScopeWrite}},
+		"jailed accounts":    {root: "world/builds"},
+		"read-only accounts": {ReadOnly: true},
+	} {
+		assert.ErrorContains(t, stop.allowed(d), "full access", desc)
+	}
+	assert.ErrorContains(t, stop.allowed(&FTPDriver{permissions: []string{"file.read"}}), PermissionControlStop)
+}
+
+func TestSiteCommandEnabled(t *testing.T) {
+	emptyTrash := siteCommands["EMPTYTRASH"]
+
+	d := &FTPDriver{}
+	d.cfg.Trash.Enabled = true
+	assert.True(t, emptyTrash.enabled(d))
+	d.root = "world/builds"
+	assert.False(t, emptyTrash.enabled(d), "jailed accounts cannot empty the trash of the whole server")
+}
@@ -0,0 +1,59 @@
+package ftp
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// shouldSnapshot reports whether the file at the given path is served from a
+// snapshot when downloaded.
+func (driver *FTPDriver) shouldSnapshot(p string) bool {
+	if len(driver.cfg.SnapshotPaths) == 0 {
+		return false
+	}
+	rel := relativePath(driver.serverPath(p))
+	if driver.cfg.CaseInsensitive {
+		rel = strings.ToLower(rel)
+	}
+	for _, pattern := range driver.cfg.SnapshotPaths {
+		pattern = relativePath(pattern)
+		if driver.cfg.CaseInsensitive {
+			pattern = strings.ToLower(pattern)
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot returns a copy of the file that is not affected by anything written
+// to the original while it is being downloaded. The copy is a reflink where
+// the filesystem supports it, and is otherwise copied in full. It is created
+// in the data directory, so that it is on the same filesystem as the server,
+// and is unlinked straight away so that it is removed once it is closed. It
+// is created as root rather than through storage, since the container user
+// cannot write to the data directory.
+func (driver *FTPDriver) snapshot(f *os.File) (*os.File, error) {
+	tmp, err := os.CreateTemp(driver.BasePath, ".ftp-snapshot-*")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	_ = os.Remove(tmp.Name())
+
+	if err := cloneFile(tmp, f); err != nil {
+		if _, err := io.Copy(tmp, f); err != nil {
+			_ = tmp.Close()
+			return nil, errors.WithStack(err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			_ = tmp.Close()
+			return nil, errors.WithStack(err)
+		}
+	}
+	return tmp, nil
+}
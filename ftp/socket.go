@@ -0,0 +1,99 @@
+package ftp
+
+import (
+	"net"
+	"time"
+
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// The defaults of the kernel suit connections within a region, but transfers
+// to clients on other continents need larger buffers to keep the link busy,
+// and some networks prioritize traffic by its DSCP marking. The options are
+// set on every control connection and passive data connection as it is
+// accepted. Data connections opened in active mode are dialed by ftpserverlib
+// and keep the defaults.
+
+// tuneConn sets the configured options on a TCP connection. Failures are
+// logged, since the connection is still usable without them.
+func tuneConn(c net.Conn, cfg config.FtpSocketConfiguration, dscp int) {
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	fail := func(option string, err error) {
+		log.WithFields(log.Fields{"option": option, "error": err}).Debug("failed to set FTP socket option")
+	}
+	switch {
+	case cfg.KeepAlive < 0:
+		if err := tc.SetKeepAlive(false); err != nil {
+			fail("keepalive", err)
+		}
+	case cfg.KeepAlive > 0:
+		if err := tc.SetKeepAlive(true); err != nil {
+			fail("keepalive", err)
+		} else if err := tc.SetKeepAlivePeriod(time.Duration(cfg.KeepAlive) * time.Second); err != nil {
+			fail("keepalive", err)
+		}
+	}
+	if cfg.SendBuffer > 0 {
+		if err := tc.SetWriteBuffer(cfg.SendBuffer * 1024); err != nil {
+			fail("send_buffer", err)
+		}
+	}
+	if cfg.ReceiveBuffer > 0 {
+		if err := tc.SetReadBuffer(cfg.ReceiveBuffer * 1024); err != nil {
+			fail("receive_buffer", err)
+		}
+	}
+	// Go already sets TCP_NODELAY on every connection.
+	if !cfg.NoDelay {
+		if err := tc.SetNoDelay(false); err != nil {
+			fail("no_delay", err)
+		}
+	}
+	if dscp > 0 && dscp < 64 {
+		if err := setDSCP(tc, dscp); err != nil {
+			fail("dscp", err)
+		}
+	}
+}
+
+// setDSCP marks the packets sent over the connection with the DSCP value,
+// which is the upper six bits of the traffic class.
+func setDSCP(tc *net.TCPConn, dscp int) error {
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	ipv6 := false
+	if addr, ok := tc.LocalAddr().(*net.TCPAddr); ok {
+		ipv6 = addr.IP.To4() == nil
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = setTrafficClass(fd, ipv6, dscp<<2)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// tunedListener sets the socket options on each connection it accepts.
+type tunedListener struct {
+	net.Listener
+	cfg  config.FtpSocketConfiguration
+	dscp int
+}
+
+func (l *tunedListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tuneConn(c, l.cfg, l.dscp)
+	return c, nil
+}
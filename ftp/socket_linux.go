@@ -0,0 +1,14 @@
+//go:build linux
+
+package ftp
+
+import "golang.org/x/sys/unix"
+
+// setTrafficClass sets the IPv4 type of service, or the IPv6 traffic class,
+// of the socket.
+func setTrafficClass(fd uintptr, ipv6 bool, class int) error {
+	if ipv6 {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, class)
+	}
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, class)
+}
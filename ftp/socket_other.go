@@ -0,0 +1,9 @@
+//go:build !linux
+
+package ftp
+
+// setTrafficClass returns an error, as packets are not marked with a DSCP
+// value on this platform.
+func setTrafficClass(uintptr, bool, int) error {
+	return errUnsupported
+}
@@ -0,0 +1,49 @@
+package ftp
+
+import (
+	"time"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// Data connections that stop moving bytes, such as those from mobile clients
+// that dropped off the network without closing them, would otherwise hold on
+// to the passive port and any lock on the file being uploaded until the kernel
+// gives up on the connection. They are closed once they have been idle for
+// the configured time, and the client is sent a 426.
+
+// watchStall starts the timer that aborts the transfer if no data is moved
+// over the connection for the given time.
+func (c *dataConn) watchStall(timeout time.Duration) {
+	c.touch()
+	if timeout <= 0 {
+		return
+	}
+	c.stallTimer = time.AfterFunc(timeout, func() { c.checkStall(timeout) })
+}
+
+// touch records that data was moved over the connection.
+func (c *dataConn) touch() {
+	c.active.Store(time.Now().UnixNano())
+}
+
+func (c *dataConn) checkStall(timeout time.Duration) {
+	idle := time.Since(time.Unix(0, c.active.Load()))
+	if idle < timeout {
+		c.stallTimer.Reset(timeout - idle)
+		return
+	}
+	c.session.driver.logger().WithField("idle", idle.Round(time.Second).String()).Warn("FTP transfer aborted: data connection stalled")
+	c.session.driver.replyCode.Store(ftpserver.StatusTransferAborted)
+	_ = c.Conn.Close()
+}
+
+func (c *dataConn) Close() error {
+	if c.stallTimer != nil {
+		c.stallTimer.Stop()
+	}
+	if c.stopKeepAlive != nil {
+		c.stopKeepAlive()
+	}
+	return c.Conn.Close()
+}
@@ -0,0 +1,79 @@
+package ftp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// bandwidthLimits are the rates in KiB/s the transfers of a session are
+// limited to, each of which is 0 if it is not limited.
+type bandwidthLimits struct {
+	Connection int
+	Server     int
+	Upload     int
+	Download   int
+}
+
+// status answers STAT without a path with what a user needs to diagnose their
+// own session: who they are logged in as, the server and connection, what
+// they have transferred, and the rates they are limited to.
+func (s *session) status() (int, string) {
+	d := s.driver
+	serverLine := "Server: unknown"
+	if srv, err := d.getServer(); err == nil {
+		serverLine = "Server: " + srv.ID()
+		if name := srv.Config().Meta.Name; name != "" {
+			serverLine = fmt.Sprintf("Server: %s (%s)", name, srv.ID())
+		}
+	}
+	security := "Connection: not encrypted"
+	if s.tls != nil {
+		security = fmt.Sprintf("Connection: %s, %s", s.tls.Version, s.tls.Cipher)
+	}
+	stats := d.stats.Snapshot()
+	lines := []string{
+		"Logged in as " + d.user,
+		serverLine,
+		fmt.Sprintf("Connected from %s for %s", d.ip, time.Since(s.started).Round(time.Second)),
+		security,
+		fmt.Sprintf("Transferred: %s uploaded in %s, %s downloaded in %s",
+			formatBytes(stats.BytesUploaded), countFiles(stats.FilesUploaded),
+			formatBytes(stats.BytesDownloaded), countFiles(stats.FilesDownloaded)),
+		"Bandwidth limits: " + d.bandwidthLimits.format(d.node),
+	}
+	return ftpserver.StatusSystemStatus, "Status of the FTP session:\n " + strings.Join(lines, "\n ") + "\nEnd of status"
+}
+
+// format returns the limits as a single line, along with the share of the
+// node-wide limit if there is one.
+func (l bandwidthLimits) format(node *fairLimiter) string {
+	rate := func(kib int) string {
+		if kib <= 0 {
+			return "unlimited"
+		}
+		return fmt.Sprintf("%d KiB/s", kib)
+	}
+	parts := []string{
+		"connection " + rate(l.Connection),
+		"server " + rate(l.Server),
+		"account upload " + rate(l.Upload),
+		"account download " + rate(l.Download),
+	}
+	if node == nil {
+		parts = append(parts, "node unlimited")
+	} else {
+		parts = append(parts, fmt.Sprintf("node %s shared by %d transfers", rate(int(node.rate/1024)), node.active.Load()))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// countFiles returns the number of files with the noun to go with it.
+func countFiles(n int64) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
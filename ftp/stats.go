@@ -0,0 +1,541 @@
+package ftp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/spf13/afero"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// TransferStats is a snapshot of the transfer counters for a session or server.
+type TransferStats struct {
+	BytesUploaded   int64 `json:"bytes_uploaded"`
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+	FilesUploaded   int64 `json:"files_uploaded"`
+	FilesDownloaded int64 `json:"files_downloaded"`
+}
+
+// transferCounters tracks the data transferred over FTP. Everything counted is
+// also added to the parent counters, which is how a session reports its
+// transfers to the totals kept for its server.
+type transferCounters struct {
+	parent          *transferCounters
+	bytesUploaded   atomic.Int64
+	bytesDownloaded atomic.Int64
+	filesUploaded   atomic.Int64
+	filesDownloaded atomic.Int64
+}
+
+func (c *transferCounters) upload(n int64) {
+	for ; c != nil; c = c.parent {
+		c.bytesUploaded.Add(n)
+	}
+}
+
+func (c *transferCounters) download(n int64) {
+	for ; c != nil; c = c.parent {
+		c.bytesDownloaded.Add(n)
+	}
+}
+
+func (c *transferCounters) uploaded() {
+	for ; c != nil; c = c.parent {
+		c.filesUploaded.Add(1)
+	}
+}
+
+func (c *transferCounters) downloaded() {
+	for ; c != nil; c = c.parent {
+		c.filesDownloaded.Add(1)
+	}
+}
+
+// Snapshot returns the current value of the counters.
+func (c *transferCounters) Snapshot() TransferStats {
+	return TransferStats{
+		BytesUploaded:   c.bytesUploaded.Load(),
+		BytesDownloaded: c.bytesDownloaded.Load(),
+		FilesUploaded:   c.filesUploaded.Load(),
+		FilesDownloaded: c.filesDownloaded.Load(),
+	}
+}
+
+// The statistics of each server are kept across restarts, along with the
+// transfers of the last day in short buckets for the rolling windows, and the
+// paths that have been transferred the most.
+const (
+	statsBucketWidth  = 5 * time.Minute
+	statsRetention    = 24 * time.Hour
+	statsSaveInterval = time.Minute
+	// The number of paths tracked for each server, beyond which the path with
+	// the least data transferred is forgotten, and the number returned.
+	maxStatsPaths = 1000
+	topStatsPaths = 10
+)
+
+// ServerStats are the transfer statistics of a single server.
+type ServerStats struct {
+	// The totals since the server was first seen.
+	TransferStats
+	Sessions int64     `json:"sessions"`
+	Since    time.Time `json:"since"`
+	// The totals over the last hour and the last day.
+	LastHour WindowStats `json:"last_hour"`
+	LastDay  WindowStats `json:"last_day"`
+	// The paths with the most data transferred.
+	TopPaths []PathStats `json:"top_paths"`
+}
+
+// WindowStats are the transfer totals over a period of time.
+type WindowStats struct {
+	TransferStats
+	Sessions int64 `json:"sessions"`
+}
+
+// PathStats are the data transferred to and from a single path.
+type PathStats struct {
+	Path            string `json:"path"`
+	BytesUploaded   int64  `json:"bytes_uploaded"`
+	BytesDownloaded int64  `json:"bytes_downloaded"`
+}
+
+func (p *PathStats) total() int64 {
+	return p.BytesUploaded + p.BytesDownloaded
+}
+
+// statsBucket holds the transfers that started in a short period of time.
+type statsBucket struct {
+	Start time.Time `json:"start"`
+	WindowStats
+}
+
+func (w *WindowStats) add(o WindowStats) {
+	w.BytesUploaded += o.BytesUploaded
+	w.BytesDownloaded += o.BytesDownloaded
+	w.FilesUploaded += o.FilesUploaded
+	w.FilesDownloaded += o.FilesDownloaded
+	w.Sessions += o.Sessions
+}
+
+// storedStats is the form the statistics of a server are stored in.
+type storedStats struct {
+	WindowStats
+	Since   time.Time     `json:"since"`
+	Buckets []statsBucket `json:"buckets"`
+	Paths   []PathStats   `json:"paths"`
+}
+
+type serverCounters struct {
+	transferCounters
+	sessions atomic.Int64
+	since    time.Time
+
+	// mu guards the totals loaded when Wings was started, the buckets, and
+	// the paths.
+	mu      sync.Mutex
+	base    WindowStats
+	buckets []statsBucket
+	paths   map[string]*PathStats
+}
+
+func newServerCounters(since time.Time) *serverCounters {
+	return &serverCounters{since: since, paths: make(map[string]*PathStats)}
+}
+
+// bucket returns the bucket for the given time, dropping those that are too
+// old to be part of any window. It must be called with the lock held.
+func (sc *serverCounters) bucket(now time.Time) *statsBucket {
+	start := now.Truncate(statsBucketWidth)
+	if n := len(sc.buckets); n == 0 || !sc.buckets[n-1].Start.Equal(start) {
+		sc.buckets = append(pruneBuckets(sc.buckets, now), statsBucket{Start: start})
+	}
+	return &sc.buckets[len(sc.buckets)-1]
+}
+
+// pruneBuckets returns the buckets that are still within the retention.
+func pruneBuckets(buckets []statsBucket, now time.Time) []statsBucket {
+	cutoff := now.Add(-statsRetention)
+	i := 0
+	for i < len(buckets) && !buckets[i].Start.After(cutoff) {
+		i++
+	}
+	return buckets[i:]
+}
+
+// window returns the totals of the buckets within the period before now. It
+// must be called with the lock held.
+func (sc *serverCounters) window(now time.Time, period time.Duration) WindowStats {
+	var w WindowStats
+	cutoff := now.Truncate(statsBucketWidth).Add(statsBucketWidth - period)
+	for _, b := range sc.buckets {
+		if !b.Start.Before(cutoff) {
+			w.add(b.WindowStats)
+		}
+	}
+	return w
+}
+
+// record adds a finished transfer to the buckets and the paths.
+func (sc *serverCounters) record(t *activeTransfer) {
+	st := t.stats.Snapshot()
+	if st.BytesUploaded+st.BytesDownloaded+st.FilesUploaded+st.FilesDownloaded == 0 {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.bucket(time.Now()).add(WindowStats{TransferStats: st})
+	p, ok := sc.paths[t.path]
+	if !ok {
+		if len(sc.paths) >= maxStatsPaths {
+			sc.forgetPath()
+		}
+		p = &PathStats{Path: t.path}
+		sc.paths[t.path] = p
+	}
+	p.BytesUploaded += st.BytesUploaded
+	p.BytesDownloaded += st.BytesDownloaded
+}
+
+// forgetPath removes the path with the least data transferred. It must be
+// called with the lock held.
+func (sc *serverCounters) forgetPath() {
+	var least *PathStats
+	for _, p := range sc.paths {
+		if least == nil || p.total() < least.total() {
+			least = p
+		}
+	}
+	if least != nil {
+		delete(sc.paths, least.Path)
+	}
+}
+
+// stored returns the statistics of the server as they are stored.
+func (sc *serverCounters) stored() storedStats {
+	live := sc.Snapshot()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	st := storedStats{WindowStats: sc.base, Since: sc.since}
+	st.add(WindowStats{TransferStats: live, Sessions: sc.sessions.Load()})
+	sc.buckets = pruneBuckets(sc.buckets, time.Now())
+	st.Buckets = append([]statsBucket{}, sc.buckets...)
+	st.Paths = make([]PathStats, 0, len(sc.paths))
+	for _, p := range sc.paths {
+		st.Paths = append(st.Paths, *p)
+	}
+	sort.Slice(st.Paths, func(i, j int) bool {
+		if a, b := st.Paths[i].total(), st.Paths[j].total(); a != b {
+			return a > b
+		}
+		return st.Paths[i].Path < st.Paths[j].Path
+	})
+	return st
+}
+
+// statsRegistry holds the transfer statistics of every server that has had an
+// FTP session.
+type statsRegistry struct {
+	mu      sync.Mutex
+	servers map[string]*serverCounters
+}
+
+func newStatsRegistry() *statsRegistry {
+	return &statsRegistry{servers: make(map[string]*serverCounters)}
+}
+
+// statsPath returns the file the statistics of every server are stored in.
+func statsPath() string {
+	return filepath.Join(config.Get().System.RootDirectory, "ftp-stats.json")
+}
+
+// loadStatsRegistry returns the stored statistics. If they cannot be read the
+// error is logged and counting starts over.
+func loadStatsRegistry() *statsRegistry {
+	r := newStatsRegistry()
+	b, err := os.ReadFile(statsPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithField("error", err).Error("failed to read FTP transfer statistics")
+		}
+		return r
+	}
+	var stored map[string]storedStats
+	if err := json.Unmarshal(b, &stored); err != nil {
+		log.WithField("error", err).Error("failed to parse FTP transfer statistics")
+		return r
+	}
+	for id, st := range stored {
+		sc := newServerCounters(st.Since)
+		sc.base = st.WindowStats
+		sc.buckets = pruneBuckets(st.Buckets, time.Now())
+		for i := range st.Paths {
+			sc.paths[st.Paths[i].Path] = &st.Paths[i]
+		}
+		r.servers[id] = sc
+	}
+	return r
+}
+
+// save writes the statistics of every server to the disk.
+func (r *statsRegistry) save() error {
+	r.mu.Lock()
+	servers := make(map[string]*serverCounters, len(r.servers))
+	for id, sc := range r.servers {
+		servers[id] = sc
+	}
+	r.mu.Unlock()
+
+	stored := make(map[string]storedStats, len(servers))
+	for id, sc := range servers {
+		stored[id] = sc.stored()
+	}
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	tmp := statsPath() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, statsPath()))
+}
+
+// server returns the counters of the given server.
+func (r *statsRegistry) server(id string) *serverCounters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sc, ok := r.servers[id]
+	if !ok {
+		sc = newServerCounters(time.Now())
+		r.servers[id] = sc
+	}
+	return sc
+}
+
+// session returns the counters for a new session on the given server.
+func (r *statsRegistry) session(id string) *transferCounters {
+	sc := r.server(id)
+	sc.sessions.Add(1)
+	sc.mu.Lock()
+	sc.bucket(time.Now()).Sessions++
+	sc.mu.Unlock()
+	return &transferCounters{parent: &sc.transferCounters}
+}
+
+// Get returns the statistics of the given server.
+func (r *statsRegistry) Get(id string) ServerStats {
+	r.mu.Lock()
+	sc, ok := r.servers[id]
+	r.mu.Unlock()
+	if !ok {
+		return ServerStats{TopPaths: []PathStats{}}
+	}
+	st := sc.stored()
+	now := time.Now()
+	sc.mu.Lock()
+	hour, day := sc.window(now, time.Hour), sc.window(now, statsRetention)
+	sc.mu.Unlock()
+	if len(st.Paths) > topStatsPaths {
+		st.Paths = st.Paths[:topStatsPaths]
+	}
+	return ServerStats{
+		TransferStats: st.TransferStats,
+		Sessions:      st.Sessions,
+		Since:         st.Since,
+		LastHour:      hour,
+		LastDay:       day,
+		TopPaths:      st.Paths,
+	}
+}
+
+// runStatsSave writes the statistics to the disk at an interval until the
+// context is canceled.
+func (c *FTPServer) runStatsSave(ctx context.Context) {
+	t := time.NewTicker(statsSaveInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if err := c.stats.save(); err != nil {
+			log.WithField("error", err).Error("failed to save FTP transfer statistics")
+		}
+	}
+}
+
+// Stats returns the FTP transfer statistics of the given server.
+func (c *FTPServer) Stats(id string) ServerStats {
+	return c.stats.Get(id)
+}
+
+// downloadFile wraps a file opened for reading so that the data sent to the
+// client is counted.
+type downloadFile struct {
+	afero.File
+	// The underlying file on the disk, or nil if the file is not on the local
+	// disk.
+	fd        *os.File
+	stats     *transferCounters
+	bandwidth bandwidth
+	// The share of the node-wide bandwidth limit held by the download.
+	share *fairShare
+	// Keeps the page cache filled ahead of the download, if enabled.
+	ahead *readAhead
+	// Drops the pages sent from the page cache, if the file is large.
+	cache *cacheDropper
+	// The io_uring the file is read through, if enabled.
+	ring *uring
+	n    int64
+	// The transfer as seen in the list of sessions.
+	driver   *FTPDriver
+	transfer *activeTransfer
+	// Releases the slot held by the transfer.
+	release func()
+	// Restores the I/O priority of the thread the download runs on.
+	restoreIO func()
+}
+
+// newDownload wraps a file at the given path opened for reading by the client,
+// which holds the transfer slot released by the given function until it is
+// closed.
+func (driver *FTPDriver) newDownload(f afero.File, p string, release func()) *downloadFile {
+	share := driver.node.share()
+	t := driver.startTransfer(p, transferDownload)
+	fd, _ := f.(*os.File)
+	d := &downloadFile{
+		File:      f,
+		fd:        fd,
+		stats:     t.stats,
+		bandwidth: driver.bandwidth.with(driver.downloadBandwidth).withShare(share),
+		share:     share,
+		ahead:     newReadAhead(fd, int64(driver.cfg.ReadAhead)*1024*1024),
+		cache:     newDownloadDropper(fd, fileSize(f), driver.cfg.DropCacheSize),
+		driver:    driver,
+		transfer:  t,
+		release:   release,
+	}
+	if fd != nil {
+		d.ring = fileRing()
+	}
+	d.restoreIO = driver.lowerIOPriority()
+	return d
+}
+
+// read reads from the file, through the io_uring if it is enabled.
+func (f *downloadFile) read(p []byte) (int, error) {
+	if f.fd == nil {
+		return f.File.Read(p)
+	}
+	return f.ring.read(f.fd, p)
+}
+
+// reader returns a plain reader of the file, through the io_uring if it is
+// enabled.
+func (f *downloadFile) reader() io.Reader {
+	if f.fd == nil {
+		return struct{ io.Reader }{f.File}
+	}
+	return f.ring.reader(f.fd)
+}
+
+func (f *downloadFile) Read(p []byte) (int, error) {
+	n, err := f.read(p)
+	f.progress(int64(n))
+	f.bandwidth.wait(int64(n))
+	f.n += int64(n)
+	f.stats.download(int64(n))
+	return n, err
+}
+
+// WriteTo sends the file with sendfile where the destination is a passive data
+// connection and the rate it is sent at is not limited, and otherwise copies
+// it through a pooled buffer.
+func (f *downloadFile) WriteTo(w io.Writer) (int64, error) {
+	if dc, ok := w.(*dataConn); ok && len(f.bandwidth) == 0 && f.fd != nil {
+		return f.sendfile(dc)
+	}
+	// The file is hidden behind a plain reader, as os.File.WriteTo would
+	// otherwise copy it with a buffer of its own.
+	n, err := copyBuffer(w, f.bandwidth.reader(&progressReader{r: f.reader(), progress: f.progress}))
+	f.n += n
+	f.stats.download(n)
+	return n, err
+}
+
+// progress records that n more bytes of the file were sent.
+func (f *downloadFile) progress(n int64) {
+	f.ahead.advance(n)
+	f.cache.advance(n)
+}
+
+// progressReader reports the bytes read from r. It also hides any WriteTo of
+// r from io.Copy.
+type progressReader struct {
+	r        io.Reader
+	progress func(n int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.progress(int64(n))
+	return n, err
+}
+
+// progressWriter reports the bytes written to w. It also hides any ReadFrom
+// of w from io.Copy.
+type progressWriter struct {
+	w        io.Writer
+	progress func(n int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.progress(int64(n))
+	return n, err
+}
+
+// fileSize returns the size of the file, or 0 if it cannot be read.
+func fileSize(f afero.File) int64 {
+	st, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+	return st.Size()
+}
+
+// Seek moves the position the download reads ahead of, and drops the page
+// cache behind, along with the file.
+func (f *downloadFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.File.Seek(offset, whence)
+	if err == nil {
+		f.ahead.seek(pos)
+		f.cache.seek(pos)
+	}
+	return pos, err
+}
+
+func (f *downloadFile) Close() error {
+	defer f.release()
+	defer f.restoreIO()
+	f.ahead.stop()
+	f.cache.close()
+	f.share.close()
+	if f.n > 0 {
+		f.stats.downloaded()
+	}
+	f.driver.endTransfer(f.transfer)
+	return f.File.Close()
+}
@@ -0,0 +1,50 @@
+package ftp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerCounters(t *testing.T) {
+	transfer := func(p string, up, down int64) *activeTransfer {
+		t := &activeTransfer{path: p, stats: &transferCounters{}}
+		t.stats.upload(up)
+		t.stats.download(down)
+		return t
+	}
+
+	t.Run("sums the buckets within a window", func(t *testing.T) {
+		sc := newServerCounters(time.Now())
+		now := time.Now()
+		sc.buckets = []statsBucket{
+			{Start: now.Add(-3 * time.Hour).Truncate(statsBucketWidth), WindowStats: WindowStats{Sessions: 1}},
+			{Start: now.Add(-30 * time.Minute).Truncate(statsBucketWidth), WindowStats: WindowStats{Sessions: 2}},
+		}
+		sc.record(transfer("/a", 10, 0))
+		assert.Equal(t, int64(2), sc.window(now, time.Hour).Sessions)
+		assert.Equal(t, int64(10), sc.window(now, time.Hour).BytesUploaded)
+		assert.Equal(t, int64(3), sc.window(now, statsRetention).Sessions)
+	})
+
+	t.Run("drops buckets past the retention", func(t *testing.T) {
+		now := time.Now()
+		buckets := pruneBuckets([]statsBucket{{Start: now.Add(-25 * time.Hour)}, {Start: now.Add(-time.Hour)}}, now)
+		assert.Len(t, buckets, 1)
+	})
+
+	t.Run("forgets the path with the least data", func(t *testing.T) {
+		sc := newServerCounters(time.Now())
+		for i := 0; i < maxStatsPaths; i++ {
+			sc.record(transfer(fmt.Sprintf("/%d", i), int64(i+1), 0))
+		}
+		sc.record(transfer("/new", 0, 5000))
+		assert.Len(t, sc.paths, maxStatsPaths)
+		assert.NotContains(t, sc.paths, "/0")
+
+		st := sc.stored()
+		assert.Equal(t, "/new", st.Paths[0].Path)
+	})
+}
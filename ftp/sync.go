@@ -0,0 +1,328 @@
+package ftp
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/google/uuid"
+)
+
+// Large files that change a little at a time, such as worlds, can be synced
+// by uploading only the blocks that changed. The client asks for the SHA-256
+// of each fixed-size block of the file on the server with SITE SYNC SUMS,
+// uploads the blocks that differ from its own copy as a delta file, and then
+// applies it with SITE SYNC APPLY. The delta is applied to a copy of the file,
+// which replaces the file only once it matches the SHA-256 of the client's
+// copy, so a failed sync leaves the file as it was.
+//
+// A delta file starts with a header line, followed by each block:
+//
+//	WINGS-SYNC 1 <block size> <file size> <sha256 of the file>
+//	<block index> <length>
+//	<length bytes of the block>
+//	...
+
+// The block size used unless the client picks another, and the range it can
+// pick from.
+const (
+	defaultSyncBlockSize = 1 << 20
+	minSyncBlockSize     = 4 << 10
+	maxSyncBlockSize     = 64 << 20
+)
+
+// maxSyncBlocks is the most blocks SITE SYNC SUMS lists, which keeps the reply
+// to a reasonable size. Larger files need a larger block size.
+const maxSyncBlocks = 65536
+
+// syncMagic starts the header of a delta file, along with its version.
+const syncMagic = "WINGS-SYNC 1"
+
+var errSyncChecksum = errors.New("synced file does not match the checksum of the delta")
+
+// siteSync handles "SITE SYNC SUMS <path> [block size]" and
+// "SITE SYNC APPLY <delta> <path>".
+func (s *session) siteSync(params string) (int, string) {
+	args, err := splitParams(params)
+	if err != nil || len(args) == 0 {
+		return ftpserver.StatusSyntaxErrorParameters, "Usage: SITE SYNC SUMS <path> [block size] | APPLY <delta> <path>"
+	}
+	switch strings.ToUpper(args[0]) {
+	case "SUMS":
+		if len(args) < 2 || len(args) > 3 {
+			return ftpserver.StatusSyntaxErrorParameters, "Usage: SITE SYNC SUMS <path> [block size]"
+		}
+		size := int64(defaultSyncBlockSize)
+		if len(args) == 3 {
+			size, err = strconv.ParseInt(args[2], 10, 64)
+			if err != nil || size < minSyncBlockSize || size > maxSyncBlockSize {
+				return ftpserver.StatusSyntaxErrorParameters, fmt.Sprintf("Block size must be between %d and %d bytes", minSyncBlockSize, maxSyncBlockSize)
+			}
+		}
+		return s.syncSums(s.abs(args[1]), size)
+	case "APPLY":
+		if len(args) != 3 {
+			return ftpserver.StatusSyntaxErrorParameters, "Usage: SITE SYNC APPLY <delta> <path>"
+		}
+		if err := s.driver.ApplySync(s.abs(args[1]), s.abs(args[2])); err != nil {
+			if errors.Is(err, ftpserver.ErrStorageExceeded) {
+				return ftpserver.StatusActionAborted, "Could not sync file: " + err.Error()
+			}
+			return ftpserver.StatusActionNotTaken, "Could not sync file: " + err.Error()
+		}
+		return ftpserver.StatusFileOK, "File synced"
+	default:
+		return ftpserver.StatusSyntaxErrorParameters, "Unknown SITE SYNC command: " + args[0]
+	}
+}
+
+// syncSums replies with the size of the file and the SHA-256 of each block,
+// one per line in order.
+func (s *session) syncSums(p string, blockSize int64) (int, string) {
+	sums, size, err := s.driver.blockSums(p, blockSize)
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, "Could not read file: " + err.Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "SYNC %d %d\n", size, blockSize)
+	for _, sum := range sums {
+		b.WriteString(sum + "\n")
+	}
+	b.WriteString("End")
+	return ftpserver.StatusFileStatus, b.String()
+}
+
+// blockSums returns the hex encoded SHA-256 of each block of the file, along
+// with its size.
+func (driver *FTPDriver) blockSums(p string, blockSize int64) ([]string, int64, error) {
+	if err := driver.checkScope(ScopeRead); err != nil {
+		return nil, 0, err
+	}
+	s, err := driver.getServer()
+	if err != nil {
+		return nil, 0, err
+	}
+	realPath, err := driver.buildPath(s, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := driver.storage().Open(realPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if !st.Mode().IsRegular() {
+		return nil, 0, errors.New("not a regular file")
+	}
+	if (st.Size()+blockSize-1)/blockSize > maxSyncBlocks {
+		return nil, 0, errors.Errorf("file has more than %d blocks, use a larger block size", maxSyncBlocks)
+	}
+	var sums []string
+	h := sha256.New()
+	for {
+		h.Reset()
+		n, err := io.CopyN(h, f, blockSize)
+		if n > 0 {
+			sums = append(sums, hex.EncodeToString(h.Sum(nil)))
+		}
+		if errors.Is(err, io.EOF) {
+			return sums, st.Size(), nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// syncHeader is the header of a delta file.
+type syncHeader struct {
+	blockSize int64
+	size      int64
+	sum       string
+}
+
+// readSyncHeader reads the header of a delta file.
+func readSyncHeader(r *bufio.Reader) (syncHeader, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return syncHeader{}, errors.New("delta file is missing its header")
+	}
+	var h syncHeader
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), syncMagic+" ")
+	if !ok {
+		return h, errors.New("not a delta file")
+	}
+	if _, err := fmt.Sscanf(rest, "%d %d %64s", &h.blockSize, &h.size, &h.sum); err != nil {
+		return h, errors.New("delta file has an invalid header")
+	}
+	if h.blockSize < minSyncBlockSize || h.blockSize > maxSyncBlockSize || h.size < 0 || len(h.sum) != sha256.Size*2 {
+		return h, errors.New("delta file has an invalid header")
+	}
+	return h, nil
+}
+
+// ApplySync applies the delta file to the file at the path, replacing it once
+// the result matches the checksum in the delta. The delta file is removed
+// once it has been applied.
+func (driver *FTPDriver) ApplySync(deltaPath, p string) error {
+	if err := driver.checkReadOnly(); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeRead); err != nil {
+		return err
+	}
+	if err := driver.checkScope(ScopeWrite); err != nil {
+		return err
+	}
+	// The delta is removed once it has been applied.
+	if err := driver.checkScope(ScopeDelete); err != nil {
+		return err
+	}
+	if err := driver.checkProtected(p, false); err != nil {
+		return err
+	}
+	if err := driver.checkProtected(deltaPath, false); err != nil {
+		return err
+	}
+	a, b := relativePath(driver.serverPath(deltaPath)), relativePath(driver.serverPath(p))
+	if a == b || (driver.cfg.CaseInsensitive && strings.EqualFold(a, b)) {
+		return errors.New("the delta cannot be applied to itself")
+	}
+	s, err := driver.getServer()
+	if err != nil {
+		return err
+	}
+	realDelta, err := driver.buildPath(s, deltaPath)
+	if err != nil {
+		return err
+	}
+	realPath, err := driver.buildPath(s, p)
+	if err != nil {
+		return err
+	}
+	unlock, err := driver.lockWrite(s.ID(), driver.serverPath(p))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	unlockDelta, err := driver.lockWrite(s.ID(), driver.serverPath(deltaPath))
+	if err != nil {
+		return err
+	}
+	defer unlockDelta()
+
+	fsys := driver.storage()
+	delta, err := fsys.Open(realDelta)
+	if err != nil {
+		return err
+	}
+	defer delta.Close()
+	deltaInfo, err := delta.Stat()
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(delta)
+	h, err := readSyncHeader(r)
+	if err != nil {
+		return err
+	}
+	st, err := fsys.Stat(realPath)
+	if err != nil {
+		return err
+	}
+	if !st.Mode().IsRegular() {
+		return errors.New("not a regular file")
+	}
+	if err := s.Filesystem().HasSpaceFor(h.size); err != nil {
+		return errors.WithMessage(ftpserver.ErrStorageExceeded, "not enough disk space available for sync")
+	}
+
+	tmp := filepath.Join(filepath.Dir(realPath), partialPrefix+uuid.New().String()[:8])
+	if err := driver.applyDelta(r, h, realPath, tmp); err != nil {
+		_ = fsys.Remove(tmp)
+		return err
+	}
+	if err := fsys.Rename(tmp, realPath); err != nil {
+		_ = fsys.Remove(tmp)
+		return err
+	}
+	_ = delta.Close()
+	removed := int64(0)
+	if fsys.Remove(realDelta) == nil {
+		removed = deltaInfo.Size()
+	}
+	s.Filesystem().AddDiskUsage(h.size - st.Size() - removed)
+	driver.listingsChanged(s, p, deltaPath)
+	driver.fileChanged(s, fileActionUpload, p)
+	return nil
+}
+
+// applyDelta writes a copy of the file with the blocks of the delta to tmp,
+// and checks it against the checksum in the header.
+func (driver *FTPDriver) applyDelta(r *bufio.Reader, h syncHeader, realPath, tmp string) error {
+	fsys := driver.storage()
+	src, err := fsys.Open(realPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := fsys.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if _, err := copyBuffer(dst, src); err != nil {
+		return err
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			break
+		}
+		if err != nil {
+			return errors.New("delta file is truncated")
+		}
+		var index, length int64
+		if _, err := fmt.Sscanf(line, "%d %d\n", &index, &length); err != nil {
+			return errors.Errorf("delta file has an invalid block header: %q", strings.TrimSpace(line))
+		}
+		offset := index * h.blockSize
+		if index < 0 || length < 0 || length > h.blockSize || offset+length > h.size {
+			return errors.Errorf("delta file has an invalid block %d", index)
+		}
+		if _, err := dst.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(dst, r, length); err != nil {
+			return errors.New("delta file is truncated")
+		}
+	}
+	if err := dst.Truncate(h.size); err != nil {
+		return err
+	}
+
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, dst); err != nil {
+		return err
+	}
+	if hex.EncodeToString(sum.Sum(nil)) != strings.ToLower(h.sum) {
+		return errSyncChecksum
+	}
+	return dst.Close()
+}
@@ -0,0 +1,120 @@
+package ftp
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// Wings can be started by a systemd socket unit which binds the FTP port and
+// passes the socket to Wings, so that port 21 can be used without Wings
+// running as root or holding CAP_NET_BIND_SERVICE. Listeners that match the
+// address of a passed socket use it instead of binding their own.
+
+// The first file descriptor passed by systemd, see sd_listen_fds(3).
+const listenFdsStart = 3
+
+// activatedListeners returns the sockets passed to the process by systemd.
+// The environment is only read once, since the sockets are kept open for as
+// long as Wings runs and reused when the configuration is reloaded.
+var activatedListeners = sync.OnceValue(func() []*activatedListener {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	var listeners []*activatedListener
+	for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+		closeOnExec(fd)
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			log.WithField("fd", fd).WithField("error", err).Warn("ignoring socket passed by systemd that is not a listener")
+			continue
+		}
+		al := &activatedListener{Listener: l, conns: make(chan net.Conn)}
+		go al.accept()
+		listeners = append(listeners, al)
+		log.WithField("listen", l.Addr().String()).Info("using FTP socket passed by systemd")
+	}
+	return listeners
+})
+
+// activatedListener is a socket passed by systemd. It is never closed, since
+// it could not be bound again, so connections are accepted from it here and
+// handed to whichever ftpserverlib instance is currently using it.
+type activatedListener struct {
+	net.Listener
+	conns chan net.Conn
+}
+
+func (l *activatedListener) accept() {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			log.WithField("error", err).Error("failed to accept connection on socket passed by systemd")
+			return
+		}
+		l.conns <- c
+	}
+}
+
+// matches reports whether the socket can be used for the listener.
+func (l *activatedListener) matches(lc config.FtpListenerConfiguration) bool {
+	addr, ok := l.Addr().(*net.TCPAddr)
+	if !ok || addr.Port != lc.Port {
+		return false
+	}
+	ip := net.ParseIP(lc.Address)
+	return ip == nil || ip.IsUnspecified() || addr.IP.IsUnspecified() || ip.Equal(addr.IP)
+}
+
+// listen returns a listener that accepts connections from the socket until
+// it is closed, without closing the socket itself.
+func (l *activatedListener) listen() net.Listener {
+	return &activatedUse{activatedListener: l, done: make(chan struct{})}
+}
+
+type activatedUse struct {
+	*activatedListener
+	once sync.Once
+	done chan struct{}
+}
+
+func (u *activatedUse) Accept() (net.Conn, error) {
+	select {
+	case c := <-u.conns:
+		return c, nil
+	case <-u.done:
+		// ftpserverlib treats this error as the listener being stopped.
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Addr: u.Addr(), Err: net.ErrClosed}
+	}
+}
+
+func (u *activatedUse) Close() error {
+	u.once.Do(func() { close(u.done) })
+	return nil
+}
+
+// listenTCP returns a listener for the address, using a socket passed by
+// systemd if one matches it.
+func listenTCP(lc config.FtpListenerConfiguration) (net.Listener, error) {
+	for _, l := range activatedListeners() {
+		if l.matches(lc) {
+			return l.listen(), nil
+		}
+	}
+	return net.Listen("tcp", listenAddress(lc))
+}
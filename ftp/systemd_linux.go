@@ -0,0 +1,11 @@
+//go:build linux
+
+package ftp
+
+import "golang.org/x/sys/unix"
+
+// closeOnExec keeps a socket passed by systemd from being inherited by the
+// processes Wings starts.
+func closeOnExec(fd int) {
+	unix.CloseOnExec(fd)
+}
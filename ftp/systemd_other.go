@@ -0,0 +1,6 @@
+//go:build !linux
+
+package ftp
+
+// closeOnExec does nothing, as sockets are only passed by systemd on Linux.
+func closeOnExec(int) {}
@@ -0,0 +1,165 @@
+package ftp
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/system"
+)
+
+// Logins, the resolution of request paths, and transfers are traced with
+// OpenTelemetry, so that a slow transfer can be told apart from a slow disk or
+// a slow Panel. Spans are exported over OTLP/HTTP to the configured endpoint,
+// or to the one in the standard environment variables, and carry the session
+// they belong to so the spans of one session can be found together.
+
+// The time given to the exporter to send the remaining spans when the
+// configuration changes or Wings stops.
+const tracingShutdownTimeout = 5 * time.Second
+
+const tracerName = "github.com/pterodactyl/wings/ftp"
+
+// tracing is the tracer provider spans are started with, replaced whenever the
+// tracing configuration changes. Without one, spans are not recorded.
+type tracing struct {
+	mu       sync.Mutex
+	cfg      config.FtpTracingConfiguration
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+func newTracing() *tracing {
+	return &tracing{tracer: noop.NewTracerProvider().Tracer(tracerName)}
+}
+
+// tracingEnabled reports whether spans are exported with the configuration.
+func tracingEnabled(cfg config.FtpTracingConfiguration) bool {
+	return cfg.Endpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// open starts exporting spans with the configuration, unless it is the one
+// already in use. Spans started with the previous configuration are flushed
+// in the background.
+func (t *tracing) open(cfg config.FtpTracingConfiguration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.provider != nil && reflect.DeepEqual(cfg, t.cfg) {
+		return nil
+	}
+	previous := t.provider
+	t.provider, t.tracer, t.cfg = nil, noop.NewTracerProvider().Tracer(tracerName), cfg
+	if previous != nil {
+		go shutdownProvider(previous)
+	}
+	if !tracingEnabled(cfg) {
+		return nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return err
+	}
+	t.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "wings"),
+			attribute.String("service.version", system.Version),
+		)),
+	)
+	t.tracer = t.provider.Tracer(tracerName)
+	return nil
+}
+
+func shutdownProvider(p *sdktrace.TracerProvider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
+
+// close flushes the spans that have not been exported yet and stops
+// exporting them.
+func (t *tracing) close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	previous := t.provider
+	t.provider, t.tracer = nil, noop.NewTracerProvider().Tracer(tracerName)
+	t.mu.Unlock()
+	if previous == nil {
+		return nil
+	}
+	return shutdownProvider(previous)
+}
+
+// enabled reports whether spans are being exported.
+func (t *tracing) enabled() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.provider != nil
+}
+
+// start starts a span, which is not recorded if tracing is disabled.
+func (t *tracing) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if t == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	t.mu.Lock()
+	tracer := t.tracer
+	t.mu.Unlock()
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span, marking it as failed if there was an error.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startSpan starts a span of the session, with the attributes identifying it.
+func (driver *FTPDriver) startSpan(name string, attrs ...attribute.KeyValue) trace.Span {
+	if !driver.tracing.enabled() {
+		return trace.SpanFromContext(context.Background())
+	}
+	_, span := driver.tracing.start(context.Background(), name, append(driver.spanAttributes(), attrs...)...)
+	return span
+}
+
+// spanAttributes returns the attributes identifying the session on its spans.
+func (driver *FTPDriver) spanAttributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("ftp.session", driver.sessionID),
+		attribute.String("ftp.username", driver.user),
+		attribute.String("client.address", driver.ip),
+	}
+	if driver.server != nil {
+		attrs = append(attrs, attribute.String("ftp.server", driver.server.ID()))
+	}
+	return attrs
+}
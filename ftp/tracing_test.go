@@ -0,0 +1,45 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	t.Run("records nothing while disabled", func(t *testing.T) {
+		driver := &FTPDriver{tracing: newTracing(), stats: &transferCounters{}}
+		assert.False(t, driver.startSpan("ftp.resolve_path").IsRecording())
+
+		// Drivers without tracing, such as in tests, still transfer files.
+		driver = &FTPDriver{stats: &transferCounters{}}
+		driver.endTransfer(driver.startTransfer("/a", transferUpload))
+	})
+
+	t.Run("records transfers with their size", func(t *testing.T) {
+		rec := tracetest.NewSpanRecorder()
+		tr := newTracing()
+		tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+		tr.tracer = tr.provider.Tracer(tracerName)
+
+		driver := &FTPDriver{tracing: tr, stats: &transferCounters{}, sessionID: "1a2b3c4d", user: "admin", ip: "192.0.2.1"}
+		transfer := driver.startTransfer("/world.zip", transferDownload)
+		transfer.stats.download(1024)
+		driver.endTransfer(transfer)
+
+		spans := rec.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "ftp.transfer", spans[0].Name())
+		attrs := attribute.NewSet(spans[0].Attributes()...)
+		v, _ := attrs.Value("ftp.bytes")
+		assert.Equal(t, int64(1024), v.AsInt64())
+		v, _ = attrs.Value("ftp.session")
+		assert.Equal(t, "1a2b3c4d", v.AsString())
+		v, _ = attrs.Value("ftp.direction")
+		assert.Equal(t, transferDownload, v.AsString())
+	})
+}
@@ -0,0 +1,52 @@
+package ftp
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+var errTooManyTransfers = errors.New("too many transfers in progress on this node, try again later")
+
+// transferSlots counts the data transfers in progress on the node, so that
+// only a limited number of them read from or write to the disk at once and
+// the disk is left to the running game servers. Transfers beyond the limit
+// are not queued, the client is told to try again with a 450 reply.
+type transferSlots struct {
+	n atomic.Int64
+}
+
+// tryAcquire takes a slot for a transfer, returning a function that releases
+// it. If the limit has been reached false is returned. A limit of zero or
+// less takes a slot without limiting the transfers, so that the count stays
+// accurate if a limit is configured later.
+func (t *transferSlots) tryAcquire(limit int) (func(), bool) {
+	if t == nil {
+		return func() {}, true
+	}
+	for {
+		n := t.n.Load()
+		if limit > 0 && n >= int64(limit) {
+			return nil, false
+		}
+		if t.n.CompareAndSwap(n, n+1) {
+			break
+		}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { t.n.Add(-1) })
+	}, true
+}
+
+// acquireTransfer takes a slot for a transfer by the session, returning an
+// error that is sent to the client as a 450 reply if there is none free.
+func (driver *FTPDriver) acquireTransfer() (func(), error) {
+	release, ok := driver.transfers.tryAcquire(driver.cfg.MaxConcurrentTransfers)
+	if !ok {
+		return nil, withReplyCode(ftpserver.StatusFileActionNotTaken, errTooManyTransfers)
+	}
+	return release, nil
+}
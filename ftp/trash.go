@@ -0,0 +1,113 @@
+package ftp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/spf13/afero"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+)
+
+// Each deletion is moved into its own batch directory within the trash so that
+// deleting the same path twice does not collide, and so the purge routine can
+// use the modification time of the batch to determine its age.
+const trashBatchFormat = "20060102-150405.000000000"
+
+// trashPath returns the location of the trash directory for the given server.
+func (driver *FTPDriver) trashPath(s *server.Server) string {
+	return filepath.Join(driver.BasePath, s.ID(), filepath.Clean("/"+driver.cfg.Trash.Directory))
+}
+
+// moveToTrash moves the file or directory at the given real path into the
+// trash for the server. Anything that already lives within the trash is
+// removed from the disk permanently.
+func (driver *FTPDriver) moveToTrash(s *server.Server, realPath string) error {
+	trash := driver.trashPath(s)
+	if realPath == trash || strings.HasPrefix(realPath, trash+string(filepath.Separator)) {
+		return removeAll(driver.storage(), realPath, usageRemoved(s))
+	}
+
+	rel, err := filepath.Rel(filepath.Join(driver.BasePath, s.ID()), realPath)
+	if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(trash, time.Now().UTC().Format(trashBatchFormat), rel)
+	driver.listings.invalidate(trash)
+	if err := driver.storage().MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	driver.logger().WithField("path", rel).Debug("moving deleted FTP path into trash")
+
+	return driver.storage().Rename(realPath, dst)
+}
+
+// purgeTrash permanently removes every trash batch that is older than the
+// configured retention period for all the servers on this node.
+func purgeTrash(m *server.Manager, fsys Backend, basePath string, cfg config.FtpTrashConfiguration) {
+	cutoff := time.Now().Add(-time.Duration(cfg.Retention) * time.Hour)
+	for _, s := range m.All() {
+		dir := filepath.Join(basePath, s.ID(), filepath.Clean("/"+cfg.Directory))
+		entries, err := afero.ReadDir(fsys, dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				s.Log().WithField("error", err).Warn("ftp: failed to read trash directory")
+			}
+			continue
+		}
+		for _, e := range entries {
+			if !e.ModTime().Before(cutoff) {
+				continue
+			}
+			if err := removeAll(fsys, filepath.Join(dir, e.Name()), usageRemoved(s)); err != nil {
+				s.Log().WithField("error", err).Warn("ftp: failed to purge trash entry")
+			}
+		}
+	}
+}
+
+// runTrashPurge purges expired trash entries at the configured interval until
+// the context is canceled.
+func (c *FTPServer) runTrashPurge(ctx context.Context, cfg config.FtpTrashConfiguration) {
+	interval := time.Duration(cfg.PurgeInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		purgeTrash(c.manager, c.backend, c.BasePath, cfg)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// siteEmptyTrash handles "SITE EMPTYTRASH" which permanently removes everything
+// in the trash for the server the session is attached to.
+func (s *session) siteEmptyTrash(_ string) (int, string) {
+	if !s.driver.cfg.Trash.Enabled {
+		return ftpserver.StatusCommandNotImplemented, "Trash is not enabled on this server"
+	}
+	if err := s.driver.checkReadOnly(); err != nil {
+		return ftpserver.StatusActionNotTaken, err.Error()
+	}
+	srv, err := s.driver.getServer()
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, err.Error()
+	}
+	if err := removeAll(s.driver.storage(), s.driver.trashPath(srv), usageRemoved(srv)); err != nil {
+		srv.Log().WithField("error", err).Warn("ftp: failed to empty trash")
+		return ftpserver.StatusActionNotTaken, "Could not empty trash"
+	}
+	return ftpserver.StatusOK, "Trash emptied"
+}
@@ -0,0 +1,330 @@
+package ftp
+
+import (
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/spf13/afero"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+)
+
+// The number of bytes from the start of an upload used to sniff its content
+// type. This matches the default read limit of the mimetype package.
+const sniffLength = 3072
+
+// uploadRules returns the upload rules that apply to the given server, taking
+// any override for its Egg into account.
+func (driver *FTPDriver) uploadRules(s *server.Server) config.FtpUploadRules {
+	if rules, ok := driver.cfg.Uploads.Eggs[s.Config().Egg.ID]; ok {
+		return rules
+	}
+	return driver.cfg.Uploads.FtpUploadRules
+}
+
+// checkUploadName returns an error if the file at the given path may not be
+// uploaded because of its extension.
+func (driver *FTPDriver) checkUploadName(s *server.Server, p string) error {
+	rules := driver.uploadRules(s)
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(p), "."))
+	if len(rules.AllowedExtensions) > 0 && !containsExtension(rules.AllowedExtensions, ext) {
+		return errors.Wrap(ftpserver.ErrFileNameNotAllowed, "file type is not allowed")
+	}
+	if containsExtension(rules.BlockedExtensions, ext) {
+		return errors.Wrap(ftpserver.ErrFileNameNotAllowed, "file type is blocked")
+	}
+	return nil
+}
+
+func containsExtension(list []string, ext string) bool {
+	for _, e := range list {
+		if strings.ToLower(strings.TrimPrefix(e, ".")) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadFile wraps every file opened for writing so that any post-upload
+// handling is performed once the client has finished with it.
+type uploadFile struct {
+	afero.File
+	driver *FTPDriver
+	server *server.Server
+	path   string
+	unlock func()
+	// Releases the transfer slot held by the upload.
+	releaseSlot func()
+
+	// The underlying file on the disk.
+	fd *os.File
+	// The number of bytes preallocated at a time once the upload is larger
+	// than it, or zero if the upload is not preallocated as it grows.
+	chunk int64
+	// The number of bytes written to the file so far.
+	written int64
+	// The offset up to which space has been preallocated for the file.
+	allocated int64
+	// The checksum of the upload, or nil if checksums are disabled or the
+	// upload did not start at the beginning of the file.
+	hash hash.Hash
+	// The size of the file before it was opened, used to update the disk
+	// usage of the server once the upload is closed.
+	size int64
+	// The limits on the rate the upload is received at, including its share
+	// of the node-wide limit.
+	bandwidth bandwidth
+	share     *fairShare
+	// The transfer as seen in the list of sessions.
+	transfer *activeTransfer
+	// Drops the pages written from the page cache, once the upload is large.
+	cache *cacheDropper
+	// The partial file the upload is written to, if uploads are resumable,
+	// and whether ftpserverlib reported that the transfer failed.
+	partial *partialUpload
+	failed  bool
+	// Restores the I/O priority of the thread the upload runs on.
+	restoreIO func()
+}
+
+// ReadFrom passes the upload through to the underlying file so that it is able
+// to use its own, more efficient, implementation where it has one.
+// When the upload is preallocated as it grows, the data is read a chunk at a
+// time so that the space for each chunk can be reserved before it is written.
+func (f *uploadFile) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		if err := f.reserve(f.chunk); err != nil {
+			return total, err
+		}
+		src := r
+		if f.chunk > 0 {
+			src = io.LimitReader(r, f.chunk)
+		}
+		src = f.bandwidth.reader(src)
+		if f.hash != nil {
+			src = io.TeeReader(src, f.hash)
+		}
+		n, err := f.readFrom(src)
+		total += n
+		if err != nil || f.chunk <= 0 || n < f.chunk {
+			f.driver.notifyQuota(f.server, err)
+			return total, err
+		}
+	}
+}
+
+func (f *uploadFile) readFrom(r io.Reader) (n int64, err error) {
+	if rf, ok := f.File.(io.ReaderFrom); ok && spliceable(r) {
+		n, err = rf.ReadFrom(r)
+		f.cache.advance(n)
+	} else {
+		n, err = copyBuffer(&progressWriter{w: f.File, progress: f.cache.advance}, r)
+	}
+	f.written += n
+	f.transfer.stats.upload(n)
+	return n, err
+}
+
+func (f *uploadFile) Write(p []byte) (int, error) {
+	if err := f.reserve(int64(len(p))); err != nil {
+		f.driver.notifyQuota(f.server, err)
+		return 0, err
+	}
+	f.bandwidth.wait(int64(len(p)))
+	n, err := f.File.Write(p)
+	if f.hash != nil {
+		f.hash.Write(p[:n])
+	}
+	f.cache.advance(int64(n))
+	f.written += int64(n)
+	f.transfer.stats.upload(int64(n))
+	return n, err
+}
+
+// Seek is used by ftpserverlib to resume an upload. The checksum can only be
+// computed for uploads that start at the beginning of the file, or continue a
+// partial file from the bytes it has received.
+func (f *uploadFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.seekPartial(offset, whence); err != nil {
+		return 0, err
+	}
+	pos, err := f.File.Seek(offset, whence)
+	if f.partial == nil && pos != 0 || f.partial != nil && pos != f.partial.Size {
+		f.hash = nil
+	}
+	if err == nil {
+		f.cache.seek(pos)
+	}
+	return pos, err
+}
+
+func (f *uploadFile) Close() error {
+	defer f.restoreIO()
+	defer f.unlock()
+	defer f.releaseSlot()
+	defer f.driver.listings.invalidate(f.Name())
+	defer f.updateUsage()
+	defer f.share.close()
+	defer f.driver.endTransfer(f.transfer)
+	f.release()
+	f.cache.close()
+	err := f.File.Close()
+	if f.partial != nil {
+		if perr := f.finishPartial(f.failed || err != nil); err == nil {
+			err = perr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if f.failed && f.partial != nil {
+		return nil
+	}
+	if f.driver.cfg.Checksums && f.fd != nil {
+		name := f.fd.Name()
+		if f.partial != nil {
+			name = f.partial.target
+		}
+		recordChecksum(name, f.hash)
+	}
+	if f.driver.cfg.ClamAV.Enabled {
+		if err := f.driver.scanUpload(f.server, f.path); err != nil {
+			return err
+		}
+	}
+	f.transfer.stats.uploaded()
+	f.driver.fileChanged(f.server, fileActionUpload, f.path)
+	return nil
+}
+
+// updateUsage adds the change in the size of the file to the disk usage that
+// Wings tracks for the server. Uploads that were rejected and removed reduce
+// the usage by the size the file had before.
+func (f *uploadFile) updateUsage() {
+	name := f.Name()
+	if f.partial != nil && f.partial.done {
+		name = f.partial.target
+	}
+	var size int64
+	if st, err := f.driver.storage().Stat(name); err == nil {
+		size = st.Size()
+	}
+	if size != f.size {
+		f.server.Filesystem().AddDiskUsage(size - f.size)
+	}
+}
+
+// sniffedFile wraps a file being uploaded and holds back the first bytes
+// written to it until its content type has been checked against the blocked
+// MIME types, so that nothing is written to the disk for a rejected upload.
+type sniffedFile struct {
+	afero.File
+	fsys    Backend
+	blocked []string
+	head    []byte
+	// err is set once the upload has been rejected.
+	err     error
+	checked bool
+}
+
+// sniffUploads wraps the given file if the server has any blocked MIME types
+// configured, otherwise the file is returned as is. Only uploads that start at
+// the beginning of the file are sniffed.
+func (driver *FTPDriver) sniffUploads(s *server.Server, f afero.File) afero.File {
+	blocked := driver.uploadRules(s).BlockedMimeTypes
+	if len(blocked) == 0 {
+		return f
+	}
+	return &sniffedFile{File: f, fsys: driver.storage(), blocked: blocked}
+}
+
+func (f *sniffedFile) Write(p []byte) (int, error) {
+	if f.checked {
+		return f.File.Write(p)
+	}
+	n := min(len(p), sniffLength-len(f.head))
+	f.head = append(f.head, p[:n]...)
+	if len(f.head) < sniffLength {
+		return n, nil
+	}
+	if err := f.check(); err != nil {
+		return n, err
+	}
+	m, err := f.File.Write(p[n:])
+	return n + m, err
+}
+
+// ReadFrom checks the start of the upload before handing the remainder of it
+// off to the underlying file, which keeps the zero-copy path that *os.File
+// provides for everything after the sniffed bytes. Files without a ReadFrom of
+// their own are copied to through a buffer.
+func (f *sniffedFile) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	if !f.checked {
+		buf := make([]byte, sniffLength-len(f.head))
+		read, err := io.ReadFull(r, buf)
+		f.head = append(f.head, buf[:read]...)
+		n = int64(read)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return n, err
+		}
+		if err := f.check(); err != nil {
+			return n, err
+		}
+	}
+	var m int64
+	var err error
+	if rf, ok := f.File.(io.ReaderFrom); ok {
+		m, err = rf.ReadFrom(r)
+	} else {
+		m, err = copyBuffer(f.File, r)
+	}
+	return n + m, err
+}
+
+// check sniffs the buffered bytes and writes them out if the upload is
+// allowed.
+func (f *sniffedFile) check() error {
+	f.checked = true
+	if f.err = checkContentType(f.blocked, mimetype.Detect(f.head)); f.err != nil {
+		return f.err
+	}
+	_, err := f.File.Write(f.head)
+	f.head = nil
+	return err
+}
+
+// checkContentType returns an error if the content type, or any type it is a
+// kind of, is one of the blocked MIME types.
+func checkContentType(blocked []string, m *mimetype.MIME) error {
+	for t := m; t != nil; t = t.Parent() {
+		for _, b := range blocked {
+			if t.Is(b) {
+				return errors.Wrap(ftpserver.ErrFileNameNotAllowed, "content type "+m.String()+" is blocked")
+			}
+		}
+	}
+	return nil
+}
+
+// Close flushes uploads that were too small to fill the sniff buffer, and
+// removes the file from the disk if the upload was rejected.
+func (f *sniffedFile) Close() error {
+	if !f.checked && len(f.head) > 0 {
+		_ = f.check()
+	}
+	if f.err != nil {
+		_ = f.File.Close()
+		_ = f.fsys.Remove(f.File.Name())
+		return f.err
+	}
+	return f.File.Close()
+}
@@ -0,0 +1,47 @@
+package ftp
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffedFile(t *testing.T) {
+	open := func(t *testing.T) (*sniffedFile, string) {
+		p := filepath.Join(t.TempDir(), "upload")
+		f, err := os.Create(p)
+		require.NoError(t, err)
+		return &sniffedFile{File: f, fsys: NewLocalBackend(), blocked: []string{"text/x-python"}}, p
+	}
+
+	t.Run("rejects and removes blocked content", func(t *testing.T) {
+		f, p := open(t)
+
+		_, err := io.Copy(f, bytes.NewReader([]byte("#!/usr/bin/env python\nimport os\n")))
+		require.NoError(t, err)
+
+		err = f.Close()
+		assert.True(t, errors.Is(err, ftpserver.ErrFileNameNotAllowed))
+		assert.NoFileExists(t, p)
+	})
+
+	t.Run("writes allowed content", func(t *testing.T) {
+		f, p := open(t)
+		data := bytes.Repeat([]byte("hello world\n"), 1024)
+
+		_, err := io.Copy(f, bytes.NewReader(data))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		b, err := os.ReadFile(p)
+		require.NoError(t, err)
+		assert.Equal(t, data, b)
+	})
+}
@@ -0,0 +1,132 @@
+package ftp
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// On nodes with hundreds of transfers in progress, the read and write system
+// calls made for every buffer of every transfer add up. When enabled, the
+// reads of downloads and the writes of uploads that are not left to sendfile
+// or splice are instead queued on an io_uring shared by every transfer, and a
+// single goroutine collects their completions. Each operation uses the
+// current position of the file, so the file can still be seeked and sent
+// with sendfile as usual.
+//
+// This is experimental, and falls back to regular file I/O on platforms other
+// than Linux, or if the kernel does not support io_uring or the operations
+// used, which were added in Linux 5.6.
+
+// The number of operations that can be queued on the ring at once. Further
+// operations wait for one of them to complete.
+const uringEntries = 256
+
+// The operations used, from linux/io_uring.h.
+const (
+	ioringOpRead  = 22
+	ioringOpWrite = 23
+)
+
+var (
+	errUringUnsupported = errors.New("ftp: io_uring read and write are not supported by the kernel")
+	errUringStopped     = errors.New("ftp: io_uring stopped")
+)
+
+var (
+	ringOnce   sync.Once
+	sharedRing atomic.Pointer[uring]
+)
+
+// fileRing returns the io_uring shared by every transfer, or nil if it is not
+// enabled or not available. The ring is set up the first time it is needed
+// and kept until Wings stops, or until it stops working.
+func fileRing() *uring {
+	if !config.Get().System.Ftp.IOUring {
+		return nil
+	}
+	ringOnce.Do(func() {
+		r, err := newUring(uringEntries)
+		if err != nil {
+			log.WithField("error", err).Warn("io_uring is not available, FTP transfers use regular file I/O")
+			return
+		}
+		sharedRing.Store(r)
+	})
+	return sharedRing.Load()
+}
+
+// read reads from the file through the ring, or directly if the ring is nil.
+func (r *uring) read(f *os.File, p []byte) (int, error) {
+	if r == nil {
+		return f.Read(p)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := r.do(ioringOpRead, f, p)
+	if err != nil {
+		return 0, &os.PathError{Op: "read", Path: f.Name(), Err: err}
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// write writes all of p to the file through the ring, or directly if the ring
+// is nil.
+func (r *uring) write(f *os.File, p []byte) (int, error) {
+	if r == nil {
+		return f.Write(p)
+	}
+	var total int
+	for total < len(p) {
+		n, err := r.do(ioringOpWrite, f, p[total:])
+		total += n
+		if err != nil {
+			return total, &os.PathError{Op: "write", Path: f.Name(), Err: err}
+		}
+		if n == 0 {
+			return total, io.ErrShortWrite
+		}
+	}
+	return total, nil
+}
+
+// reader returns a reader of the file through the ring. The file is hidden,
+// so that io.Copy reads from the reader rather than the file itself.
+func (r *uring) reader(f *os.File) io.Reader {
+	return &uringReader{f: f, ring: r}
+}
+
+type uringReader struct {
+	f    *os.File
+	ring *uring
+}
+
+func (r *uringReader) Read(p []byte) (int, error) {
+	return r.ring.read(r.f, p)
+}
+
+// uringFile is a file being uploaded that is written to through the ring.
+// Uploads that can be spliced from the data connection still use the
+// ReadFrom of the file.
+type uringFile struct {
+	*os.File
+	ring *uring
+}
+
+func (f *uringFile) Read(p []byte) (int, error) {
+	return f.ring.read(f.File, p)
+}
+
+func (f *uringFile) Write(p []byte) (int, error) {
+	return f.ring.write(f.File, p)
+}
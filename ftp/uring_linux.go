@@ -0,0 +1,298 @@
+//go:build linux
+
+package ftp
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"golang.org/x/sys/unix"
+)
+
+// The offsets the rings are mapped at, and the flags used, from
+// linux/io_uring.h.
+const (
+	ioringOffSQRing      = 0
+	ioringOffCQRing      = 0x8000000
+	ioringOffSQEs        = 0x10000000
+	ioringEnterGetEvents = 1
+	ioringRegisterProbe  = 8
+	ioUringOpSupported   = 1
+)
+
+// The number of times submitting an operation is retried while the kernel is
+// short of resources.
+const uringEnterRetries = 10
+
+// uringParams is struct io_uring_params.
+type uringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        struct {
+		head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+		userAddr                                                        uint64
+	}
+	cqOff struct {
+		head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+		userAddr                                                        uint64
+	}
+}
+
+// uringSQE is struct io_uring_sqe.
+type uringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+// uringCQE is struct io_uring_cqe.
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uring is an io_uring that operations are submitted to by any goroutine,
+// whose completions are collected by a goroutine of its own.
+type uring struct {
+	fd int
+
+	sqHead, sqTail, sqMask *uint32
+	sqArray                []uint32
+	sqes                   []uringSQE
+	cqHead, cqTail, cqMask *uint32
+	cqes                   []uringCQE
+
+	// Limits the operations in progress to the size of the ring, so that
+	// completions never overflow it.
+	slots chan struct{}
+
+	// mu guards the submission ring and the operations waiting for their
+	// completion, keyed by the user data given to the kernel.
+	mu      sync.Mutex
+	pending map[uint64]chan int32
+	next    uint64
+	stopped bool
+}
+
+func newUring(entries uint32) (*uring, error) {
+	var p uringParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, errors.Wrap(errno, "ftp: io_uring_setup")
+	}
+	r := &uring{
+		fd:      int(fd),
+		slots:   make(chan struct{}, p.sqEntries),
+		pending: make(map[uint64]chan int32),
+	}
+	if !r.supports(ioringOpRead, ioringOpWrite) {
+		_ = unix.Close(r.fd)
+		return nil, errUringUnsupported
+	}
+	mmap := func(offset int64, size uint32) ([]byte, error) {
+		return unix.Mmap(r.fd, offset, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	}
+	sq, err := mmap(ioringOffSQRing, p.sqOff.array+p.sqEntries*4)
+	if err != nil {
+		_ = unix.Close(r.fd)
+		return nil, errors.Wrap(err, "ftp: mmap io_uring submission ring")
+	}
+	cq, err := mmap(ioringOffCQRing, p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(uringCQE{})))
+	if err != nil {
+		_ = unix.Munmap(sq)
+		_ = unix.Close(r.fd)
+		return nil, errors.Wrap(err, "ftp: mmap io_uring completion ring")
+	}
+	sqes, err := mmap(ioringOffSQEs, p.sqEntries*uint32(unsafe.Sizeof(uringSQE{})))
+	if err != nil {
+		_ = unix.Munmap(sq)
+		_ = unix.Munmap(cq)
+		_ = unix.Close(r.fd)
+		return nil, errors.Wrap(err, "ftp: mmap io_uring submission entries")
+	}
+	field := func(b []byte, off uint32) *uint32 {
+		return (*uint32)(unsafe.Pointer(&b[off]))
+	}
+	r.sqHead, r.sqTail, r.sqMask = field(sq, p.sqOff.head), field(sq, p.sqOff.tail), field(sq, p.sqOff.ringMask)
+	r.sqArray = unsafe.Slice(field(sq, p.sqOff.array), p.sqEntries)
+	r.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&sqes[0])), p.sqEntries)
+	r.cqHead, r.cqTail, r.cqMask = field(cq, p.cqOff.head), field(cq, p.cqOff.tail), field(cq, p.cqOff.ringMask)
+	r.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&cq[p.cqOff.cqes])), p.cqEntries)
+	go r.reap()
+	return r, nil
+}
+
+// supports reports whether the kernel supports all of the given operations.
+func (r *uring) supports(ops ...uint8) bool {
+	// struct io_uring_probe is followed by a struct io_uring_probe_op for
+	// each operation, with its flags at the third byte.
+	const maxOps = 256
+	probe := make([]byte, 16+maxOps*8)
+	_, _, errno := unix.Syscall6(unix.SYS_IO_URING_REGISTER, uintptr(r.fd), ioringRegisterProbe, uintptr(unsafe.Pointer(&probe[0])), maxOps, 0, 0)
+	if errno != 0 {
+		return false
+	}
+	for _, op := range ops {
+		if op > probe[0] || probe[16+int(op)*8+2]&ioUringOpSupported == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// submit queues an operation on the file descriptor and waits for it to
+// complete, returning the result given by the kernel.
+func (r *uring) submit(op uint8, fd int, buf []byte) (int32, error) {
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	done := make(chan int32, 1)
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return 0, errUringStopped
+	}
+	r.next++
+	id := r.next
+	tail := atomic.LoadUint32(r.sqTail)
+	idx := tail & *r.sqMask
+	r.sqes[idx] = uringSQE{
+		opcode: op,
+		fd:     int32(fd),
+		// An offset of -1 uses, and moves, the current position of the file.
+		off:      ^uint64(0),
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		len:      uint32(len(buf)),
+		userData: id,
+	}
+	r.sqArray[idx] = idx
+	// The kernel only reads the entry once it is told about it by
+	// io_uring_enter, so the tail is moved back if that fails.
+	atomic.StoreUint32(r.sqTail, tail+1)
+	if err := r.enter(); err != nil {
+		atomic.StoreUint32(r.sqTail, tail)
+		r.mu.Unlock()
+		return 0, err
+	}
+	r.pending[id] = done
+	r.mu.Unlock()
+
+	res, ok := <-done
+	if !ok {
+		return 0, errUringStopped
+	}
+	// The buffer is written to by the kernel until the operation completes.
+	runtime.KeepAlive(buf)
+	return res, nil
+}
+
+// enter submits the entry at the tail of the submission ring, retrying if
+// the call is interrupted or the kernel is short of resources.
+func (r *uring) enter() error {
+	for i := 0; ; i++ {
+		n, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), 1, 0, 0, 0, 0)
+		switch {
+		case errno == 0 && n == 1:
+			return nil
+		case errno == unix.EINTR:
+		case (errno == 0 || errno == unix.EAGAIN || errno == unix.EBUSY) && i < uringEnterRetries:
+			time.Sleep(time.Millisecond)
+		default:
+			if errno == 0 {
+				errno = unix.EAGAIN
+			}
+			return errors.Wrap(errno, "ftp: io_uring_enter")
+		}
+	}
+}
+
+// reap waits for operations to complete and hands their results to the
+// goroutines waiting for them. If waiting fails the ring is stopped.
+func (r *uring) reap() {
+	for {
+		_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), 0, 1, ioringEnterGetEvents, 0, 0)
+		if errno != 0 && errno != unix.EINTR {
+			log.WithField("error", errno).Error("failed to wait for io_uring completions, FTP transfers use regular file I/O")
+			r.stop()
+			return
+		}
+		head := atomic.LoadUint32(r.cqHead)
+		tail := atomic.LoadUint32(r.cqTail)
+		for ; head != tail; head++ {
+			cqe := r.cqes[head&*r.cqMask]
+			r.mu.Lock()
+			done := r.pending[cqe.userData]
+			delete(r.pending, cqe.userData)
+			r.mu.Unlock()
+			if done != nil {
+				done <- cqe.res
+			}
+		}
+		atomic.StoreUint32(r.cqHead, head)
+	}
+}
+
+// stop fails the operations waiting for their completion and any submitted
+// afterwards, and closes the ring, which cancels the operations still in the
+// kernel. Transfers started afterwards use regular file I/O.
+func (r *uring) stop() {
+	sharedRing.CompareAndSwap(r, nil)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+	r.stopped = true
+	for id, done := range r.pending {
+		delete(r.pending, id)
+		close(done)
+	}
+	_ = unix.Close(r.fd)
+}
+
+// do runs an operation on the file, which is held open until it completes.
+func (r *uring) do(op uint8, f *os.File, p []byte) (int, error) {
+	raw, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var res int32
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		res, serr = r.submit(op, int(fd), p)
+	}); err != nil {
+		return 0, err
+	}
+	if serr != nil {
+		return 0, serr
+	}
+	if res < 0 {
+		return 0, syscall.Errno(-res)
+	}
+	return int(res), nil
+}
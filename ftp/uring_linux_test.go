@@ -0,0 +1,48 @@
+package ftp
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUringStop(t *testing.T) {
+	r, err := newUring(8)
+	if err != nil {
+		t.Skipf("io_uring is not available: %s", err)
+	}
+	sharedRing.Store(r)
+	t.Cleanup(func() { sharedRing.Store(nil) })
+
+	pr, pw, err := os.Pipe()
+	require.NoError(t, err)
+	defer pr.Close()
+	defer pw.Close()
+
+	// Nothing is written to the pipe, so the read waits until the ring stops.
+	read := make(chan error, 1)
+	go func() {
+		_, err := r.read(pr, make([]byte, 16))
+		read <- err
+	}()
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.pending) == 1
+	}, time.Second, time.Millisecond)
+
+	r.stop()
+	select {
+	case err := <-read:
+		assert.ErrorIs(t, err, errUringStopped, "operations in progress fail")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the read did not return once the ring stopped")
+	}
+	assert.Nil(t, sharedRing.Load(), "new transfers do not use the ring")
+
+	_, err = r.write(pw, []byte("data"))
+	assert.ErrorIs(t, err, errUringStopped, "operations submitted afterwards fail")
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package ftp
+
+import "os"
+
+// uring is never set up on this platform, as io_uring is specific to Linux.
+type uring struct{}
+
+func newUring(uint32) (*uring, error) {
+	return nil, errUringUnsupported
+}
+
+func (r *uring) do(uint8, *os.File, []byte) (int, error) {
+	return 0, errUnsupported
+}
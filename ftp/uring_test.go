@@ -0,0 +1,49 @@
+package ftp
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUring(t *testing.T) {
+	r, err := newUring(8)
+	if err != nil {
+		t.Skipf("io_uring is not available: %s", err)
+	}
+
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := os.Create(filepath.Join(dir, strconv.Itoa(i)))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer f.Close()
+
+			w := &uringFile{File: f, ring: r}
+			for off := 0; off < len(data); off += 4096 {
+				n, err := w.Write(data[off : off+4096])
+				if !assert.NoError(t, err) || !assert.Equal(t, 4096, n) {
+					return
+				}
+			}
+			_, err = f.Seek(0, io.SeekStart)
+			assert.NoError(t, err)
+			got, err := io.ReadAll(r.reader(f))
+			assert.NoError(t, err)
+			assert.Equal(t, data, got)
+		}()
+	}
+	wg.Wait()
+}
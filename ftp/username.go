@@ -0,0 +1,68 @@
+package ftp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Usernames follow the format: user_{server-id}, where the server ID is either
+// the full UUID of the server or 8 characters of it.
+var validUsernameRegexp = regexp.MustCompile(`^(?i)(.+)_([a-z0-9]{8}|[a-z0-9-]{36})$`)
+
+// The usernames of SFTP follow the format user.{server-id}, where the server
+// ID is the first 8 characters of its UUID.
+var sftpUsernameRegexp = regexp.MustCompile(`^(?i)(.+)\.([a-z0-9]{8})$`)
+
+// ParseFTPUsername splits an FTP username into the name of the user and the
+// key of the server it logs in to, which is either the full UUID of the
+// server or its first or last 8 characters. It returns false if the username
+// is not in the format user_{server-id}.
+func ParseFTPUsername(username string) (user string, serverKey string, ok bool) {
+	m := validUsernameRegexp.FindStringSubmatch(username)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// parseAccountUsername splits the username of an account into the name of the
+// user and the key of the server it logs in to. Accounts mapped to a server
+// may have any username, which is used whole as the name of the user, while
+// any other username must be in the format user_{server-id}.
+func parseAccountUsername(username string) (user string, serverKey string, ok bool) {
+	if id, ok := mappedServer(username); ok {
+		return username, id, true
+	}
+	return ParseFTPUsername(username)
+}
+
+// ParseSFTPUsername splits an SFTP username, in the format user.{server-id},
+// into the name of the user and the key of the server it logs in to.
+func ParseSFTPUsername(username string) (user string, serverKey string, ok bool) {
+	if _, _, ftp := ParseFTPUsername(username); ftp {
+		return "", "", false
+	}
+	m := sftpUsernameRegexp.FindStringSubmatch(username)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// matchesServerKey reports whether the server key given in a username refers
+// to the server with the given ID.
+func matchesServerKey(srvID, serverKey string) bool {
+	// Try exact match (full UUID)
+	if srvID == serverKey {
+		return true
+	}
+	// Try short ID match (first 8 chars)
+	if len(srvID) >= 8 && srvID[:8] == serverKey {
+		return true
+	}
+	// Try last 8 chars match
+	if len(srvID) >= 8 && strings.HasSuffix(srvID, serverKey) {
+		return true
+	}
+	return false
+}
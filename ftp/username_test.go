@@ -0,0 +1,70 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFTPUsername(t *testing.T) {
+	id := "8f2c1d3e-0000-4000-8000-00001111aaaa"
+
+	for username, want := range map[string][2]string{
+		"alice_8f2c1d3e":        {"alice", "8f2c1d3e"},
+		"alice_" + id:           {"alice", id},
+		"alice_smith_1111aaaa":  {"alice_smith", "1111aaaa"},
+		"Alice_8F2C1D3E":        {"Alice", "8F2C1D3E"},
+		"alice_other1_8f2c1d3e": {"alice_other1", "8f2c1d3e"},
+	} {
+		user, key, ok := ParseFTPUsername(username)
+		assert.True(t, ok, username)
+		assert.Equal(t, want[0], user, username)
+		assert.Equal(t, want[1], key, username)
+	}
+
+	for _, username := range []string{"alice", "alice_", "_8f2c1d3e", "alice_8f2c1d3", "alice_8f2c1d3e!", "alice-8f2c1d3e"} {
+		_, _, ok := ParseFTPUsername(username)
+		assert.False(t, ok, username)
+	}
+}
+
+func TestMatchesServerKey(t *testing.T) {
+	id := "8f2c1d3e-0000-4000-8000-00001111aaaa"
+	assert.True(t, matchesServerKey(id, id))
+	assert.True(t, matchesServerKey(id, "8f2c1d3e"))
+	assert.True(t, matchesServerKey(id, "1111aaaa"))
+	assert.False(t, matchesServerKey(id, "00004000"))
+	assert.False(t, matchesServerKey(id, "8F2C1D3E"))
+}
+
+func TestParseSFTPUsername(t *testing.T) {
+	user, key, ok := ParseSFTPUsername("alice.8f2c1d3e")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", user)
+	assert.Equal(t, "8f2c1d3e", key)
+
+	user, _, ok = ParseSFTPUsername("alice_b.8f2c1d3e")
+	assert.True(t, ok)
+	assert.Equal(t, "alice_b", user)
+
+	// Usernames in the FTP format are left to ParseFTPUsername.
+	for _, username := range []string{"alice.b_8f2c1d3e", "alice", "alice.8f2c1d3", ".8f2c1d3e"} {
+		_, _, ok := ParseSFTPUsername(username)
+		assert.False(t, ok, username)
+	}
+}
+
+func TestPanelPermissions(t *testing.T) {
+	driver := &FTPDriver{permissions: []string{"file.read", "file.create"}}
+	assert.True(t, driver.allowed(ScopeRead))
+	assert.True(t, driver.allowed(ScopeWrite))
+	assert.False(t, driver.allowed(ScopeDelete))
+	assert.False(t, driver.allowed(ScopeRename))
+
+	// Scopes of the account still apply.
+	driver.scopes = []string{ScopeRead}
+	assert.False(t, driver.allowed(ScopeWrite))
+
+	// Accounts on the node are not limited by Panel permissions.
+	assert.True(t, (&FTPDriver{}).allowed(ScopeDelete))
+}
@@ -0,0 +1,150 @@
+package ftp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/spf13/afero"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/server/backup"
+)
+
+// Wings can present directories in the FTP root that are not part of the
+// server's files, but are backed by another location on the node. Virtual
+// paths are read-only and shadow anything with the same name on the server.
+
+// backupsDir is the virtual directory the server's local backups are listed in.
+const backupsDir = ".backups"
+
+// PermissionBackupDownload is the Panel permission users authenticated
+// through the Panel need to see and download the backups in /.backups.
+const PermissionBackupDownload = "backup.download"
+
+var errVirtualReadOnly = errors.New("virtual directory is read-only")
+
+// virtualPath returns the name of the entry within the virtual backups
+// directory for a request path, which is empty for the directory itself. If
+// the path is not within the virtual directory false is returned.
+func (driver *FTPDriver) virtualPath(p string) (string, bool) {
+	if !driver.cfg.ExposeBackups || driver.root != "" {
+		return "", false
+	}
+	rel := relativePath(p)
+	if rel == backupsDir {
+		return "", true
+	}
+	if name, ok := strings.CutPrefix(rel, backupsDir+"/"); ok {
+		return name, true
+	}
+	return "", false
+}
+
+// virtualDirInfo describes a virtual directory.
+type virtualDirInfo struct {
+	name string
+}
+
+func (i virtualDirInfo) Name() string       { return i.name }
+func (i virtualDirInfo) Size() int64        { return 0 }
+func (i virtualDirInfo) Mode() os.FileMode  { return os.ModeDir | 0o555 }
+func (i virtualDirInfo) ModTime() time.Time { return time.Now() }
+func (i virtualDirInfo) IsDir() bool        { return true }
+func (i virtualDirInfo) Sys() interface{}   { return nil }
+
+// checkBackupAccess returns an error if the session is not allowed to list or
+// download the server's backups, which needs the same access as downloading
+// its files and, for Panel users, the backup.download permission.
+func (driver *FTPDriver) checkBackupAccess() error {
+	if err := driver.checkScope(ScopeRead); err != nil {
+		return err
+	}
+	if !driver.can("file.read-content") || !driver.can(PermissionBackupDownload) {
+		return withReplyCode(ftpserver.StatusActionNotTaken, errors.New("permission denied: this account cannot download backups"))
+	}
+	return nil
+}
+
+// backupPath returns the path of a local backup on the node if it belongs to
+// the server.
+func backupPath(s *server.Server, name string) (string, error) {
+	if name == "" || strings.Contains(name, "/") || !strings.HasSuffix(name, ".tar.gz") {
+		return "", os.ErrNotExist
+	}
+	p := filepath.Join(config.Get().System.BackupDirectory, name)
+	if backup.LocalServer(p) != s.ID() {
+		return "", os.ErrNotExist
+	}
+	return p, nil
+}
+
+// statVirtual returns the file information for a virtual path.
+func (driver *FTPDriver) statVirtual(s *server.Server, name string) (os.FileInfo, error) {
+	if err := driver.checkBackupAccess(); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return virtualDirInfo{name: backupsDir}, nil
+	}
+	p, err := backupPath(s, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+// listVirtual passes the server's local backups to the callback.
+func (driver *FTPDriver) listVirtual(s *server.Server, callback func(os.FileInfo) error) error {
+	if err := driver.checkBackupAccess(); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(config.Get().System.BackupDirectory)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := backupPath(s, entry.Name()); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if err := callback(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openVirtual opens a file within a virtual directory for reading.
+func (driver *FTPDriver) openVirtual(s *server.Server, name string, flag int) (afero.File, error) {
+	if isWriteFlag(flag) {
+		return nil, errVirtualReadOnly
+	}
+	if err := driver.checkBackupAccess(); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("is a directory")
+	}
+	p, err := backupPath(s, name)
+	if err != nil {
+		return nil, err
+	}
+	release, err := driver.acquireTransfer()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return driver.newDownload(f, "/"+backupsDir+"/"+name, release), nil
+}
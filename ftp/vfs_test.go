@@ -0,0 +1,114 @@
+package ftp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/server/backup"
+)
+
+func TestBackupPath(t *testing.T) {
+	dir := t.TempDir()
+	config.Set(&config.Configuration{
+		AuthenticationToken: "test",
+		System:              config.SystemConfiguration{BackupDirectory: dir},
+	})
+	s, err := server.New(nil)
+	require.NoError(t, err)
+	require.NoError(t, s.SyncWithConfiguration(remote.ServerConfigurationResponse{
+		Settings: json.RawMessage(`{"uuid":"` + testServerID + `"}`),
+	}))
+
+	newBackup := func(uuid, owner string) *backup.LocalBackup {
+		b := backup.NewLocal(nil, uuid, "")
+		require.NoError(t, os.WriteFile(b.Path(), []byte("archive"), 0o600))
+		if owner != "" {
+			require.NoError(t, b.SetServer(owner))
+		}
+		return b
+	}
+
+	t.Run("finds backups of the server", func(t *testing.T) {
+		b := newBackup("11111111-1111-1111-1111-111111111111", testServerID)
+		p, err := backupPath(s, filepath.Base(b.Path()))
+		require.NoError(t, err)
+		assert.Equal(t, b.Path(), p)
+	})
+
+	t.Run("hides backups of other servers", func(t *testing.T) {
+		b := newBackup("22222222-2222-2222-2222-222222222222", "3c1d9e2a-0000-4000-8000-000000000000")
+		_, err := backupPath(s, filepath.Base(b.Path()))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("hides backups that cannot be attributed", func(t *testing.T) {
+		b := newBackup("33333333-3333-3333-3333-333333333333", "")
+		_, err := backupPath(s, filepath.Base(b.Path()))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("removes the manifest with the backup", func(t *testing.T) {
+		b := newBackup("44444444-4444-4444-4444-444444444444", testServerID)
+		require.NoError(t, b.Remove())
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			assert.NotContains(t, entry.Name(), "44444444")
+		}
+	})
+}
+
+func TestBackupAccess(t *testing.T) {
+	dir := t.TempDir()
+	config.Set(&config.Configuration{
+		AuthenticationToken: "test",
+		System:              config.SystemConfiguration{BackupDirectory: dir},
+	})
+	s, err := server.New(nil)
+	require.NoError(t, err)
+	require.NoError(t, s.SyncWithConfiguration(remote.ServerConfigurationResponse{
+		Settings: json.RawMessage(`{"uuid":"` + testServerID + `"}`),
+	}))
+	b := backup.NewLocal(nil, "11111111-1111-1111-1111-111111111111", "")
+	require.NoError(t, os.WriteFile(b.Path(), []byte("archive"), 0o600))
+	require.NoError(t, b.SetServer(testServerID))
+	name := "/" + backupsDir + "/" + filepath.Base(b.Path())
+
+	newDriver := func(scopes, permissions []string) *FTPDriver {
+		return &FTPDriver{server: s, scopes: scopes, permissions: permissions, cfg: config.FtpConfiguration{ExposeBackups: true}}
+	}
+
+	t.Run("downloads with read access", func(t *testing.T) {
+		driver := newDriver(nil, nil)
+		f, err := driver.OpenFile(name, os.O_RDONLY, 0)
+		require.NoError(t, err)
+		assert.NoError(t, f.Close())
+	})
+
+	for desc, driver := range map[string]*FTPDriver{
+		"refuses upload-only accounts":                  newDriver([]string{ScopeWrite}, nil),
+		"refuses Panel users without backup.download":   newDriver(nil, []string{"file.read", "file.read-content"}),
+		"refuses Panel users without file.read-content": newDriver(nil, []string{"file.read", PermissionBackupDownload}),
+	} {
+		t.Run(desc, func(t *testing.T) {
+			_, err := driver.OpenFile(name, os.O_RDONLY, 0)
+			assert.ErrorContains(t, err, "permission denied")
+			_, err = driver.Stat(name)
+			assert.ErrorContains(t, err, "permission denied")
+		})
+	}
+
+	t.Run("allows Panel users with backup.download", func(t *testing.T) {
+		driver := newDriver(nil, []string{"file.read", "file.read-content", PermissionBackupDownload})
+		_, err := driver.Stat(name)
+		assert.NoError(t, err)
+	})
+}
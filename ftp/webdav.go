@@ -0,0 +1,611 @@
+package ftp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// Some networks block FTP entirely, leaving users without a way to manage
+// their files other than the Panel. When enabled, the same files are served
+// over WebDAV, which every major operating system can mount as a network
+// drive. Requests are authenticated with HTTP basic auth using the FTP
+// username and password, and are handled by the same driver as FTP commands,
+// so accounts are jailed, limited, and logged just as they are over FTP.
+//
+// HTTP has no connection to log in on, so a login is remembered as a session
+// until it has made no requests for the configured time, and is listed and
+// disconnected through the API like any FTP session.
+
+// How often idle WebDAV sessions are looked for.
+const davSweepInterval = time.Minute
+
+// davServer serves WebDAV requests, authenticating them against the FTP
+// accounts.
+type davServer struct {
+	d        *FTPServerDriver
+	srv      *http.Server
+	listener net.Listener
+	timeout  time.Duration
+
+	// mu guards the logged in sessions, keyed by a hash of the address and
+	// credentials they logged in with, and the lock systems of each server.
+	mu       sync.Mutex
+	sessions map[[sha256.Size]byte]*davSession
+	locks    map[string]webdav.LockSystem
+}
+
+// davSession is a WebDAV login.
+type davSession struct {
+	key     [sha256.Size]byte
+	client  *davClient
+	driver  *FTPDriver
+	handler *webdav.Handler
+	// The password file of the account the session logged in with, or nil if
+	// the login was validated by the Panel.
+	credential os.FileInfo
+	// The time of the last request, and the number of requests in progress.
+	lastUsed time.Time
+	active   int
+}
+
+// listenWebDAV opens the WebDAV listener, returning nil if it is not enabled.
+func (c *FTPServer) listenWebDAV(cfg config.FtpConfiguration, node *fairLimiter) (*davServer, error) {
+	wc := cfg.WebDAV
+	if !wc.Enabled {
+		return nil, nil
+	}
+	addr := net.JoinHostPort(wc.Address, strconv.Itoa(wc.Port))
+	d := c.serverDriver(cfg, node)
+	d.listen = addr
+	if wc.CertificateFile != "" {
+		cert, err := tls.LoadX509KeyPair(wc.CertificateFile, wc.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ftp: failed to load tls certificate for webdav listener %s", addr)
+		}
+		d.tls = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ftp: failed to bind webdav listener %s", addr)
+	}
+	if d.tls != nil {
+		l = tls.NewListener(l, d.tls)
+	}
+	ds := &davServer{
+		d:        d,
+		listener: l,
+		timeout:  time.Duration(wc.SessionTimeout) * time.Second,
+		sessions: make(map[[sha256.Size]byte]*davSession),
+		locks:    make(map[string]webdav.LockSystem),
+	}
+	ds.srv = &http.Server{Handler: ds, ReadHeaderTimeout: 30 * time.Second}
+	if cfg.IdleTimeout > 0 {
+		ds.srv.IdleTimeout = time.Duration(cfg.IdleTimeout) * time.Second
+	}
+	return ds, nil
+}
+
+// serve accepts requests until the listener is closed, ending sessions once
+// they have been idle for the configured time.
+func (ds *davServer) serve(ctx context.Context) {
+	log.WithField("listen", ds.d.listen).Info("starting FTP WebDAV listener")
+	go func() {
+		ticker := time.NewTicker(davSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				ds.sweep(now)
+			}
+		}
+	}()
+	if err := ds.srv.Serve(ds.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.WithField("error", err).Error("FTP WebDAV listener error")
+	}
+}
+
+// close closes the listener and ends every session.
+func (ds *davServer) close() error {
+	if ds == nil {
+		return nil
+	}
+	err := ds.srv.Close()
+	ds.mu.Lock()
+	sessions := ds.sessions
+	ds.sessions = make(map[[sha256.Size]byte]*davSession)
+	ds.mu.Unlock()
+	for _, s := range sessions {
+		ds.d.ClientDisconnected(s.client)
+	}
+	return err
+}
+
+// sweep ends the sessions that have not made a request within the timeout.
+func (ds *davServer) sweep(now time.Time) {
+	var idle []*davSession
+	ds.mu.Lock()
+	for k, s := range ds.sessions {
+		if s.active == 0 && now.Sub(s.lastUsed) > ds.timeout {
+			delete(ds.sessions, k)
+			idle = append(idle, s)
+		}
+	}
+	ds.mu.Unlock()
+	for _, s := range idle {
+		ds.d.ClientDisconnected(s.client)
+	}
+}
+
+// end ends the session with the given key, if it is still logged in.
+func (ds *davServer) end(key [sha256.Size]byte) {
+	ds.mu.Lock()
+	s, ok := ds.sessions[key]
+	delete(ds.sessions, key)
+	ds.mu.Unlock()
+	if ok {
+		ds.d.ClientDisconnected(s.client)
+	}
+}
+
+func (ds *davServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if penalized, _ := ds.d.offenders.penalty(ip); penalized {
+		http.Error(w, "Too many failed logins, try again later", http.StatusForbidden)
+		return
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		ds.unauthorized(w, "Authentication required")
+		return
+	}
+	s, err := ds.login(r, ip, username, password)
+	if err != nil {
+		ds.unauthorized(w, authMessage(ds.d.cfg.Messages, err))
+		return
+	}
+	defer ds.release(s)
+	s.handler.ServeHTTP(w, r)
+}
+
+func (ds *davServer) unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="Pterodactyl", charset="UTF-8"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
+
+// login returns the session for the credentials of a request, logging in if
+// they have no session yet. A session of an account on the node is ended, and
+// logged in again, if the password file of the account has been removed or
+// replaced since, so that deleting an account or changing its password takes
+// effect on the next request rather than once the session is idle.
+func (ds *davServer) login(r *http.Request, ip, username, password string) (*davSession, error) {
+	key := sha256.Sum256([]byte(ip + "\x00" + username + "\x00" + password))
+	if s := ds.acquire(key); s != nil {
+		if !s.credentialChanged() {
+			return s, nil
+		}
+		ds.release(s)
+		ds.end(key)
+	}
+	// The password file is looked at before logging in, so that a change
+	// made while the login is checked is noticed on the next request.
+	var credential os.FileInfo
+	if !ds.d.usesPanel(username) {
+		credential, _ = Credentials().stat(username, credentialPassword)
+	}
+	client := newDavClient(r, ds.d.tls != nil, func() { ds.end(key) })
+	cd, err := ds.d.AuthUser(client, username, password)
+	if err != nil {
+		return nil, err
+	}
+	driver := cd.(*ClientDriver).FTPDriver
+	s := &davSession{key: key, client: client, driver: driver, credential: credential, lastUsed: time.Now(), active: 1}
+	s.handler = &webdav.Handler{
+		FileSystem: &davFS{driver: driver},
+		LockSystem: ds.lockSystem(driver),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				driver.logger().WithFields(log.Fields{
+					"method": r.Method,
+					"path":   truncateParam(r.URL.Path),
+					"error":  err,
+				}).Debug("WebDAV request failed")
+			}
+		},
+	}
+	ds.mu.Lock()
+	// Another request with the same credentials may have logged in first, in
+	// which case its session is used rather than keeping two.
+	if existing, ok := ds.sessions[key]; ok {
+		existing.active++
+		ds.mu.Unlock()
+		ds.d.ClientDisconnected(client)
+		return existing, nil
+	}
+	ds.sessions[key] = s
+	ds.mu.Unlock()
+	return s, nil
+}
+
+// acquire returns the session with the given key, counting a request in
+// progress for it.
+func (ds *davServer) acquire(key [sha256.Size]byte) *davSession {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	s, ok := ds.sessions[key]
+	if !ok {
+		return nil
+	}
+	s.active++
+	return s
+}
+
+// credentialChanged reports whether the password file of the account the
+// session logged in with has been removed or changed since. Passwords are
+// written to a new file which replaces the old one, so a new password is
+// noticed even if it is written within the resolution of the timestamps.
+func (s *davSession) credentialChanged() bool {
+	if s.credential == nil {
+		return false
+	}
+	st, err := Credentials().stat(s.driver.user, credentialPassword)
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(st, s.credential) || !st.ModTime().Equal(s.credential.ModTime()) || st.Size() != s.credential.Size()
+}
+
+// release notes that a request of the session has finished.
+func (ds *davServer) release(s *davSession) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	s.active--
+	s.lastUsed = time.Now()
+}
+
+// lockSystem returns the WebDAV locks of the directory the session is jailed
+// to, shared by every session with the same root.
+func (ds *davServer) lockSystem(driver *FTPDriver) webdav.LockSystem {
+	key := path.Join(driver.server.ID(), driver.root)
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ls, ok := ds.locks[key]
+	if !ok {
+		ls = webdav.NewMemLS()
+		ds.locks[key] = ls
+	}
+	return ls
+}
+
+// davError returns the error the WebDAV handler expects for the errors of the
+// driver, which only recognizes the errors of the os package. Rejections by
+// the driver are reported as permission errors.
+func davError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return os.ErrNotExist
+	case errors.Is(err, os.ErrExist):
+		return os.ErrExist
+	case errors.Is(err, os.ErrPermission):
+		return os.ErrPermission
+	}
+	var re *replyError
+	if errors.As(translateError(err), &re) && (re.code == ftpserver.StatusActionNotTaken || re.code == ftpserver.StatusActionNotTakenNoFile) {
+		return os.ErrPermission
+	}
+	return err
+}
+
+// davFS implements webdav.FileSystem with the driver of a session.
+type davFS struct {
+	driver *FTPDriver
+}
+
+func (fs *davFS) Mkdir(_ context.Context, name string, _ os.FileMode) error {
+	if _, err := fs.driver.Stat(name); err == nil {
+		return os.ErrExist
+	}
+	// MKCOL does not create missing parents, unlike MKD.
+	if _, err := fs.driver.Stat(path.Dir(name)); err != nil {
+		return davError(err)
+	}
+	return davError(fs.driver.MakeDir(name))
+}
+
+// OpenFile opens a file for the handler. Files opened for reading are only
+// opened by the driver once they are read from, since the handler also opens
+// every file it lists. The handler opens files for writing without creating
+// or truncating them only to change their properties, which are not stored,
+// so those are opened the same way.
+func (fs *davFS) OpenFile(_ context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	if flag&(os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		info, err := fs.driver.Stat(name)
+		if err != nil {
+			return nil, davError(err)
+		}
+		if info.IsDir() {
+			return &davDir{driver: fs.driver, name: name, info: davInfo{info}}, nil
+		}
+		return &davFile{driver: fs.driver, name: name, info: davInfo{info}}, nil
+	}
+	f, err := fs.driver.OpenFile(name, flag, 0644)
+	if err != nil {
+		return nil, davError(err)
+	}
+	return &davFile{driver: fs.driver, name: name, f: f}, nil
+}
+
+func (fs *davFS) RemoveAll(_ context.Context, name string) error {
+	info, err := fs.driver.Stat(name)
+	if err != nil {
+		return davError(err)
+	}
+	if info.IsDir() {
+		return davError(fs.driver.DeleteDir(name))
+	}
+	return davError(fs.driver.DeleteFile(name))
+}
+
+func (fs *davFS) Rename(_ context.Context, oldName, newName string) error {
+	return davError(fs.driver.Rename(oldName, newName))
+}
+
+func (fs *davFS) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	info, err := fs.driver.Stat(name)
+	if err != nil {
+		return nil, davError(err)
+	}
+	return davInfo{info}, nil
+}
+
+// davInfo gives the handler the content type of a file from its extension,
+// so that it does not read the start of every file it lists to detect it.
+type davInfo struct {
+	os.FileInfo
+}
+
+func (i davInfo) ContentType(context.Context) (string, error) {
+	if t := mime.TypeByExtension(path.Ext(i.Name())); t != "" {
+		return t, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// davFile is a file opened by the handler. Files opened for reading are
+// opened as a download on the first read, until which seeks only move the
+// position to read from.
+type davFile struct {
+	driver *FTPDriver
+	name   string
+	info   os.FileInfo
+	f      afero.File
+	pos    int64
+}
+
+func (f *davFile) open() error {
+	if f.f != nil {
+		return nil
+	}
+	file, err := f.driver.OpenFile(f.name, os.O_RDONLY, 0)
+	if err != nil {
+		return davError(err)
+	}
+	f.f = file
+	if f.pos > 0 {
+		if _, err := f.f.Seek(f.pos, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *davFile) Read(p []byte) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.f.Read(p)
+}
+
+func (f *davFile) Write(p []byte) (int, error) {
+	if f.f == nil {
+		return 0, os.ErrPermission
+	}
+	return f.f.Write(p)
+}
+
+func (f *davFile) Seek(offset int64, whence int) (int64, error) {
+	if f.f != nil {
+		return f.f.Seek(offset, whence)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, os.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *davFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+func (f *davFile) Stat() (os.FileInfo, error) {
+	if f.info != nil {
+		return f.info, nil
+	}
+	info, err := f.f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return davInfo{info}, nil
+}
+
+// Close finishes the download or upload, writing it to the access log and
+// xferlog as an FTP transfer would be once its reply is sent.
+func (f *davFile) Close() error {
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	if err != nil {
+		f.driver.logReply([]byte("451 "))
+	} else {
+		f.driver.logReply([]byte("226 "))
+	}
+	return davError(err)
+}
+
+// davDir is a directory opened by the handler, whose entries are listed by the
+// driver when they are first read.
+type davDir struct {
+	driver  *FTPDriver
+	name    string
+	info    os.FileInfo
+	entries []os.FileInfo
+	listed  bool
+}
+
+func (d *davDir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		err := d.driver.ListDir(d.name, func(info os.FileInfo) error {
+			d.entries = append(d.entries, davInfo{info})
+			return nil
+		})
+		if err != nil {
+			return nil, davError(err)
+		}
+		d.listed = true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *davDir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *davDir) Read([]byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *davDir) Write([]byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *davDir) Seek(int64, int) (int64, error) {
+	return 0, nil
+}
+
+func (d *davDir) Close() error {
+	return nil
+}
+
+// The IDs given to WebDAV sessions, counting down from the top so that they
+// do not clash with those given to FTP connections by ftpserverlib.
+var davClientID atomic.Uint32
+
+// davClient stands in for the FTP connection of a WebDAV session, so that it
+// can be logged in and listed by the same code as FTP sessions.
+type davClient struct {
+	id     uint32
+	remote net.Addr
+	local  net.Addr
+	agent  string
+	tls    bool
+	extra  any
+	// Ends the session, for when it is disconnected through the API.
+	end func()
+}
+
+func newDavClient(r *http.Request, tls bool, end func()) *davClient {
+	c := &davClient{
+		id:    ^davClientID.Add(1),
+		agent: r.UserAgent(),
+		tls:   tls,
+		end:   end,
+	}
+	c.remote = tcpAddr(r.RemoteAddr)
+	c.local = &net.TCPAddr{}
+	if a, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		c.local = a
+	}
+	return c
+}
+
+// tcpAddr parses the remote address of a request.
+func tcpAddr(addr string) net.Addr {
+	a, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+	return a
+}
+
+func (c *davClient) Path() string                              { return "/" }
+func (c *davClient) SetPath(string)                            {}
+func (c *davClient) SetListPath(string)                        {}
+func (c *davClient) SetDebug(bool)                             {}
+func (c *davClient) Debug() bool                               { return false }
+func (c *davClient) ID() uint32                                { return c.id }
+func (c *davClient) RemoteAddr() net.Addr                      { return c.remote }
+func (c *davClient) LocalAddr() net.Addr                       { return c.local }
+func (c *davClient) GetClientVersion() string                  { return c.agent }
+func (c *davClient) HasTLSForControl() bool                    { return c.tls }
+func (c *davClient) HasTLSForTransfers() bool                  { return c.tls }
+func (c *davClient) GetLastCommand() string                    { return "" }
+func (c *davClient) GetLastDataChannel() ftpserver.DataChannel { return 0 }
+func (c *davClient) SetTLSRequirement(ftpserver.TLSRequirement) error {
+	return nil
+}
+func (c *davClient) SetExtra(extra any) { c.extra = extra }
+func (c *davClient) Extra() any         { return c.extra }
+
+func (c *davClient) Close() error {
+	c.end()
+	return nil
+}
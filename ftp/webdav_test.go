@@ -0,0 +1,103 @@
+package ftp
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+type fakeInfo struct {
+	name string
+	size int64
+}
+
+func (i fakeInfo) Name() string       { return i.name }
+func (i fakeInfo) Size() int64        { return i.size }
+func (i fakeInfo) Mode() os.FileMode  { return 0644 }
+func (i fakeInfo) ModTime() time.Time { return time.Time{} }
+func (i fakeInfo) IsDir() bool        { return false }
+func (i fakeInfo) Sys() any           { return nil }
+
+func TestDavError(t *testing.T) {
+	pathError := &fs.PathError{Op: "open", Path: "/var/lib/pterodactyl/volumes/8f2a1c3e/server.jar", Err: syscall.ENOENT}
+	assert.Nil(t, davError(nil))
+	assert.Equal(t, os.ErrNotExist, davError(pathError))
+	assert.Equal(t, os.ErrPermission, davError(errReadOnly))
+	assert.Equal(t, os.ErrPermission, davError(errors.Wrap(syscall.ENAMETOOLONG, "create")))
+
+	// Errors that are not the client's fault are left for the handler to
+	// report as such.
+	busy := errors.Wrap(syscall.EBUSY, "open")
+	assert.Equal(t, busy, davError(busy))
+}
+
+func TestDavFileSeek(t *testing.T) {
+	// Files opened for reading are not opened by the driver until they are
+	// read, so seeks only move the position.
+	f := &davFile{name: "/server.jar", info: davInfo{fakeInfo{name: "server.jar", size: 100}}}
+
+	n, err := f.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), n)
+	n, err = f.Seek(-10, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(90), n)
+	_, err = f.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+
+	_, err = f.Write([]byte("x"))
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.NoError(t, f.Close())
+}
+
+func TestDavInfoContentType(t *testing.T) {
+	ct, err := davInfo{fakeInfo{name: "config.json"}}.ContentType(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", ct)
+
+	ct, err = davInfo{fakeInfo{name: "level.dat"}}.ContentType(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", ct)
+}
+
+func TestDavSessionCredentialChanged(t *testing.T) {
+	config.Set(&config.Configuration{
+		AuthenticationToken: "test",
+		System:              config.SystemConfiguration{Ftp: config.FtpConfiguration{CredentialsPath: t.TempDir()}},
+	})
+	const username = "alice_8f2a1c3e"
+	require.NoError(t, SetPassword(username, "secret"))
+	st, err := Credentials().stat(username, credentialPassword)
+	require.NoError(t, err)
+	s := &davSession{driver: &FTPDriver{user: username}, credential: st}
+	assert.False(t, s.credentialChanged())
+
+	t.Run("when the password is rotated", func(t *testing.T) {
+		_, err := RotatePassword(username)
+		require.NoError(t, err)
+		assert.True(t, s.credentialChanged())
+	})
+
+	t.Run("when the account is deleted", func(t *testing.T) {
+		st, err := Credentials().stat(username, credentialPassword)
+		require.NoError(t, err)
+		s.credential = st
+		assert.False(t, s.credentialChanged())
+		require.NoError(t, DeleteAccount(username))
+		assert.True(t, s.credentialChanged())
+	})
+
+	t.Run("not for logins validated by the Panel", func(t *testing.T) {
+		assert.False(t, (&davSession{driver: &FTPDriver{user: username}}).credentialChanged())
+	})
+}
@@ -0,0 +1,172 @@
+package ftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+// The events sent to webhooks, in addition to the file change actions.
+const (
+	webhookLoginNewIP    = "login.new_ip"
+	webhookQuotaExceeded = "quota.exceeded"
+)
+
+// The number of webhook deliveries that can be in progress at once. Events
+// beyond this are dropped rather than queued without limit.
+const maxWebhookDeliveries = 32
+
+// The default number of times a delivery is retried after it fails.
+const defaultWebhookRetries = 3
+
+// webhookPayload is the JSON body sent to a webhook.
+type webhookPayload struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Server   string    `json:"server"`
+	Username string    `json:"username"`
+	IP       string    `json:"ip"`
+	Paths    []string  `json:"paths,omitempty"`
+}
+
+// webhooks delivers FTP events to the webhooks in the configuration.
+type webhooks struct {
+	client *http.Client
+	slots  chan struct{}
+}
+
+func newWebhooks() *webhooks {
+	return &webhooks{
+		client: &http.Client{Timeout: 10 * time.Second},
+		slots:  make(chan struct{}, maxWebhookDeliveries),
+	}
+}
+
+// notify sends an event for the session to every webhook subscribed to it.
+func (driver *FTPDriver) notify(s *server.Server, event string, paths ...string) {
+	if driver.webhooks == nil || len(driver.cfg.Webhooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(webhookPayload{
+		Event:    event,
+		Time:     time.Now().UTC(),
+		Server:   s.ID(),
+		Username: driver.user,
+		IP:       driver.ip,
+		Paths:    paths,
+	})
+	if err != nil {
+		return
+	}
+	for _, hook := range driver.cfg.Webhooks {
+		if len(hook.Events) > 0 && !slices.Contains(hook.Events, event) {
+			continue
+		}
+		select {
+		case driver.webhooks.slots <- struct{}{}:
+			go func(hook config.FtpWebhookConfiguration) {
+				defer func() { <-driver.webhooks.slots }()
+				driver.webhooks.deliver(hook, event, body)
+			}(hook)
+		default:
+			log.WithFields(log.Fields{"url": hook.URL, "event": event}).Warn("dropping FTP webhook: too many deliveries in progress")
+		}
+	}
+}
+
+// deliver sends the body to the webhook, retrying with an exponential backoff
+// if it cannot be delivered.
+func (w *webhooks) deliver(hook config.FtpWebhookConfiguration, event string, body []byte) {
+	retries := hook.Retries
+	if retries <= 0 {
+		retries = defaultWebhookRetries
+	}
+	delay := time.Second
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if err = w.send(hook, event, body); err == nil {
+			return
+		}
+	}
+	log.WithFields(log.Fields{"url": hook.URL, "event": event, "error": err}).Warn("failed to deliver FTP webhook")
+}
+
+func (w *webhooks) send(hook config.FtpWebhookConfiguration, event string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Pterodactyl Wings/v"+system.Version)
+	req.Header.Set("X-Wings-Event", event)
+	if hook.Secret != "" {
+		req.Header.Set("X-Wings-Signature", "sha256="+webhookSignature(hook.Secret, body))
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode >= 300 {
+		return errors.Errorf("webhook returned %s", res.Status)
+	}
+	return nil
+}
+
+// webhookSignature returns the hex encoded HMAC-SHA256 of the body.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyQuota sends a quota.exceeded event if the error is the result of the
+// server running out of disk space.
+func (driver *FTPDriver) notifyQuota(s *server.Server, err error) {
+	if err != nil && errors.Is(err, ftpserver.ErrStorageExceeded) {
+		driver.notify(s, webhookQuotaExceeded)
+	}
+}
+
+// rememberIP records the IP address the account logged in from, returning
+// true if the account has not logged in from it before. The addresses are
+// stored alongside the password of the account.
+func rememberIP(username, ip string) bool {
+	store := Credentials()
+	data, err := store.read(username, credentialIPs)
+	if err != nil && !os.IsNotExist(err) {
+		return false
+	}
+	known := strings.Fields(string(data))
+	if slices.Contains(known, ip) {
+		return false
+	}
+	if err := store.write(username, credentialIPs, []byte(strings.Join(append(known, ip), "\n")+"\n")); err != nil {
+		return false
+	}
+	// An account with no addresses recorded has not logged in since this
+	// was added, so it is not reported as a new address.
+	return len(known) > 0
+}
@@ -0,0 +1,42 @@
+package ftp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestWebhooks_Deliver(t *testing.T) {
+	var attempts atomic.Int32
+	var signature, event string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		signature = r.Header.Get("X-Wings-Signature")
+		event = r.Header.Get("X-Wings-Event")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	payload := []byte(`{"event":"upload"}`)
+	newWebhooks().deliver(config.FtpWebhookConfiguration{URL: srv.URL, Secret: "secret", Retries: 1}, "upload", payload)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+	assert.EqualValues(t, 2, attempts.Load(), "a failed delivery should be retried")
+	assert.Equal(t, "upload", event)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+}
@@ -0,0 +1,73 @@
+package ftp
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// notWritableError is returned when an FTP user attempts to modify a path that
+// is outside of the writable subtrees configured for the server.
+type notWritableError struct {
+	path string
+}
+
+func (e *notWritableError) Error() string {
+	return fmt.Sprintf("%s is not writable over FTP", e.path)
+}
+
+// writablePaths returns the subtrees that may be modified over FTP on the
+// server, taking any override for its Egg into account. An empty list means
+// the whole server is writable.
+func (driver *FTPDriver) writablePaths() []string {
+	if len(driver.cfg.EggWritablePaths) > 0 {
+		if s, err := driver.getServer(); err == nil {
+			if paths, ok := driver.cfg.EggWritablePaths[s.Config().Egg.ID]; ok {
+				return paths
+			}
+		}
+	}
+	return driver.cfg.WritablePaths
+}
+
+// checkWritable returns an error if the given path, relative to the server
+// root, is not at or beneath one of the writable subtrees.
+func (driver *FTPDriver) checkWritable(rel string) error {
+	paths := driver.writablePaths()
+	if len(paths) == 0 {
+		return nil
+	}
+	for _, pattern := range paths {
+		pattern = relativePath(pattern)
+		if driver.cfg.CaseInsensitive {
+			pattern = strings.ToLower(pattern)
+		}
+		if withinSubtree(rel, pattern) {
+			return nil
+		}
+	}
+	return &notWritableError{path: "/" + rel}
+}
+
+// withinSubtree reports whether the path is the directory matched by the
+// pattern, or is anywhere beneath it. Each component of the pattern may be a
+// glob.
+func withinSubtree(rel string, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	if rel == "" {
+		return false
+	}
+	relParts := strings.Split(rel, "/")
+	patternParts := strings.Split(pattern, "/")
+	if len(relParts) < len(patternParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if ok, _ := path.Match(part, relParts[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package ftp
+
+import (
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+)
+
+// noXattr translates the errors returned for an attribute that is not set, or
+// a filesystem without extended attributes, to errNoXattr.
+func noXattr(err error) error {
+	if errors.Is(err, unix.ENODATA) || errors.Is(err, unix.ENOTSUP) {
+		return errNoXattr
+	}
+	return err
+}
+
+// lsetXattr sets the extended attribute on the file, or on the link itself if
+// it is a symbolic link.
+func lsetXattr(p, name string, value []byte) error {
+	return unix.Lsetxattr(p, name, value, 0)
+}
+
+// lremoveXattr removes the extended attribute from the file, or from the link
+// itself if it is a symbolic link.
+func lremoveXattr(p, name string) error {
+	return noXattr(unix.Lremovexattr(p, name))
+}
+
+// fgetXattr reads the extended attribute of the open file into buf.
+func fgetXattr(fd uintptr, name string, buf []byte) (int, error) {
+	n, err := unix.Fgetxattr(int(fd), name, buf)
+	return n, noXattr(err)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package ftp
+
+// Extended attributes are not used on this platform, so checksums are never
+// recorded and files never have one to remove or read.
+
+func lsetXattr(string, string, []byte) error {
+	return errUnsupported
+}
+
+func lremoveXattr(string, string) error {
+	return errNoXattr
+}
+
+func fgetXattr(uintptr, string, []byte) (int, error) {
+	return 0, errNoXattr
+}
@@ -0,0 +1,59 @@
+package ftp
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// The xferlog is written in the format used by wu-ftpd, see xferlog(5), so
+// that the tooling built around it for billing and abuse reports can be used
+// with Wings unchanged. Only completed transfers are written to it.
+
+// The time format of the first field of an xferlog line.
+const xferlogTimeFormat = "Mon Jan _2 15:04:05 2006"
+
+// xferLog writes transfers to the configured xferlog file.
+type xferLog struct {
+	logFile
+}
+
+func newXferLog() *xferLog {
+	return &xferLog{}
+}
+
+// write adds a line for the transfer to the xferlog, if it is enabled. The
+// transfer is marked complete if the reply sent for it was successful.
+func (l *xferLog) write(driver *FTPDriver, t *activeTransfer, st TransferStats, now time.Time, code int) {
+	if l == nil {
+		return
+	}
+	direction := "o"
+	if t.direction == transferUpload {
+		direction = "i"
+	}
+	status := "i"
+	if code >= 200 && code < 300 {
+		status = "c"
+	}
+	// The fields are separated by spaces, so any in the file name are
+	// replaced to keep the line parseable.
+	name := strings.Join(strings.Fields(filepath.Join(driver.BasePath, driver.server.ID(), t.path)), "_")
+	fields := []string{
+		now.Format(xferlogTimeFormat),
+		strconv.FormatInt(int64(now.Sub(t.started).Round(time.Second)/time.Second), 10),
+		driver.ip,
+		strconv.FormatInt(st.BytesUploaded+st.BytesDownloaded, 10),
+		name,
+		// Binary transfer, no special action, from a real (not anonymous)
+		// user of the ftp service with no RFC 931 authentication.
+		"b", "_", direction, "r", driver.user, "ftp", "0", "*",
+		status,
+	}
+	if err := l.writeLine([]byte(strings.Join(fields, " "))); err != nil {
+		log.WithField("error", err).Warn("failed to write to FTP xferlog")
+	}
+}
@@ -2,12 +2,16 @@ package router
 
 import (
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/ftp"
+	"github.com/pterodactyl/wings/router/middleware"
 )
 
 type ftpChangePasswordRequest struct {
@@ -16,7 +20,9 @@ type ftpChangePasswordRequest struct {
 	NewPassword     string `json:"new_password" binding:"required"`
 }
 
-// postFtpChangePassword handles changing FTP password for a user.
+// postFtpChangePassword handles changing FTP password for a user. The account
+// must belong to the server, and unless the request is authorized with the
+// token of the node the current password of the account must be given.
 // POST /api/servers/:server/ftp/change-password
 // Request body: {username, current_password, new_password}
 func postFtpChangePassword(c *gin.Context) {
@@ -38,9 +44,14 @@ func postFtpChangePassword(c *gin.Context) {
 		return
 	}
 
-	if len(req.NewPassword) < 6 {
+	if err := ftp.CheckPassword(req.Username, req.NewPassword); err != nil {
+		var pe *ftp.PasswordPolicyError
+		if !errors.As(err, &pe) {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "New password must be at least 6 characters long",
+			"error": "New " + pe.Error(),
 		})
 		return
 	}
@@ -49,30 +60,47 @@ func postFtpChangePassword(c *gin.Context) {
 		"subsystem": "ftp",
 		"server_id": s.ID(),
 		"username":  req.Username,
+		"ip":        c.ClientIP(),
 	})
 
-	// Check if password file exists
-	passwordDir := "/var/lib/pterodactyl/passwords"
-	passwordFile := filepath.Join(passwordDir, req.Username+".txt")
-	
-	_, err := os.Stat(passwordFile)
-	fileExists := err == nil
-
-	// If file exists, verify current password (if provided)
-	if fileExists {
-		// If current password is provided, verify it
-		if len(req.CurrentPassword) > 0 {
-			if !verifyFtpPassword(req.Username, req.CurrentPassword) {
-				logger.Warn("FTP password change failed: invalid current password")
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"error": "Current password is incorrect",
-				})
-				return
-			}
+	// The username is used to name the password file, so it must not be able
+	// to reach the accounts of other servers or anything outside of the
+	// password directory.
+	if !ftp.AccountBelongsTo(req.Username, s.ID()) {
+		logger.Warn("FTP password change rejected: account does not belong to server")
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "The FTP account does not belong to this server.",
+		})
+		return
+	}
+
+	fileExists := ftp.AccountExists(req.Username)
+
+	// The Panel may change the password of an account, or create it, without
+	// knowing the current one. Anyone else must prove they know it, and gets
+	// the same answer whether or not the account exists.
+	authorized := middleware.IsAuthorized(c)
+	if !authorized {
+		// Attempts are limited so that the endpoint cannot be used to guess
+		// passwords or to thrash the password files.
+		if wait, ok := middleware.ExtractFtpServer(c).AllowPasswordChange(c.ClientIP(), req.Username); !ok {
+			logger.Warn("FTP password change rejected: too many attempts")
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many password change attempts, try again later.",
+			})
+			return
 		}
-		// If no current password provided but file exists, just allow the change
-		// (Panel may not always provide current password on first setup)
-	} else {
+	}
+	if !authorized || len(req.CurrentPassword) > 0 {
+		if !fileExists || !verifyFtpPassword(req.Username, req.CurrentPassword) {
+			logger.Warn("FTP password change failed: invalid current password")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Current password is incorrect",
+			})
+			return
+		}
+	} else if !fileExists {
 		logger.Info("FTP password file does not exist, creating new one")
 	}
 
@@ -94,38 +122,666 @@ func postFtpChangePassword(c *gin.Context) {
 
 // verifyFtpPassword checks if the password is correct for the FTP user.
 func verifyFtpPassword(username, password string) bool {
-	passwordDir := "/var/lib/pterodactyl/passwords"
-	passwordFile := filepath.Join(passwordDir, username+".txt")
+	return ftp.VerifyPassword(username, password)
+}
+
+// changeFtpPassword updates the FTP password for a user.
+func changeFtpPassword(username, newPassword string) error {
+	if err := ftp.SetPassword(username, newPassword); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"subsystem": "ftp",
+		"username":  username,
+	}).Debug("FTP password file updated")
+
+	return nil
+}
+
+type ftpServerSettingsRequest struct {
+	ReadOnly *bool `json:"read_only" binding:"required"`
+}
+
+// patchServerFtp changes the FTP settings of a server. Making a server
+// read-only applies to sessions that are already logged in, and persists
+// until it is made writable again.
+// PATCH /api/servers/:server/ftp
+// Request body: {read_only}
+func patchServerFtp(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	var req ftpServerSettingsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body. Required fields: read_only",
+		})
+		return
+	}
+	if err := middleware.ExtractFtpServer(c).SetServerReadOnly(s.ID(), *req.ReadOnly); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"subsystem": "ftp",
+		"server_id": s.ID(),
+		"read_only": *req.ReadOnly,
+	}).Info("FTP read-only mode of server changed")
+	c.JSON(http.StatusOK, gin.H{"read_only": *req.ReadOnly})
+}
+
+// getServerFtpInfo returns the details needed to connect to a server over
+// FTP: the listeners it can be reached on and the username format.
+// GET /api/servers/:server/ftp/info
+func getServerFtpInfo(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+	c.JSON(http.StatusOK, middleware.ExtractFtpServer(c).Info(s.ID()))
+}
 
-	data, err := os.ReadFile(passwordFile)
+// getServerFtpStats returns the FTP transfer totals for a server since it was
+// first seen, over the last hour and day, and the paths with the most data
+// transferred. The statistics are saved to the disk, so they carry over
+// restarts of Wings.
+// GET /api/servers/:server/ftp/stats
+func getServerFtpStats(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	c.JSON(http.StatusOK, middleware.ExtractFtpServer(c).Stats(s.ID()))
+}
+
+// getServerFtpSessions returns the FTP sessions logged in to a server along
+// with the progress of any transfer they are making.
+// GET /api/servers/:server/ftp/sessions
+func getServerFtpSessions(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	c.JSON(http.StatusOK, middleware.ExtractFtpServer(c).Sessions(s.ID()))
+}
+
+// deleteServerFtpSession disconnects an FTP session from a server.
+// DELETE /api/servers/:server/ftp/sessions/:id
+func deleteServerFtpSession(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	err := middleware.ExtractFtpServer(c).Disconnect(s.ID(), c.Param("id"))
+	if errors.Is(err, ftp.ErrSessionNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested FTP session does not exist.",
+		})
+		return
+	}
 	if err != nil {
-		return false
+		middleware.CaptureAndAbort(c, err)
+		return
 	}
+	c.Status(http.StatusNoContent)
+}
 
-	storedPassword := strings.TrimSpace(string(data))
-	return storedPassword == password
+// getServerFtpUsers returns the FTP accounts of a server.
+// GET /api/servers/:server/ftp/users
+func getServerFtpUsers(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	accounts, err := ftp.ServerAccounts(s.ID())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
 }
 
-// changeFtpPassword updates the FTP password for a user.
-func changeFtpPassword(username, newPassword string) error {
-	passwordDir := "/var/lib/pterodactyl/passwords"
-	passwordFile := filepath.Join(passwordDir, username+".txt")
+type ftpCreateUserRequest struct {
+	Username  string     `json:"username" binding:"required"`
+	Root      string     `json:"root"`
+	ReadOnly  bool       `json:"read_only"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Scopes    []string   `json:"scopes"`
+	// Mapped creates the account with the username as given, mapped to the
+	// server, rather than with the suffix of the server added to it.
+	Mapped bool `json:"mapped"`
+}
 
-	// Ensure directory exists
-	if err := os.MkdirAll(passwordDir, 0700); err != nil {
-		return err
+// ftpAccountName returns the full username of an account of the server from
+// the name given in the request, which may be given without the suffix of the
+// server. If the name cannot be that of an account of the server the request
+// is aborted.
+func ftpAccountName(c *gin.Context, serverID, name string) (string, bool) {
+	username := ftp.ServerAccountName(serverID, name)
+	if name == "" || !ftp.AccountBelongsTo(username, serverID) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The FTP username is not valid for this server.",
+		})
+		return "", false
 	}
+	return username, true
+}
 
-	// Write new password to file with restrictive permissions
-	if err := os.WriteFile(passwordFile, []byte(newPassword), 0600); err != nil {
-		return err
+// postServerFtpUser creates an FTP account for a server with a generated
+// password, which is only ever returned in this response. The username may be
+// given without the suffix of the server.
+// POST /api/servers/:server/ftp/users
+// Request body: {username, root, read_only, expires_at, scopes}
+func postServerFtpUser(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	var req ftpCreateUserRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body. Required fields: username",
+		})
+		return
 	}
 
+	opts := ftp.AccountOptions{
+		Root:      req.Root,
+		ReadOnly:  req.ReadOnly,
+		ExpiresAt: req.ExpiresAt,
+		Scopes:    req.Scopes,
+	}
+	username := req.Username
+	if req.Mapped {
+		opts.ServerID = s.ID()
+	} else {
+		var ok bool
+		if username, ok = ftpAccountName(c, s.ID(), req.Username); !ok {
+			return
+		}
+	}
+	password, err := ftp.CreateAccount(username, opts)
+	switch {
+	case errors.Is(err, ftp.ErrInvalidScope):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Unknown permission scope. Valid scopes are: " + strings.Join(ftp.Scopes, ", "),
+		})
+		return
+	case errors.Is(err, ftp.ErrInvalidUsername):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The username may only contain letters, numbers, dots, dashes and underscores.",
+		})
+		return
+	case errors.Is(err, ftp.ErrAccountExists):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "An FTP account with that username already exists.",
+		})
+		return
+	case err != nil:
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
 	log.WithFields(log.Fields{
 		"subsystem": "ftp",
+		"server_id": s.ID(),
 		"username":  username,
-		"file":      passwordFile,
-	}).Debug("FTP password file updated")
+	}).Info("FTP account created")
+	c.JSON(http.StatusCreated, gin.H{
+		"username": username,
+		"password": password,
+	})
+}
 
-	return nil
+type ftpRotatePasswordsRequest struct {
+	Usernames []string `json:"usernames"`
+}
+
+// postServerFtpRotatePasswords replaces the passwords of the FTP accounts of a
+// server with generated ones and disconnects the sessions logged in with them.
+// Every account of the server is rotated unless usernames are given, which may
+// be given without the suffix of the server. The new passwords are only ever
+// returned in this response.
+// POST /api/servers/:server/ftp/rotate-passwords
+// Request body: {usernames}
+func postServerFtpRotatePasswords(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	var req ftpRotatePasswordsRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request body.",
+			})
+			return
+		}
+	}
+
+	var usernames []string
+	if len(req.Usernames) == 0 {
+		accounts, err := ftp.ServerAccounts(s.ID())
+		if err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+		for _, a := range accounts {
+			usernames = append(usernames, a.Username)
+		}
+	} else {
+		for _, name := range req.Usernames {
+			username, ok := ftpAccountName(c, s.ID(), name)
+			if !ok {
+				return
+			}
+			usernames = append(usernames, username)
+		}
+	}
+
+	passwords := make(map[string]string, len(usernames))
+	for _, username := range usernames {
+		password, err := ftp.RotatePassword(username)
+		if errors.Is(err, ftp.ErrAccountNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error":     "The requested FTP account does not exist.",
+				"username":  username,
+				"passwords": passwords,
+			})
+			return
+		}
+		if err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+		passwords[username] = password
+		middleware.ExtractFtpServer(c).DisconnectUser(s.ID(), username)
+	}
+	log.WithFields(log.Fields{
+		"subsystem": "ftp",
+		"server_id": s.ID(),
+		"accounts":  len(passwords),
+	}).Info("FTP passwords rotated")
+	c.JSON(http.StatusOK, gin.H{"passwords": passwords})
+}
+
+// deleteServerFtpUser removes an FTP account of a server and disconnects the
+// sessions logged in with it. The username may be given without the suffix of
+// the server.
+// DELETE /api/servers/:server/ftp/users/:username
+func deleteServerFtpUser(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	username, ok := ftpAccountName(c, s.ID(), c.Param("username"))
+	if !ok {
+		return
+	}
+	err := ftp.DeleteAccount(username)
+	if errors.Is(err, ftp.ErrAccountNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested FTP account does not exist.",
+		})
+		return
+	}
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	n := middleware.ExtractFtpServer(c).DisconnectUser(s.ID(), username)
+	log.WithFields(log.Fields{
+		"subsystem": "ftp",
+		"server_id": s.ID(),
+		"username":  username,
+		"sessions":  n,
+	}).Info("FTP account deleted")
+	c.Status(http.StatusNoContent)
+}
+
+// ftpAccountScopes responds with the permission scopes of an account, or
+// aborts the request if they could not be read or changed.
+func ftpAccountScopes(c *gin.Context, username string, scopes []string, err error) {
+	switch {
+	case errors.Is(err, ftp.ErrAccountNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested FTP account does not exist.",
+		})
+	case errors.Is(err, ftp.ErrInvalidScope):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Unknown permission scope. Valid scopes are: " + strings.Join(ftp.Scopes, ", "),
+		})
+	case err != nil:
+		middleware.CaptureAndAbort(c, err)
+	default:
+		if scopes == nil {
+			scopes = ftp.Scopes
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"username":   username,
+			"restricted": len(scopes) != len(ftp.Scopes),
+			"scopes":     scopes,
+		})
+	}
+}
+
+// getServerFtpUserScopes returns the permission scopes of an FTP account of a
+// server.
+// GET /api/servers/:server/ftp/users/:username/scopes
+func getServerFtpUserScopes(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	username, ok := ftpAccountName(c, s.ID(), c.Param("username"))
+	if !ok {
+		return
+	}
+	scopes, err := ftp.AccountScopes(username)
+	ftpAccountScopes(c, username, scopes, err)
+}
+
+type ftpUserScopesRequest struct {
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// putServerFtpUserScopes replaces the permission scopes of an FTP account of
+// a server. Sessions already logged in with the account keep the scopes they
+// logged in with.
+// PUT /api/servers/:server/ftp/users/:username/scopes
+// Request body: {scopes}
+func putServerFtpUserScopes(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	var req ftpUserScopesRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body. Required fields: scopes",
+		})
+		return
+	}
+	username, ok := ftpAccountName(c, s.ID(), c.Param("username"))
+	if !ok {
+		return
+	}
+	scopes, err := ftp.SetAccountScopes(username, req.Scopes)
+	ftpAccountScopes(c, username, scopes, err)
+}
+
+// deleteServerFtpUserScopes removes the permission scopes of an FTP account
+// of a server, allowing it to do everything again.
+// DELETE /api/servers/:server/ftp/users/:username/scopes
+func deleteServerFtpUserScopes(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	username, ok := ftpAccountName(c, s.ID(), c.Param("username"))
+	if !ok {
+		return
+	}
+	scopes, err := ftp.SetAccountScopes(username, nil)
+	ftpAccountScopes(c, username, scopes, err)
+}
+
+type ftpUserBandwidthRequest struct {
+	Upload   int `json:"upload"`
+	Download int `json:"download"`
+}
+
+// putServerFtpUserBandwidth sets the rate in KiB/s data can be uploaded and
+// downloaded at by the sessions of an FTP account of a server combined, where
+// 0 does not limit it.
+// PUT /api/servers/:server/ftp/users/:username/bandwidth
+// Request body: {upload, download}
+func putServerFtpUserBandwidth(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	var req ftpUserBandwidthRequest
+	if err := c.BindJSON(&req); err != nil || req.Upload < 0 || req.Download < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body. The upload and download limits must be 0 or more.",
+		})
+		return
+	}
+	username, ok := ftpAccountName(c, s.ID(), c.Param("username"))
+	if !ok {
+		return
+	}
+	err := ftp.SetAccountBandwidth(username, req.Upload, req.Download)
+	if errors.Is(err, ftp.ErrAccountNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested FTP account does not exist.",
+		})
+		return
+	}
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"username": username,
+		"upload":   req.Upload,
+		"download": req.Download,
+	})
+}
+
+// getServerFtpExport returns the FTP accounts of a server with a bcrypt hash
+// of their password, to be imported on another node.
+// GET /api/servers/:server/ftp/export
+func getServerFtpExport(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	accounts, err := ftp.ExportAccounts(s.ID())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+type ftpImportRequest struct {
+	Accounts  []ftp.ExportedAccount `json:"accounts" binding:"required"`
+	Overwrite bool                  `json:"overwrite"`
+}
+
+// postServerFtpImport adds FTP accounts exported from another node to a
+// server. Accounts that already exist are skipped unless overwrite is set.
+// POST /api/servers/:server/ftp/import
+// Request body: {accounts, overwrite}
+func postServerFtpImport(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+
+	var req ftpImportRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body. Required fields: accounts",
+		})
+		return
+	}
+	res, err := ftp.ImportAccounts(s.ID(), req.Accounts, req.Overwrite)
+	switch {
+	case errors.Is(err, ftp.ErrInvalidUsername):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "An FTP account does not belong to this server: " + err.Error(),
+		})
+		return
+	case errors.Is(err, ftp.ErrInvalidPasswordHash):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "An FTP account does not have a valid password hash: " + err.Error(),
+		})
+		return
+	case errors.Is(err, ftp.ErrInvalidScope):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Unknown permission scope. Valid scopes are: " + strings.Join(ftp.Scopes, ", "),
+		})
+		return
+	case err != nil:
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"subsystem": "ftp",
+		"server_id": s.ID(),
+		"imported":  len(res.Imported),
+		"skipped":   len(res.Skipped),
+	}).Info("FTP accounts imported")
+	c.JSON(http.StatusOK, res)
+}
+
+// getServerFtpChecksum verifies a file against the checksum recorded when it
+// was uploaded over FTP.
+// GET /api/servers/:server/ftp/checksum?file=
+func getServerFtpChecksum(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+	f, _, err := s.Filesystem().File(strings.TrimLeft(c.Query("file"), "/"))
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	defer f.Close()
+
+	res, err := ftp.VerifyChecksum(f)
+	if errors.Is(err, ftp.ErrNoChecksum) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "No checksum has been recorded for this file.",
+		})
+		return
+	}
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// postFtpReload reloads the FTP configuration from the disk. New connections
+// use the reloaded configuration, while existing sessions keep theirs.
+// POST /api/system/ftp/reload
+func postFtpReload(c *gin.Context) {
+	if err := middleware.ExtractFtpServer(c).Reload(); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// postFtpRestart opens the FTP listeners again without reloading the
+// configuration, such as to recover a listener or pick up renewed
+// certificates, without restarting Wings.
+// POST /api/system/ftp/restart
+func postFtpRestart(c *gin.Context) {
+	err := middleware.ExtractFtpServer(c).Restart()
+	if errors.Is(err, ftp.ErrNotRunning) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "The FTP server is not running.",
+		})
+		return
+	}
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getSystemFtp returns the state of the FTP listeners, the number of active
+// sessions, and the errors recorded in the last hour.
+// GET /api/system/ftp
+func getSystemFtp(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.ExtractFtpServer(c).Health())
+}
+
+// getSystemFtpBans returns the addresses whose FTP connections are currently
+// penalized, either for failing to log in too many times or by hand.
+// GET /api/system/ftp/bans
+func getSystemFtpBans(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.ExtractFtpServer(c).Bans())
+}
+
+type ftpBanRequest struct {
+	IP string `json:"ip" binding:"required"`
+	// The length of the ban in seconds, or 0 for a ban that does not end.
+	Duration int    `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+// postSystemFtpBan bans an address from FTP, replacing any ban it already
+// has, and disconnects it.
+// POST /api/system/ftp/bans
+// Request body: {ip, duration, reason}
+func postSystemFtpBan(c *gin.Context) {
+	var req ftpBanRequest
+	if err := c.BindJSON(&req); err != nil || req.Duration < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body. Required fields: ip",
+		})
+		return
+	}
+	err := middleware.ExtractFtpServer(c).BanIP(req.IP, time.Duration(req.Duration)*time.Second, req.Reason)
+	if errors.Is(err, ftp.ErrInvalidIP) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The given address is not a valid IP address.",
+		})
+		return
+	}
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// deleteSystemFtpBan lifts the ban of an address.
+// DELETE /api/system/ftp/bans/:ip
+func deleteSystemFtpBan(c *gin.Context) {
+	err := middleware.ExtractFtpServer(c).UnbanIP(c.Param("ip"))
+	if errors.Is(err, ftp.ErrBanNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The given address is not banned.",
+		})
+		return
+	}
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getSystemFtpProfile captures a CPU profile of Wings for the given number of
+// seconds, 30 by default, and returns it in the pprof format. The goroutines
+// of FTP sessions are labeled, so the profile can be narrowed down to FTP with
+// "go tool pprof -tagfocus subsystem=ftp".
+// GET /api/system/ftp/debug/profile?seconds=
+func getSystemFtpProfile(c *gin.Context) {
+	seconds := 30
+	if v := c.Query("seconds"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The number of seconds must be a positive integer.",
+			})
+			return
+		}
+		seconds = n
+	}
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="ftp-cpu.pprof"`)
+	err := middleware.ExtractFtpServer(c).CPUProfile(c.Request.Context(), c.Writer, time.Duration(seconds)*time.Second)
+	if err != nil {
+		c.Writer.Header().Del("Content-Disposition")
+	}
+	if errors.Is(err, ftp.ErrProfilingDisabled) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Profiling is not enabled for FTP.",
+		})
+		return
+	}
+	if errors.Is(err, ftp.ErrProfileInProgress) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "A CPU profile is already being captured.",
+		})
+		return
+	}
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+	}
+}
+
+// getSystemFtpHeap returns a profile of the memory allocated by Wings in the
+// pprof format.
+// GET /api/system/ftp/debug/heap
+func getSystemFtpHeap(c *gin.Context) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="ftp-heap.pprof"`)
+	err := middleware.ExtractFtpServer(c).HeapProfile(c.Writer)
+	if errors.Is(err, ftp.ErrProfilingDisabled) {
+		c.Writer.Header().Del("Content-Disposition")
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Profiling is not enabled for FTP.",
+		})
+		return
+	}
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+	}
 }
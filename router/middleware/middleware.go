@@ -12,6 +12,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/ftp"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/server"
 )
@@ -43,6 +44,15 @@ func AttachServerManager(m *server.Manager) gin.HandlerFunc {
 	}
 }
 
+// AttachFtpServer attaches the FTP server instance to the request context so
+// that routes are able to inspect and manage FTP sessions.
+func AttachFtpServer(s *ftp.FTPServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("ftp_server", s)
+		c.Next()
+	}
+}
+
 // AttachApiClient attaches the application API client which allows routes to
 // access server resources from the Panel easily.
 func AttachApiClient(client remote.Client) gin.HandlerFunc {
@@ -145,9 +155,7 @@ func ServerExists() gin.HandlerFunc {
 		var s *server.Server
 		if c.Param("server") != "" {
 			manager := ExtractManager(c)
-			s = manager.Find(func(s *server.Server) bool {
-				return c.Param("server") == s.ID()
-			})
+			s, _ = manager.Get(c.Param("server"))
 		}
 		if s == nil {
 			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "The requested resource does not exist on this instance."})
@@ -186,6 +194,16 @@ func RequireAuthorization() gin.HandlerFunc {
 	}
 }
 
+// IsAuthorized reports whether the request carries the authentication token
+// of the node, for routes that are also open to requests without it.
+func IsAuthorized(c *gin.Context) bool {
+	auth := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(auth) != 2 || auth[0] != "Bearer" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[1]), []byte(config.Get().Token.Token)) == 1
+}
+
 // RemoteDownloadEnabled checks if remote downloads are enabled for this instance
 // and if not aborts the request.
 func RemoteDownloadEnabled() gin.HandlerFunc {
@@ -228,6 +246,14 @@ func ExtractApiClient(c *gin.Context) remote.Client {
 	panic("middleware/middlware: cannot extract api clinet: not present in context")
 }
 
+// ExtractFtpServer returns the FTP server instance set on the request context.
+func ExtractFtpServer(c *gin.Context) *ftp.FTPServer {
+	if v, ok := c.Get("ftp_server"); ok {
+		return v.(*ftp.FTPServer)
+	}
+	panic("middleware/middleware: cannot extract ftp server: not present in context")
+}
+
 // ExtractManager returns the server manager instance set on the request context.
 func ExtractManager(c *gin.Context) *server.Manager {
 	if v, ok := c.Get("manager"); ok {
@@ -6,13 +6,14 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/ftp"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/router/middleware"
 	wserver "github.com/pterodactyl/wings/server"
 )
 
 // Configure configures the routing infrastructure for this daemon instance.
-func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
+func Configure(m *wserver.Manager, client remote.Client, ftpServer *ftp.FTPServer) *gin.Engine {
 	gin.SetMode("release")
 
 	router := gin.New()
@@ -22,7 +23,7 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 		return nil
 	}
 	router.Use(middleware.AttachRequestID(), middleware.CaptureErrors(), middleware.SetAccessControlHeaders())
-	router.Use(middleware.AttachServerManager(m), middleware.AttachApiClient(client))
+	router.Use(middleware.AttachServerManager(m), middleware.AttachApiClient(client), middleware.AttachFtpServer(ftpServer))
 	// @todo log this into a different file so you can setup IP blocking for abusive requests and such.
 	// This should still dump requests in debug mode since it does help with understanding the request
 	// lifecycle and quickly seeing what was called leading to the logs. However, it isn't feasible to mix
@@ -55,7 +56,8 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	router.POST("/api/transfers", postTransfers)
 
 	// FTP password change does not require Authorization header because the current password
-	// serves as authentication - if it's incorrect, the operation fails.
+	// serves as authentication - if it's incorrect, the operation fails. Requests that do carry
+	// the node token may change the password without it.
 	ftpPublic := router.Group("/api/servers/:server/ftp")
 	ftpPublic.Use(middleware.ServerExists())
 	{
@@ -74,6 +76,14 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	protected := router.Use(middleware.RequireAuthorization())
 	protected.POST("/api/update", postUpdateConfiguration)
 	protected.GET("/api/system", getSystemInformation)
+	protected.GET("/api/system/ftp", getSystemFtp)
+	protected.POST("/api/system/ftp/reload", postFtpReload)
+	protected.POST("/api/system/ftp/restart", postFtpRestart)
+	protected.GET("/api/system/ftp/bans", getSystemFtpBans)
+	protected.POST("/api/system/ftp/bans", postSystemFtpBan)
+	protected.DELETE("/api/system/ftp/bans/:ip", deleteSystemFtpBan)
+	protected.GET("/api/system/ftp/debug/profile", getSystemFtpProfile)
+	protected.GET("/api/system/ftp/debug/heap", getSystemFtpHeap)
 	protected.GET("/api/servers", getAllServers)
 	protected.POST("/api/servers", postCreateServer)
 	protected.DELETE("/api/transfers/:server", deleteTransfer)
@@ -123,6 +133,26 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 			backup.POST("/:backup/restore", postServerRestoreBackup)
 			backup.DELETE("/:backup", deleteServerBackup)
 		}
+
+		ftp := server.Group("/ftp")
+		{
+			ftp.PATCH("", patchServerFtp)
+			ftp.GET("/info", getServerFtpInfo)
+			ftp.GET("/stats", getServerFtpStats)
+			ftp.GET("/sessions", getServerFtpSessions)
+			ftp.DELETE("/sessions/:id", deleteServerFtpSession)
+			ftp.GET("/checksum", getServerFtpChecksum)
+			ftp.GET("/users", getServerFtpUsers)
+			ftp.POST("/users", postServerFtpUser)
+			ftp.POST("/rotate-passwords", postServerFtpRotatePasswords)
+			ftp.GET("/export", getServerFtpExport)
+			ftp.POST("/import", postServerFtpImport)
+			ftp.DELETE("/users/:username", deleteServerFtpUser)
+			ftp.GET("/users/:username/scopes", getServerFtpUserScopes)
+			ftp.PUT("/users/:username/scopes", putServerFtpUserScopes)
+			ftp.DELETE("/users/:username/scopes", deleteServerFtpUserScopes)
+			ftp.PUT("/users/:username/bandwidth", putServerFtpUserBandwidth)
+		}
 	}
 
 	return router
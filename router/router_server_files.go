@@ -247,6 +247,16 @@ func postServerWriteFile(c *gin.Context) {
 		return
 	}
 
+	// Don't interleave writes with an upload to the same file over FTP.
+	unlock, ok := middleware.ExtractFtpServer(c).LockWrite(s.ID(), f)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "Cannot write file, it is currently being uploaded over FTP.",
+		})
+		return
+	}
+	defer unlock()
+
 	if err := s.Filesystem().Write(f, c.Request.Body, c.Request.ContentLength, 0o644); err != nil {
 		if filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory) {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
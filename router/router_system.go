@@ -81,6 +81,7 @@ func postCreateServer(c *gin.Context) {
 	// Plop that server instance onto the request so that it can be referenced in
 	// requests from here-on out.
 	manager.Add(install.Server())
+	go middleware.ExtractFtpServer(c).SyncDedicatedPorts()
 
 	// Begin the installation process in the background to not block the request
 	// cycle. If there are any errors they will be logged and communicated back
@@ -20,6 +20,11 @@ const (
 	ActivitySftpRename          = models.Event("server:sftp.rename")
 	ActivitySftpDelete          = models.Event("server:sftp.delete")
 	ActivityFileUploaded        = models.Event("server:file.uploaded")
+	ActivityFtpWrite            = models.Event("server:ftp.write")
+	ActivityFtpCreateDirectory  = models.Event("server:ftp.create-directory")
+	ActivityFtpRename           = models.Event("server:ftp.rename")
+	ActivityFtpDelete           = models.Event("server:ftp.delete")
+	ActivityFtpMalwareDetected  = models.Event("server:ftp.malware-detected")
 )
 
 // RequestActivity is a wrapper around a LoggedEvent that is able to track additional request
@@ -89,6 +89,12 @@ func (s *Server) Backup(b backup.BackupInterface) error {
 		return errors.WrapIf(err, "backup: error while generating server backup")
 	}
 
+	if lb, ok := b.(*backup.LocalBackup); ok {
+		if err := lb.SetServer(s.ID()); err != nil {
+			s.Log().WithField("backup", b.Identifier()).WithField("error", err).Warn("failed to write manifest of local backup, it will not be listed over FTP")
+		}
+	}
+
 	// Try to notify the panel about the status of this backup. If for some reason this request
 	// fails, delete the archive from the daemon and return that error up the chain to the caller.
 	if notifyError := s.notifyPanelOfBackup(b.Identifier(), ad, true); notifyError != nil {
@@ -2,10 +2,14 @@ package backup
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"os"
+	"strings"
+	"sync"
 
 	"emperror.dev/errors"
+	"github.com/apex/log"
 	"github.com/juju/ratelimit"
 	"github.com/mholt/archives"
 
@@ -47,8 +51,51 @@ func LocateLocal(client remote.Client, uuid string) (*LocalBackup, os.FileInfo,
 	return b, st, nil
 }
 
+// localManifest is written next to a local backup archive, recording the
+// server the backup belongs to so that the backups of a server can be found
+// without asking the Panel.
+type localManifest struct {
+	Server string `json:"server"`
+}
+
+// manifestPath returns the path of the manifest of the archive at p.
+func manifestPath(p string) string {
+	return strings.TrimSuffix(p, ".tar.gz") + ".json"
+}
+
+// unattributed contains the archives that have already been logged as not
+// belonging to any known server.
+var unattributed sync.Map
+
+// SetServer records the server that the backup belongs to in a manifest next
+// to the archive.
+func (b *LocalBackup) SetServer(uuid string) error {
+	data, err := json.Marshal(localManifest{Server: uuid})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(manifestPath(b.Path()), data, 0o600))
+}
+
+// LocalServer returns the UUID of the server that the local backup archive at
+// the given path belongs to, or an empty string if it is not known. Archives
+// without a manifest are logged once.
+func LocalServer(p string) string {
+	var m localManifest
+	if data, err := os.ReadFile(manifestPath(p)); err == nil && json.Unmarshal(data, &m) == nil && m.Server != "" {
+		return m.Server
+	}
+	if _, logged := unattributed.LoadOrStore(p, true); !logged {
+		log.WithField("path", p).Warn("backup: could not determine which server a local backup belongs to")
+	}
+	return ""
+}
+
 // Remove removes a backup from the system.
 func (b *LocalBackup) Remove() error {
+	if err := os.Remove(manifestPath(b.Path())); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
 	return os.Remove(b.Path())
 }
 
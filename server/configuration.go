@@ -35,6 +35,15 @@ type Configuration struct {
 	// be started or modified except in certain scenarios by an admin user.
 	Suspended bool `json:"suspended"`
 
+	// Whether FTP access has been disabled for the server by the Panel, such
+	// as when it is not included in the plan the server is on.
+	FtpDisabled bool `json:"ftp_disabled"`
+
+	// The rate in KiB/s the FTP sessions of the server are limited to between
+	// them, as set by the Panel for the plan the server is on. If this is 0
+	// the limits in the node's configuration apply.
+	FtpBandwidth int `json:"ftp_bandwidth"`
+
 	// The command that should be used when booting up the server instance.
 	Invocation string `json:"invocation"`
 
@@ -67,6 +76,21 @@ func (s *Server) Config() *Configuration {
 	return &s.cfg
 }
 
+// FtpDisabled reports whether FTP logins are disabled for the server.
+func (s *Server) FtpDisabled() bool {
+	s.cfg.mu.RLock()
+	defer s.cfg.mu.RUnlock()
+	return s.cfg.FtpDisabled
+}
+
+// FtpBandwidth returns the rate in KiB/s the Panel limits the FTP sessions of
+// the server to, or 0 if it does not.
+func (s *Server) FtpBandwidth() int {
+	s.cfg.mu.RLock()
+	defer s.cfg.mu.RUnlock()
+	return s.cfg.FtpBandwidth
+}
+
 // DiskSpace returns the amount of disk space available to a server in bytes.
 func (s *Server) DiskSpace() int64 {
 	s.cfg.mu.RLock()
@@ -29,12 +29,19 @@ import (
 // and the compressed file will be placed at that location named
 // `archive-{date}.tar.gz`.
 func (fs *Filesystem) CompressFiles(dir string, paths []string) (ufs.FileInfo, error) {
-	a := &Archive{Filesystem: fs, BaseDirectory: dir, Files: paths}
 	d := path.Join(
 		dir,
 		fmt.Sprintf("archive-%s.tar.gz", strings.ReplaceAll(time.Now().Format(time.RFC3339), ":", "")),
 	)
-	f, err := fs.unixFS.OpenFile(d, ufs.O_WRONLY|ufs.O_CREATE, 0o644)
+	return fs.CompressFilesTo(dir, paths, d)
+}
+
+// CompressFilesTo works the same as CompressFiles, except that the archive is
+// written to the provided destination path rather than an automatically named
+// file. Any existing file at the destination is truncated.
+func (fs *Filesystem) CompressFilesTo(dir string, paths []string, dst string) (ufs.FileInfo, error) {
+	a := &Archive{Filesystem: fs, BaseDirectory: dir, Files: paths}
+	f, err := fs.unixFS.OpenFile(dst, ufs.O_WRONLY|ufs.O_CREATE|ufs.O_TRUNC, 0o644)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +51,7 @@ func (fs *Filesystem) CompressFiles(dir string, paths []string) (ufs.FileInfo, e
 		return nil, err
 	}
 	if !fs.unixFS.CanFit(cw.BytesWritten()) {
-		_ = fs.unixFS.Remove(d)
+		_ = fs.unixFS.Remove(dst)
 		return nil, newFilesystemError(ErrCodeDiskSpace, nil)
 	}
 	fs.unixFS.Add(cw.BytesWritten())
@@ -163,6 +170,47 @@ func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file strin
 	})
 }
 
+// ArchiveFiles calls fn for each file within the archive at the given path,
+// with the path it would be extracted to relative to dir and a reader of its
+// contents, without extracting anything. The archive is read through once.
+func (fs *Filesystem) ArchiveFiles(ctx context.Context, dir string, file string, fn func(name string, r io.Reader) error) error {
+	f, err := fs.unixFS.Open(filepath.Join(dir, file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	format, input, err := archives.Identify(ctx, filepath.Base(file), f)
+	if err != nil {
+		if errors.Is(err, archives.NoMatch) {
+			return newFilesystemError(ErrCodeUnknownArchive, err)
+		}
+		return err
+	}
+	if ex, ok := format.(archives.Extractor); ok {
+		return ex.Extract(ctx, input, func(ctx context.Context, f archives.FileInfo) error {
+			if f.IsDir() {
+				return nil
+			}
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			return fn(f.NameInArchive, r)
+		})
+	}
+	if de, ok := format.(archives.Decompressor); ok {
+		r, err := de.OpenReader(input)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return fn(strings.TrimSuffix(file, format.Extension()), r)
+	}
+	return nil
+}
+
 // ExtractStreamUnsafe .
 func (fs *Filesystem) ExtractStreamUnsafe(ctx context.Context, dir string, r io.Reader) error {
 	format, input, err := archives.Identify(ctx, "archive.tar.gz", r)
@@ -212,3 +212,10 @@ func (fs *Filesystem) HasSpaceFor(size int64) error {
 func (fs *Filesystem) addDisk(i int64) int64 {
 	return fs.unixFS.Add(i)
 }
+
+// AddDiskUsage updates the cached disk usage for changes made to the server's
+// files outside of the Filesystem instance, such as uploads over FTP. The
+// value may be negative.
+func (fs *Filesystem) AddDiskUsage(i int64) int64 {
+	return fs.addDisk(i)
+}
@@ -26,6 +26,10 @@ type Manager struct {
 	mu      sync.RWMutex
 	client  remote.Client
 	servers []*Server
+	// The servers keyed by their UUID, and by the first and last eight
+	// characters of it, rebuilt whenever the servers change.
+	byID    map[string]*Server
+	byShort map[string]*Server
 }
 
 // NewManager returns a new server manager instance. This will boot up all the
@@ -75,6 +79,7 @@ func (m *Manager) Keys() []string {
 func (m *Manager) Put(s []*Server) {
 	m.mu.Lock()
 	m.servers = s
+	m.reindex()
 	m.mu.Unlock()
 }
 
@@ -89,16 +94,51 @@ func (m *Manager) All() []*Server {
 func (m *Manager) Add(s *Server) {
 	m.mu.Lock()
 	m.servers = append(m.servers, s)
+	m.reindex()
 	m.mu.Unlock()
 }
 
 // Get returns a single server instance and a boolean value indicating if it was
 // found in the global collection or not.
 func (m *Manager) Get(uuid string) (*Server, bool) {
-	match := m.Find(func(server *Server) bool {
-		return server.ID() == uuid
-	})
-	return match, match != nil
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	match, ok := m.byID[uuid]
+	return match, ok
+}
+
+// Lookup returns the server with the given UUID, or whose UUID starts or ends
+// with the given eight characters, as used in SFTP and FTP usernames. If more
+// than one server matches the short form the first one in the collection is
+// returned. A nil result is returned if nothing is found.
+func (m *Manager) Lookup(key string) *Server {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if s, ok := m.byID[key]; ok {
+		return s
+	}
+	return m.byShort[key]
+}
+
+// reindex rebuilds the lookup maps from the servers in the collection. It
+// must be called with the lock held.
+func (m *Manager) reindex() {
+	m.byID = make(map[string]*Server, len(m.servers))
+	m.byShort = make(map[string]*Server, len(m.servers)*2)
+	for _, s := range m.servers {
+		id := s.ID()
+		if _, ok := m.byID[id]; !ok {
+			m.byID[id] = s
+		}
+		if len(id) < 8 {
+			continue
+		}
+		for _, k := range []string{id[:8], id[len(id)-8:]} {
+			if _, ok := m.byShort[k]; !ok {
+				m.byShort[k] = s
+			}
+		}
+	}
 }
 
 // Filter returns only those items matching the filter criteria.
@@ -138,6 +178,7 @@ func (m *Manager) Remove(filter func(match *Server) bool) {
 		}
 	}
 	m.servers = r
+	m.reindex()
 }
 
 // PersistStates writes the current environment states to the disk for each
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestManager(t *testing.T) {
+	g := Goblin(t)
+
+	newServer := func(uuid string) *Server {
+		s := &Server{}
+		s.cfg.Uuid = uuid
+		return s
+	}
+
+	g.Describe("Manager#Lookup", func() {
+		a := newServer("8f2c1d3e-0000-4000-8000-00001111aaaa")
+		b := newServer("1111aaaa-0000-4000-8000-000022223333")
+
+		g.It("should find servers by their UUID and its short forms", func() {
+			m := NewEmptyManager(nil)
+			m.Put([]*Server{a, b})
+
+			g.Assert(m.Lookup(a.ID()) == a).IsTrue()
+			g.Assert(m.Lookup("8f2c1d3e") == a).IsTrue()
+			g.Assert(m.Lookup("22223333") == b).IsTrue()
+			g.Assert(m.Lookup("00000000") == nil).IsTrue()
+		})
+
+		g.It("should prefer the first server when short forms collide", func() {
+			m := NewEmptyManager(nil)
+			m.Put([]*Server{a, b})
+
+			g.Assert(m.Lookup("1111aaaa") == a).IsTrue()
+		})
+
+		g.It("should follow servers being added and removed", func() {
+			m := NewEmptyManager(nil)
+			m.Add(a)
+			m.Add(b)
+			s, ok := m.Get(b.ID())
+			g.Assert(ok).IsTrue()
+			g.Assert(s == b).IsTrue()
+
+			m.Remove(func(s *Server) bool { return s == a })
+			g.Assert(m.Lookup("8f2c1d3e") == nil).IsTrue()
+			g.Assert(m.Lookup("1111aaaa") == b).IsTrue()
+		})
+	})
+}